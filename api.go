@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,6 +19,11 @@ type RedditAPI struct {
 	client      *http.Client
 	userAgent   string
 	rateLimiter *RateLimiter
+	limiter     *FairLimiter // Budget shared with all other API clients in the process
+	consumer    string       // Identifies this client in the shared budget
+	backoff     *OpenGraphDB // Persists backoff state across runs, nil to disable
+	ledger      *RateLedger  // Budget shared with other processes, nil to disable
+	clientID    string       // Identifies this client in the ledger
 }
 
 // RateLimiter implements simple rate limiting for API calls
@@ -51,15 +58,89 @@ func NewRedditAPI(client *http.Client) *RedditAPI {
 		client:      client,
 		userAgent:   "GoRedditFeedGenerator/1.0 by YourRedditUsername",
 		rateLimiter: NewRateLimiter(1 * time.Second), // 1 second minimum between calls
+		limiter:     DefaultRedditLimiter,
+		consumer:    DefaultTenant,
 	}
 }
 
+// SetIdentity makes the client present itself with its own User-Agent and draw from
+// the rate limit budget of its Reddit client ID. Empty values keep the defaults.
+func (api *RedditAPI) SetIdentity(clientID, userAgent string) {
+	if userAgent != "" {
+		api.userAgent = userAgent
+	}
+	api.limiter = RedditLimiterFor(clientID)
+	api.clientID = clientID
+}
+
+// SetRateLedger makes the client share its request budget with other processes
+func (api *RedditAPI) SetRateLedger(ledger *RateLedger) {
+	api.ledger = ledger
+}
+
+// SetConsumer sets the name this client uses in the shared rate limit budget
+func (api *RedditAPI) SetConsumer(name string) {
+	api.consumer = name
+}
+
 // FetchRedditHomepage fetches posts from the authenticated user's homepage with retry logic
 func (api *RedditAPI) FetchRedditHomepage() ([]RedditPost, error) {
+	return api.FetchRedditHomepageContext(context.Background())
+}
+
+// FetchRedditHomepageContext fetches homepage posts, tracing requests as children of ctx
+func (api *RedditAPI) FetchRedditHomepageContext(ctx context.Context) ([]RedditPost, error) {
+	// Reddit API endpoint for user's front page. Limit to 100 posts for a good sample.
+	// For a logged-in user, this is usually accessed via /hot or /best without a subreddit prefix.
+	// Let's use /best as it's often the default sorted homepage.
+	posts, err := api.FetchListing(ctx, "/best", url.Values{"limit": {"100"}}) // User's personalized "best" feed
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Reddit homepage: %w", err)
+	}
+
+	slog.Info("Successfully fetched Reddit homepage posts", "count", len(posts))
+	return posts, nil
+}
+
+// FetchRedditHomepageBefore fetches the homepage posts submitted after the post with the
+// given fullname, from the homepage sorted by new. Only /new is ordered by time: on
+// ranked listings such as /best, before pages towards the top of the ranking, which
+// isn't the same as newer.
+func (api *RedditAPI) FetchRedditHomepageBefore(ctx context.Context, before string) ([]RedditPost, error) {
+	posts, err := api.FetchListing(ctx, "/new", url.Values{"limit": {"100"}, "before": {before}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch new homepage posts: %w", err)
+	}
+
+	slog.Info("Successfully fetched new homepage posts", "count", len(posts))
+	return posts, nil
+}
+
+// FetchListing fetches the posts of a listing endpoint such as "/best" or "/top",
+// retrying transient failures
+func (api *RedditAPI) FetchListing(ctx context.Context, path string, params url.Values) ([]RedditPost, error) {
+	listing, err := api.FetchListingPage(ctx, path, params)
+	if err != nil {
+		return nil, err
+	}
+	return listing.Data.Children, nil
+}
+
+// FetchListingPage fetches a page of a listing with its cursors, retrying transient failures
+func (api *RedditAPI) FetchListingPage(ctx context.Context, path string, params url.Values) (*RedditListing, error) {
 	const maxRetries = 3
-	var posts []RedditPost
+	var listing *RedditListing
 	var err error
 
+	if err := api.checkBackoff(path); err != nil {
+		return nil, err
+	}
+
+	apiURL := "https://oauth.reddit.com" + path
+	if len(params) > 0 {
+		apiURL += "?" + params.Encode()
+	}
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			backoff := time.Duration(attempt) * 2 * time.Second
@@ -67,111 +148,185 @@ func (api *RedditAPI) FetchRedditHomepage() ([]RedditPost, error) {
 			time.Sleep(backoff)
 		}
 
-		posts, err = api.fetchHomepageWithRateLimit()
+		listing, err = api.fetchListingWithRateLimit(ctx, apiURL)
 		if err == nil {
 			break
 		}
 
 		// If it's a rate limit error, wait longer
 		if isRateLimitError(err) {
-			slog.Warn("Rate limited by Reddit API", "attempt", attempt+1)
-			time.Sleep(time.Duration(attempt+1) * 5 * time.Second)
+			wait := time.Duration(attempt+1) * 5 * time.Second
+			var apiErr *RedditAPIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+			slog.Warn("Rate limited by Reddit API", "attempt", attempt+1, "wait", wait)
+			time.Sleep(wait)
 			continue
 		}
 
+		// Retrying won't help when access is denied or the token is no longer valid
+		var apiErr *RedditAPIError
+		if errors.As(err, &apiErr) && !apiErr.Retryable() {
+			slog.Warn("Reddit API request failed permanently", "error", err,
+				"skip_source", SkipSource(err), "needs_reauth", NeedsReauth(err))
+			break
+		}
+
 		// For other errors, log and continue retrying
 		slog.Warn("Reddit API request failed", "attempt", attempt+1, "error", err)
 	}
 
+	api.recordFetchResult(path, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Reddit homepage after %d attempts: %w", maxRetries, err)
+		return nil, err
 	}
-
-	slog.Info("Successfully fetched Reddit homepage posts", "count", len(posts))
-	return posts, nil
+	return listing, nil
 }
 
-// fetchHomepageWithRateLimit fetches homepage posts with rate limiting
-func (api *RedditAPI) fetchHomepageWithRateLimit() ([]RedditPost, error) {
-	api.rateLimiter.Wait()
-
-	// Reddit API endpoint for user's front page. Limit to 100 posts for a good sample.
-	// For a logged-in user, this is usually accessed via /hot or /best without a subreddit prefix.
-	// Let's use /best as it's often the default sorted homepage.
-	apiURL := "https://oauth.reddit.com/best?limit=100" // User's personalized "best" feed
-
-	req, err := http.NewRequest("GET", apiURL, nil)
+// fetchListingWithRateLimit fetches a single listing page with rate limiting
+func (api *RedditAPI) fetchListingWithRateLimit(ctx context.Context, apiURL string) (*RedditListing, error) {
+	resp, err := api.get(ctx, apiURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("User-Agent", api.userAgent)
-
-	resp, err := api.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make API request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Reddit API returned non-OK status: %s", resp.Status)
+		return nil, parseRedditError(resp)
 	}
 
-	var listing RedditListing
-	err = json.NewDecoder(resp.Body).Decode(&listing)
+	listing, err := decodeListing(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode Reddit API response: %w", err)
 	}
 
-	return listing.Data.Children, nil
+	return listing, nil
 }
 
-// FetchConcurrentHomepage fetches multiple pages of homepage posts concurrently
-func (api *RedditAPI) FetchConcurrentHomepage(pageCount int) ([]RedditPost, error) {
-	if pageCount <= 0 {
-		pageCount = 1
+// get performs a rate-limited, audited and traced GET request against the Reddit API
+func (api *RedditAPI) get(ctx context.Context, apiURL string) (*http.Response, error) {
+	ctx, span := StartSpan(ctx, "reddit GET", SpanKindClient)
+	defer span.End()
+
+	api.rateLimiter.Wait()
+	if err := api.limiter.Wait(ctx, api.consumer); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+	if api.ledger != nil {
+		if err := api.ledger.Wait(ctx, api.clientID, redditRate()); err != nil {
+			span.RecordError(err)
+			return nil, fmt.Errorf("rate limit ledger: %w", err)
+		}
 	}
 
-	type result struct {
-		posts []RedditPost
-		err   error
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("User-Agent", api.userAgent)
+	span.SetAttributes("http.request.method", "GET", "url.full", apiURL, "reddit.consumer", api.consumer)
 
-	results := make(chan result, pageCount)
-	var wg sync.WaitGroup
+	start := time.Now()
+	resp, err := api.client.Do(req)
+	auditAPICall(api.consumer, req.URL.Path, resp, time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("failed to make API request: %w", err)
+	}
+	api.limiter.Observe(resp.Header)
+	if api.ledger != nil {
+		if err := api.ledger.Observe(api.clientID, resp.Header); err != nil {
+			slog.Warn("Failed to share rate limit pause", "error", err)
+		}
+	}
+	span.SetAttributes("http.response.status_code", resp.StatusCode,
+		"reddit.ratelimit.remaining", resp.Header.Get("X-Ratelimit-Remaining"))
+	if resp.StatusCode >= 400 {
+		span.RecordError(fmt.Errorf("HTTP %s", resp.Status))
+	}
 
-	// First page
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		posts, err := api.fetchHomepageWithRateLimit()
-		results <- result{posts: posts, err: err}
-	}()
+	return resp, nil
+}
 
-	// Additional pages would require pagination logic
-	// For now, just fetch the first page
+// FetchConcurrentHomepage fetches up to pageCount pages of homepage posts. Despite
+// the name, the pages are fetched one after another since each needs the cursor of
+// the previous one.
+func (api *RedditAPI) FetchConcurrentHomepage(pageCount int) ([]RedditPost, error) {
+	return api.FetchPages(context.Background(), "/best", max(pageCount, 1), "", nil)
+}
 
-	wg.Wait()
-	close(results)
+// FetchPages fetches up to maxPages pages of a listing, following its after cursor
+// from the given one ("" for the first page). Every page is a separate rate limited
+// request. onPage, if set, is called with the posts of each page and the cursor of
+// the next one, e.g. to checkpoint progress. Posts that moved to a later page while
+// paging are only returned once.
+func (api *RedditAPI) FetchPages(ctx context.Context, path string, maxPages int, after string, onPage func(posts []RedditPost, after string)) ([]RedditPost, error) {
+	var posts []RedditPost
+	for page := 0; page < maxPages; page++ {
+		params := url.Values{"limit": {"100"}}
+		if after != "" {
+			params.Set("after", after)
+			params.Set("count", strconv.Itoa(len(posts)))
+		}
+		listing, err := api.FetchListingPage(ctx, path, params)
+		if err != nil {
+			return nil, err
+		}
 
-	var allPosts []RedditPost
-	for res := range results {
-		if res.err != nil {
-			return nil, res.err
+		after = listing.Data.After
+		if onPage != nil {
+			onPage(listing.Data.Children, after)
+		}
+		posts = append(posts, listing.Data.Children...)
+		slog.Debug("Fetched listing page", "path", path, "page", page+1, "posts", len(listing.Data.Children))
+		if after == "" || len(listing.Data.Children) == 0 {
+			break
 		}
-		allPosts = append(allPosts, res.posts...)
 	}
+	return uniquePosts(posts), nil
+}
 
-	return allPosts, nil
+// uniquePosts drops later copies of posts listed more than once, counting them in
+// the Duplicates of the copy kept
+func uniquePosts(posts []RedditPost) []RedditPost {
+	seen := make(map[string]int, len(posts)) // Index of each post in unique
+	unique := posts[:0:0]
+	for _, post := range posts {
+		if i, ok := seen[post.Data.Name]; ok {
+			unique[i].Data.Duplicates += 1 + post.Data.Duplicates
+			continue
+		}
+		seen[post.Data.Name] = len(unique)
+		unique = append(unique, post)
+	}
+	return unique
 }
 
 // FilterPosts applies score and comment count filters to a list of Reddit posts
 func FilterPosts(posts []RedditPost, minScore, minComments int) []RedditPost {
+	return filterByEngagement(posts, minScore, minComments, nil)
+}
+
+// filterByEngagement applies score and comment count filters, counting rejections by
+// rule in stats
+func filterByEngagement(posts []RedditPost, minScore, minComments int, stats FilterStats) []RedditPost {
 	var filtered []RedditPost
+	lowScore, fewComments := 0, 0
 	for _, post := range posts {
-		if post.Data.Score >= minScore && post.Data.NumComments >= minComments {
+		switch {
+		case post.Data.Score < minScore:
+			lowScore++
+		case post.Data.NumComments < minComments:
+			fewComments++
+		default:
 			filtered = append(filtered, post)
 		}
 	}
+	stats.record(RuleScore, lowScore, minScore > 0)
+	stats.record(RuleComments, fewComments, minComments > 0)
 
 	slog.Info("Filtered posts", "original", len(posts), "filtered", len(filtered), "minScore", minScore, "minComments", minComments)
 	return filtered
@@ -211,14 +366,32 @@ func isRateLimitError(err error) bool {
 	}
 
 	// Check for OAuth2 retrieve error with 429 status
-	if oe, ok := err.(*oauth2.RetrieveError); ok {
+	var oe *oauth2.RetrieveError
+	if errors.As(err, &oe) && oe.Response != nil {
 		return oe.Response.StatusCode == http.StatusTooManyRequests
 	}
 
-	return false
+	return errors.Is(err, ErrRedditRateLimited)
 }
 
-// CreateAuthenticatedClient creates an OAuth2 authenticated HTTP client
+// CreateAuthenticatedClient creates an OAuth2 authenticated HTTP client. Tokens
+// refreshed during the run are saved to the config.
 func CreateAuthenticatedClient(ctx context.Context, token *oauth2.Token) *http.Client {
-	return OAuth2Config.Client(ctx, token)
+	source := NewRefreshableTokenSource(ctx, OAuth2Config, GlobalConfig.AppType, token)
+	source.OnRefresh = func(newToken *oauth2.Token, err error) {
+		auditAuth(AuditTokenRefresh, DefaultTenant, err)
+		if err != nil {
+			return
+		}
+		Token = newToken
+		GlobalConfig.AccessToken = newToken.AccessToken
+		if newToken.RefreshToken != "" {
+			GlobalConfig.RefreshToken = newToken.RefreshToken
+		}
+		GlobalConfig.ExpiresAt = newToken.Expiry
+		if err := SaveConfig(); err != nil {
+			slog.Warn("Failed to save refreshed token", "error", err)
+		}
+	}
+	return NewRetryingClient(ctx, source)
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// archiveDroppedElements are removed from archived pages: scripts and anything else
+// that loads or runs code
+var archiveDroppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Base:     true,
+}
+
+// archiveFileName names the archived copy of a URL
+func archiveFileName(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return hex.EncodeToString(sum[:8]) + ".html"
+}
+
+// archiveLink returns where the archived copy of a page is reachable: under
+// archive_url when set, else as a file URL
+func (p *Pipeline) archiveLink(file string) string {
+	if p.config.ArchiveURL != "" {
+		return strings.TrimSuffix(p.config.ArchiveURL, "/") + "/" + url.PathEscape(file)
+	}
+	path, err := filepath.Abs(filepath.Join(p.config.ArchiveDir, file))
+	if err != nil {
+		path = filepath.Join(p.config.ArchiveDir, file)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// archivable reports whether a post links to an external page worth archiving
+func (p *Pipeline) archivable(post RedditPost) bool {
+	return !post.Data.IsSelf && isValidURL(post.Data.URL) &&
+		!p.config.redditDomains().Matches(post.Data.URL) && !p.config.blockedDomains().Matches(post.Data.URL)
+}
+
+// archivePages saves a sanitized copy of each linked page that isn't archived yet into
+// archive_dir and links the copies from their posts. Offline runs only link existing
+// copies.
+func (p *Pipeline) archivePages(ctx context.Context, posts []RedditPost, ogf *OpenGraphFetcher, offline bool) []RedditPost {
+	if p.config.ArchiveDir == "" {
+		return posts
+	}
+	if err := os.MkdirAll(p.config.ArchiveDir, 0755); err != nil {
+		slog.Warn("Failed to create archive directory", "error", err)
+		return posts
+	}
+
+	var tasks []Task
+	for i := range posts {
+		post := &posts[i]
+		if !p.archivable(*post) {
+			continue
+		}
+		file := archiveFileName(post.Data.URL)
+		path := filepath.Join(p.config.ArchiveDir, file)
+		if _, err := os.Stat(path); err == nil {
+			post.Data.ArchiveURL = p.archiveLink(file)
+			continue
+		}
+		if offline {
+			continue
+		}
+
+		tasks = append(tasks, Task{
+			Host:     hostOf(post.Data.URL),
+			Priority: len(posts) - i,
+			Run: func(ctx context.Context) {
+				if err := ogf.archivePage(ctx, post.Data.URL, path); err != nil {
+					slog.Debug("Failed to archive page", "url", post.Data.URL, "error", err)
+					return
+				}
+				post.Data.ArchiveURL = p.archiveLink(file)
+			},
+		})
+	}
+
+	if len(tasks) > 0 {
+		ogf.scheduler.RunBatch(ctx, tasks)
+		slog.Debug("Archived linked pages", "attempted", len(tasks))
+	}
+	return posts
+}
+
+// archivePage fetches a page and writes a sanitized single-file copy of it
+func (ogf *OpenGraphFetcher) archivePage(ctx context.Context, pageURL, path string) (err error) {
+	ctx, span := StartSpan(ctx, "archive GET", SpanKindClient)
+	span.SetAttributes("url.full", pageURL, "server.address", hostOf(pageURL))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	content, err := ogf.fetchHTML(ctx, pageURL, span)
+	if err != nil {
+		return err
+	}
+	archived, err := sanitizeArchive(content, pageURL, time.Now())
+	if err != nil {
+		return err
+	}
+	return writeFeedFile(path, archived)
+}
+
+// sanitizeArchive strips scripts, embeds, forms and event handlers from a page and
+// adds a banner naming the original. Relative links and images resolve against the
+// original URL.
+func sanitizeArchive(content, pageURL string, archivedAt time.Time) ([]byte, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	var clean func(*html.Node)
+	clean = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			switch {
+			case c.Type == html.ElementNode && archiveDroppedElements[c.DataAtom]:
+				n.RemoveChild(c)
+			case c.Type == html.ElementNode && c.DataAtom == atom.Link && !strings.EqualFold(attr(c, "rel"), "stylesheet"):
+				n.RemoveChild(c)
+			case c.Type == html.ElementNode && c.DataAtom == atom.Meta && strings.EqualFold(attr(c, "http-equiv"), "refresh"):
+				n.RemoveChild(c)
+			default:
+				if c.Type == html.ElementNode {
+					c.Attr = safeAttributes(c.Attr)
+				}
+				clean(c)
+			}
+			c = next
+		}
+	}
+	clean(doc)
+
+	if head := findElement(doc, "head"); head != nil {
+		base := &html.Node{Type: html.ElementNode, Data: "base", DataAtom: atom.Base, Attr: []html.Attribute{{Key: "href", Val: pageURL}}}
+		head.InsertBefore(base, head.FirstChild)
+	}
+	if body := findElement(doc, "body"); body != nil {
+		banner, err := html.ParseFragment(strings.NewReader(fmt.Sprintf(
+			`<p style="padding:0.5em;background:#ffd;border:1px solid #cc9;font:14px sans-serif">Archived copy of <a href="%s">%s</a> from %s</p>`,
+			escapeXML(pageURL), escapeXML(pageURL), archivedAt.UTC().Format(time.RFC1123))), body)
+		if err == nil && len(banner) > 0 {
+			body.InsertBefore(banner[0], body.FirstChild)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("failed to render archived page: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// safeAttributes drops event handlers and javascript: URLs
+func safeAttributes(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") || strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:") {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// attr returns the value of an attribute of an element
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
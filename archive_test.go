@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchivePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Article</title><script>track()</script><meta http-equiv="refresh" content="0;url=/x"></head>
+<body><p onclick="steal()">Long <a href="javascript:alert(1)">text</a></p><img src="/a.png"><iframe src="/ad"></iframe></body></html>`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	p := NewPipeline(&Config{ArchiveDir: dir, ArchiveURL: "https://example.com/archive/"}, nil, nil)
+	post := seenPost("t3_article", 10)
+	post.Data.URL = server.URL + "/article"
+	self := seenPost("t3_self", 10)
+	self.Data.IsSelf = true
+	self.Data.URL = "https://www.reddit.com/r/test/comments/self/"
+
+	posts := p.archivePages(context.Background(), []RedditPost{post, self}, NewOpenGraphFetcher(nil), false)
+	file := archiveFileName(post.Data.URL)
+	if want := "https://example.com/archive/" + file; posts[0].Data.ArchiveURL != want {
+		t.Errorf("expected archive link %s, got %q", want, posts[0].Data.ArchiveURL)
+	}
+	if posts[1].Data.ArchiveURL != "" {
+		t.Errorf("expected self posts not to be archived")
+	}
+
+	archived, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatalf("expected an archived copy: %v", err)
+	}
+	for _, banned := range []string{"<script", "track()", "onclick", "javascript:", "<iframe", "refresh"} {
+		if strings.Contains(string(archived), banned) {
+			t.Errorf("expected %s to be stripped:\n%s", banned, archived)
+		}
+	}
+	for _, want := range []string{`<base href="` + post.Data.URL + `"/>`, "Archived copy of", `<img src="/a.png"/>`} {
+		if !strings.Contains(string(archived), want) {
+			t.Errorf("expected %s in the archived copy:\n%s", want, archived)
+		}
+	}
+
+	// Offline runs link existing copies
+	server.Close()
+	post.Data.ArchiveURL = ""
+	if offline := p.archivePages(context.Background(), []RedditPost{post}, NewOpenGraphFetcher(nil), true); offline[0].Data.ArchiveURL == "" {
+		t.Errorf("expected the existing copy to be linked offline")
+	}
+
+	item := NewFeedGenerator(nil).createFeedItem(posts[0], nil)
+	if !strings.Contains(item.Description, "Archived copy: https://example.com/archive/"+file) {
+		t.Errorf("expected the archive link in the description:\n%s", item.Description)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Audit log location and rotation
+const (
+	AuditLogFile      = "red_rss_audit.log" // JSON lines file with one record per event
+	AuditLogMaxSize   = 5 * 1024 * 1024     // Rotate when the file grows beyond this size
+	AuditLogRotations = 3                   // Number of rotated files kept (.1 is the newest)
+)
+
+// Audit event kinds
+const (
+	AuditAuth         = "auth"          // Interactive or password grant authentication
+	AuditTokenRefresh = "token_refresh" // Access token refreshed with the refresh token
+	AuditAPICall      = "api_call"      // Reddit API request
+)
+
+// AuditEvent is a single structured audit record
+type AuditEvent struct {
+	Time               time.Time `json:"time"`
+	Kind               string    `json:"kind"`
+	Tenant             string    `json:"tenant,omitempty"`
+	Endpoint           string    `json:"endpoint,omitempty"`
+	Status             int       `json:"status,omitempty"`
+	RateLimitRemaining string    `json:"ratelimit_remaining,omitempty"`
+	RateLimitReset     string    `json:"ratelimit_reset,omitempty"`
+	DurationMs         int64     `json:"duration_ms,omitempty"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// AuditLog appends audit events to a size-rotated JSON lines file
+type AuditLog struct {
+	mu        sync.Mutex
+	path      string
+	maxSize   int64
+	rotations int
+}
+
+// DefaultAuditLog receives the audit events of the process. It discards them until
+// resolveAppPaths points it at the data directory.
+var DefaultAuditLog = NewAuditLog("", AuditLogMaxSize, AuditLogRotations)
+
+// NewAuditLog creates an audit log writing to path, or discarding events without one
+func NewAuditLog(path string, maxSize int64, rotations int) *AuditLog {
+	return &AuditLog{
+		path:      path,
+		maxSize:   maxSize,
+		rotations: rotations,
+	}
+}
+
+// Record appends an event. Failures are logged but never interrupt the caller.
+func (a *AuditLog) Record(event AuditEvent) {
+	if a.path == "" {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		slog.Warn("Failed to encode audit event", "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.rotateIfNeeded(); err != nil {
+		slog.Warn("Failed to rotate audit log", "error", err)
+	}
+
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		slog.Warn("Failed to open audit log", "path", a.path, "error", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		slog.Warn("Failed to write audit event", "error", err)
+	}
+}
+
+// rotateIfNeeded shifts path → path.1 → path.2 … once the file exceeds maxSize
+func (a *AuditLog) rotateIfNeeded() error {
+	info, err := os.Stat(a.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < a.maxSize {
+		return nil
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", a.path, a.rotations))
+	for i := a.rotations - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+	}
+	return os.Rename(a.path, a.path+".1")
+}
+
+// ReadAll returns all events from the rotated files and the current file, oldest first
+func (a *AuditLog) ReadAll() ([]AuditEvent, error) {
+	if a.path == "" {
+		return nil, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	paths := make([]string, 0, a.rotations+1)
+	for i := a.rotations; i >= 1; i-- {
+		paths = append(paths, fmt.Sprintf("%s.%d", a.path, i))
+	}
+	paths = append(paths, a.path)
+
+	var events []AuditEvent
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var event AuditEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				continue // Skip torn writes from crashed runs
+			}
+			events = append(events, event)
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+
+	return events, nil
+}
+
+// auditAPICall records a Reddit API request and its rate limit headers
+func auditAPICall(tenant, endpoint string, resp *http.Response, duration time.Duration, err error) {
+	event := AuditEvent{
+		Kind:       AuditAPICall,
+		Tenant:     tenant,
+		Endpoint:   endpoint,
+		DurationMs: duration.Milliseconds(),
+	}
+	if resp != nil {
+		event.Status = resp.StatusCode
+		event.RateLimitRemaining = resp.Header.Get("X-Ratelimit-Remaining")
+		event.RateLimitReset = resp.Header.Get("X-Ratelimit-Reset")
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	DefaultAuditLog.Record(event)
+}
+
+// auditAuth records an authentication or token refresh outcome
+func auditAuth(kind, tenant string, err error) {
+	event := AuditEvent{Kind: kind, Tenant: tenant, Endpoint: "/api/v1/access_token"}
+	if err != nil {
+		event.Error = err.Error()
+		var re *oauth2.RetrieveError
+		if errors.As(err, &re) && re.Response != nil {
+			event.Status = re.Response.StatusCode
+		}
+	}
+	DefaultAuditLog.Record(event)
+}
+
+// runAudit implements the audit subcommand
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	count := fs.Int("n", 50, "number of most recent events to show (0 for all)")
+	kind := fs.String("kind", "", "only show events of this kind (auth, token_refresh, api_call)")
+	tenant := fs.String("tenant", "", "only show events for this tenant")
+	status := fs.Int("status", 0, "only show events with this HTTP status, e.g. 401 or 429")
+	errorsOnly := fs.Bool("errors", false, "only show failed events")
+	asJSON := fs.Bool("json", false, "print events as JSON lines")
+	fs.Parse(args)
+
+	events, err := DefaultAuditLog.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var filtered []AuditEvent
+	for _, event := range events {
+		if *kind != "" && event.Kind != *kind {
+			continue
+		}
+		if *tenant != "" && event.Tenant != *tenant {
+			continue
+		}
+		if *status != 0 && event.Status != *status {
+			continue
+		}
+		if *errorsOnly && event.Error == "" && event.Status < 400 {
+			continue
+		}
+		filtered = append(filtered, event)
+	}
+
+	if *count > 0 && len(filtered) > *count {
+		filtered = filtered[len(filtered)-*count:]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, event := range filtered {
+			encoder.Encode(event)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tKIND\tTENANT\tSTATUS\tENDPOINT\tREMAINING\tRESET\tDURATION\tERROR")
+	for _, event := range filtered {
+		status := ""
+		if event.Status != 0 {
+			status = strconv.Itoa(event.Status)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			event.Time.Local().Format("2006-01-02 15:04:05"), event.Kind, event.Tenant, status,
+			event.Endpoint, event.RateLimitRemaining, event.RateLimitReset, event.DurationMs, event.Error)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log := NewAuditLog(path, 200, 2)
+
+	for i := 0; i < 20; i++ {
+		log.Record(AuditEvent{Kind: AuditAPICall, Endpoint: "/best", Status: 200 + i})
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("expected rotated file: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Errorf("expected at most 2 rotated files")
+	}
+
+	events, err := log.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(events) == 0 || len(events) >= 20 {
+		t.Fatalf("expected old events to be rotated away, got %d events", len(events))
+	}
+
+	// Events must come back oldest first across rotated files
+	for i := 1; i < len(events); i++ {
+		if events[i].Status <= events[i-1].Status {
+			t.Fatalf("events out of order: %d after %d", events[i].Status, events[i-1].Status)
+		}
+	}
+	if events[len(events)-1].Status != 219 {
+		t.Errorf("expected newest event last, got status %d", events[len(events)-1].Status)
+	}
+}
+
+// useAuditLog points DefaultAuditLog at a temporary file for the test
+func useAuditLog(t *testing.T) *AuditLog {
+	saved := DefaultAuditLog
+	t.Cleanup(func() { DefaultAuditLog = saved })
+	DefaultAuditLog = NewAuditLog(filepath.Join(t.TempDir(), AuditLogFile), AuditLogMaxSize, AuditLogRotations)
+	return DefaultAuditLog
+}
+
+func TestDefaultAuditLog(t *testing.T) {
+	t.Chdir(t.TempDir())
+	auditAPICall("", "/best", nil, 0, nil)
+	if _, err := os.Stat(AuditLogFile); err == nil {
+		t.Error("expected no audit log in the working directory before the paths are resolved")
+	}
+
+	log := useAuditLog(t)
+	auditAPICall("alice", "/best", nil, 0, nil)
+	if events, err := log.ReadAll(); err != nil || len(events) != 1 || events[0].Tenant != "alice" {
+		t.Errorf("expected the API call in the audit log, got %v, %v", events, err)
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -15,6 +21,21 @@ import (
 // AuthenticateUser starts a local web server, opens the browser for authentication,
 // and retrieves the access and refresh tokens.
 func AuthenticateUser() error {
+	// Catch configuration mistakes before sending the user to an opaque browser error
+	if err := PreflightOAuthConfig(&GlobalConfig); err != nil {
+		return fmt.Errorf("OAuth configuration check failed: %w", err)
+	}
+
+	checkCtx, checkCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := ValidateClientID(checkCtx, &GlobalConfig)
+	checkCancel()
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return fmt.Errorf("client ID check failed: %w", err)
+	} else if err != nil {
+		slog.Warn("Could not verify client ID with Reddit, continuing", "error", err)
+	}
+
 	// Create a context for the HTTP server to allow graceful shutdown
 	serverCtx, serverCancel := context.WithCancel(context.Background())
 	defer serverCancel() // Ensure context is always cancelled
@@ -46,15 +67,30 @@ func AuthenticateUser() error {
 	// Construct the authorization URL
 	authURL := OAuth2Config.AuthCodeURL("state", oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("duration", "permanent"))
 
-	// Open the URL in the user's default browser
-	slog.Info("Opening browser for Reddit authentication", "url", authURL)
-	err := OpenBrowser(authURL)
-	if err != nil {
-		return fmt.Errorf("failed to open browser: %w. Please open the URL manually: %s", err, authURL)
+	// Open the URL in the user's default browser, or let the user open it anywhere and
+	// paste the redirect back when there is no browser
+	var pasted <-chan string
+	if !HeadlessAuth {
+		slog.Info("Opening browser for Reddit authentication", "url", authURL)
+		if err := OpenBrowser(authURL); err != nil {
+			slog.Warn("Failed to open browser, continuing without one", "error", err)
+			HeadlessAuth = true
+		}
+	}
+	if HeadlessAuth {
+		printHeadlessInstructions(os.Stderr, authURL)
+		pasted = readPastedLine(os.Stdin)
 	}
 
-	// Wait for the authorization code to be sent via the channel
-	authCode := <-AuthCodeChan
+	// Wait for the authorization code from the callback or pasted by the user
+	var authCode string
+	select {
+	case authCode = <-AuthCodeChan:
+	case input := <-pasted:
+		if authCode, err = parseAuthCode(input); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
 
 	if authCode == "" {
 		return fmt.Errorf("authentication failed: no authorization code received")
@@ -62,6 +98,7 @@ func AuthenticateUser() error {
 
 	// Exchange the authorization code for tokens with retry logic
 	err = exchangeAuthCodeForTokens(authCode)
+	auditAuth(AuditAuth, DefaultTenant, err)
 	if err != nil {
 		return fmt.Errorf("failed to exchange authorization code: %w", err)
 	}
@@ -77,10 +114,78 @@ func AuthenticateUser() error {
 	slog.Info("Authentication successful, tokens saved")
 
 	// Ensure the server goroutine has finished before proceeding
+	serverCancel()
 	ServerWg.Wait()
 	return nil
 }
 
+// HeadlessAuth prints the authorization URL instead of opening a browser, for servers
+// without one. The user opens it on any machine and pastes the redirect back.
+var HeadlessAuth bool
+
+// NonInteractiveEnv turns on non-interactive mode when set, e.g. in containers
+const NonInteractiveEnv = "RED_RSS_NON_INTERACTIVE"
+
+// NonInteractive makes runs fail instead of prompting for config values or starting
+// browser authorization. The config and refresh token then come from RED_RSS_*
+// environment variables or -set overrides.
+var NonInteractive bool
+
+// printHeadlessInstructions tells the user how to authorize without a local browser
+func printHeadlessInstructions(w io.Writer, authURL string) {
+	fmt.Fprintf(w, "Open this URL in a browser on any machine and allow access:\n\n  %s\n\n", authURL)
+	fmt.Fprintf(w, "Reddit then redirects to %s. If that page doesn't load, copy the full\n", OAuth2Config.RedirectURL)
+	fmt.Fprint(w, "address from the browser's address bar (or just its code parameter) and paste it here:\n")
+}
+
+// readPastedLine returns a channel receiving the first non-empty line of r. Nothing
+// is sent when r ends without one, e.g. when stdin isn't a terminal.
+func readPastedLine(r io.Reader) <-chan string {
+	lines := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				lines <- line
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+// parseAuthCode extracts the authorization code from a pasted redirect URL, its query
+// string, or the bare code
+func parseAuthCode(input string) (string, error) {
+	input, _, _ = strings.Cut(strings.TrimSpace(input), "#") // Reddit appends #_ to redirects
+	query := input
+	if _, q, ok := strings.Cut(input, "?"); ok {
+		query = q
+	}
+	if !strings.Contains(query, "=") {
+		if input == "" || strings.ContainsAny(input, " /") {
+			return "", fmt.Errorf("%q is not a redirect URL or authorization code", input)
+		}
+		return input, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pasted URL: %w", err)
+	}
+	if errorParam := values.Get("error"); errorParam != "" {
+		return "", fmt.Errorf("%s: %s", errorParam, describeCallbackError(errorParam))
+	}
+	if state := values.Get("state"); state != "" && state != "state" {
+		return "", fmt.Errorf("state mismatch in pasted URL")
+	}
+	code := values.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no code in pasted URL")
+	}
+	return code, nil
+}
+
 // exchangeAuthCodeForTokens exchanges authorization code for tokens with retry logic
 func exchangeAuthCodeForTokens(authCode string) error {
 	const maxRetries = 5
@@ -107,7 +212,7 @@ func exchangeAuthCodeForTokens(authCode string) error {
 			continue
 		}
 
-		return fmt.Errorf("failed to exchange authorization code for token after %d attempts: %w", i+1, err)
+		return fmt.Errorf("failed to exchange authorization code for token after %d attempts: %w", i+1, explainOAuthError(err))
 	}
 
 	return fmt.Errorf("failed to exchange authorization code for token after %d retries", maxRetries)
@@ -121,8 +226,9 @@ func OAuth2CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	errorParam := query.Get("error")
 
 	if errorParam != "" {
-		slog.Error("OAuth2 callback error", "error", errorParam)
-		fmt.Fprintf(w, "Authentication failed: %s. Please check the console for details.", errorParam)
+		hint := describeCallbackError(errorParam)
+		slog.Error("OAuth2 callback error", "error", errorParam, "hint", hint)
+		fmt.Fprintf(w, "Authentication failed: %s. Hint: %s", errorParam, hint)
 		AuthCodeChan <- "" // Send empty string to unblock main goroutine
 		return
 	}
@@ -164,6 +270,41 @@ func OpenBrowser(url string) error {
 	return exec.Command(cmd, args...).Start()
 }
 
+// AuthenticateScriptApp obtains an access token for a "script" app using the password
+// grant with credentials from RED_RSS_USERNAME and RED_RSS_PASSWORD. Reddit does not
+// issue refresh tokens for this grant, so it is repeated whenever the token expires.
+func AuthenticateScriptApp() error {
+	username := os.Getenv("RED_RSS_USERNAME")
+	password := os.Getenv("RED_RSS_PASSWORD")
+	if username == "" || password == "" {
+		return fmt.Errorf("app_type %q requires RED_RSS_USERNAME and RED_RSS_PASSWORD to be set", AppTypeScript)
+	}
+
+	if err := PreflightOAuthConfig(&GlobalConfig); err != nil {
+		return fmt.Errorf("OAuth configuration check failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, err := OAuth2Config.PasswordCredentialsToken(ctx, username, password)
+	auditAuth(AuditAuth, DefaultTenant, err)
+	if err != nil {
+		return fmt.Errorf("failed to obtain token with password grant: %w", explainOAuthError(err))
+	}
+
+	Token = token
+	GlobalConfig.AccessToken = Token.AccessToken
+	GlobalConfig.RefreshToken = ""
+	GlobalConfig.ExpiresAt = Token.Expiry
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	slog.Info("Script app authentication successful", "username", username)
+	return nil
+}
+
 // RefreshAccessToken uses the refresh token to obtain a new access token.
 func RefreshAccessToken() error {
 	if Token == nil || Token.RefreshToken == "" {
@@ -177,8 +318,9 @@ func RefreshAccessToken() error {
 	// The oauth2.Config.TokenSource correctly handles the empty ClientSecret for installed apps.
 	tokenSource := OAuth2Config.TokenSource(ctx, Token)
 	newToken, err := tokenSource.Token()
+	auditAuth(AuditTokenRefresh, DefaultTenant, err)
 	if err != nil {
-		return fmt.Errorf("failed to get new token from refresh token: %w", err)
+		return fmt.Errorf("failed to get new token from refresh token: %w", explainOAuthError(err))
 	}
 
 	Token = newToken // Update the global token
@@ -187,7 +329,12 @@ func RefreshAccessToken() error {
 	GlobalConfig.ExpiresAt = Token.Expiry
 
 	if err := SaveConfig(); err != nil {
-		return fmt.Errorf("failed to save updated config: %w", err)
+		// A container may not be able to keep the tokens, but the refresh token it
+		// was given keeps working
+		if !NonInteractive {
+			return fmt.Errorf("failed to save updated config: %w", err)
+		}
+		slog.Warn("Failed to save refreshed tokens", "error", err)
 	}
 
 	slog.Info("Access token refreshed successfully")
@@ -196,18 +343,56 @@ func RefreshAccessToken() error {
 
 // InitializeOAuth2Config initializes the OAuth2 configuration
 func InitializeOAuth2Config() {
-	// Define Reddit's OAuth2 endpoints manually
+	OAuth2Config = newOAuth2Config(&GlobalConfig)
+}
+
+// newOAuth2Config builds the Reddit OAuth2 configuration for the given settings
+func newOAuth2Config(config *Config) *oauth2.Config {
+	// Define Reddit's OAuth2 endpoints manually.
+	// Reddit requires client credentials in the Authorization header for every app type.
 	redditEndpoint := oauth2.Endpoint{
-		AuthURL:  "https://www.reddit.com/api/v1/authorize",
-		TokenURL: "https://www.reddit.com/api/v1/access_token",
+		AuthURL:   "https://www.reddit.com/api/v1/authorize",
+		TokenURL:  "https://www.reddit.com/api/v1/access_token",
+		AuthStyle: oauth2.AuthStyleInHeader,
+	}
+
+	// Request necessary scopes, listing subscriptions and friends and reading replies
+	// in the inbox need their own
+	scopes := []string{"identity", "read", "history"}
+	if config.Subscriptions != "" || config.FriendsFeed {
+		scopes = append(scopes, "mysubreddits")
+	}
+	if config.RepliesFeed {
+		scopes = append(scopes, "privatemessages")
 	}
 
-	// Initialize OAuth2 config
-	OAuth2Config = &oauth2.Config{
-		ClientID:     GlobalConfig.ClientID,
-		ClientSecret: GlobalConfig.ClientSecret, // This will be an empty string for installed apps
-		RedirectURL:  GlobalConfig.RedirectURI,
-		Scopes:       []string{"identity", "read", "history"}, // Request necessary scopes
-		Endpoint:     redditEndpoint,                          // Use the manually defined endpoint
+	return &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret, // This will be an empty string for installed apps
+		RedirectURL:  config.RedirectURI,
+		Scopes:       scopes,
+		Endpoint:     redditEndpoint, // Use the manually defined endpoint
+	}
+}
+
+// scriptTokenSource obtains a new token with the password grant whenever asked.
+// Wrap it in oauth2.ReuseTokenSource so it is only called once the token expires.
+type scriptTokenSource struct {
+	ctx    context.Context
+	config *oauth2.Config
+}
+
+// Token performs the password grant with credentials from the environment
+func (s *scriptTokenSource) Token() (*oauth2.Token, error) {
+	username := os.Getenv("RED_RSS_USERNAME")
+	password := os.Getenv("RED_RSS_PASSWORD")
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("app_type %q requires RED_RSS_USERNAME and RED_RSS_PASSWORD to be set", AppTypeScript)
+	}
+
+	token, err := s.config.PasswordCredentialsToken(s.ctx, username, password)
+	if err != nil {
+		return nil, explainOAuthError(err)
 	}
+	return token, nil
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// redditAppsURL is where users manage their Reddit OAuth apps
+const redditAppsURL = "https://www.reddit.com/prefs/apps"
+
+// clientIDPattern matches the shape of Reddit OAuth client IDs
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{10,30}$`)
+
+// OAuthError wraps an OAuth2 failure with targeted instructions for the user
+type OAuthError struct {
+	Code string // OAuth2 error code, e.g. "invalid_grant"
+	Hint string // Human-readable instructions for fixing the problem
+	Err  error  // Underlying error, if any
+}
+
+func (e *OAuthError) Error() string {
+	msg := e.Code
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Hint == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (%s)", msg, e.Hint)
+}
+
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
+// expectedRedirectURI returns the redirect URI the local callback server listens on
+func expectedRedirectURI() string {
+	return fmt.Sprintf("http://localhost:%s/callback", AuthPort)
+}
+
+// appTypeLabel returns the app type name as shown in Reddit's app settings
+func appTypeLabel(appType string) string {
+	switch appType {
+	case AppTypeWeb:
+		return "web app"
+	case AppTypeScript:
+		return "script"
+	default:
+		return "installed app"
+	}
+}
+
+// PreflightOAuthConfig performs offline sanity checks on the OAuth settings before
+// the browser flow is started, so common mistakes fail fast with instructions.
+func PreflightOAuthConfig(config *Config) error {
+	label := appTypeLabel(config.AppType)
+	clientID := strings.TrimSpace(config.ClientID)
+	if clientID == "" {
+		return &OAuthError{Code: "missing_client_id", Hint: fmt.Sprintf("create a %q app at %s and copy the ID shown under the app name", label, redditAppsURL)}
+	}
+
+	if clientID != config.ClientID {
+		return &OAuthError{Code: "invalid_client_id", Hint: "client_id contains leading or trailing whitespace"}
+	}
+
+	if !clientIDPattern.MatchString(clientID) {
+		return &OAuthError{Code: "invalid_client_id", Hint: fmt.Sprintf("%q does not look like a Reddit client ID; copy the string shown under the app name at %s, not the app name or secret", clientID, redditAppsURL)}
+	}
+
+	if config.AppType != AppTypeInstalled && config.AppType != "" && config.ClientSecret == "" {
+		return &OAuthError{Code: "missing_client_secret", Hint: fmt.Sprintf("a %q requires client_secret; copy the secret shown at %s", label, redditAppsURL)}
+	}
+
+	// Script apps use the password grant and never redirect
+	if config.AppType == AppTypeScript {
+		return nil
+	}
+
+	expected := expectedRedirectURI()
+	if config.RedirectURI == "" {
+		return &OAuthError{Code: "missing_redirect_uri", Hint: fmt.Sprintf("set redirect_uri to %s and use the same value in the Reddit app settings", expected)}
+	}
+
+	u, err := url.Parse(config.RedirectURI)
+	if err != nil {
+		return &OAuthError{Code: "invalid_redirect_uri", Hint: fmt.Sprintf("redirect_uri must be %s", expected), Err: err}
+	}
+
+	if u.Port() != AuthPort || u.Path != "/callback" {
+		return &OAuthError{Code: "redirect_uri_mismatch", Hint: fmt.Sprintf("the local callback server listens on %s but redirect_uri is %s; both the config and the Reddit app settings must use %s", expected, config.RedirectURI, expected)}
+	}
+
+	return nil
+}
+
+// ValidateClientID checks with Reddit that the client credentials are accepted by
+// requesting an application-only token, which requires no user interaction.
+func ValidateClientID(ctx context.Context, config *Config) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return validateClientID(ctx, client, "https://www.reddit.com/api/v1/access_token", config)
+}
+
+// validateClientID requests an application-only token from the given token URL. Installed
+// apps use the installed_client grant, apps with a secret use client_credentials.
+func validateClientID(ctx context.Context, client *http.Client, tokenURL string, config *Config) error {
+	form := url.Values{}
+	if config.ClientSecret == "" {
+		form.Set("grant_type", "https://oauth.reddit.com/grants/installed_client")
+		form.Set("device_id", "DO_NOT_TRACK_THIS_DEVICE")
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(config.ClientID, config.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "GoRedditFeedGenerator/1.0 by YourRedditUsername")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Reddit token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return &OAuthError{
+			Code: "invalid_client",
+			Hint: fmt.Sprintf("Reddit did not accept client_id %q; check the credentials at %s and make sure the app type is %q", config.ClientID, redditAppsURL, appTypeLabel(config.AppType)),
+		}
+	case http.StatusTooManyRequests:
+		// Don't block authentication on a rate-limited probe
+		slog.Warn("Client ID check was rate limited, skipping")
+		return nil
+	default:
+		return fmt.Errorf("unexpected status from Reddit token endpoint: %s", resp.Status)
+	}
+}
+
+// describeCallbackError returns instructions for an error code sent to the OAuth2 callback
+func describeCallbackError(code string) string {
+	switch code {
+	case "access_denied":
+		return "the authorization request was declined; run again and click \"Allow\""
+	case "unsupported_response_type":
+		return "Reddit rejected the response type; this is a bug, please report it"
+	case "invalid_scope":
+		return "Reddit rejected the requested scopes (identity, read, history)"
+	case "invalid_request":
+		return fmt.Sprintf("Reddit rejected the request; check that the app at %s is a %q with redirect uri %s", redditAppsURL, appTypeLabel(GlobalConfig.AppType), expectedRedirectURI())
+	default:
+		return fmt.Sprintf("check the app settings at %s (app type %q, redirect uri %s)", redditAppsURL, appTypeLabel(GlobalConfig.AppType), expectedRedirectURI())
+	}
+}
+
+// explainOAuthError converts token endpoint failures into OAuthErrors with targeted hints
+func explainOAuthError(err error) error {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return err
+	}
+
+	code := re.ErrorCode
+	status := 0
+	if re.Response != nil {
+		status = re.Response.StatusCode
+	}
+
+	switch {
+	case status == http.StatusUnauthorized:
+		return &OAuthError{Code: "invalid_client", Err: err, Hint: fmt.Sprintf("Reddit did not accept the client credentials; verify client_id at %s and that the app type matches the config", redditAppsURL)}
+	case code == "invalid_grant" && GlobalConfig.AppType == AppTypeScript:
+		return &OAuthError{Code: code, Err: err, Hint: "Reddit rejected the username or password; check RED_RSS_USERNAME and RED_RSS_PASSWORD and note that accounts with two-factor authentication are not supported"}
+	case code == "invalid_grant":
+		return &OAuthError{Code: code, Err: err, Hint: fmt.Sprintf("the authorization code or refresh token is expired or revoked, or the redirect uri differs from %s; clear refresh_token in %s to re-authenticate", expectedRedirectURI(), ConfigPath)}
+	case code == "unsupported_grant_type":
+		return &OAuthError{Code: code, Err: err, Hint: "the Reddit app type does not support this grant; check app_type in the config"}
+	case status == http.StatusTooManyRequests:
+		return &OAuthError{Code: "rate_limited", Err: err, Hint: "Reddit is rate limiting token requests; wait a minute and try again"}
+	}
+
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPreflightOAuthConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		redirect string
+		wantCode string
+	}{
+		{"valid", "abcDEF123_-xyz", expectedRedirectURI(), ""},
+		{"missing client id", "", expectedRedirectURI(), "missing_client_id"},
+		{"whitespace", " abcDEF123_-xyz", expectedRedirectURI(), "invalid_client_id"},
+		{"bad shape", "my app", expectedRedirectURI(), "invalid_client_id"},
+		{"wrong port", "abcDEF123_-xyz", "http://localhost:9999/callback", "redirect_uri_mismatch"},
+		{"wrong path", "abcDEF123_-xyz", "http://localhost:" + AuthPort + "/oauth", "redirect_uri_mismatch"},
+		{"missing redirect", "abcDEF123_-xyz", "", "missing_redirect_uri"},
+	}
+
+	scriptApp := &Config{ClientID: "abcDEF123_-xyz", AppType: AppTypeScript}
+	var oauthErr *OAuthError
+	if err := PreflightOAuthConfig(scriptApp); !errors.As(err, &oauthErr) || oauthErr.Code != "missing_client_secret" {
+		t.Errorf("expected missing_client_secret for script app, got %v", err)
+	}
+
+	// Script apps never redirect, so redirect_uri is not checked
+	scriptApp.ClientSecret = "s3cret"
+	if err := PreflightOAuthConfig(scriptApp); err != nil {
+		t.Errorf("unexpected error for script app: %v", err)
+	}
+
+	for _, test := range tests {
+		err := PreflightOAuthConfig(&Config{ClientID: test.clientID, RedirectURI: test.redirect})
+		if test.wantCode == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) {
+			t.Errorf("%s: expected OAuthError, got %v", test.name, err)
+			continue
+		}
+		if oauthErr.Code != test.wantCode {
+			t.Errorf("%s: expected code %q, got %q", test.name, test.wantCode, oauthErr.Code)
+		}
+	}
+}
+
+func TestValidateClientID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		grant := r.PostForm.Get("grant_type")
+		if (secret == "" && grant != "https://oauth.reddit.com/grants/installed_client") ||
+			(secret != "" && grant != "client_credentials") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if user != "goodclientid123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"x","token_type":"bearer"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	if err := validateClientID(ctx, server.Client(), server.URL, &Config{ClientID: "goodclientid123"}); err != nil {
+		t.Errorf("expected valid client ID, got %v", err)
+	}
+
+	webApp := &Config{ClientID: "goodclientid123", ClientSecret: "s3cret", AppType: AppTypeWeb}
+	if err := validateClientID(ctx, server.Client(), server.URL, webApp); err != nil {
+		t.Errorf("expected valid web app credentials, got %v", err)
+	}
+
+	err := validateClientID(ctx, server.Client(), server.URL, &Config{ClientID: "badclientid123"})
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_client" {
+		t.Errorf("expected invalid_client OAuthError, got %v", err)
+	}
+}
+
+func TestExplainOAuthError(t *testing.T) {
+	invalidGrant := &oauth2.RetrieveError{
+		Response:  &http.Response{StatusCode: http.StatusBadRequest},
+		ErrorCode: "invalid_grant",
+	}
+
+	var oauthErr *OAuthError
+	if err := explainOAuthError(invalidGrant); !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" {
+		t.Errorf("expected invalid_grant OAuthError, got %v", err)
+	}
+
+	// The original RetrieveError must remain reachable for callers that inspect it
+	if err := explainOAuthError(invalidGrant); !errors.Is(err, invalidGrant) {
+		t.Errorf("expected wrapped RetrieveError, got %v", err)
+	}
+
+	plain := errors.New("network down")
+	if err := explainOAuthError(plain); err != plain {
+		t.Errorf("expected non-OAuth error to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAuthCode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"http://localhost:8080/callback?state=state&code=abc123#_", "abc123", false},
+		{"  state=state&code=abc123\n", "abc123", false},
+		{"abc123", "abc123", false},
+		{"http://localhost:8080/callback?state=state&error=access_denied", "", true},
+		{"http://localhost:8080/callback?state=other&code=abc123", "", true},
+		{"http://localhost:8080/callback", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseAuthCode(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAuthCode(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadPastedLine(t *testing.T) {
+	select {
+	case line := <-readPastedLine(strings.NewReader("\n  \ncode=abc\nmore\n")):
+		if line != "code=abc" {
+			t.Errorf("expected first non-empty line, got %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a pasted line")
+	}
+
+	select {
+	case line := <-readPastedLine(strings.NewReader("")):
+		t.Errorf("expected nothing from empty input, got %q", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
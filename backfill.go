@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeedRetention is how long backfilled posts fill up a feed before only newly
+// fetched posts remain
+const SeedRetention = 7 * 24 * time.Hour
+
+// BackfillSpec selects historical posts, written as sort:time:count, e.g. "top:month:50"
+type BackfillSpec struct {
+	Sort  string // "top" or "controversial"
+	Time  string // "hour", "day", "week", "month", "year" or "all"
+	Count int    // Number of posts, at most 100
+}
+
+// ParseBackfillSpec parses a sort:time:count backfill specification
+func ParseBackfillSpec(spec string) (BackfillSpec, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return BackfillSpec{}, fmt.Errorf("invalid backfill %q, expected sort:time:count such as top:month:50", spec)
+	}
+
+	b := BackfillSpec{Sort: parts[0], Time: parts[1]}
+	if b.Sort != "top" && b.Sort != "controversial" {
+		return BackfillSpec{}, fmt.Errorf("invalid backfill sort %q, expected top or controversial", b.Sort)
+	}
+	if !slices.Contains([]string{"hour", "day", "week", "month", "year", "all"}, b.Time) {
+		return BackfillSpec{}, fmt.Errorf("invalid backfill time %q, expected hour, day, week, month, year or all", b.Time)
+	}
+
+	count, err := strconv.Atoi(parts[2])
+	if err != nil || count < 1 || count > 100 {
+		return BackfillSpec{}, fmt.Errorf("invalid backfill count %q, expected 1-100", parts[2])
+	}
+	b.Count = count
+
+	return b, nil
+}
+
+// Backfill seeds the item store with historical posts so a new feed isn't empty
+// until new posts arrive. It returns the number of seeded posts.
+func (p *Pipeline) Backfill(ctx context.Context, spec BackfillSpec) (int, error) {
+	if p.db == nil {
+		return 0, fmt.Errorf("backfill requires the cache database")
+	}
+
+	params := url.Values{"t": {spec.Time}, "limit": {strconv.Itoa(spec.Count)}}
+	posts, err := p.api.FetchListing(ctx, "/"+spec.Sort, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch backfill posts: %w", err)
+	}
+
+	if err := p.db.SaveSeenPosts(posts); err != nil {
+		return 0, err
+	}
+	if err := p.db.SaveSeeds(HomepageSource, posts); err != nil {
+		return 0, err
+	}
+
+	slog.Info("Backfilled feed", "source", HomepageSource, "sort", spec.Sort, "time", spec.Time, "posts", len(posts))
+	return len(posts), nil
+}
+
+// withSeeds appends the source's backfilled posts that weren't fetched, oldest last
+func (p *Pipeline) withSeeds(posts []RedditPost) []RedditPost {
+	if p.db == nil {
+		return posts
+	}
+
+	seeds, err := p.db.GetSeeds(HomepageSource, time.Now().Add(-SeedRetention))
+	if err != nil {
+		slog.Warn("Failed to load backfilled posts", "error", err)
+		return posts
+	}
+	if len(seeds) == 0 {
+		return posts
+	}
+
+	fetched := make(map[string]bool, len(posts))
+	for _, post := range posts {
+		fetched[post.Data.Name] = true
+	}
+
+	var extra []RedditPost
+	for _, seed := range seeds {
+		if !fetched[seed.Data.Name] {
+			extra = append(extra, seed)
+		}
+	}
+	slices.SortFunc(extra, newestFirst)
+
+	slog.Debug("Adding backfilled posts", "count", len(extra))
+	return append(posts, extra...)
+}
+
+// SaveSeeds marks posts as backfilled for a source
+func (ogDB *OpenGraphDB) SaveSeeds(source string, posts []RedditPost) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	now := time.Now().Unix()
+	for _, post := range posts {
+		if post.Data.Name == "" {
+			continue
+		}
+		_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO seeded_posts (source, fullname, seeded_at) VALUES (?, ?, ?)`,
+			source, post.Data.Name, now)
+		if err != nil {
+			return fmt.Errorf("failed to save backfilled post: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetSeeds returns the posts backfilled for a source since the given time
+func (ogDB *OpenGraphDB) GetSeeds(source string, since time.Time) ([]RedditPost, error) {
+	ogDB.mu.RLock()
+	rows, err := ogDB.db.Query(`SELECT fullname FROM seeded_posts WHERE source = ? AND seeded_at > ?`, source, since.Unix())
+	if err != nil {
+		ogDB.mu.RUnlock()
+		return nil, fmt.Errorf("failed to load backfilled posts: %w", err)
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			ogDB.mu.RUnlock()
+			return nil, fmt.Errorf("failed to scan backfilled post: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	ogDB.mu.RUnlock()
+
+	stored, err := ogDB.GetSeenPosts(names)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]RedditPost, 0, len(stored))
+	for _, name := range names {
+		if post, ok := stored[name]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
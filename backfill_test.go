@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestParseBackfillSpec(t *testing.T) {
+	spec, err := ParseBackfillSpec("top:month:50")
+	if err != nil {
+		t.Fatalf("ParseBackfillSpec failed: %v", err)
+	}
+	if spec.Sort != "top" || spec.Time != "month" || spec.Count != 50 {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+
+	for _, invalid := range []string{"top:month", "new:month:50", "top:decade:50", "top:month:0", "top:month:500", "top:month:x"} {
+		if _, err := ParseBackfillSpec(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestWithSeeds(t *testing.T) {
+	db := newTestDB(t)
+	p := &Pipeline{config: &Config{}, db: db}
+
+	older := seenPost("t3_old", 500)
+	older.Data.CreatedUTC = 1000
+	newer := seenPost("t3_new", 800)
+	newer.Data.CreatedUTC = 2000
+	overlap := seenPost("t3_both", 900)
+
+	seeds := []RedditPost{older, newer, overlap}
+	if err := db.SaveSeenPosts(seeds); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+	if err := db.SaveSeeds(HomepageSource, seeds); err != nil {
+		t.Fatalf("SaveSeeds failed: %v", err)
+	}
+
+	posts := p.withSeeds([]RedditPost{seenPost("t3_fresh", 10), overlap})
+
+	var names []string
+	for _, post := range posts {
+		names = append(names, post.Data.Name)
+	}
+	want := []string{"t3_fresh", "t3_both", "t3_new", "t3_old"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, names)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Persisted backoff after failed Reddit API fetches. The delay doubles with each
+// consecutive failure, from BackoffBase up to BackoffMax.
+const (
+	BackoffBase = time.Minute
+	BackoffMax  = 6 * time.Hour
+)
+
+// ErrBackoff is returned when an endpoint is skipped because an earlier run backed off
+var ErrBackoff = errors.New("reddit: backing off after earlier failures")
+
+// Backoff is the persisted backoff state of a Reddit API endpoint
+type Backoff struct {
+	Endpoint    string
+	Failures    int       // Consecutive failed fetches
+	NextAttempt time.Time // No requests before this time
+	LastError   string
+}
+
+// BackoffError reports that an endpoint is still backing off
+type BackoffError struct {
+	Backoff
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("%s: %s until %s after %d failures (last error: %s)", ErrBackoff, e.Endpoint,
+		e.NextAttempt.Format(time.RFC3339), e.Failures, e.LastError)
+}
+
+func (e *BackoffError) Unwrap() error {
+	return ErrBackoff
+}
+
+// backoffDelay returns how long to wait after the given number of consecutive
+// failures, at least as long as Reddit asked for
+func backoffDelay(failures int, retryAfter time.Duration) time.Duration {
+	delay := BackoffBase
+	for i := 1; i < failures && delay < BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, BackoffMax)
+	return max(delay, retryAfter)
+}
+
+// shouldBackOff reports whether a failed fetch should delay the next attempts. Errors
+// that retrying can't fix, like a revoked token, and cancelled runs don't count.
+func shouldBackOff(err error) bool {
+	if err == nil || errors.Is(err, ErrBackoff) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *RedditAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return true
+}
+
+// SetBackoffStore makes the client persist backoff state in db, so later runs honor it
+func (api *RedditAPI) SetBackoffStore(db *OpenGraphDB) {
+	api.backoff = db
+}
+
+// checkBackoff returns a BackoffError while the endpoint is backing off
+func (api *RedditAPI) checkBackoff(endpoint string) error {
+	if api.backoff == nil {
+		return nil
+	}
+
+	b, err := api.backoff.GetBackoff(endpoint)
+	if err != nil {
+		slog.Warn("Failed to load backoff state", "endpoint", endpoint, "error", err)
+		return nil
+	}
+	if b != nil && time.Now().Before(b.NextAttempt) {
+		return &BackoffError{Backoff: *b}
+	}
+	return nil
+}
+
+// recordFetchResult updates the persisted backoff of an endpoint after a fetch
+func (api *RedditAPI) recordFetchResult(endpoint string, fetchErr error) {
+	if api.backoff == nil {
+		return
+	}
+
+	if fetchErr == nil {
+		if err := api.backoff.ClearBackoff(endpoint); err != nil {
+			slog.Warn("Failed to clear backoff state", "endpoint", endpoint, "error", err)
+		}
+		return
+	}
+	if !shouldBackOff(fetchErr) {
+		return
+	}
+
+	b, err := api.backoff.GetBackoff(endpoint)
+	if err != nil {
+		slog.Warn("Failed to load backoff state", "endpoint", endpoint, "error", err)
+	}
+	if b == nil {
+		b = &Backoff{Endpoint: endpoint}
+	}
+
+	var retryAfter time.Duration
+	var apiErr *RedditAPIError
+	if errors.As(fetchErr, &apiErr) {
+		retryAfter = apiErr.RetryAfter
+	}
+
+	b.Failures++
+	b.NextAttempt = time.Now().Add(backoffDelay(b.Failures, retryAfter))
+	b.LastError = fetchErr.Error()
+	if err := api.backoff.SaveBackoff(b); err != nil {
+		slog.Warn("Failed to save backoff state", "endpoint", endpoint, "error", err)
+		return
+	}
+	slog.Warn("Backing off Reddit API endpoint", "endpoint", endpoint, "failures", b.Failures, "until", b.NextAttempt)
+}
+
+// GetBackoff returns the backoff state of an endpoint, or nil if it isn't backing off
+func (ogDB *OpenGraphDB) GetBackoff(endpoint string) (*Backoff, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	b := &Backoff{Endpoint: endpoint}
+	var nextAttempt int64
+	err := ogDB.db.QueryRow(`SELECT failures, next_attempt, last_error FROM api_backoff WHERE endpoint = ?`, endpoint).
+		Scan(&b.Failures, &nextAttempt, &b.LastError)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backoff: %w", err)
+	}
+	b.NextAttempt = time.Unix(nextAttempt, 0)
+	return b, nil
+}
+
+// SaveBackoff stores the backoff state of an endpoint
+func (ogDB *OpenGraphDB) SaveBackoff(b *Backoff) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO api_backoff (endpoint, failures, next_attempt, last_error) VALUES (?, ?, ?, ?)`,
+		b.Endpoint, b.Failures, b.NextAttempt.Unix(), b.LastError)
+	if err != nil {
+		return fmt.Errorf("failed to save backoff: %w", err)
+	}
+	return nil
+}
+
+// ClearBackoff resets the backoff of an endpoint after a successful fetch
+func (ogDB *OpenGraphDB) ClearBackoff(endpoint string) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	if _, err := ogDB.db.Exec(`DELETE FROM api_backoff WHERE endpoint = ?`, endpoint); err != nil {
+		return fmt.Errorf("failed to clear backoff: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		failures   int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{1, 0, time.Minute},
+		{2, 0, 2 * time.Minute},
+		{4, 0, 8 * time.Minute},
+		{20, 0, BackoffMax},
+		{1, 10 * time.Minute, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoffDelay(tt.failures, tt.retryAfter); got != tt.want {
+			t.Errorf("backoffDelay(%d, %v) = %v, want %v", tt.failures, tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffPersistsAcrossClients(t *testing.T) {
+	db := newTestDB(t)
+	api := NewRedditAPI(nil)
+	api.SetBackoffStore(db)
+
+	api.recordFetchResult("/best", &RedditAPIError{StatusCode: 503, Kind: ErrRedditServer})
+	api.recordFetchResult("/best", &RedditAPIError{StatusCode: 429, Kind: ErrRedditRateLimited})
+
+	// A new client, as in the next invocation of the binary, sees the backoff
+	restarted := NewRedditAPI(nil)
+	restarted.SetBackoffStore(db)
+
+	err := restarted.checkBackoff("/best")
+	if !errors.Is(err, ErrBackoff) {
+		t.Fatalf("expected ErrBackoff, got %v", err)
+	}
+	var backoffErr *BackoffError
+	if !errors.As(err, &backoffErr) || backoffErr.Failures != 2 {
+		t.Errorf("expected 2 recorded failures, got %v", err)
+	}
+	if until := time.Until(backoffErr.NextAttempt); until < time.Minute || until > 2*time.Minute {
+		t.Errorf("expected about 2 minutes of backoff, got %v", until)
+	}
+
+	if err := restarted.checkBackoff("/top"); err != nil {
+		t.Errorf("expected other endpoints to be unaffected, got %v", err)
+	}
+
+	// Errors retrying can't fix don't back off, success resets
+	restarted.recordFetchResult("/top", &RedditAPIError{StatusCode: 401, Kind: ErrRedditUnauthorized})
+	if err := restarted.checkBackoff("/top"); err != nil {
+		t.Errorf("expected no backoff after an auth error, got %v", err)
+	}
+	restarted.recordFetchResult("/best", nil)
+	if err := restarted.checkBackoff("/best"); err != nil {
+		t.Errorf("expected success to clear the backoff, got %v", err)
+	}
+
+	if !shouldBackOff(fmt.Errorf("failed to make API request: %w", errors.New("connection refused"))) {
+		t.Error("expected network errors to back off")
+	}
+}
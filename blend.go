@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Listings the homepage feed can blend
+const (
+	BlendPopular = "popular" // r/popular, trending posts in the user's region
+	BlendAll     = "all"     // r/all
+)
+
+// BlendSource is a listing blended into the homepage feed
+type BlendSource struct {
+	Source string `json:"source"` // "homepage", "popular", "all", "r/golang", "lemmy:technology@lemmy.world" or "hn:front"
+	Weight int    `json:"weight"` // Posts taken in turn relative to the other sources (default 1)
+	Max    int    `json:"max"`    // Maximum number of posts from the source, 0 for no limit
+}
+
+// weight returns the share of the source, defaulting to 1
+func (s BlendSource) weight() int {
+	return max(s.Weight, 1)
+}
+
+// validateBlend checks the blend config
+func validateBlend(sources []BlendSource) error {
+	seen := make(map[string]bool)
+	for _, s := range sources {
+		if err := validateSource(s.Source); err != nil {
+			return fmt.Errorf("invalid blend: %w", err)
+		}
+		if seen[s.Source] {
+			return fmt.Errorf("blend has %q more than once", s.Source)
+		}
+		seen[s.Source] = true
+		if s.Weight < 0 || s.Max < 0 {
+			return fmt.Errorf("blend weight and max of %q must be >= 0", s.Source)
+		}
+	}
+	return nil
+}
+
+// fetchBlend fetches the sources of the blend config concurrently and blends them. A
+// failing source is left out, the fetch only fails when all of them fail.
+func (p *Pipeline) fetchBlend(ctx context.Context, checkpoint *Checkpoint) ([]RedditPost, error) {
+	sources := make([]Source, len(p.config.Blend))
+	for i, s := range p.config.Blend {
+		sources[i] = p.newSource(s.Source, checkpoint)
+	}
+	listings, err := fetchSources(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := blendPosts(p.config.Blend, listings)
+	slog.Debug("Blended listings", "sources", len(p.config.Blend), "posts", len(posts))
+	return posts, nil
+}
+
+// blendPosts interleaves the listings of sources, taking as many posts from each in
+// turn as its weight, up to its max. Posts in more than one listing are kept where
+// they first appear, and so are links posted to several sites, e.g. an article on
+// both Reddit and Hacker News. The copies left out are counted in the Duplicates of
+// the post kept.
+func blendPosts(sources []BlendSource, listings [][]RedditPost) []RedditPost {
+	type position struct{ queue, index int }
+	seen := make(map[string]position)
+	links := make(map[string]position) // The first post of each link
+	queues := make([][]RedditPost, len(listings))
+	for i, listing := range listings {
+		for _, post := range listing {
+			link := linkKey(post)
+			kept, duplicate := seen[post.Data.Name]
+			if first, ok := links[link]; !duplicate && ok && queues[first.queue][first.index].Data.Origin != post.Data.Origin {
+				kept, duplicate = first, true
+			}
+			if duplicate {
+				queues[kept.queue][kept.index].Data.Duplicates += 1 + post.Data.Duplicates
+				continue
+			}
+			if sources[i].Max > 0 && len(queues[i]) >= sources[i].Max {
+				break
+			}
+			seen[post.Data.Name] = position{i, len(queues[i])}
+			if _, ok := links[link]; !ok && link != "" {
+				links[link] = seen[post.Data.Name]
+			}
+			queues[i] = append(queues[i], post)
+		}
+	}
+
+	var blended []RedditPost
+	for len(blended) < len(seen) {
+		for i, s := range sources {
+			take := min(s.weight(), len(queues[i]))
+			blended = append(blended, queues[i][:take]...)
+			queues[i] = queues[i][take:]
+		}
+	}
+	return blended
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestBlendPosts(t *testing.T) {
+	listing := func(names ...string) []RedditPost {
+		var posts []RedditPost
+		for _, name := range names {
+			posts = append(posts, seenPost(name, 100))
+		}
+		return posts
+	}
+	sources := []BlendSource{
+		{Source: HomepageFeed, Weight: 2},
+		{Source: BlendPopular, Max: 2},
+		{Source: BlendAll},
+	}
+	listings := [][]RedditPost{
+		listing("h1", "h2", "h3", "h4", "h5"),
+		listing("h2", "p1", "p2", "p3"),
+		listing("a1", "p1", "a2"),
+	}
+
+	var got []string
+	for _, post := range blendPosts(sources, listings) {
+		got = append(got, post.Data.Name)
+	}
+	// h2 counts for the homepage, p1 for popular, and popular is capped at 2
+	want := []string{"h1", "h2", "p1", "a1", "h3", "h4", "p2", "a2", "h5"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestValidateBlend(t *testing.T) {
+	tests := []struct {
+		sources []BlendSource
+		wantErr bool
+	}{
+		{[]BlendSource{{Source: HomepageFeed, Weight: 3}, {Source: BlendPopular, Max: 10}}, false},
+		{[]BlendSource{{Source: "golang"}}, true},
+		{[]BlendSource{{Source: BlendAll}, {Source: BlendAll}}, true},
+		{[]BlendSource{{Source: BlendAll, Weight: -1}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateBlend(tt.sources); (err != nil) != tt.wantErr {
+			t.Errorf("validateBlend(%+v) = %v, wantErr %v", tt.sources, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBlendPostsDeduplicatesLinksAcrossSites(t *testing.T) {
+	post := func(name, origin, link string) RedditPost {
+		p := seenPost(name, 100)
+		p.Data.Origin = origin
+		p.Data.URL = link
+		return p
+	}
+	sources := []BlendSource{{Source: "r/programming"}, {Source: "hn:front"}}
+	listings := [][]RedditPost{
+		{post("t3_a", "", "https://example.com/article"), post("t3_b", "", "https://example.com/article")},
+		{post("hn:1", OriginHackerNews, "http://www.example.com/article/#comments"), post("hn:2", OriginHackerNews, "https://example.com/other")},
+	}
+
+	var got []string
+	for _, post := range blendPosts(sources, listings) {
+		got = append(got, post.Data.Name)
+	}
+	// The HN story of the article is dropped, Reddit posts of the same link are kept
+	want := []string{"t3_a", "hn:2", "t3_b"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Dropped copies are counted on the post kept, and as dedup rejections of the run
+	listings = append(listings, []RedditPost{post("t3_a", "", "https://example.com/article")})
+	posts := uniquePosts(append(blendPosts(append(sources, BlendSource{Source: BlendAll}), listings), post("hn:2", OriginHackerNews, "https://example.com/other")))
+	duplicates := make(map[string]int)
+	for _, post := range posts {
+		duplicates[post.Data.Name] = post.Data.Duplicates
+	}
+	if duplicates["t3_a"] != 2 || duplicates["hn:2"] != 1 || duplicates["t3_b"] != 0 {
+		t.Errorf("expected the dropped copies counted on the posts kept, got %v", duplicates)
+	}
+
+	kept := historyPost("t3_c", 100)
+	kept.Data.Duplicates = 3
+	p := &Pipeline{config: &Config{FeedType: "rss"}}
+	opts := DefaultRunOptions()
+	opts.Offline = true
+	result, err := p.build(context.Background(), []RedditPost{kept}, opts, nil)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if result.Rejected[RuleDedup] != 3 {
+		t.Errorf("expected 3 duplicates counted, got %v", result.Rejected)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// knownBots are bot accounts that post threads to many subreddits and don't match
+// the name heuristic
+var knownBots = []string{
+	"AutoModerator",
+	"RepostSleuthBot",
+	"RemindMeBot",
+	"sneakpeekbot",
+	"WikiSummarizerBot",
+	"SaveVideo",
+	"savevideobot",
+	"stabbot",
+	"GifReversingBot",
+	"TweetPoster",
+}
+
+// isBot reports whether an account is a bot: it is in bot_authors or the known list,
+// or its name ends with "bot", unless it is in not_bots. Names are case-insensitive.
+func (p *Pipeline) isBot(author string) bool {
+	match := func(name string) bool { return strings.EqualFold(name, author) }
+	switch {
+	case author == "" || author == "[deleted]" || slices.ContainsFunc(p.config.NotBots, match):
+		return false
+	case slices.ContainsFunc(p.config.BotAuthors, match) || slices.ContainsFunc(knownBots, match):
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(author), "bot")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set at build time with e.g.
+// -ldflags "-X main.Version=1.1.0 -X main.Commit=$(git rev-parse HEAD) -X main.BuildDate=$(date -u +%FT%TZ)".
+// Commit and BuildDate default to the VCS information the Go toolchain embeds.
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// CurrentBuild returns the build metadata of the running binary
+func CurrentBuild() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// String describes the build in one line, e.g. "1.0.0 (commit 0123abc, built 2024-06-01T12:00:00Z)"
+func (b BuildInfo) String() string {
+	var details []string
+	if b.Commit != "" {
+		commit := b.Commit[:min(len(b.Commit), 7)]
+		if b.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, "commit "+commit)
+	}
+	if b.BuildDate != "" {
+		details = append(details, "built "+b.BuildDate)
+	}
+	if len(details) == 0 {
+		return b.Version
+	}
+	return fmt.Sprintf("%s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+// generatorComment marks feeds with the build that generated them
+func generatorComment() string {
+	return fmt.Sprintf("<!-- Generated by red-rss %s -->", CurrentBuild())
+}
+
+// withGeneratorComment adds the generator comment after the XML declaration of a feed
+func withGeneratorComment(content string) string {
+	if rest, ok := strings.CutPrefix(content, `<?xml version="1.0" encoding="UTF-8"?>`); ok {
+		return `<?xml version="1.0" encoding="UTF-8"?>` + generatorComment() + rest
+	}
+	return generatorComment() + content
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		build BuildInfo
+		want  string
+	}{
+		{BuildInfo{Version: "1.0.0"}, "1.0.0"},
+		{BuildInfo{Version: "1.0.0", Commit: "0123456789abcdef", BuildDate: "2024-06-01T12:00:00Z"}, "1.0.0 (commit 0123456, built 2024-06-01T12:00:00Z)"},
+		{BuildInfo{Version: "1.0.0", Commit: "0123456789abcdef", Modified: true}, "1.0.0 (commit 0123456-dirty)"},
+	}
+	for _, tt := range tests {
+		if got := tt.build.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestWithGeneratorComment(t *testing.T) {
+	got := withGeneratorComment(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<rss></rss>")
+	want := `<?xml version="1.0" encoding="UTF-8"?><!-- Generated by red-rss ` + Version
+	if !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "-->\n<rss></rss>") {
+		t.Errorf("expected generator comment after the XML declaration, got %q", got)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Types of cache export records
+const (
+	cacheRecordOpenGraph = "opengraph"
+	cacheRecordPost      = "post"
+	cacheRecordEmitted   = "emitted"   // A post in a generation of a feed, from posts_seen
+	cacheRecordSeeded    = "seeded"    // A post seeded into a feed by a backfill, from seeded_posts
+	cacheRecordDelivered = "delivered" // A post delivered to an item notifier, from item_deliveries
+)
+
+// cacheRecord is a line of a cache export: an OpenGraph cache entry, a stored post
+// with the times it was first and last seen, or a record of a post emitted to a feed,
+// seeded into it or delivered to a notifier at a time
+type cacheRecord struct {
+	Type      string         `json:"type"`
+	OpenGraph *OpenGraphData `json:"opengraph,omitempty"`
+	Post      *RedditPost    `json:"post,omitempty"`
+	FirstSeen int64          `json:"first_seen,omitempty"`
+	LastSeen  int64          `json:"last_seen,omitempty"`
+	Sink      string         `json:"sink,omitempty"`
+	Feed      string         `json:"feed,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	Permalink string         `json:"permalink,omitempty"`
+	Fullname  string         `json:"fullname,omitempty"`
+	At        int64          `json:"at,omitempty"`
+}
+
+// CacheTransfer counts the records of a cache export or import
+type CacheTransfer struct {
+	OpenGraph  int
+	Posts      int
+	Emitted    int
+	Seeded     int
+	Deliveries int
+}
+
+// String summarizes the counts
+func (t CacheTransfer) String() string {
+	return fmt.Sprintf("%d OpenGraph entries, %d posts, %d emitted, %d seeded and %d delivered items",
+		t.OpenGraph, t.Posts, t.Emitted, t.Seeded, t.Deliveries)
+}
+
+// cacheTable is a table of per-feed records in a cache export
+type cacheTable struct {
+	recordType string
+	query      string                                          // Selects the rows to export
+	scan       func(rows *sql.Rows, record *cacheRecord) error // Reads a row into a record
+	count      func(t *CacheTransfer) *int                     // Counter of the records
+	insert     string                                          // Imports a record, keeping stored rows
+	args       func(record cacheRecord) []any                  // Arguments of insert
+}
+
+// cacheTables are the per-feed records of a cache export
+var cacheTables = []cacheTable{
+	{
+		recordType: cacheRecordEmitted,
+		query:      `SELECT feed, permalink, emitted_at FROM posts_seen ORDER BY emitted_at, feed, permalink`,
+		scan:       func(rows *sql.Rows, r *cacheRecord) error { return rows.Scan(&r.Feed, &r.Permalink, &r.At) },
+		count:      func(t *CacheTransfer) *int { return &t.Emitted },
+		insert:     `INSERT OR IGNORE INTO posts_seen (feed, permalink, emitted_at) VALUES (?, ?, ?)`,
+		args:       func(r cacheRecord) []any { return []any{r.Feed, r.Permalink, r.At} },
+	},
+	{
+		recordType: cacheRecordSeeded,
+		query:      `SELECT source, fullname, seeded_at FROM seeded_posts ORDER BY seeded_at, source, fullname`,
+		scan:       func(rows *sql.Rows, r *cacheRecord) error { return rows.Scan(&r.Source, &r.Fullname, &r.At) },
+		count:      func(t *CacheTransfer) *int { return &t.Seeded },
+		insert:     `INSERT OR IGNORE INTO seeded_posts (source, fullname, seeded_at) VALUES (?, ?, ?)`,
+		args:       func(r cacheRecord) []any { return []any{r.Source, r.Fullname, r.At} },
+	},
+	{
+		recordType: cacheRecordDelivered,
+		query:      `SELECT sink, feed, permalink, delivered_at FROM item_deliveries ORDER BY delivered_at, sink, feed, permalink`,
+		scan:       func(rows *sql.Rows, r *cacheRecord) error { return rows.Scan(&r.Sink, &r.Feed, &r.Permalink, &r.At) },
+		count:      func(t *CacheTransfer) *int { return &t.Deliveries },
+		insert:     `INSERT OR IGNORE INTO item_deliveries (sink, feed, permalink, delivered_at) VALUES (?, ?, ?, ?)`,
+		args:       func(r cacheRecord) []any { return []any{r.Sink, r.Feed, r.Permalink, r.At} },
+	},
+}
+
+// runCacheTransfer implements `cache export` and `cache import`, which copy the
+// OpenGraph cache, the stored posts and the records of which posts feeds emitted,
+// seeded and delivered between machines as NDJSON
+func runCacheTransfer(command string, args []string) error {
+	fs := flag.NewFlagSet("cache "+command, flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: red-rss cache %s <file.ndjson | ->", command)
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	name := fs.Arg(0)
+	if command == "import" {
+		in := os.Stdin
+		if name != "-" {
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			in = file
+		}
+		result, err := db.ImportCache(in)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Imported %s\n", result)
+		return nil
+	}
+
+	out := os.Stdout
+	if name != "-" {
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	result, err := db.ExportCache(out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s\n", result)
+	return nil
+}
+
+// ExportCache writes the valid OpenGraph cache entries, the stored posts and the
+// records of emitted, seeded and delivered posts as one JSON record per line.
+// Encrypted posts are written decrypted.
+func (ogDB *OpenGraphDB) ExportCache(w io.Writer) (CacheTransfer, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var result CacheTransfer
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+
+	rows, err := ogDB.db.Query(`SELECT url, title, description, image, site_name, word_count, canonical, fetched_at, expires_at
+			  FROM opengraph_cache WHERE expires_at > datetime('now') ORDER BY url`)
+	if err != nil {
+		return result, fmt.Errorf("failed to export OpenGraph cache: %w", err)
+	}
+	for rows.Next() {
+		var og OpenGraphData
+		if err := rows.Scan(&og.URL, &og.Title, &og.Description, &og.Image, &og.SiteName, &og.WordCount, &og.Canonical, &og.FetchedAt, &og.ExpiresAt); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to export OpenGraph cache: %w", err)
+		}
+		if err := enc.Encode(cacheRecord{Type: cacheRecordOpenGraph, OpenGraph: &og}); err != nil {
+			rows.Close()
+			return result, err
+		}
+		result.OpenGraph++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to export OpenGraph cache: %w", err)
+	}
+
+	rows, err = ogDB.db.Query(`SELECT post, compressed, encrypted, first_seen, last_seen FROM seen_posts ORDER BY first_seen, fullname`)
+	if err != nil {
+		return result, fmt.Errorf("failed to export posts: %w", err)
+	}
+	for rows.Next() {
+		var data []byte
+		var compressed, encrypted bool
+		record := cacheRecord{Type: cacheRecordPost}
+		if err := rows.Scan(&data, &compressed, &encrypted, &record.FirstSeen, &record.LastSeen); err != nil {
+			return result, fmt.Errorf("failed to export posts: %w", err)
+		}
+		if data, err = ogDB.openField(data, encrypted); err != nil {
+			return result, err
+		}
+		if data, err = decompressField(data, compressed); err != nil {
+			return result, err
+		}
+		if err := json.Unmarshal(data, &record.Post); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to decode stored post: %w", err)
+		}
+		if err := enc.Encode(record); err != nil {
+			rows.Close()
+			return result, err
+		}
+		result.Posts++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to export posts: %w", err)
+	}
+
+	for _, table := range cacheTables {
+		if err := ogDB.exportTable(enc, table, table.count(&result)); err != nil {
+			return result, err
+		}
+	}
+
+	return result, out.Flush()
+}
+
+// exportTable writes a record for each row of a table
+func (ogDB *OpenGraphDB) exportTable(enc *json.Encoder, table cacheTable, count *int) error {
+	rows, err := ogDB.db.Query(table.query)
+	if err != nil {
+		return fmt.Errorf("failed to export %s records: %w", table.recordType, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		record := cacheRecord{Type: table.recordType}
+		if err := table.scan(rows, &record); err != nil {
+			return fmt.Errorf("failed to export %s records: %w", table.recordType, err)
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		*count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to export %s records: %w", table.recordType, err)
+	}
+	return nil
+}
+
+// ImportCache adds the records of a cache export. OpenGraph entries that have expired
+// or are already cached are skipped, posts keep the newer of the stored and the
+// imported copy with the earliest first-seen time of both, and emitted, seeded and
+// delivered records already stored are kept.
+func (ogDB *OpenGraphDB) ImportCache(r io.Reader) (CacheTransfer, error) {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	var result CacheTransfer
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; line++ {
+		var record cacheRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return result, fmt.Errorf("record %d: %w", line, err)
+		}
+
+		switch {
+		case record.Type == cacheRecordOpenGraph && record.OpenGraph != nil:
+			imported, err := importOpenGraph(tx, record.OpenGraph)
+			if err != nil {
+				return result, fmt.Errorf("record %d: %w", line, err)
+			}
+			if imported {
+				result.OpenGraph++
+			}
+		case record.Type == cacheRecordPost && record.Post != nil && record.Post.Data.Name != "":
+			if err := ogDB.importPost(tx, record); err != nil {
+				return result, fmt.Errorf("record %d: %w", line, err)
+			}
+			result.Posts++
+		default:
+			imported := false
+			for _, table := range cacheTables {
+				if record.Type != table.recordType {
+					continue
+				}
+				if _, err := tx.Exec(table.insert, table.args(record)...); err != nil {
+					return result, fmt.Errorf("record %d: failed to import %s record: %w", line, record.Type, err)
+				}
+				*table.count(&result)++
+				imported = true
+			}
+			if !imported {
+				return result, fmt.Errorf("record %d: unknown record type %q", line, record.Type)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
+}
+
+// importOpenGraph adds an OpenGraph entry unless it has expired or a valid one is
+// already cached, reporting whether it did
+func importOpenGraph(tx *sql.Tx, og *OpenGraphData) (bool, error) {
+	if og.URL == "" || !og.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM opengraph_cache WHERE url = ? AND expires_at > datetime('now')`, og.URL).Scan(&count)
+	if err != nil || count > 0 {
+		return false, err
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO opengraph_cache
+			  (url, title, description, image, site_name, word_count, canonical, fetched_at, expires_at, version)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+		og.URL, og.Title, og.Description, og.Image, og.SiteName, og.WordCount, og.Canonical, og.FetchedAt, og.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to import OpenGraph entry: %w", err)
+	}
+	return true, nil
+}
+
+// importPost stores an exported post, compressed and encrypted as the database keeps them
+func (ogDB *OpenGraphDB) importPost(tx *sql.Tx, record cacheRecord) error {
+	data, err := json.Marshal(record.Post)
+	if err != nil {
+		return fmt.Errorf("failed to encode post: %w", err)
+	}
+	data, compressed, err := compressField(data)
+	if err != nil {
+		return err
+	}
+	data, encrypted, err := ogDB.sealField(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO seen_posts (fullname, post, compressed, encrypted, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(fullname) DO UPDATE SET
+			post = CASE WHEN excluded.last_seen > last_seen THEN excluded.post ELSE post END,
+			compressed = CASE WHEN excluded.last_seen > last_seen THEN excluded.compressed ELSE compressed END,
+			encrypted = CASE WHEN excluded.last_seen > last_seen THEN excluded.encrypted ELSE encrypted END,
+			first_seen = MIN(first_seen, excluded.first_seen),
+			last_seen = MAX(last_seen, excluded.last_seen)`,
+		record.Post.Data.Name, data, compressed, encrypted, record.FirstSeen, record.LastSeen)
+	if err != nil {
+		return fmt.Errorf("failed to import post: %w", err)
+	}
+	return nil
+}
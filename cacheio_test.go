@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheExportImport(t *testing.T) {
+	src := newTestDB(t)
+	now := time.Now()
+	for _, og := range []*OpenGraphData{
+		{URL: "https://example.com/a", Title: "A", FetchedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{URL: "https://example.com/old", Title: "Old", FetchedAt: now.Add(-48 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	} {
+		if err := src.SaveCachedOpenGraph(og); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.SaveSeenPosts([]RedditPost{seenPost("t3_a", 5), seenPost("t3_b", 7)}); err != nil {
+		t.Fatal(err)
+	}
+	emittedPost := seenPost("t3_a", 5)
+	emittedPost.Data.Permalink = "/r/test/comments/a/"
+	if err := src.SaveEmittedPosts(HomepageFeed, []RedditPost{emittedPost}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveSeeds("r/golang", []RedditPost{seenPost("t3_b", 7)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.MarkDelivered("discord:123", HomepageFeed, "/r/test/comments/a/", now); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	exported, err := src.ExportCache(&buf)
+	if err != nil {
+		t.Fatalf("ExportCache: %v", err)
+	}
+	want := CacheTransfer{OpenGraph: 1, Posts: 2, Emitted: 1, Seeded: 1, Deliveries: 1}
+	if exported != want {
+		t.Errorf("exported %+v", exported)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 6 {
+		t.Errorf("expected 6 lines, got %d", lines)
+	}
+
+	dst := newTestDB(t)
+	// The destination already has a newer copy of t3_b
+	newer := seenPost("t3_b", 100)
+	if err := dst.SaveSeenPosts([]RedditPost{newer}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := dst.ImportCache(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ImportCache: %v", err)
+	}
+	if imported != want {
+		t.Errorf("imported %+v", imported)
+	}
+	if emitted, _ := dst.GetEmittedPosts(HomepageFeed, []string{"/r/test/comments/a/"}); !emitted["/r/test/comments/a/"] {
+		t.Error("expected the emitted post to be imported")
+	}
+	if seeds, _ := dst.GetSeeds("r/golang", now.Add(-time.Hour)); len(seeds) != 1 {
+		t.Errorf("expected the seeded post to be imported, got %v", seeds)
+	}
+	if delivered, _ := dst.IsDelivered("discord:123", HomepageFeed, "/r/test/comments/a/"); !delivered {
+		t.Error("expected the delivered item to be imported")
+	}
+
+	if og, _ := dst.GetCachedOpenGraph("https://example.com/a"); og == nil || og.Title != "A" {
+		t.Errorf("OpenGraph entry not imported: %+v", og)
+	}
+	posts, err := dst.GetSeenPosts([]string{"t3_a", "t3_b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts["t3_a"].Data.Score != 5 || posts["t3_b"].Data.Score != 100 {
+		t.Errorf("unexpected posts after import: %+v", posts)
+	}
+
+	// Importing again changes nothing
+	if again, err := dst.ImportCache(bytes.NewReader(buf.Bytes())); err != nil || again.OpenGraph != 0 {
+		t.Errorf("second import = %+v, %v", again, err)
+	}
+
+	if _, err := dst.ImportCache(strings.NewReader(`{"type":"bogus"}`)); err == nil {
+		t.Error("expected an unknown record type to be rejected")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ampCacheHosts serve copies of AMP pages under /c/s/<host>/<path>
+var ampCacheHosts = []string{".cdn.ampproject.org", ".ampproject.net"}
+
+// ampQueryKeys are query parameters that select the AMP version of a page
+var ampQueryKeys = map[string]string{"amp": "", "outputType": "amp", "output": "amp"}
+
+// canonicalURL rewrites AMP and mobile URLs to the desktop URL of the page. URLs it
+// doesn't recognize are returned unchanged.
+func canonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return raw
+	}
+
+	// AMP caches embed the original URL in their path
+	if host := u.Hostname(); (host == "www.google.com" || host == "google.com") && strings.HasPrefix(u.Path, "/amp/") {
+		return canonicalURL(unwrapAMPCache(strings.TrimPrefix(u.Path, "/amp"), u.RawQuery))
+	}
+	if slices.ContainsFunc(ampCacheHosts, func(suffix string) bool { return strings.HasSuffix(u.Hostname(), suffix) }) {
+		if path, ok := strings.CutPrefix(u.Path, "/c"); ok {
+			return canonicalURL(unwrapAMPCache(path, u.RawQuery))
+		}
+		if path, ok := strings.CutPrefix(u.Path, "/v"); ok {
+			return canonicalURL(unwrapAMPCache(path, u.RawQuery))
+		}
+		return raw
+	}
+
+	// amp.example.com, m.example.com and en.m.wikipedia.org
+	labels := strings.Split(u.Hostname(), ".")
+	for i := 0; i < len(labels)-2; i++ {
+		if labels[i] == "amp" || labels[i] == "m" || labels[i] == "mobile" {
+			labels = slices.Delete(labels, i, i+1)
+			break
+		}
+	}
+	host := strings.Join(labels, ".")
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	// /amp/ path segments, trailing /amp and article.amp.html
+	segments := strings.Split(u.EscapedPath(), "/")
+	segments = slices.DeleteFunc(segments, func(s string) bool { return s == "amp" })
+	for i, s := range segments {
+		segments[i] = strings.TrimSuffix(strings.Replace(s, ".amp.", ".", 1), ".amp")
+	}
+	if path := strings.Join(segments, "/"); path != u.EscapedPath() {
+		if path == "" {
+			path = "/"
+		}
+		u.Path, _ = url.PathUnescape(path)
+		u.RawPath = path
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key, value := range ampQueryKeys {
+			if query.Has(key) && (value == "" || query.Get(key) == value) {
+				query.Del(key)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// unwrapAMPCache returns the original URL of an AMP cache path such as
+// /s/example.com/article, where /s marks https
+func unwrapAMPCache(path, query string) string {
+	scheme := "http://"
+	if rest, ok := strings.CutPrefix(path, "/s/"); ok {
+		scheme, path = "https://", "/"+rest
+	}
+	raw := scheme + strings.TrimPrefix(path, "/")
+	if query != "" {
+		raw += "?" + query
+	}
+	return raw
+}
+
+// resolveCanonical returns the canonical link of a page as an absolute URL, or "" when
+// it is missing or unusable. Links to the front page of a site are ignored for other
+// pages, as some sites point every page there.
+func resolveCanonical(pageURL, href string) string {
+	page, err := url.Parse(pageURL)
+	if err != nil || href == "" {
+		return ""
+	}
+	canonical, err := page.Parse(strings.TrimSpace(href))
+	if err != nil || (canonical.Scheme != "http" && canonical.Scheme != "https") || canonical.Host == "" {
+		return ""
+	}
+	if strings.Trim(canonical.Path, "/") == "" && strings.Trim(page.Path, "/") != "" {
+		return ""
+	}
+	canonical.Fragment = ""
+	return canonical.String()
+}
+
+// canonicalizeLinks rewrites the AMP and mobile links of posts before their pages
+// are fetched, when canonical_urls is set
+func (p *Pipeline) canonicalizeLinks(posts []RedditPost) []RedditPost {
+	if !p.config.CanonicalURLs {
+		return posts
+	}
+	for i, post := range posts {
+		if post.Data.IsSelf || p.config.redditDomains().Matches(post.Data.URL) {
+			continue
+		}
+		if canonical := canonicalURL(post.Data.URL); canonical != post.Data.URL {
+			slog.Debug("Canonicalized link", "post", post.Data.Name, "from", post.Data.URL, "to", canonical)
+			posts[i].Data.URL = canonical
+		}
+	}
+	return posts
+}
+
+// applyCanonicalLinks replaces the links of posts with the canonical links of the
+// fetched pages, moving their OpenGraph data along
+func (p *Pipeline) applyCanonicalLinks(posts []RedditPost, ogData map[string]*OpenGraphData) []RedditPost {
+	if !p.config.CanonicalURLs {
+		return posts
+	}
+	for i, post := range posts {
+		og := ogData[post.Data.URL]
+		if og == nil || og.Canonical == "" || post.Data.IsSelf || p.config.redditDomains().Matches(og.Canonical) {
+			continue
+		}
+		canonical := canonicalURL(og.Canonical)
+		if canonical == post.Data.URL {
+			continue
+		}
+		slog.Debug("Using canonical link", "post", post.Data.Name, "from", post.Data.URL, "to", canonical)
+		ogData[canonical] = og
+		posts[i].Data.URL = canonical
+	}
+	return posts
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://www.google.com/amp/s/example.com/news/story.html":      "https://example.com/news/story.html",
+		"https://example-com.cdn.ampproject.org/c/s/example.com/a/amp/": "https://example.com/a/",
+		"https://example-com.cdn.ampproject.org/v/example.com/a?amp=1":  "http://example.com/a",
+		"https://amp.theguardian.com/world/2024/story":                  "https://theguardian.com/world/2024/story",
+		"https://m.youtube.com/watch?v=abc":                             "https://youtube.com/watch?v=abc",
+		"https://en.m.wikipedia.org/wiki/Go_(programming_language)":     "https://en.wikipedia.org/wiki/Go_(programming_language)",
+		"https://www.bbc.com/news/amp/world-123":                        "https://www.bbc.com/news/world-123",
+		"https://example.com/2024/article.amp.html":                     "https://example.com/2024/article.html",
+		"https://example.com/story?outputType=amp&id=7":                 "https://example.com/story?id=7",
+		"https://example.com/story?output=json":                         "https://example.com/story?output=json",
+		"https://m.com/page":                                            "https://m.com/page",
+		"https://example.com/examples/ampersand":                        "https://example.com/examples/ampersand",
+		"not a url":                                                     "not a url",
+	} {
+		if got := canonicalURL(raw); got != want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestResolveCanonical(t *testing.T) {
+	page := "https://example.com/news/amp/story"
+	for href, want := range map[string]string{
+		"/news/story#top":            "https://example.com/news/story",
+		"https://example.com/news/x": "https://example.com/news/x",
+		"https://example.com/":       "",
+		"javascript:void(0)":         "",
+		"":                           "",
+	} {
+		if got := resolveCanonical(page, href); got != want {
+			t.Errorf("resolveCanonical(%q) = %q, want %q", href, got, want)
+		}
+	}
+}
+
+func TestCanonicalLinks(t *testing.T) {
+	og, err := NewOpenGraphFetcher(nil).parseOpenGraphTags(`<html><head>
+		<link rel="Canonical" href="https://example.com/story"><title>Story</title>
+		</head></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if og.Canonical != "https://example.com/story" {
+		t.Fatalf("expected the canonical link, got %q", og.Canonical)
+	}
+
+	post := seenPost("t3_a", 1)
+	post.Data.URL = "https://m.example.com/story?amp"
+	self := seenPost("t3_self", 1)
+	self.Data.IsSelf = true
+	self.Data.URL = "https://www.reddit.com/r/test/comments/self/"
+
+	p := NewPipeline(&Config{CanonicalURLs: true}, nil, nil)
+	posts := p.canonicalizeLinks([]RedditPost{post, self})
+	if posts[0].Data.URL != "https://example.com/story" {
+		t.Errorf("expected the desktop link, got %q", posts[0].Data.URL)
+	}
+
+	posts[0].Data.URL = "https://example.com/story-short"
+	ogData := map[string]*OpenGraphData{"https://example.com/story-short": og}
+	posts = p.applyCanonicalLinks(posts, ogData)
+	if posts[0].Data.URL != "https://example.com/story" || ogData["https://example.com/story"] != og {
+		t.Errorf("expected the canonical link with its OpenGraph data, got %q", posts[0].Data.URL)
+	}
+	if posts[1].Data.URL != self.Data.URL {
+		t.Errorf("expected self posts to keep their link, got %q", posts[1].Data.URL)
+	}
+}
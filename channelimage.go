@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Decoders of the image formats whose size can be checked
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits of the images used as feed images
+const (
+	MinChannelImageSize     = 100  // Smallest width and height, smaller images are icons or tracking pixels
+	MaxChannelImageSize     = 8192 // Largest width and height
+	MaxChannelImageAspect   = 3.0  // Widest ratio of the longer side to the shorter one
+	MaxChannelImageProbes   = 3    // Top posts whose image is checked per run
+	imageSizeTTL            = 7 * 24 * time.Hour
+	maxImageSizeProbeLength = 256 * 1024 // Bytes read to find the dimensions of an image
+)
+
+// channelImage returns the og:image of the top post of the feed, the one with the
+// highest score whose image passes the size checks, or nil
+func (p *Pipeline) channelImage(ctx context.Context, posts []RedditPost, ogData map[string]*OpenGraphData, ogFetcher *OpenGraphFetcher) *ImageSource {
+	if !p.config.ChannelImage {
+		return nil
+	}
+
+	ranked := make([]RedditPost, len(posts))
+	copy(ranked, posts)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Data.Score > ranked[j].Data.Score })
+
+	probes := 0
+	for _, post := range ranked {
+		og := ogData[previewLink(post)]
+		if og == nil || og.Image == "" || !isValidURL(og.Image) {
+			continue
+		}
+		if probes++; probes > MaxChannelImageProbes {
+			break
+		}
+		image, err := ogFetcher.ImageSize(ctx, og.Image)
+		if err != nil {
+			slog.Debug("Failed to check feed image", "url", og.Image, "error", err)
+			continue
+		}
+		if validChannelImage(image) {
+			return image
+		}
+	}
+	return nil
+}
+
+// validChannelImage reports whether an image has a usable size for a feed image
+func validChannelImage(image *ImageSource) bool {
+	if image == nil || image.Width < MinChannelImageSize || image.Height < MinChannelImageSize {
+		return false
+	}
+	if image.Width > MaxChannelImageSize || image.Height > MaxChannelImageSize {
+		return false
+	}
+	long, short := max(image.Width, image.Height), min(image.Width, image.Height)
+	return float64(long)/float64(short) <= MaxChannelImageAspect
+}
+
+// ImageSize returns the dimensions of an image, from the cache database when it was
+// checked recently. Only the start of the image is downloaded. Images that can't be
+// decoded are cached with a zero size.
+func (ogf *OpenGraphFetcher) ImageSize(ctx context.Context, url string) (*ImageSource, error) {
+	if ogf.db != nil {
+		if cached, err := ogf.db.GetImageSize(url); err != nil {
+			slog.Warn("Failed to load cached image size", "url", url, "error", err)
+		} else if cached != nil {
+			return cached, nil
+		}
+	}
+	if ogf.cacheOnly || ogf.blocked.Matches(url) {
+		return nil, fmt.Errorf("not fetching image %s", url)
+	}
+
+	image, err := probeImageSize(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if ogf.db != nil {
+		if err := ogf.db.SaveImageSize(image, time.Now()); err != nil {
+			slog.Warn("Failed to cache image size", "url", url, "error", err)
+		}
+	}
+	return image, nil
+}
+
+// probeImageSize downloads the start of an image and decodes its dimensions
+func probeImageSize(ctx context.Context, url string) (*ImageSource, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GoRedditFeedGenerator/1.0)")
+	req.Header.Set("Accept", "image/png,image/jpeg,image/gif;q=0.9,*/*;q=0.5")
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	size := &ImageSource{URL: url}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return size, nil
+	}
+	if config, _, err := image.DecodeConfig(io.LimitReader(resp.Body, maxImageSizeProbeLength)); err == nil {
+		size.Width, size.Height = config.Width, config.Height
+	}
+	return size, nil
+}
+
+// GetImageSize returns the cached dimensions of an image, or nil when it wasn't
+// checked recently
+func (ogDB *OpenGraphDB) GetImageSize(url string) (*ImageSource, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	image := &ImageSource{URL: url}
+	var checkedAt int64
+	err := ogDB.db.QueryRow(`SELECT width, height, checked_at FROM image_sizes WHERE url = ?`, url).Scan(&image.Width, &image.Height, &checkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image size: %w", err)
+	}
+	if time.Since(time.Unix(checkedAt, 0)) > imageSizeTTL {
+		return nil, nil
+	}
+	return image, nil
+}
+
+// SaveImageSize caches the dimensions of an image
+func (ogDB *OpenGraphDB) SaveImageSize(image *ImageSource, at time.Time) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO image_sizes (url, width, height, checked_at) VALUES (?, ?, ?, ?)`,
+		image.URL, image.Width, image.Height, at.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save image size: %w", err)
+	}
+	return nil
+}
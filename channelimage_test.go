@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChannelImage(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		sizes := map[string]image.Rectangle{
+			"/tiny.png":   image.Rect(0, 0, 1, 1),
+			"/banner.png": image.Rect(0, 0, 1200, 100),
+			"/photo.png":  image.Rect(0, 0, 400, 300),
+		}
+		size, ok := sizes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(size)); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	post := func(name, link string, score int) RedditPost {
+		p := historyPost(name, score)
+		p.Data.URL = link
+		return p
+	}
+	posts := []RedditPost{
+		post("t3_photo", "https://example.com/photo", 50),
+		post("t3_tiny", "https://example.com/tiny", 300),
+		post("t3_banner", "https://example.com/banner", 200),
+		post("t3_none", "https://example.com/none", 1000),
+	}
+	ogData := map[string]*OpenGraphData{
+		"https://example.com/photo":  {Image: server.URL + "/photo.png"},
+		"https://example.com/tiny":   {Image: server.URL + "/tiny.png"},
+		"https://example.com/banner": {Image: server.URL + "/banner.png"},
+	}
+
+	db := newTestDB(t)
+	fetcher := NewOpenGraphFetcher(db)
+	p := &Pipeline{config: &Config{ChannelImage: true}}
+
+	// The tiny image and the wide banner of the higher scored posts are skipped
+	image := p.channelImage(context.Background(), posts, ogData, fetcher)
+	if image == nil || image.URL != server.URL+"/photo.png" || image.Width != 400 || image.Height != 300 {
+		t.Fatalf("expected the photo as the feed image, got %+v", image)
+	}
+
+	// Checked sizes come from the cache, also when fetching is off
+	fetches.Store(0)
+	fetcher.SetCacheOnly(true)
+	if again := p.channelImage(context.Background(), posts, ogData, fetcher); again == nil || again.URL != image.URL || fetches.Load() != 0 {
+		t.Errorf("expected the cached feed image without fetches, got %+v after %d fetches", again, fetches.Load())
+	}
+
+	gen := NewFeedGenerator(nil)
+	gen.SetImage(image)
+	for _, enhanced := range []bool{false, true} {
+		content, err := gen.RenderFeed(posts[:1], nil, "atom", enhanced)
+		if err != nil || !strings.Contains(string(content), "<logo>"+image.URL+"</logo>") {
+			t.Errorf("expected the image as the atom logo (enhanced %v), got %v:\n%s", enhanced, err, content)
+		}
+	}
+	content, err := gen.RenderFeed(posts[:1], nil, "rss", false)
+	if err != nil || !strings.Contains(string(content), "<url>"+image.URL+"</url>") {
+		t.Errorf("expected the image as the channel image, got %v:\n%s", err, content)
+	}
+
+	p.config.ChannelImage = false
+	if image := p.channelImage(context.Background(), posts, ogData, fetcher); image != nil {
+		t.Errorf("expected no feed image when disabled, got %+v", image)
+	}
+}
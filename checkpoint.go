@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CheckpointMaxAge is how long an interrupted run can be resumed. Older
+// checkpoints are discarded because their posts no longer reflect Reddit.
+const CheckpointMaxAge = time.Hour
+
+// Checkpoint is the saved progress of an interrupted feed generation
+type Checkpoint struct {
+	Key       string          // Identifies the run, e.g. the tenant and listing
+	Posts     []RedditPost    // Posts fetched so far
+	After     string          // Listing cursor for the next page
+	Pages     int             // Number of listing pages fetched
+	FetchDone bool            // All pages have been fetched
+	Enriched  map[string]bool // URLs whose OpenGraph fetch was already attempted
+	UpdatedAt time.Time
+	Resumed   bool // The posts come from an interrupted run rather than a fresh fetch
+}
+
+// LoadCheckpoint returns the saved progress for key, or nil when there is none or
+// it is too old to resume
+func (ogDB *OpenGraphDB) LoadCheckpoint(key string) (*Checkpoint, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var posts []byte
+	var fetchDone int
+	var updatedAt int64
+	var encrypted bool
+	cp := &Checkpoint{Key: key, Enriched: make(map[string]bool)}
+
+	err := ogDB.db.QueryRow(`SELECT posts, after, pages, fetch_done, updated_at, encrypted FROM run_checkpoints WHERE key = ?`, key).
+		Scan(&posts, &cp.After, &cp.Pages, &fetchDone, &updatedAt, &encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	cp.FetchDone = fetchDone != 0
+	cp.UpdatedAt = time.Unix(updatedAt, 0)
+	if time.Since(cp.UpdatedAt) > CheckpointMaxAge {
+		return nil, nil
+	}
+
+	if posts, err = ogDB.openField(posts, encrypted); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(posts, &cp.Posts); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint posts: %w", err)
+	}
+
+	rows, err := ogDB.db.Query(`SELECT url FROM checkpoint_urls WHERE key = ?`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint URLs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint URL: %w", err)
+		}
+		cp.Enriched[url] = true
+	}
+
+	return cp, rows.Err()
+}
+
+// SaveCheckpoint stores the fetch progress of a run
+func (ogDB *OpenGraphDB) SaveCheckpoint(cp *Checkpoint) error {
+	posts, err := json.Marshal(cp.Posts)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint posts: %w", err)
+	}
+
+	fetchDone := 0
+	if cp.FetchDone {
+		fetchDone = 1
+	}
+
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	posts, encrypted, err := ogDB.sealField(posts)
+	if err != nil {
+		return err
+	}
+	_, err = ogDB.db.Exec(`INSERT OR REPLACE INTO run_checkpoints (key, posts, after, pages, fetch_done, updated_at, encrypted)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, cp.Key, posts, cp.After, cp.Pages, fetchDone, time.Now().Unix(), encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// MarkCheckpointURL records that the OpenGraph fetch for url was attempted in the run
+func (ogDB *OpenGraphDB) MarkCheckpointURL(key, url string) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	if _, err := ogDB.db.Exec(`INSERT OR IGNORE INTO checkpoint_urls (key, url) VALUES (?, ?)`, key, url); err != nil {
+		return fmt.Errorf("failed to save checkpoint URL: %w", err)
+	}
+	return nil
+}
+
+// ClearCheckpoint removes the progress of a run once it has completed
+func (ogDB *OpenGraphDB) ClearCheckpoint(key string) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	if _, err := ogDB.db.Exec(`DELETE FROM run_checkpoints WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+	if _, err := ogDB.db.Exec(`DELETE FROM checkpoint_urls WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to clear checkpoint URLs: %w", err)
+	}
+	return nil
+}
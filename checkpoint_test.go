@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+
+	var post RedditPost
+	post.Data.Title = "Resumable"
+	post.Data.URL = "https://example.com/a"
+
+	cp := &Checkpoint{Key: "default:best", Posts: []RedditPost{post}, Pages: 1, FetchDone: true}
+	if err := db.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint failed: %v", err)
+	}
+	if err := db.MarkCheckpointURL(cp.Key, "https://example.com/a"); err != nil {
+		t.Fatalf("MarkCheckpointURL failed: %v", err)
+	}
+
+	loaded, err := db.LoadCheckpoint(cp.Key)
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if !loaded.FetchDone || loaded.Pages != 1 || len(loaded.Posts) != 1 || loaded.Posts[0].Data.Title != "Resumable" {
+		t.Errorf("unexpected checkpoint: %+v", loaded)
+	}
+	if !loaded.Enriched["https://example.com/a"] {
+		t.Errorf("expected attempted URL to be recorded")
+	}
+
+	if err := db.ClearCheckpoint(cp.Key); err != nil {
+		t.Fatalf("ClearCheckpoint failed: %v", err)
+	}
+	if loaded, _ := db.LoadCheckpoint(cp.Key); loaded != nil {
+		t.Errorf("expected no checkpoint after clearing")
+	}
+}
+
+func TestCheckpointExpires(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SaveCheckpoint(&Checkpoint{Key: "old", FetchDone: true}); err != nil {
+		t.Fatalf("SaveCheckpoint failed: %v", err)
+	}
+	stale := time.Now().Add(-2 * CheckpointMaxAge).Unix()
+	if _, err := db.db.Exec(`UPDATE run_checkpoints SET updated_at = ? WHERE key = ?`, stale, "old"); err != nil {
+		t.Fatalf("failed to age checkpoint: %v", err)
+	}
+
+	loaded, err := db.LoadCheckpoint("old")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if loaded != nil {
+		t.Errorf("expected stale checkpoint to be ignored")
+	}
+}
+
+func TestFailedRunClearsCheckpoint(t *testing.T) {
+	db := newTestDB(t)
+	opts := DefaultRunOptions()
+	opts.Offline = true
+	saveCheckpoint := func() {
+		cp := &Checkpoint{Key: runKey(opts), Posts: []RedditPost{historyPost("t3_a", 100)}, Pages: 1, FetchDone: true}
+		if err := db.SaveCheckpoint(cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An interrupted run resumes from the checkpoint
+	saveCheckpoint()
+	p := NewPipeline(&Config{FeedType: "rss"}, nil, db)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Generate(ctx, opts); err == nil {
+		t.Fatal("expected the interrupted run to fail")
+	}
+	if cp, _ := db.LoadCheckpoint(runKey(opts)); cp == nil {
+		t.Error("expected the checkpoint of an interrupted run to be kept")
+	}
+
+	// A run failing otherwise would fail the same way on the checkpoint
+	p.config.FeedType = "html"
+	if _, err := p.Generate(context.Background(), opts); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if cp, _ := db.LoadCheckpoint(runKey(opts)); cp != nil {
+		t.Error("expected the checkpoint of a failed run to be cleared")
+	}
+}
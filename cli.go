@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigOverrides are key=value assignments from -set that override the config for a
+// single run. They are never saved to the config file.
+var ConfigOverrides configAssignments
+
+// configAssignments collects repeated key=value flags
+type configAssignments []string
+
+func (a *configAssignments) String() string {
+	return strings.Join(*a, ",")
+}
+
+func (a *configAssignments) Set(value string) error {
+	if key, _, ok := strings.Cut(value, "="); !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*a = append(*a, value)
+	return nil
+}
+
+// configValue converts a value from the command line to JSON for the config key.
+// Values that aren't valid JSON, and any value of a string key, are strings.
+func configValue(field reflect.StructField, value string) json.RawMessage {
+	if field.Type.Kind() == reflect.String || !json.Valid([]byte(value)) {
+		quoted, _ := json.Marshal(value)
+		return quoted
+	}
+	return json.RawMessage(value)
+}
+
+// setConfigKeys sets keys of a JSON config object to command line values
+func setConfigKeys(data []byte, assignments []string) ([]byte, error) {
+	keys := make(map[string]json.RawMessage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := configFields()
+	for _, assignment := range assignments {
+		key, value, _ := strings.Cut(assignment, "=")
+		field, ok := fields[key]
+		if !ok {
+			msg := fmt.Sprintf("unknown key %q", key)
+			if suggestion := closestConfigKey(key, fields); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			return nil, fmt.Errorf("%s", msg)
+		}
+		keys[key] = configValue(field, value)
+	}
+	return json.MarshalIndent(keys, "", "  ")
+}
+
+// applyConfigOverrides sets the assigned keys of config and validates the result
+func applyConfigOverrides(config *Config, assignments []string) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if data, err = setConfigKeys(data, assignments); err != nil {
+		return err
+	}
+
+	var overridden Config
+	if err := decodeConfig(data, &overridden); err != nil {
+		return err
+	}
+	if err := validateConfig(&overridden); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	*config = overridden
+	return nil
+}
+
+// runAuth implements the auth subcommand
+func runAuth(args []string) error {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file, or a comma-separated list merged in order")
+	headless := fs.Bool("headless", false, "print the authorization URL and read the redirect from stdin instead of opening a browser")
+	force := fs.Bool("force", false, "authorize again even if the saved tokens still work")
+	fs.Parse(args)
+
+	ConfigPath = *configPath
+	HeadlessAuth = *headless
+	InitializeDefaultConfig()
+	if err := loadConfigFromFile(); err != nil {
+		return err
+	}
+	InitializeOAuth2Config()
+
+	if *force {
+		GlobalConfig.AccessToken = ""
+		GlobalConfig.RefreshToken = ""
+		GlobalConfig.ExpiresAt = time.Time{}
+	}
+	if err := handleAuthentication(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Authenticated, access token valid until %s\n", Token.Expiry.Format(time.RFC3339))
+	return nil
+}
+
+// runCache implements the cache subcommand
+func runCache(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "warm":
+			return runCacheWarm(args[1:])
+		case "export", "import":
+			return runCacheTransfer(args[0], args[1:])
+		}
+	}
+	if len(args) == 0 || (args[0] != "stats" && args[0] != "vacuum") {
+		return fmt.Errorf("usage: red-rss cache stats | vacuum | warm <urls.txt> | export <file.ndjson> | import <file.ndjson>")
+	}
+
+	db, err := InitOpenGraphDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if args[0] == "vacuum" {
+		if err := db.CleanupExpiredEntries(); err != nil {
+			return err
+		}
+		return db.VacuumDatabase()
+	}
+
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return err
+	}
+	size, err := db.GetDatabaseSize()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database:         %s (%d KB)\n", CacheDBPath, size/1024)
+	fmt.Printf("OpenGraph entries: %d (%d valid, %d expired)\n", stats.TotalEntries, stats.ValidEntries, stats.ExpiredEntries)
+	if stats.OldestEntry != nil && stats.NewestEntry != nil {
+		fmt.Printf("Fetched between:  %s and %s\n", stats.OldestEntry.Format(time.RFC3339), stats.NewestEntry.Format(time.RFC3339))
+	}
+
+	rules, err := db.FilterStatsSince(time.Now().Add(-FilterStatsWindow))
+	if err != nil {
+		return err
+	}
+	if len(rules) > 0 {
+		fmt.Printf("\nPosts rejected by filter rule in the last %d days:\n", int(FilterStatsWindow.Hours()/24))
+		for _, rule := range rules {
+			fmt.Printf("  %-17s %6d in %d runs (%.1f per run)\n", rule.Rule, rule.Rejected, rule.Runs, float64(rule.Rejected)/float64(rule.Runs))
+		}
+	}
+	return nil
+}
+
+// runConfigSet sets keys of a config file, creating it with defaults if needed
+func runConfigSet(args []string) error {
+	fs := flag.NewFlagSet("config set", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	fs.Parse(args)
+
+	pairs := fs.Args()
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return fmt.Errorf("usage: red-rss config set [-config-file file] <key> <value> [<key> <value>...]")
+	}
+	if len(configPaths(*configPath)) != 1 {
+		return fmt.Errorf("config set edits a single file, not %q", *configPath)
+	}
+	var assignments []string
+	for i := 0; i < len(pairs); i += 2 {
+		assignments = append(assignments, pairs[i]+"="+pairs[i+1])
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if os.IsNotExist(err) {
+		data, err = json.Marshal(DefaultConfig())
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if data, err = setConfigKeys(data, assignments); err != nil {
+		return err
+	}
+
+	config := DefaultConfig()
+	if err := decodeConfig(data, &config); err != nil {
+		return err
+	}
+	if err := validateConfig(&config); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if err := os.WriteFile(*configPath, data, 0600); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
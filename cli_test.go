@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfigOverrides(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientID = "abcDEF123_-xyz"
+
+	err := applyConfigOverrides(&config, []string{"score_filter=100", "feed_type=rss", "subreddits=[\"golang\"]", "user_agent=123"})
+	if err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if config.ScoreFilter != 100 || config.FeedType != "rss" || len(config.Subreddits) != 1 || config.UserAgent != "123" {
+		t.Errorf("overrides not applied: %+v", config)
+	}
+	if config.ClientID != "abcDEF123_-xyz" || config.OutputPath != "reddit.xml" {
+		t.Errorf("expected other keys to be kept, got %+v", config)
+	}
+
+	for _, bad := range []string{"score_filtr=1", "score_filter=many", "feed_type=html"} {
+		if err := applyConfigOverrides(&config, []string{bad}); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+	if err := applyConfigOverrides(&config, []string{"score_filtr=1"}); err == nil || !strings.Contains(err.Error(), `did you mean "score_filter"`) {
+		t.Errorf("expected suggestion for typo, got %v", err)
+	}
+}
+
+func TestRunConfigSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	// Without client_id the new file would be invalid
+	if err := runConfigSet([]string{"-config-file", path, "score_filter", "100"}); err == nil {
+		t.Errorf("expected error creating a config without client_id")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no config file after a failed set")
+	}
+
+	if err := runConfigSet([]string{"-config-file", path, "client_id", "abcDEF123_-xyz", "score_filter", "100"}); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if err := runConfigSet([]string{"-config-file", path, "enhanced_atom", "false"}); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(path, &config); err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if config.ClientID != "abcDEF123_-xyz" || config.ScoreFilter != 100 || config.EnhancedAtom {
+		t.Errorf("unexpected config after set: %+v", config)
+	}
+
+	data, _ := os.ReadFile(path)
+	var keys map[string]json.RawMessage
+	json.Unmarshal(data, &keys)
+	if string(keys["output_path"]) != `"reddit.xml"` {
+		t.Errorf("expected defaults in a new config file, got %s", data)
+	}
+}
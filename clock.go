@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SourceDateEpochEnv fixes the timestamps written to feeds, as in reproducible builds
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// Clock returns the current time. Pipelines take one so that tests and reproducible
+// builds can fix the timestamps written to feeds.
+type Clock func() time.Time
+
+// FixedClock returns a clock that always returns t
+func FixedClock(t time.Time) Clock {
+	return func() time.Time { return t }
+}
+
+// defaultClock returns the system clock, or a clock fixed at SOURCE_DATE_EPOCH when
+// it is set
+func defaultClock() Clock {
+	epoch := os.Getenv(SourceDateEpochEnv)
+	if epoch == "" {
+		return time.Now
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		slog.Warn("Ignoring invalid "+SourceDateEpochEnv, "value", epoch, "error", err)
+		return time.Now
+	}
+	return FixedClock(time.Unix(seconds, 0).UTC())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Command is a subcommand invoked as `red-rss <name> [flags]`
+type Command struct {
+	Usage string                    // One-line description shown in help
+	Run   func(args []string) error // Runs the command with the remaining arguments
+}
+
+// commands maps subcommand names to their implementations
+var commands = map[string]Command{
+	"audit":      {Usage: "show recent Reddit API and authentication events", Run: runAudit},
+	"auth":       {Usage: "authorize with Reddit, or again with -force, without generating a feed", Run: runAuth},
+	"ban":        {Usage: "exclude a post from feeds regardless of filters", Run: runBan},
+	"cache":      {Usage: "show cache database statistics (stats), clean it up (vacuum), pre-fetch a URL list (warm urls.txt) or copy it (export/import file.ndjson)", Run: runCache},
+	"compare":    {Usage: "compare the posts two filter files would emit, e.g. -filters a.json -filters b.json", Run: runCompare},
+	"config":     {Usage: "show the config files (show [-effective]), set keys (set score_filter 100), print their JSON Schema (schema) or list the filter presets (presets)", Run: runConfig},
+	"discover":   {Usage: "suggest subreddits that are often filtered out of the feed", Run: runDiscover},
+	"doctor":     {Usage: "check config, database, network, auth, fetching, enrichment and rendering", Run: runDoctor},
+	"history":    {Usage: "delete old fetch history, e.g. history prune -max-age 720h", Run: runHistory},
+	"merge":      {Usage: "merge generated feeds into one, e.g. merge a.xml b.xml -o all.xml", Run: runMerge},
+	"pin":        {Usage: "include a post in feeds regardless of filters", Run: runPin},
+	"regenerate": {Usage: "rebuild the feed from stored history, e.g. -as-of 2024-06-01", Run: runRegenerate},
+	"render":     {Usage: "re-render a feed from stored posts and cached previews without fetching, e.g. render -feed homepage", Run: runRender},
+	"serve":      {Usage: "run as a daemon serving feeds for one or more tenants", Run: runServe},
+	"thresholds": {Usage: "show how many items per day score thresholds would produce", Run: runThresholds},
+}
+
+// runCommand dispatches args to a subcommand, reporting whether one matched.
+// Without a known subcommand the default one-shot feed generation runs.
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	if args[0] == "help" {
+		printCommands()
+		return true, nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+
+	resolveAppPaths()
+	return true, cmd.Run(args[1:])
+}
+
+// printCommands lists the available subcommands
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: red-rss [fetch] [flags] | red-rss <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "fetch", "generate the feed once, the default without a command")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Usage)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultCommentDepth      = 1         // Top-level comments only
+	MaxCommentDepth          = 5         // Deepest reply level fetched
+	DefaultCommentsPerMinute = 60        // Comment fetches per minute, within the Reddit API budget
+	CommentCacheTTL          = time.Hour // How long fetched comments are reused
+	maxCommentReplies        = 2         // Replies shown per comment
+	maxCommentLength         = 500       // Characters of a comment body shown in items
+)
+
+// Comment is a comment shown in a feed item, with the replies shown under it
+type Comment struct {
+	Author  string    `json:"author"`
+	Body    string    `json:"body"`
+	Score   int       `json:"score"`
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// commentThing is a t1 comment as it appears in the comments endpoint. Replies are
+// a listing, or "" for comments without replies.
+type commentThing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Author   string          `json:"author"`
+		Body     string          `json:"body"`
+		Score    int             `json:"score"`
+		Stickied bool            `json:"stickied"`
+		Replies  json.RawMessage `json:"replies"`
+	} `json:"data"`
+}
+
+// commentListing is a listing of comments
+type commentListing struct {
+	Data struct {
+		Children []commentThing `json:"children"`
+	} `json:"data"`
+}
+
+// decodeComments converts comment things into comments, keeping up to limit
+// comments per level and depth levels. Stickied and deleted comments and "load
+// more" stubs are skipped.
+func decodeComments(things []commentThing, limit, depth int) []Comment {
+	var comments []Comment
+	for _, thing := range things {
+		if len(comments) == limit {
+			break
+		}
+		d := thing.Data
+		if thing.Kind != "t1" || d.Stickied || d.Author == "[deleted]" || d.Body == "[removed]" || d.Body == "[deleted]" {
+			continue
+		}
+
+		comment := Comment{Author: d.Author, Body: strings.TrimSpace(d.Body), Score: d.Score}
+		if depth > 1 && len(d.Replies) > 0 && d.Replies[0] == '{' {
+			var replies commentListing
+			if err := json.Unmarshal(d.Replies, &replies); err == nil {
+				comment.Replies = decodeComments(replies.Data.Children, maxCommentReplies, depth-1)
+			}
+		}
+		comments = append(comments, comment)
+	}
+	return comments
+}
+
+// FetchComments fetches the top comments of a post, up to limit per level and depth
+// levels of replies
+func (api *RedditAPI) FetchComments(ctx context.Context, postID string, limit, depth int) ([]Comment, error) {
+	params := url.Values{
+		"sort":     {"top"},
+		"limit":    {strconv.Itoa(limit)},
+		"depth":    {strconv.Itoa(depth)},
+		"raw_json": {"1"},
+	}
+	resp, err := api.get(ctx, "https://oauth.reddit.com/comments/"+postID+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseRedditError(resp)
+	}
+
+	// The response holds the post and its comments as two listings
+	var listings []commentListing
+	if err := json.NewDecoder(resp.Body).Decode(&listings); err != nil {
+		return nil, fmt.Errorf("failed to decode comments: %w", err)
+	}
+	if len(listings) < 2 {
+		return nil, fmt.Errorf("unexpected comments response with %d listings", len(listings))
+	}
+	return decodeComments(listings[1].Data.Children, limit, depth), nil
+}
+
+// commentDepth returns the reply levels of comments shown in items
+func (c *Config) commentDepth() int {
+	if c.CommentDepth > 0 {
+		return c.CommentDepth
+	}
+	return DefaultCommentDepth
+}
+
+// newCommentLimiter paces comment fetches according to the config, so that they
+// don't use up the Reddit API budget of the listing fetches
+func newCommentLimiter(config *Config) *RateLimiter {
+	perMinute := config.CommentsPerMinute
+	if perMinute <= 0 {
+		perMinute = DefaultCommentsPerMinute
+	}
+	return NewRateLimiter(time.Minute / time.Duration(perMinute))
+}
+
+// attachComments adds the top comments to the posts of the feed when top_comments
+// is set. Comments are cached for CommentCacheTTL; offline runs only use the cache.
+// Posts whose comments fail to load are kept without them.
+func (p *Pipeline) attachComments(ctx context.Context, posts []RedditPost, opts RunOptions) []RedditPost {
+	limit := p.config.TopComments
+	if limit <= 0 {
+		return posts
+	}
+	depth := p.config.commentDepth()
+
+	fetched := 0
+	for i, post := range posts {
+		if post.Data.NumComments == 0 || post.Data.Tombstone != "" || !post.Data.onReddit() {
+			continue
+		}
+
+		if p.db != nil {
+			comments, err := p.db.GetCachedComments(post.Data.Name, limit, depth)
+			if err != nil {
+				slog.Warn("Failed to load cached comments", "post", post.Data.Name, "error", err)
+			}
+			if comments != nil {
+				posts[i].Data.Comments = comments
+				continue
+			}
+		}
+		if opts.Offline || ctx.Err() != nil {
+			continue
+		}
+
+		if p.commentLimiter == nil {
+			p.commentLimiter = newCommentLimiter(p.config)
+		}
+		p.commentLimiter.Wait()
+		id := strings.TrimPrefix(post.Data.Name, "t3_")
+		comments, err := p.api.FetchComments(ctx, id, limit, depth)
+		if err != nil {
+			slog.Warn("Failed to fetch comments", "post", post.Data.Name, "error", err)
+			continue
+		}
+		fetched++
+		posts[i].Data.Comments = comments
+
+		if p.db != nil {
+			if err := p.db.SaveCachedComments(post.Data.Name, limit, depth, comments, time.Now()); err != nil {
+				slog.Warn("Failed to cache comments", "post", post.Data.Name, "error", err)
+			}
+		}
+	}
+
+	slog.Debug("Attached top comments", "fetched", fetched)
+	return posts
+}
+
+// commentsText renders comments as plain text for item descriptions, indenting replies
+func commentsText(comments []Comment) string {
+	var text strings.Builder
+	text.WriteString("\n\nTop comments:")
+	var write func(comments []Comment, indent string)
+	write = func(comments []Comment, indent string) {
+		for _, c := range comments {
+			body := strings.Join(strings.Fields(c.Body), " ")
+			text.WriteString(fmt.Sprintf("\n%s- u/%s (%d): %s", indent, c.Author, c.Score, truncateText(body, maxCommentLength)))
+			write(c.Replies, indent+"  ")
+		}
+	}
+	write(comments, "")
+	return text.String()
+}
+
+// commentsHTML renders comments for enhanced Atom content, nesting replies
+func commentsHTML(comments []Comment) string {
+	var b strings.Builder
+	var write func(comments []Comment)
+	write = func(comments []Comment) {
+		for _, c := range comments {
+			b.WriteString(fmt.Sprintf(`<blockquote><p><strong>u/%s</strong> (%d points)</p><p>%s</p>`,
+				escapeXML(c.Author), c.Score, escapeXML(truncateText(c.Body, maxCommentLength))))
+			write(c.Replies)
+			b.WriteString(`</blockquote>`)
+		}
+	}
+	b.WriteString(`<div class="comments"><h3>💬 Top Comments</h3>`)
+	write(comments)
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// GetCachedComments returns the cached comments of a post fetched with the same
+// limit and depth, or nil when there are none or they expired
+func (ogDB *OpenGraphDB) GetCachedComments(fullname string, limit, depth int) ([]Comment, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var data []byte
+	var encrypted bool
+	err := ogDB.db.QueryRow(`SELECT comments, encrypted FROM comment_cache WHERE fullname = ? AND comment_limit = ? AND depth = ? AND fetched_at > ?`,
+		fullname, limit, depth, time.Now().Add(-CommentCacheTTL).Unix()).Scan(&data, &encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cached comments: %w", err)
+	}
+
+	if data, err = ogDB.openField(data, encrypted); err != nil {
+		return nil, err
+	}
+	comments := []Comment{}
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return nil, fmt.Errorf("failed to decode cached comments: %w", err)
+	}
+	return comments, nil
+}
+
+// SaveCachedComments caches the comments of a post
+func (ogDB *OpenGraphDB) SaveCachedComments(fullname string, limit, depth int, comments []Comment, at time.Time) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	data, err := json.Marshal(comments)
+	if err != nil {
+		return err
+	}
+	data, encrypted, err := ogDB.sealField(data)
+	if err != nil {
+		return err
+	}
+	_, err = ogDB.db.Exec(`INSERT OR REPLACE INTO comment_cache (fullname, comment_limit, depth, comments, fetched_at, encrypted) VALUES (?, ?, ?, ?, ?, ?)`,
+		fullname, limit, depth, data, at.Unix(), encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to cache comments: %w", err)
+	}
+	return nil
+}
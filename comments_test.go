@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCommentsResponse = `[
+	{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {"name": "t3_abc"}}]}},
+	{"kind": "Listing", "data": {"children": [
+		{"kind": "t1", "data": {"author": "AutoModerator", "body": "Rules", "score": 1, "stickied": true, "replies": ""}},
+		{"kind": "t1", "data": {"author": "alice", "body": "First <b>comment</b>", "score": 42, "replies": {"kind": "Listing", "data": {"children": [
+			{"kind": "t1", "data": {"author": "bob", "body": "A reply", "score": 7, "replies": ""}},
+			{"kind": "more", "data": {}}
+		]}}}},
+		{"kind": "t1", "data": {"author": "[deleted]", "body": "[deleted]", "score": 3, "replies": ""}},
+		{"kind": "t1", "data": {"author": "carol", "body": "Second", "score": 5, "replies": ""}},
+		{"kind": "t1", "data": {"author": "dave", "body": "Third", "score": 2, "replies": ""}}
+	]}}
+]`
+
+func TestAttachComments(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if req.URL.Path != "/comments/abc" || req.URL.Query().Get("sort") != "top" || req.URL.Query().Get("depth") != "2" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(testCommentsResponse))}, nil
+	})}
+
+	p := NewPipeline(&Config{TopComments: 2, CommentDepth: 2}, client, newTestDB(t))
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+	p.commentLimiter = NewRateLimiter(0)
+
+	post := seenPost("t3_abc", 10)
+	post.Data.NumComments = 5
+	quiet := seenPost("t3_quiet", 1)
+
+	posts := p.attachComments(context.Background(), []RedditPost{post, quiet}, DefaultRunOptions())
+	comments := posts[0].Data.Comments
+	if len(comments) != 2 || comments[0].Author != "alice" || comments[1].Author != "carol" {
+		t.Fatalf("expected the comments of alice and carol, got %+v", comments)
+	}
+	if len(comments[0].Replies) != 1 || comments[0].Replies[0].Author != "bob" {
+		t.Errorf("expected the reply of bob, got %+v", comments[0].Replies)
+	}
+	if posts[1].Data.Comments != nil {
+		t.Errorf("expected no comments for a post without any")
+	}
+
+	// Cached comments are reused, also offline
+	opts := DefaultRunOptions()
+	opts.Offline = true
+	post.Data.Comments = nil
+	posts = p.attachComments(context.Background(), []RedditPost{post}, opts)
+	if len(posts[0].Data.Comments) != 2 || calls.Load() != 1 {
+		t.Errorf("expected cached comments without another request, got %d requests", calls.Load())
+	}
+
+	text := commentsText(comments)
+	if !strings.Contains(text, "\n- u/alice (42): First <b>comment</b>\n  - u/bob (7): A reply") {
+		t.Errorf("unexpected comments text %q", text)
+	}
+	if html := commentsHTML(comments); !strings.Contains(html, "First &lt;b&gt;comment&lt;/b&gt;") {
+		t.Errorf("expected escaped comment bodies, got %q", html)
+	}
+}
+
+func TestCommentCacheExpiry(t *testing.T) {
+	db := newTestDB(t)
+	comments := []Comment{{Author: "alice", Body: "Hi", Score: 1}}
+	if err := db.SaveCachedComments("t3_old", 3, 1, comments, time.Now().Add(-2*CommentCacheTTL)); err != nil {
+		t.Fatal(err)
+	}
+	if cached, err := db.GetCachedComments("t3_old", 3, 1); err != nil || cached != nil {
+		t.Errorf("expected expired comments to be ignored, got %v, %v", cached, err)
+	}
+
+	if err := db.SaveCachedComments("t3_new", 3, 1, comments, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := db.GetCachedComments("t3_new", 5, 1); cached != nil {
+		t.Errorf("expected comments fetched with another limit to be ignored")
+	}
+	if cached, _ := db.GetCachedComments("t3_new", 3, 1); len(cached) != 1 {
+		t.Errorf("expected the cached comments, got %v", cached)
+	}
+}
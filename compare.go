@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// FilterComparison is the difference between the posts two filter configurations emit
+type FilterComparison struct {
+	A, B  []RedditPost // Posts emitted by each configuration
+	OnlyA []RedditPost
+	OnlyB []RedditPost
+	Both  int
+}
+
+// CompareFilters runs the same posts through the filters of two configurations
+func CompareFilters(posts []RedditPost, a, b *Config, db *OpenGraphDB, opts RunOptions) FilterComparison {
+	var c FilterComparison
+	c.A = (&Pipeline{config: a, db: db}).selectPosts(posts, opts, nil)
+	c.B = (&Pipeline{config: b, db: db}).selectPosts(posts, opts, nil)
+
+	inA := make(map[string]bool, len(c.A))
+	for _, post := range c.A {
+		inA[post.Data.Name] = true
+	}
+	inB := make(map[string]bool, len(c.B))
+	for _, post := range c.B {
+		inB[post.Data.Name] = true
+		if !inA[post.Data.Name] {
+			c.OnlyB = append(c.OnlyB, post)
+		}
+	}
+	for _, post := range c.A {
+		if inB[post.Data.Name] {
+			c.Both++
+		} else {
+			c.OnlyA = append(c.OnlyA, post)
+		}
+	}
+
+	return c
+}
+
+// readFilterFile applies the settings of a filter file on top of base. Filter files
+// use the config file format and usually only set score_filter and comment_filter.
+func readFilterFile(path string, base Config) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter file: %w", err)
+	}
+	if err := decodeConfig(data, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse filter file %s: %w", path, err)
+	}
+	return &base, nil
+}
+
+// runCompare implements the compare subcommand
+func runCompare(args []string) error {
+	var filters stringList
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Var(&filters, "filters", "filter file to compare, given exactly twice")
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	asOf := fs.String("as-of", "", "compare against the fetch stored at this date or time (default: the latest)")
+	limit := fs.Int("limit", 30, "maximum number of items to include in the feed")
+	fs.Parse(args)
+
+	if len(filters) != 2 {
+		return fmt.Errorf("expected -filters exactly twice, e.g. -filters a.json -filters b.json")
+	}
+
+	at := time.Now()
+	if *asOf != "" {
+		var err error
+		if at, err = parseAsOf(*asOf); err != nil {
+			return err
+		}
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil {
+		return err
+	}
+	a, err := readFilterFile(filters[0], config)
+	if err != nil {
+		return err
+	}
+	b, err := readFilterFile(filters[1], config)
+	if err != nil {
+		return err
+	}
+
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	posts, err := LoadPostsAsOf(db, HomepageSource, at)
+	if err != nil {
+		return err
+	}
+
+	opts := DefaultRunOptions()
+	opts.Limit = *limit
+	c := CompareFilters(posts, a, b, db, opts)
+
+	fmt.Printf("A: %s emits %d of %d posts\n", filters[0], len(c.A), len(posts))
+	fmt.Printf("B: %s emits %d of %d posts\n", filters[1], len(c.B), len(posts))
+	fmt.Printf("Both emit %d posts\n", c.Both)
+	printComparePosts("Only in A", "-", c.OnlyA)
+	printComparePosts("Only in B", "+", c.OnlyB)
+	return nil
+}
+
+// printComparePosts prints one side of a comparison
+func printComparePosts(heading, marker string, posts []RedditPost) {
+	if len(posts) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", heading)
+	for _, post := range posts {
+		fmt.Printf("%s %6d pts %5d comments  %s: %s\n", marker,
+			post.Data.Score, post.Data.NumComments, post.Data.community(), post.Data.Title)
+	}
+}
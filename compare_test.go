@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareFilters(t *testing.T) {
+	posts := []RedditPost{seenPost("t3_a", 500), seenPost("t3_b", 150), seenPost("t3_c", 50)}
+	posts[1].Data.NumComments = 2
+
+	a := &Config{ScoreFilter: 100}
+	b := &Config{ScoreFilter: 10, CommentFilter: 1}
+
+	c := CompareFilters(posts, a, b, nil, DefaultRunOptions())
+	if len(c.A) != 2 || len(c.B) != 1 || c.Both != 1 {
+		t.Fatalf("unexpected comparison: A=%d B=%d both=%d", len(c.A), len(c.B), c.Both)
+	}
+	if len(c.OnlyA) != 1 || c.OnlyA[0].Data.Name != "t3_a" {
+		t.Errorf("expected only t3_a to be emitted by A alone, got %v", c.OnlyA)
+	}
+	if len(c.OnlyB) != 0 {
+		t.Errorf("expected nothing emitted by B alone, got %v", c.OnlyB)
+	}
+}
+
+func TestReadFilterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strict.json")
+	if err := os.WriteFile(path, []byte(`{"score_filter": 1000}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := Config{ScoreFilter: 50, CommentFilter: 10, FeedType: "atom"}
+	config, err := readFilterFile(path, base)
+	if err != nil {
+		t.Fatalf("readFilterFile failed: %v", err)
+	}
+	if config.ScoreFilter != 1000 || config.CommentFilter != 10 || config.FeedType != "atom" {
+		t.Errorf("expected the filter file on top of the base config, got %+v", config)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// CompressThreshold is the size from which cached fields are stored gzip-compressed
+const CompressThreshold = 1024
+
+// compressField gzips data at or above CompressThreshold, reporting whether the
+// returned bytes are compressed. Data that doesn't shrink, such as bodies that
+// are already compressed, is stored as-is.
+func compressField(data []byte) ([]byte, bool, error) {
+	if len(data) < CompressThreshold {
+		return data, false, nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, false, fmt.Errorf("failed to compress field: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, fmt.Errorf("failed to compress field: %w", err)
+	}
+
+	if buf.Len() >= len(data) {
+		return data, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// decompressField reverses compressField
+func decompressField(data []byte, compressed bool) ([]byte, error) {
+	if !compressed {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress field: %w", err)
+	}
+	defer reader.Close()
+
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress field: %w", err)
+	}
+	return out, nil
+}
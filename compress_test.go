@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCompressField(t *testing.T) {
+	small := []byte("short description")
+	if out, compressed, err := compressField(small); err != nil || compressed || !bytes.Equal(out, small) {
+		t.Errorf("expected small field to be stored as-is")
+	}
+
+	large := bytes.Repeat([]byte("<p>article content</p>"), 200)
+	out, compressed, err := compressField(large)
+	if err != nil || !compressed || len(out) >= len(large) {
+		t.Fatalf("expected large field to be compressed, got %d bytes (compressed=%v, err=%v)", len(out), compressed, err)
+	}
+	restored, err := decompressField(out, compressed)
+	if err != nil || !bytes.Equal(restored, large) {
+		t.Errorf("round trip failed: %v", err)
+	}
+
+	random := make([]byte, 4096)
+	rand.Read(random)
+	if _, compressed, _ := compressField(random); compressed {
+		t.Errorf("expected incompressible field to be stored as-is")
+	}
+}
+
+func TestHTTPCacheBodyCompressed(t *testing.T) {
+	db := newTestDB(t)
+
+	body := bytes.Repeat([]byte("<html>cached page</html>"), 100)
+	entry := &cachedResponse{StatusCode: 200, Header: map[string][]string{}, Body: body}
+	if err := db.saveHTTPCache("https://example.com/", entry); err != nil {
+		t.Fatalf("saveHTTPCache failed: %v", err)
+	}
+
+	var stored []byte
+	if err := db.db.QueryRow(`SELECT body FROM http_cache`).Scan(&stored); err != nil {
+		t.Fatalf("failed to read raw body: %v", err)
+	}
+	if len(stored) >= len(body) {
+		t.Errorf("expected compressed body in the database, got %d bytes", len(stored))
+	}
+
+	loaded, err := db.getHTTPCache("https://example.com/")
+	if err != nil || loaded == nil || !bytes.Equal(loaded.Body, body) {
+		t.Errorf("expected body to round trip, err=%v", err)
+	}
+}
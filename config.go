@@ -2,10 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -34,6 +39,11 @@ func LoadConfig(configURL string) error {
 	return nil
 }
 
+// isRemoteConfig reports whether -config names a URL rather than a config file
+func isRemoteConfig(config string) bool {
+	return strings.HasPrefix(config, "http://") || strings.HasPrefix(config, "https://")
+}
+
 // loadConfigFromURL loads configuration from a remote URL
 func loadConfigFromURL(url string) error {
 	client := &http.Client{
@@ -50,10 +60,18 @@ func loadConfigFromURL(url string) error {
 		return fmt.Errorf("HTTP error fetching config: %s", resp.Status)
 	}
 
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read remote config: %w", err)
+	}
+
 	var remoteConfig Config
-	if err := json.NewDecoder(resp.Body).Decode(&remoteConfig); err != nil {
+	if err := decodeConfig(data, &remoteConfig); err != nil {
 		return fmt.Errorf("failed to decode remote config: %w", err)
 	}
+	if err := applyEnvOverrides(&remoteConfig, os.Environ()); err != nil {
+		return err
+	}
 
 	// Validate required fields
 	if err := validateConfig(&remoteConfig); err != nil {
@@ -64,44 +82,170 @@ func loadConfigFromURL(url string) error {
 	return nil
 }
 
+// ConfigPath is the config file of the main command, or a comma-separated list of
+// files merged in order
+var ConfigPath = ConfigFileName
+
 // loadConfigFromFile loads configuration from local JSON file
 func loadConfigFromFile() error {
-	file, err := os.ReadFile(ConfigFileName)
-	if err != nil {
-		return fmt.Errorf("error reading config file: %w", err)
+	return readConfigFile(ConfigPath, &GlobalConfig)
+}
+
+// configPaths splits a comma-separated list of config files
+func configPaths(path string) []string {
+	var paths []string
+	for _, p := range strings.Split(path, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
 	}
+	return paths
+}
+
+// readConfigFile reads and validates a JSON config file into config. With a
+// comma-separated list of files, each file overrides the keys it sets in the files
+// before it. Lists are replaced rather than appended to. RED_RSS_* environment
+// variables override the files. The merged result is validated, so a base file
+// doesn't need to be valid on its own.
+func readConfigFile(path string, config *Config) error {
+	return readConfigFiles(path, config, os.Environ())
+}
+
+// readConfigFiles is readConfigFile with the environment variables of overrides,
+// none for configs that aren't the user's own, such as those of tenants. A config
+// given entirely by environment variables needs no file.
+func readConfigFiles(path string, config *Config, environ []string) error {
+	for _, p := range configPaths(path) {
+		file, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) && len(envConfigAssignments(environ)) > 0 {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
 
-	if err := json.Unmarshal(file, &GlobalConfig); err != nil {
-		return fmt.Errorf("error unmarshaling config: %w", err)
+		if err := decodeConfig(file, config); err != nil {
+			return fmt.Errorf("error unmarshaling config %s: %w", p, err)
+		}
+	}
+	if paths := configPaths(path); len(paths) > 0 {
+		loadKeyringTokens(paths[len(paths)-1], config)
+	}
+	if err := applyEnvOverrides(config, environ); err != nil {
+		return err
 	}
 
 	// Validate configuration
-	if err := validateConfig(&GlobalConfig); err != nil {
+	if err := validateConfig(config); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
 	}
 
 	return nil
 }
 
-// SaveConfig saves the current configuration to a JSON file
+// SaveConfig saves the current configuration to a JSON file. With -set overrides only
+// the tokens are saved, so the overrides stay out of the file.
 func SaveConfig() error {
-	data, err := json.MarshalIndent(GlobalConfig, "", "  ")
+	save := saveConfigFile
+	if len(ConfigOverrides) > 0 {
+		save = func(path string, config *Config) error {
+			paths := configPaths(path)
+			return saveConfigTokens(paths[len(paths)-1], config)
+		}
+	}
+	if err := save(ConfigPath, &GlobalConfig); err != nil {
+		return err
+	}
+
+	slog.Info("Configuration saved successfully")
+	return nil
+}
+
+// writeConfigFile writes config as JSON with owner-only permissions. Tokens stored in
+// the OS keyring are left out.
+func writeConfigFile(path string, config *Config) error {
+	saveKeyringTokens(path, config)
+	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error marshaling config: %w", err)
 	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
 
-	if err := os.WriteFile(ConfigFileName, data, 0600); err != nil {
+	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("error writing config file: %w", err)
 	}
 
-	slog.Info("Configuration saved successfully")
 	return nil
 }
 
+// saveConfigFile saves config to the file it was read from. With layered config
+// files only the tokens are saved, to the last file, so that the shared files keep
+// only what they set. The same goes for keys set by environment variables.
+func saveConfigFile(path string, config *Config) error {
+	paths := configPaths(path)
+	if len(paths) <= 1 && !config.envOverridden {
+		return writeConfigFile(path, config)
+	}
+	return saveConfigTokens(paths[len(paths)-1], config)
+}
+
+// saveConfigTokens saves only the tokens of config to the file at path, keeping the
+// other keys of the file. Tokens stored in the OS keyring are removed from the file.
+func saveConfigTokens(path string, config *Config) error {
+	keys := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return fmt.Errorf("error unmarshaling config %s: %w", path, err)
+		}
+	}
+
+	if saveKeyringTokens(path, config) {
+		delete(keys, "access_token")
+		delete(keys, "refresh_token")
+		delete(keys, "expires_at")
+	} else {
+		tokens, err := json.Marshal(keyringTokens{config.AccessToken, config.RefreshToken, config.ExpiresAt})
+		if err != nil {
+			return fmt.Errorf("error marshaling config: %w", err)
+		}
+		if err := json.Unmarshal(tokens, &keys); err != nil {
+			return fmt.Errorf("error marshaling config: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	return nil
+}
+
+// ErrClientIDRequired is returned for configs without a client_id, which prompts or
+// RED_RSS_CLIENT_ID can still supply
+var ErrClientIDRequired = errors.New("client_id is required")
+
 // validateConfig validates the configuration structure
 func validateConfig(config *Config) error {
 	if config.ClientID == "" {
-		return fmt.Errorf("client_id is required")
+		return ErrClientIDRequired
+	}
+
+	switch config.AppType {
+	case "", AppTypeInstalled:
+	case AppTypeWeb, AppTypeScript:
+		if config.ClientSecret == "" {
+			return fmt.Errorf("client_secret is required for app_type %q", config.AppType)
+		}
+	default:
+		return fmt.Errorf("app_type must be 'installed', 'web' or 'script'")
 	}
 
 	if config.FeedType != "rss" && config.FeedType != "atom" {
@@ -111,6 +255,20 @@ func validateConfig(config *Config) error {
 	if config.OutputPath == "" {
 		return fmt.Errorf("output_path is required")
 	}
+	if err := validateOutputURL(config.OutputPath); err != nil {
+		return err
+	}
+	if err := validateOutputPath(config.OutputPath); err != nil {
+		return err
+	}
+	if config.SFTPHost != "" {
+		if strings.HasPrefix(config.SFTPHost, "-") || strings.ContainsAny(config.SFTPHost, " /") {
+			return fmt.Errorf("sftp_host must be a host such as \"user@example.com\" or \"user@example.com:2222\"")
+		}
+		if outputURL(config.OutputPath) != nil {
+			return fmt.Errorf("sftp_host needs a local output_path to upload")
+		}
+	}
 
 	if config.ScoreFilter < 0 {
 		return fmt.Errorf("score_filter must be >= 0")
@@ -120,14 +278,237 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("comment_filter must be >= 0")
 	}
 
+	switch config.FeedUpdated {
+	case "", UpdatedNewestItem, UpdatedContentChange, UpdatedNow:
+	default:
+		return fmt.Errorf("feed_updated must be 'newest_item', 'content_change' or 'now'")
+	}
+
+	switch config.RemovedPosts {
+	case "", RemovedKeep, RemovedAnnotate, RemovedDrop, RemovedTombstone:
+	default:
+		return fmt.Errorf("removed_posts must be 'keep', 'annotate', 'drop' or 'tombstone'")
+	}
+
+	switch config.DistinguishedPosts {
+	case "", DistinguishedInclude, DistinguishedExclude, DistinguishedOnly:
+	default:
+		return fmt.Errorf("distinguished_posts must be 'include', 'exclude' or 'only'")
+	}
+
+	switch config.PostTypes {
+	case "", PostTypesAll, PostTypesLinks, PostTypesDiscussions:
+	default:
+		return fmt.Errorf("post_types must be 'all', 'links' or 'discussions'")
+	}
+
+	switch config.BotPosts {
+	case "", BotInclude, BotTag, BotExclude:
+	default:
+		return fmt.Errorf("bot_posts must be 'include', 'tag' or 'exclude'")
+	}
+
+	switch config.TitleEmoji {
+	case "", TitleEmojiKeep, TitleEmojiStrip:
+	default:
+		return fmt.Errorf("title_emoji must be 'keep' or 'strip'")
+	}
+
+	switch config.SelfPostLinks {
+	case "", SelfPostLinkIgnore, SelfPostLinkPreview, SelfPostLinkItem:
+	default:
+		return fmt.Errorf("self_post_links must be 'ignore', 'preview' or 'link'")
+	}
+
+	switch config.SubredditSort {
+	case "", SortHot, SortNew, SortTop, SortRising:
+	default:
+		return fmt.Errorf("subreddit_sort must be 'hot', 'new', 'top' or 'rising'")
+	}
+
+	for _, name := range config.Subreddits {
+		if !validSubreddit.MatchString(subredditName(name)) {
+			return fmt.Errorf("subreddits has an invalid subreddit name %q", name)
+		}
+	}
+
+	switch config.Subscriptions {
+	case "", SubscriptionsMerged, SubscriptionsPerSubreddit:
+	default:
+		return fmt.Errorf("subscriptions must be 'merged' or 'per_subreddit'")
+	}
+	if config.MaxSubscriptions < 0 {
+		return fmt.Errorf("max_subscriptions must be >= 0")
+	}
+
+	for feed, expr := range config.Schedules {
+		if !config.hasFeed(feed) {
+			return fmt.Errorf("schedules has a schedule for unknown feed %q, expected %q, %q, %q, %q, %q, %q or one of subreddits or feeds", feed, HomepageFeed, SubscriptionsFeed, FriendsFeed, SavedFeed, UpvotedFeed, RepliesFeed)
+		}
+		if _, err := ParseCron(expr); err != nil {
+			return fmt.Errorf("schedules of %s: %w", feed, err)
+		}
+	}
+
+	if err := validateFeedProfiles(config); err != nil {
+		return err
+	}
+	if err := validateCompositeFeeds(config); err != nil {
+		return err
+	}
+
+	if err := validateTokenStorage(config.TokenStorage); err != nil {
+		return err
+	}
+
+	if err := validateNotifyWebhook(config.NotifyWebhook); err != nil {
+		return err
+	}
+	if err := validateDiscordWebhook(config.DiscordWebhook); err != nil {
+		return err
+	}
+	if (config.TelegramBotToken == "") != (config.TelegramChatID == "") {
+		return fmt.Errorf("telegram_bot_token and telegram_chat_id must be set together")
+	}
+
+	if err := validateBlend(config.Blend); err != nil {
+		return err
+	}
+
+	if _, err := compileTagRules(config.TagRules); err != nil {
+		return err
+	}
+	if _, err := config.filterRules(); err != nil {
+		return err
+	}
+	if _, err := compileFilterRules(config.FilterRules); err != nil {
+		return err
+	}
+
+	if config.HistoryMaxAge != "" {
+		if d, err := time.ParseDuration(config.HistoryMaxAge); err != nil || d < 0 {
+			return fmt.Errorf("history_max_age must be a duration such as \"720h\", or \"0\" to keep history forever")
+		}
+	}
+
+	if config.HistoryMaxPosts < 0 || config.HistoryMaxSizeMB < 0 {
+		return fmt.Errorf("history_max_posts and history_max_size_mb must be >= 0")
+	}
+
+	if config.MinAwards < 0 {
+		return fmt.Errorf("min_awards must be >= 0")
+	}
+	if config.MaxDescriptionLength < 0 {
+		return fmt.Errorf("max_description_length must be >= 0")
+	}
+	if err := validateDomainList("reddit_domains", config.RedditDomains); err != nil {
+		return err
+	}
+	if err := validateDomainList("blocked_domains", config.BlockedDomains); err != nil {
+		return err
+	}
+
+	if config.TopComments < 0 || config.CommentsPerMinute < 0 {
+		return fmt.Errorf("top_comments and comments_per_minute must be >= 0")
+	}
+	if config.CommentDepth < 0 || config.CommentDepth > MaxCommentDepth {
+		return fmt.Errorf("comment_depth must be between 1 and %d", MaxCommentDepth)
+	}
+
+	if config.MinUpvoteRatio < 0 || config.MinUpvoteRatio > 1 {
+		return fmt.Errorf("min_upvote_ratio must be between 0 and 1")
+	}
+
+	if config.MaxPages < 0 || config.MaxPages > MaxListingPages {
+		return fmt.Errorf("max_pages must be between 1 and %d", MaxListingPages)
+	}
+
+	if config.HostDelay != "" {
+		if d, err := time.ParseDuration(config.HostDelay); err != nil || d < 0 {
+			return fmt.Errorf("host_delay must be a duration such as \"1s\", or \"0\" to disable")
+		}
+	}
+
+	if config.EventTimezone != "" {
+		if _, err := time.LoadLocation(config.EventTimezone); err != nil {
+			return fmt.Errorf("event_timezone must be a time zone such as \"Europe/Helsinki\": %w", err)
+		}
+	}
+	if config.EventLeadTime != "" {
+		if d, err := time.ParseDuration(config.EventLeadTime); err != nil || d <= 0 {
+			return fmt.Errorf("event_lead_time must be a positive duration such as \"2h\"")
+		}
+	}
+
+	if config.MatchThreadInterval != "" {
+		if d, err := time.ParseDuration(config.MatchThreadInterval); err != nil || d <= 0 {
+			return fmt.Errorf("match_thread_interval must be a positive duration such as \"2m\"")
+		}
+	}
+
+	if config.Schedule != "" {
+		if _, err := nextScheduledRun(config.Schedule, time.Now()); err != nil {
+			return err
+		}
+	}
+	if config.ScheduleJitter != "" {
+		if d, err := time.ParseDuration(config.ScheduleJitter); err != nil || d < 0 {
+			return fmt.Errorf("schedule_jitter must be a duration such as \"2m\"")
+		}
+	}
+
+	if config.FullFetchInterval != "" {
+		if d, err := time.ParseDuration(config.FullFetchInterval); err != nil || d <= 0 {
+			return fmt.Errorf("full_fetch_interval must be a positive duration such as \"6h\"")
+		}
+	}
+
 	return nil
 }
 
+// hasFeed reports whether the config generates a feed by the name used in schedules:
+// the homepage, one of its subreddits, subscriptions, friends, a collection or a profile
+func (config *Config) hasFeed(feed string) bool {
+	_, isProfile := config.feedProfile(feed)
+	return feed == HomepageFeed || (feed == SubscriptionsFeed && config.Subscriptions != "") || (feed == FriendsFeed && config.FriendsFeed) ||
+		slices.Contains(config.collections(), feed) ||
+		isProfile || slices.ContainsFunc(config.Subreddits, func(name string) bool { return subredditName(name) == feed })
+}
+
+// hostDelay returns the time between starting OpenGraph fetches from the same host
+func (c *Config) hostDelay() time.Duration {
+	if d, err := time.ParseDuration(c.HostDelay); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultHostDelay
+}
+
+// fullFetchInterval returns how often differential mode falls back to a full fetch
+func (c *Config) fullFetchInterval() time.Duration {
+	if d, err := time.ParseDuration(c.FullFetchInterval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultFullFetchInterval
+}
+
+// maxPages returns how many homepage pages are fetched per run
+func (c *Config) maxPages() int {
+	return max(c.MaxPages, 1)
+}
+
 // InitializeDefaultConfig sets up default configuration values
 func InitializeDefaultConfig() {
-	GlobalConfig.ScoreFilter = 0
-	GlobalConfig.CommentFilter = 0
-	GlobalConfig.FeedType = "atom"
-	GlobalConfig.EnhancedAtom = true
-	GlobalConfig.OutputPath = "reddit.xml"
+	GlobalConfig = DefaultConfig()
+}
+
+// DefaultConfig returns a configuration with default values
+func DefaultConfig() Config {
+	return Config{
+		AppType:       AppTypeInstalled,
+		ScoreFilter:   0,
+		CommentFilter: 0,
+		FeedType:      "atom",
+		EnhancedAtom:  true,
+		OutputPath:    "reddit.xml",
+	}
 }
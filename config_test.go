@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	local := filepath.Join(dir, "local.json")
+	baseJSON := `{"client_id": "id", "score_filter": 100, "comment_filter": 10, "bot_authors": ["a", "b"]}`
+	if err := os.WriteFile(base, []byte(baseJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte(`{"score_filter": 500, "output_path": "local.xml", "bot_authors": ["c"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(base+","+local, &config); err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+	if config.ClientID != "id" || config.CommentFilter != 10 || config.FeedType != "atom" {
+		t.Errorf("expected keys from the base file and defaults to remain, got %+v", config)
+	}
+	if config.ScoreFilter != 500 || config.OutputPath != "local.xml" {
+		t.Errorf("expected the later file to override, got %+v", config)
+	}
+	if !slices.Equal(config.BotAuthors, []string{"c"}) {
+		t.Errorf("expected lists to be replaced, got %v", config.BotAuthors)
+	}
+
+	// Tokens are saved to the last file only
+	config.RefreshToken = "refresh"
+	if err := saveConfigFile(base+","+local, &config); err != nil {
+		t.Fatalf("saveConfigFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(base); string(data) != baseJSON {
+		t.Errorf("expected the base file to be untouched, got %s", data)
+	}
+	data, _ := os.ReadFile(local)
+	if !strings.Contains(string(data), `"refresh_token": "refresh"`) || strings.Contains(string(data), "client_id") {
+		t.Errorf("expected only the tokens to be added to the last file, got %s", data)
+	}
+}
+
+func TestEnvConfigOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	fileJSON := `{"client_id": "id", "score_filter": 100, "feed_type": "rss"}`
+	if err := os.WriteFile(path, []byte(fileJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RED_RSS_SCORE_FILTER", "500")
+	t.Setenv("RED_RSS_CLIENT_SECRET", "secret")
+	t.Setenv("RED_RSS_SUBREDDITS", `["golang","rust"]`)
+	t.Setenv("RED_RSS_USERNAME", "not a config key")
+
+	config := DefaultConfig()
+	if err := readConfigFile(path, &config); err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+	if config.ScoreFilter != 500 || config.ClientSecret != "secret" || !slices.Equal(config.Subreddits, []string{"golang", "rust"}) {
+		t.Errorf("expected the environment to override the file, got %+v", config)
+	}
+	if config.ClientID != "id" || config.FeedType != "rss" {
+		t.Errorf("expected keys only in the file to remain, got %+v", config)
+	}
+
+	// -set overrides the environment
+	if err := applyConfigOverrides(&config, []string{"score_filter=50"}); err != nil || config.ScoreFilter != 50 {
+		t.Errorf("expected -set to override the environment, got %d, %v", config.ScoreFilter, err)
+	}
+
+	// Only the tokens are saved, so the environment stays out of the file
+	config.RefreshToken = "refresh"
+	config.envOverridden = true
+	if err := saveConfigFile(path, &config); err != nil {
+		t.Fatalf("saveConfigFile failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"refresh_token": "refresh"`) || strings.Contains(string(data), "secret") || !strings.Contains(string(data), `"score_filter": 100`) {
+		t.Errorf("expected only the tokens to be saved, got %s", data)
+	}
+
+	// Tenant configs ignore the environment
+	tenant := DefaultConfig()
+	if err := readConfigFiles(path, &tenant, nil); err != nil || tenant.ScoreFilter != 100 {
+		t.Errorf("expected the file's score_filter without the environment, got %d, %v", tenant.ScoreFilter, err)
+	}
+
+	// The environment alone is a complete config
+	t.Setenv("RED_RSS_CLIENT_ID", "env-id")
+	envOnly := DefaultConfig()
+	if err := readConfigFile(filepath.Join(t.TempDir(), "missing.json"), &envOnly); err != nil || envOnly.ClientID != "env-id" {
+		t.Errorf("expected a config from the environment alone, got %+v, %v", envOnly, err)
+	}
+
+	t.Setenv("RED_RSS_MIN_AWARDS", "many")
+	if err := readConfigFile(path, &envOnly); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	var config Config
+	err := decodeConfig([]byte(`{"client_id": "id", "scorefilter": 100}`), &config)
+	if err == nil || err.Error() != `unknown key "scorefilter", did you mean "score_filter"?` {
+		t.Errorf("expected a suggestion for the typo, got %v", err)
+	}
+
+	err = decodeConfig([]byte(`{"zzz": 1, "client_id": "id"}`), &config)
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("expected no suggestion for an unrelated key, got %v", err)
+	}
+
+	if err := decodeConfig([]byte(`{"client_id": "id", "score_filter": 100}`), &config); err != nil || config.ScoreFilter != 100 {
+		t.Errorf("expected known keys to load, got %v", err)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+	properties := schema["properties"].(map[string]any)
+	if len(properties) != len(configFields()) {
+		t.Errorf("expected a property for every config key, got %d", len(properties))
+	}
+	if got := properties["score_filter"].(map[string]any)["type"]; got != "integer" {
+		t.Errorf("expected score_filter to be an integer, got %v", got)
+	}
+	if got := properties["feed_type"].(map[string]any)["enum"]; !slices.Equal(got.([]string), []string{"rss", "atom"}) {
+		t.Errorf("unexpected feed_type enum %v", got)
+	}
+	if got := properties["bot_authors"].(map[string]any)["items"]; got.(map[string]any)["type"] != "string" {
+		t.Errorf("expected bot_authors to be a list of strings, got %v", got)
+	}
+
+	// Every enum is a valid config value
+	for key, values := range configEnums {
+		for _, value := range values {
+			config := DefaultConfig()
+			config.ClientID = "id"
+			config.ClientSecret = "secret"
+			data, _ := json.Marshal(map[string]string{key: value})
+			if err := decodeConfig(data, &config); err != nil {
+				t.Fatal(err)
+			}
+			if err := validateConfig(&config); err != nil {
+				t.Errorf("%s %q: %v", key, value, err)
+			}
+		}
+	}
+}
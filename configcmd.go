@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// secretConfigKeys are the config keys config show doesn't print
+var secretConfigKeys = []string{"client_secret", "access_token", "refresh_token", "telegram_bot_token", "discord_webhook"}
+
+// runConfig implements the config subcommand
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: red-rss config show [-effective] [-config-file base.json,local.json] | red-rss config set <key> <value> | red-rss config schema | red-rss config presets")
+	}
+
+	switch args[0] {
+	case "show":
+		return runConfigShow(args[1:])
+	case "set":
+		return runConfigSet(args[1:])
+	case "schema":
+		return runConfigSchema(args[1:])
+	case "presets":
+		return runConfigPresets(args[1:])
+	default:
+		return fmt.Errorf("unknown config command %q", args[0])
+	}
+}
+
+// runConfigShow prints each config file, or with -effective the merged config with
+// defaults as the feed generation sees it
+func runConfigShow(args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file, or a comma-separated list merged in order")
+	effective := fs.Bool("effective", false, "show the merged config including defaults")
+	fs.Parse(args)
+
+	if *effective {
+		config := DefaultConfig()
+		if err := readConfigFile(*configPath, &config); err != nil {
+			return err
+		}
+		data, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		return printConfigKeys(data)
+	}
+
+	for i, path := range configPaths(*configPath) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("# %s\n", path)
+		if err := printConfigKeys(data); err != nil {
+			return fmt.Errorf("error unmarshaling config %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// printConfigKeys prints a JSON config object with its keys sorted and secrets hidden
+func printConfigKeys(data []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	for _, key := range secretConfigKeys {
+		if value, ok := keys[key]; ok && string(value) != `""` {
+			keys[key] = json.RawMessage(`"<hidden>"`)
+		}
+	}
+
+	out, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
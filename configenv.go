@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigEnvPrefix prefixes the environment variables that override config keys, e.g.
+// RED_RSS_SCORE_FILTER for score_filter
+const ConfigEnvPrefix = "RED_RSS_"
+
+// configEnvName returns the environment variable of a config key
+func configEnvName(key string) string {
+	return ConfigEnvPrefix + strings.ToUpper(key)
+}
+
+// envConfigAssignments returns key=value assignments for the config keys set in
+// environ, sorted by key. Variables that don't name a config key, such as
+// RED_RSS_USERNAME, are left alone.
+func envConfigAssignments(environ []string) []string {
+	keys := make(map[string]string)
+	for key := range configFields() {
+		keys[configEnvName(key)] = key
+	}
+
+	var assignments []string
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if key, ok := keys[name]; ok {
+			assignments = append(assignments, key+"="+value)
+		}
+	}
+	sort.Strings(assignments)
+	return assignments
+}
+
+// applyEnvOverrides sets the config keys that environment variables override. Lists
+// and objects are JSON, e.g. RED_RSS_SUBREDDITS='["golang","rust"]'. Overridden
+// configs are never saved whole, so the values stay out of the config file.
+func applyEnvOverrides(config *Config, environ []string) error {
+	assignments := envConfigAssignments(environ)
+	if len(assignments) == 0 {
+		return nil
+	}
+	data, err := setConfigKeys(nil, assignments)
+	if err != nil {
+		return err
+	}
+	if err := decodeConfig(data, config); err != nil {
+		return fmt.Errorf("invalid %s environment variable: %w", ConfigEnvPrefix+"*", err)
+	}
+	config.envOverridden = true
+	return nil
+}
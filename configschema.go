@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configEnums are the values accepted by config keys with a fixed set of values.
+// validateConfig enforces the same sets.
+var configEnums = map[string][]string{
+	"app_type":            {AppTypeInstalled, AppTypeWeb, AppTypeScript},
+	"feed_type":           {"rss", "atom"},
+	"feed_updated":        {UpdatedNewestItem, UpdatedContentChange, UpdatedNow},
+	"removed_posts":       {RemovedKeep, RemovedAnnotate, RemovedDrop, RemovedTombstone},
+	"token_storage":       {TokenStorageFile, TokenStorageKeyring},
+	"self_post_links":     {SelfPostLinkIgnore, SelfPostLinkPreview, SelfPostLinkItem},
+	"title_emoji":         {TitleEmojiKeep, TitleEmojiStrip},
+	"distinguished_posts": {DistinguishedInclude, DistinguishedExclude, DistinguishedOnly},
+	"post_types":          {PostTypesAll, PostTypesLinks, PostTypesDiscussions},
+	"bot_posts":           {BotInclude, BotTag, BotExclude},
+	"subreddit_sort":      {SortHot, SortNew, SortTop, SortRising},
+	"subscriptions":       {SubscriptionsMerged, SubscriptionsPerSubreddit},
+}
+
+// configFields returns the Config fields by their JSON key
+func configFields() map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key != "" && key != "-" {
+			fields[key] = field
+		}
+	}
+	return fields
+}
+
+// decodeConfig unmarshals a JSON config into config, rejecting keys Config doesn't
+// have so that typos don't silently do nothing
+func decodeConfig(data []byte, config *Config) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	fields := configFields()
+	var unknown []string
+	for key := range keys {
+		if _, ok := fields[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		msg := fmt.Sprintf("unknown key %q", unknown[0])
+		if suggestion := closestConfigKey(unknown[0], fields); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		if len(unknown) > 1 {
+			msg += fmt.Sprintf(" (and %d more: %s)", len(unknown)-1, strings.Join(unknown[1:], ", "))
+		}
+		return fmt.Errorf("%s", msg)
+	}
+
+	return json.Unmarshal(data, config)
+}
+
+// closestConfigKey returns the known key a mistyped key most likely meant, or ""
+func closestConfigKey(key string, fields map[string]reflect.StructField) string {
+	normalize := func(s string) string { return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s)) }
+
+	best, bestDistance := "", 3 // Suggest only keys at most two edits away
+	for known := range fields {
+		d := editDistance(normalize(key), normalize(known))
+		if d < bestDistance || (d == bestDistance && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// ConfigSchema returns a JSON Schema of the config file for editors and validators
+func ConfigSchema() map[string]any {
+	properties := make(map[string]any)
+	for key, field := range configFields() {
+		properties[key] = jsonSchemaType(field.Type, configEnums[key])
+	}
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "red-rss configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             []string{"client_id"},
+		"additionalProperties": false,
+	}
+}
+
+// jsonSchemaType returns the JSON Schema of a config field type
+func jsonSchemaType(t reflect.Type, enum []string) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return map[string]any{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchemaType(t.Elem(), nil)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaType(t.Elem(), nil)}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			properties[key] = jsonSchemaType(t.Field(i).Type, nil)
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+
+	schema := map[string]any{"type": "string"}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+// runConfigSchema prints the JSON Schema of the config file
+func runConfigSchema(args []string) error {
+	fs := flag.NewFlagSet("config schema", flag.ExitOnError)
+	fs.Parse(args)
+
+	data, err := json.MarshalIndent(ConfigSchema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2024, 5, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 5, 19, 9, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * 1-5", time.Date(2024, 5, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * 5", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)}, // Day of month or Friday
+		{"0 12 29 2 *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		schedule, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tc.expr, err)
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("Next(%q) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected ParseCron(%q) to fail", expr)
+		}
+	}
+}
+
+func TestFeedSchedules(t *testing.T) {
+	tenant := &Tenant{Name: "test", config: Config{
+		Subreddits: []string{"golang", "programming"},
+		Schedules:  map[string]string{"golang": "0 9 * * 0"},
+	}}
+	start := time.Date(2024, 5, 15, 10, 0, 0, 0, time.Local)
+	every := ScheduleOptions{Interval: 15 * time.Minute}
+
+	// Every feed runs on startup
+	for _, feed := range tenant.Feeds() {
+		if !tenant.due(feed, start, every) {
+			t.Errorf("expected %s to run on startup", feed)
+		}
+	}
+
+	later := start.Add(20 * time.Minute)
+	if !tenant.due(HomepageFeed, later, every) || !tenant.due("programming", later, every) {
+		t.Errorf("expected unscheduled feeds to run every interval")
+	}
+	if tenant.due("golang", later, every) {
+		t.Errorf("expected golang to wait for Sunday")
+	}
+	if !tenant.due("golang", time.Date(2024, 5, 19, 9, 0, 0, 0, time.Local), every) {
+		t.Errorf("expected golang to run on Sunday at 9")
+	}
+
+	// A feed still being generated isn't started again
+	run := tenant.feedRun(HomepageFeed)
+	run.running.Lock()
+	defer run.running.Unlock()
+	if err := tenant.GenerateFeed(context.Background(), HomepageFeed); err != ErrGenerationRunning {
+		t.Errorf("expected ErrGenerationRunning, got %v", err)
+	}
+
+	if err := validateConfig(&Config{ClientID: "x", FeedType: "rss", OutputPath: "a.xml", Schedules: map[string]string{"rust": "@daily"}}); err == nil {
+		t.Errorf("expected schedules of unknown feeds to be rejected")
+	}
+}
+
+func TestScheduleSplayAndJitter(t *testing.T) {
+	opts := ScheduleOptions{Interval: time.Hour, Jitter: 5 * time.Minute, Splay: 10 * time.Minute}
+	tenant := &Tenant{Name: "test", config: Config{Subreddits: []string{"golang"}}}
+	start := time.Date(2024, 5, 15, 10, 0, 0, 0, time.Local)
+
+	for _, feed := range tenant.Feeds() {
+		delay := splayDelay("test/"+feed, opts.Splay)
+		if delay != splayDelay("test/"+feed, opts.Splay) || delay >= opts.Splay {
+			t.Fatalf("expected a stable splay below %s, got %s", opts.Splay, delay)
+		}
+		if delay > 0 && tenant.due(feed, start, opts) {
+			t.Errorf("expected %s to wait %s after startup", feed, delay)
+		}
+		if !tenant.due(feed, start.Add(delay), opts) {
+			t.Errorf("expected %s to run after its splay", feed)
+		}
+
+		next := tenant.feedRun(feed).next
+		if wait := next.Sub(start.Add(delay)); wait < opts.Interval || wait >= opts.Interval+opts.Jitter {
+			t.Errorf("expected the next run of %s within the jitter after the interval, got %s", feed, wait)
+		}
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	opts := ScheduleOptions{Interval: 15 * time.Minute, MaxInterval: time.Hour}
+	tenant := &Tenant{Name: "test"}
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, time.Local)
+	tenant.due(HomepageFeed, now, opts)
+	run := tenant.feedRun(HomepageFeed)
+
+	failure := errors.New("HTTP 429")
+	for _, want := range []time.Duration{30 * time.Minute, time.Hour, time.Hour} {
+		tenant.recordRun(HomepageFeed, failure, now, opts)
+		if run.backoff != want || !run.next.Equal(now.Add(want)) {
+			t.Errorf("expected a backoff of %s, got %s until %s", want, run.backoff, run.next)
+		}
+	}
+
+	for _, want := range []time.Duration{30 * time.Minute, 0} {
+		tenant.recordRun(HomepageFeed, nil, now, opts)
+		if run.backoff != want {
+			t.Errorf("expected the backoff to shrink to %s, got %s", want, run.backoff)
+		}
+	}
+
+	// Overlapping runs don't count as failures
+	tenant.recordRun(HomepageFeed, ErrGenerationRunning, now, opts)
+	if run.backoff != 0 {
+		t.Errorf("expected no backoff for skipped runs, got %s", run.backoff)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"crypto/cipher"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,13 +14,19 @@ import (
 
 // OpenGraphDB wraps database operations with thread safety
 type OpenGraphDB struct {
-	db *sql.DB
-	mu sync.RWMutex
+	db   *sql.DB
+	mu   sync.RWMutex
+	aead cipher.AEAD // Encrypts cached bodies and stored posts with encrypt_cache, nil otherwise
 }
 
 // InitOpenGraphDB initializes the SQLite database for OpenGraph caching
 func InitOpenGraphDB() (*OpenGraphDB, error) {
-	db, err := sql.Open("sqlite", OpenGraphDBFile)
+	return OpenOpenGraphDB(CacheDBPath)
+}
+
+// OpenOpenGraphDB opens the cache database at the given path, creating and migrating it as needed
+func OpenOpenGraphDB(path string) (*OpenGraphDB, error) {
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -67,6 +75,8 @@ func (ogDB *OpenGraphDB) createSchema() error {
 		description TEXT,
 		image TEXT,
 		site_name TEXT,
+		word_count INTEGER DEFAULT 0,
+		canonical TEXT DEFAULT '',
 		fetched_at DATETIME,
 		expires_at DATETIME,
 		version INTEGER DEFAULT 1
@@ -74,6 +84,157 @@ func (ogDB *OpenGraphDB) createSchema() error {
 	
 	CREATE INDEX IF NOT EXISTS idx_expires_at ON opengraph_cache(expires_at);
 	CREATE INDEX IF NOT EXISTS idx_fetched_at ON opengraph_cache(fetched_at);
+
+	CREATE TABLE IF NOT EXISTS http_cache (
+		key TEXT PRIMARY KEY,
+		vary TEXT,
+		status INTEGER,
+		header TEXT,
+		body BLOB,
+		compressed INTEGER DEFAULT 0,
+		encrypted INTEGER DEFAULT 0,
+		request_time INTEGER,
+		response_time INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_http_cache_response_time ON http_cache(response_time);
+
+	CREATE TABLE IF NOT EXISTS run_checkpoints (
+		key TEXT PRIMARY KEY,
+		posts TEXT,
+		after TEXT,
+		pages INTEGER,
+		fetch_done INTEGER,
+		updated_at INTEGER,
+		encrypted INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS seen_posts (
+		fullname TEXT PRIMARY KEY,
+		post BLOB,
+		compressed INTEGER DEFAULT 0,
+		encrypted INTEGER DEFAULT 0,
+		first_seen INTEGER,
+		last_seen INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_seen_posts_last_seen ON seen_posts(last_seen);
+
+	CREATE TABLE IF NOT EXISTS fetch_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT,
+		fetched_at INTEGER,
+		posts TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_fetch_runs_source ON fetch_runs(source, fetched_at);
+
+	CREATE TABLE IF NOT EXISTS post_snapshots (
+		fullname TEXT,
+		seen_at INTEGER,
+		score INTEGER,
+		num_comments INTEGER,
+		PRIMARY KEY (fullname, seen_at)
+	);
+
+	CREATE TABLE IF NOT EXISTS seeded_posts (
+		source TEXT,
+		fullname TEXT,
+		seeded_at INTEGER,
+		PRIMARY KEY (source, fullname)
+	);
+
+	CREATE TABLE IF NOT EXISTS source_cursors (
+		source TEXT PRIMARY KEY,
+		newest TEXT,
+		posts TEXT,
+		full_fetch_at INTEGER,
+		encrypted INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS checkpoint_urls (
+		key TEXT,
+		url TEXT,
+		PRIMARY KEY (key, url)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_removals (
+		fullname TEXT PRIMARY KEY,
+		reason TEXT,
+		checked_at INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS feed_state (
+		key TEXT PRIMARY KEY,
+		digest TEXT,
+		changed_at INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS api_backoff (
+		endpoint TEXT PRIMARY KEY,
+		failures INTEGER,
+		next_attempt INTEGER,
+		last_error TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS post_overrides (
+		fullname TEXT PRIMARY KEY,
+		action TEXT,
+		created_at INTEGER,
+		expires_at INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS comment_cache (
+		fullname TEXT PRIMARY KEY,
+		comment_limit INTEGER,
+		depth INTEGER,
+		comments TEXT,
+		fetched_at INTEGER,
+		encrypted INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS posts_seen (
+		feed TEXT,
+		permalink TEXT,
+		emitted_at INTEGER,
+		PRIMARY KEY (feed, permalink)
+	);
+
+	CREATE TABLE IF NOT EXISTS filter_rejections (
+		feed TEXT,
+		rule TEXT,
+		run_at INTEGER,
+		rejected INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_filter_rejections_run_at ON filter_rejections(run_at);
+
+	CREATE TABLE IF NOT EXISTS item_deliveries (
+		sink TEXT,
+		feed TEXT,
+		permalink TEXT,
+		delivered_at INTEGER,
+		PRIMARY KEY (sink, feed, permalink)
+	);
+
+	CREATE TABLE IF NOT EXISTS match_scores (
+		fullname TEXT PRIMARY KEY,
+		score TEXT,
+		changed_at INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS subscription_feeds (
+		subreddit TEXT PRIMARY KEY,
+		path TEXT,
+		written_at INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS image_sizes (
+		url TEXT PRIMARY KEY,
+		width INTEGER,
+		height INTEGER,
+		checked_at INTEGER
+	);
 	`
 
 	_, err := ogDB.db.Exec(createTableSQL)
@@ -110,6 +271,47 @@ func (ogDB *OpenGraphDB) runMigrations() error {
 		slog.Info("Added version column to opengraph_cache table")
 	}
 
+	// Large fields are stored gzip-compressed since the compressed columns were added
+	for _, table := range []string{"http_cache", "seen_posts"} {
+		if err := ogDB.addColumnIfMissing(table, "compressed", "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+		if err := ogDB.addColumnIfMissing(table, "encrypted", "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	// Posts and comments kept for runs are encrypted with encrypt_cache too
+	for _, table := range []string{"run_checkpoints", "source_cursors", "comment_cache"} {
+		if err := ogDB.addColumnIfMissing(table, "encrypted", "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+
+	if err := ogDB.addColumnIfMissing("opengraph_cache", "word_count", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ogDB.addColumnIfMissing("opengraph_cache", "canonical", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table created by an older version
+func (ogDB *OpenGraphDB) addColumnIfMissing(table, column, definition string) error {
+	var count int
+	row := ogDB.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err := row.Scan(&count); err != nil {
+		return fmt.Errorf("failed to check %s column: %w", column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := ogDB.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s column to %s: %w", column, table, err)
+	}
+	slog.Info("Added column", "table", table, "column", column)
 	return nil
 }
 
@@ -118,13 +320,13 @@ func (ogDB *OpenGraphDB) GetCachedOpenGraph(url string) (*OpenGraphData, error)
 	ogDB.mu.RLock()
 	defer ogDB.mu.RUnlock()
 
-	query := `SELECT url, title, description, image, site_name, fetched_at, expires_at 
+	query := `SELECT url, title, description, image, site_name, word_count, canonical, fetched_at, expires_at 
 			  FROM opengraph_cache WHERE url = ? AND expires_at > datetime('now')`
 
 	row := ogDB.db.QueryRow(query, url)
 
 	var og OpenGraphData
-	err := row.Scan(&og.URL, &og.Title, &og.Description, &og.Image, &og.SiteName, &og.FetchedAt, &og.ExpiresAt)
+	err := row.Scan(&og.URL, &og.Title, &og.Description, &og.Image, &og.SiteName, &og.WordCount, &og.Canonical, &og.FetchedAt, &og.ExpiresAt)
 	if err == sql.ErrNoRows {
 		return nil, nil // No cached data found
 	}
@@ -141,10 +343,10 @@ func (ogDB *OpenGraphDB) SaveCachedOpenGraph(og *OpenGraphData) error {
 	defer ogDB.mu.Unlock()
 
 	query := `INSERT OR REPLACE INTO opengraph_cache 
-			  (url, title, description, image, site_name, fetched_at, expires_at, version)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, 1)`
+			  (url, title, description, image, site_name, word_count, canonical, fetched_at, expires_at, version)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`
 
-	_, err := ogDB.db.Exec(query, og.URL, og.Title, og.Description, og.Image, og.SiteName, og.FetchedAt, og.ExpiresAt)
+	_, err := ogDB.db.Exec(query, og.URL, og.Title, og.Description, og.Image, og.SiteName, og.WordCount, og.Canonical, og.FetchedAt, og.ExpiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to save cached data: %w", err)
 	}
@@ -173,6 +375,21 @@ func (ogDB *OpenGraphDB) CleanupExpiredEntries() error {
 		slog.Info("Cleaned up expired OpenGraph entries", "count", rowsAffected)
 	}
 
+	// HTTP responses are kept for revalidation until they are too old to be useful
+	cutoff := time.Now().Add(-HTTPCacheRetention).Unix()
+	result, err = ogDB.db.Exec(`DELETE FROM http_cache WHERE response_time <= ?`, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup HTTP cache: %w", err)
+	}
+
+	if rowsAffected, err = result.RowsAffected(); err == nil && rowsAffected > 0 {
+		slog.Info("Cleaned up old HTTP cache entries", "count", rowsAffected)
+	}
+
+	if _, err := ogDB.db.Exec(`DELETE FROM comment_cache WHERE fetched_at <= ?`, time.Now().Add(-CommentCacheTTL).Unix()); err != nil {
+		return fmt.Errorf("failed to cleanup comment cache: %w", err)
+	}
+
 	return nil
 }
 
@@ -266,3 +483,33 @@ func (ogDB *OpenGraphDB) GetDatabaseSize() (int64, error) {
 
 	return size, nil
 }
+
+// CheckIntegrity runs SQLite's integrity check and returns its findings as an error
+func (ogDB *OpenGraphDB) CheckIntegrity() error {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read integrity check: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database is corrupt: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CacheKeyEnv holds a base64-encoded 32-byte cache encryption key, for machines without
+// an OS keyring
+const CacheKeyEnv = "RED_RSS_CACHE_KEY"
+
+// cacheKeySize is the size of AES-256 keys
+const cacheKeySize = 32
+
+// errCacheLocked is returned for encrypted fields read without the key
+var errCacheLocked = errors.New("cache entry is encrypted, enable encrypt_cache with the same key to read it")
+
+// OpenCacheDB opens the cache database at path and, with encrypt_cache, unlocks it
+// with the cache key of the database
+func OpenCacheDB(config *Config, path string) (*OpenGraphDB, error) {
+	db, err := OpenOpenGraphDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if !config.EncryptCache {
+		return db, nil
+	}
+
+	key, err := cacheKey(path)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.SetEncryptionKey(key); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// cacheKey returns the encryption key of a cache database from CacheKeyEnv or the OS
+// keyring, where tokens are kept with token_storage "keyring". A new key is created in
+// the keyring the first time.
+func cacheKey(dbPath string) ([]byte, error) {
+	if encoded := os.Getenv(CacheKeyEnv); encoded != "" {
+		return decodeCacheKey(encoded)
+	}
+
+	account := "cache-key:" + keyringAccount(dbPath)
+	secret, err := tokenKeyring.Get(account)
+	if err == nil {
+		return decodeCacheKey(secret)
+	}
+	if !errors.Is(err, errKeyringNotFound) {
+		return nil, fmt.Errorf("encrypt_cache needs the OS keyring or %s: %w", CacheKeyEnv, err)
+	}
+
+	key := make([]byte, cacheKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to create cache key: %w", err)
+	}
+	if err := tokenKeyring.Set(account, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store cache key in the OS keyring: %w", err)
+	}
+	return key, nil
+}
+
+// decodeCacheKey decodes a base64-encoded cache key
+func decodeCacheKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != cacheKeySize {
+		return nil, fmt.Errorf("cache key must be %d bytes encoded in base64", cacheKeySize)
+	}
+	return key, nil
+}
+
+// SetEncryptionKey makes the database encrypt cached page bodies, stored, checkpoint and
+// cursor posts and cached comments with AES-GCM under key. Fields written before stay
+// readable until they are rewritten.
+func (ogDB *OpenGraphDB) SetEncryptionKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid cache key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("invalid cache key: %w", err)
+	}
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+	ogDB.aead = aead
+	return nil
+}
+
+// sealField encrypts a field when the database has a key, reporting whether it did.
+// The random nonce is stored in front of the ciphertext.
+func (ogDB *OpenGraphDB) sealField(data []byte) ([]byte, bool, error) {
+	if ogDB.aead == nil {
+		return data, false, nil
+	}
+	nonce := make([]byte, ogDB.aead.NonceSize(), ogDB.aead.NonceSize()+len(data)+ogDB.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, false, fmt.Errorf("failed to encrypt field: %w", err)
+	}
+	return ogDB.aead.Seal(nonce, nonce, data, nil), true, nil
+}
+
+// openField reverses sealField
+func (ogDB *OpenGraphDB) openField(data []byte, encrypted bool) ([]byte, error) {
+	if !encrypted {
+		return data, nil
+	}
+	if ogDB.aead == nil {
+		return nil, errCacheLocked
+	}
+	size := ogDB.aead.NonceSize()
+	if len(data) < size {
+		return nil, fmt.Errorf("failed to decrypt field: too short")
+	}
+	out, err := ogDB.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt field, the cache key may have changed: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheEncryption(t *testing.T) {
+	useKeyring(t, &memoryKeyring{secrets: make(map[string]string)})
+	t.Setenv(CacheKeyEnv, "")
+	path := filepath.Join(t.TempDir(), "cache.db")
+	config := &Config{EncryptCache: true}
+
+	db, err := OpenCacheDB(config, path)
+	if err != nil {
+		t.Fatalf("OpenCacheDB: %v", err)
+	}
+	body := []byte(strings.Repeat("private page ", 200))
+	entry := &cachedResponse{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, RequestTime: time.Now(), ResponseTime: time.Now()}
+	if err := db.saveHTTPCache("https://example.com/", entry); err != nil {
+		t.Fatal(err)
+	}
+	post := seenPost("t3_secret", 10)
+	post.Data.Title = "secret title"
+	if err := db.SaveSeenPosts([]RedditPost{post}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SaveCheckpoint(&Checkpoint{Key: "homepage", Posts: []RedditPost{post}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveCachedComments("t3_secret", 5, 1, []Comment{{Author: "bob", Body: "secret comment"}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, secret := range map[string]string{
+		`SELECT post FROM seen_posts WHERE fullname = 't3_secret'`:        "secret title",
+		`SELECT posts FROM run_checkpoints WHERE key = 'homepage'`:        "secret title",
+		`SELECT comments FROM comment_cache WHERE fullname = 't3_secret'`: "secret comment",
+	} {
+		var raw []byte
+		if err := db.db.QueryRow(query).Scan(&raw); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, []byte(secret)) {
+			t.Errorf("expected %q to be encrypted", query)
+		}
+	}
+	db.Close()
+
+	// The key is kept in the keyring, so reopening reads the entries back
+	db, err = OpenCacheDB(config, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := db.getHTTPCache("https://example.com/")
+	if err != nil || cached == nil || !bytes.Equal(cached.Body, body) {
+		t.Errorf("getHTTPCache = %v, %v", cached, err)
+	}
+	posts, err := db.GetSeenPosts([]string{"t3_secret"})
+	if err != nil || posts["t3_secret"].Data.Title != "secret title" {
+		t.Errorf("GetSeenPosts = %v, %v", posts, err)
+	}
+	if cp, err := db.LoadCheckpoint("homepage"); err != nil || cp == nil || cp.Posts[0].Data.Title != "secret title" {
+		t.Errorf("LoadCheckpoint = %v, %v", cp, err)
+	}
+	if comments, err := db.GetCachedComments("t3_secret", 5, 1); err != nil || len(comments) != 1 || comments[0].Body != "secret comment" {
+		t.Errorf("GetCachedComments = %v, %v", comments, err)
+	}
+	db.Close()
+
+	// Without the key the entries can't be read
+	db, err = OpenCacheDB(&Config{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.GetSeenPosts([]string{"t3_secret"}); !errors.Is(err, errCacheLocked) {
+		t.Errorf("expected errCacheLocked, got %v", err)
+	}
+}
+
+func TestCacheKeyFromEnv(t *testing.T) {
+	useKeyring(t, &memoryKeyring{unavailable: true})
+
+	t.Setenv(CacheKeyEnv, "")
+	if _, err := cacheKey("cache.db"); err == nil || !strings.Contains(err.Error(), CacheKeyEnv) {
+		t.Errorf("expected an error naming %s without a keyring, got %v", CacheKeyEnv, err)
+	}
+
+	key := bytes.Repeat([]byte{7}, cacheKeySize)
+	t.Setenv(CacheKeyEnv, base64.StdEncoding.EncodeToString(key))
+	if got, err := cacheKey("cache.db"); err != nil || !bytes.Equal(got, key) {
+		t.Errorf("cacheKey = %v, %v", got, err)
+	}
+
+	t.Setenv(CacheKeyEnv, "c2hvcnQ=")
+	if _, err := cacheKey("cache.db"); err == nil {
+		t.Error("expected a short key to be rejected")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discord limits
+const (
+	discordTitleLength   = 256              // Characters of embed titles
+	discordMaxRetries    = 3                // Rate limited attempts of a message before giving up
+	discordMaxRetryAfter = time.Minute      // Longest rate limit wait honored
+	discordEmbedColor    = 0xFF4500         // Reddit orange
+	discordWebhookPath   = "/api/webhooks/" // Path prefix of webhook URLs
+)
+
+// discordMinInterval spaces messages to stay under the limit of 30 messages a minute
+// per channel, which Discord doesn't announce in its rate limit headers
+var discordMinInterval = 2 * time.Second
+
+// DiscordNotifier posts new items to a Discord channel through a webhook, each as a
+// rich embed. It keeps to Discord's rate limits, waiting as the response headers and
+// 429 responses ask.
+type DiscordNotifier struct {
+	URL string
+
+	mu   sync.Mutex
+	next time.Time // Earliest time of the next request
+}
+
+// discordNotifiers are the notifiers of the webhooks in use, so pacing holds across
+// the feeds and tenants posting to a channel
+var (
+	discordNotifiersMu sync.Mutex
+	discordNotifiers   = make(map[string]*DiscordNotifier)
+)
+
+// discordNotifier returns the notifier of a webhook
+func discordNotifier(webhook string) *DiscordNotifier {
+	discordNotifiersMu.Lock()
+	defer discordNotifiersMu.Unlock()
+	if d, ok := discordNotifiers[webhook]; ok {
+		return d
+	}
+	d := &DiscordNotifier{URL: webhook}
+	discordNotifiers[webhook] = d
+	return d
+}
+
+// discordEmbed is a Discord rich embed
+type discordEmbed struct {
+	Title     string              `json:"title"`
+	URL       string              `json:"url,omitempty"`
+	Color     int                 `json:"color"`
+	Fields    []discordEmbedField `json:"fields"`
+	Image     *discordEmbedImage  `json:"image,omitempty"`
+	Timestamp string              `json:"timestamp,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+// validateDiscordWebhook checks that discord_webhook is a Discord webhook URL
+func validateDiscordWebhook(webhook string) error {
+	if webhook == "" {
+		return nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil || u.Scheme != "https" || u.Host == "" || !strings.HasPrefix(u.Path, discordWebhookPath) {
+		return fmt.Errorf("discord_webhook must be a webhook URL such as https://discord.com/api/webhooks/<id>/<token>")
+	}
+	return nil
+}
+
+// Name identifies the webhook in the record of delivered items by its ID, keeping its
+// token out of the database
+func (d *DiscordNotifier) Name() string {
+	id := d.URL
+	if u, err := url.Parse(d.URL); err == nil {
+		id, _, _ = strings.Cut(strings.TrimPrefix(u.Path, discordWebhookPath), "/")
+	}
+	return "discord:" + id
+}
+
+// discordEmbedFor builds the embed of an item: its title linking to the post, the
+// score, subreddit and comments, and the preview image
+func discordEmbedFor(item NewItem) discordEmbed {
+	post := item.Post.Data
+	embed := discordEmbed{
+		Title: truncateText(post.Title, discordTitleLength),
+		URL:   post.discussionURL(),
+		Color: discordEmbedColor,
+		Fields: []discordEmbedField{
+			{Name: "Score", Value: strconv.Itoa(post.Score), Inline: true},
+			{Name: "Subreddit", Value: post.community(), Inline: true},
+			{Name: "Comments", Value: strconv.Itoa(post.NumComments), Inline: true},
+		},
+	}
+	if !post.IsSelf && post.URL != "" {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Link", Value: post.URL})
+	}
+	if item.Image != "" {
+		embed.Image = &discordEmbedImage{URL: item.Image}
+	}
+	if post.CreatedUTC > 0 {
+		embed.Timestamp = time.Unix(int64(post.CreatedUTC), 0).UTC().Format(time.RFC3339)
+	}
+	return embed
+}
+
+// NotifyItem posts an item as an embed
+func (d *DiscordNotifier) NotifyItem(ctx context.Context, item NewItem) error {
+	body, err := json.Marshal(map[string]any{"embeds": []discordEmbed{discordEmbedFor(item)}})
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if err := sleepUntil(ctx, d.next); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "red-rss/"+Version)
+
+		resp, err := notifyClient.Do(req)
+		if err != nil {
+			// The URL contains the webhook token, keep it out of logs
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("discord webhook failed: %w", err)
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		d.next = time.Now().Add(discordMinInterval)
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			d.next = laterOf(d.next, time.Now().Add(secondsHeader(resp.Header.Get("X-RateLimit-Reset-After"))))
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			var limited struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.Unmarshal(respBody, &limited)
+			wait := time.Duration(limited.RetryAfter * float64(time.Second))
+			if attempt+1 >= discordMaxRetries || wait > discordMaxRetryAfter {
+				return fmt.Errorf("discord webhook rate limited for %v", wait)
+			}
+			d.next = laterOf(d.next, time.Now().Add(wait))
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("discord webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+		}
+		return nil
+	}
+}
+
+// secondsHeader parses a header value in seconds, such as "1.5"
+func secondsHeader(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// laterOf returns the later of two times
+func laterOf(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// sleepUntil waits until t or until ctx is done
+func sleepUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
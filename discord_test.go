@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscordNotifier(t *testing.T) {
+	orig := discordMinInterval
+	discordMinInterval = 0
+	defer func() { discordMinInterval = orig }()
+
+	var embeds []discordEmbed
+	limited, failing := false, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !limited {
+			limited = true
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01}`))
+			return
+		}
+		var payload struct {
+			Embeds []discordEmbed `json:"embeds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		embeds = append(embeds, payload.Embeds...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	post := func(name string, created float64) RedditPost {
+		p := seenPost(name, 10)
+		p.Data.CreatedUTC = created
+		p.Data.Permalink = "/r/test/comments/" + name + "/"
+		p.Data.Subreddit = "test"
+		return p
+	}
+	p := NewPipeline(&Config{DiscordWebhook: server.URL + "/api/webhooks/123/secret"}, nil, newTestDB(t))
+	opts := DefaultRunOptions()
+	run := func(posts ...RedditPost) {
+		p.notifyNewPosts(context.Background(), posts, map[string]*OpenGraphData{
+			previewLink(post("t3_c", 0)): {Image: "https://example.com/c.png"},
+		}, opts)
+		p.recordEmittedPosts(posts, opts)
+	}
+
+	run(post("t3_a", 1))
+	run(post("t3_c", 3), post("t3_b", 2), post("t3_a", 1))
+	if len(embeds) != 2 {
+		t.Fatalf("expected 2 embeds after a rate limited retry, got %+v", embeds)
+	}
+	if embeds[0].Title != "Post t3_b" || embeds[1].Title != "Post t3_c" {
+		t.Errorf("expected oldest first, got %q and %q", embeds[0].Title, embeds[1].Title)
+	}
+	if embeds[1].Image == nil || embeds[1].Image.URL != "https://example.com/c.png" || embeds[1].Fields[1].Value != "r/test" {
+		t.Errorf("unexpected embed %+v", embeds[1])
+	}
+
+	name := p.itemNotifiers()[0].Name()
+	if name != "discord:123" {
+		t.Errorf("Name() = %q, want the webhook ID only", name)
+	}
+	if delivered, err := p.db.IsDelivered(name, HomepageFeed, "/r/test/comments/t3_b/"); err != nil || !delivered {
+		t.Errorf("expected t3_b to be recorded as delivered, got %v, %v", delivered, err)
+	}
+
+	// Delivered items aren't sent again, even after the feed's history is gone
+	p.db.db.Exec(`DELETE FROM posts_seen WHERE permalink != '/r/test/comments/t3_a/'`)
+	run(post("t3_c", 3), post("t3_b", 2), post("t3_a", 1))
+	if len(embeds) != 2 {
+		t.Errorf("expected no redelivery, got %+v", embeds[2:])
+	}
+
+	// An item that failed is sent by the next run
+	failing = true
+	run(post("t3_d", 4), post("t3_c", 3))
+	failing = false
+	run(post("t3_d", 4), post("t3_c", 3))
+	if len(embeds) != 3 || embeds[2].Title != "Post t3_d" {
+		t.Errorf("expected the failed item to be sent again, got %+v", embeds[2:])
+	}
+
+	// Feeds posting to the same webhook share its pacing
+	other := NewPipeline(&Config{DiscordWebhook: p.config.DiscordWebhook}, nil, p.db)
+	if other.itemNotifiers()[0] != p.itemNotifiers()[0] {
+		t.Error("expected one notifier per webhook")
+	}
+}
+
+func TestValidateDiscordWebhook(t *testing.T) {
+	for webhook, ok := range map[string]bool{
+		"": true,
+		"https://discord.com/api/webhooks/123/token": true,
+		"http://discord.com/api/webhooks/123/token":  false,
+		"https://example.com/hook":                   false,
+	} {
+		if err := validateDiscordWebhook(webhook); (err == nil) != ok {
+			t.Errorf("validateDiscordWebhook(%q) = %v", webhook, err)
+		}
+	}
+}
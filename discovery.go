@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DiscoveryWindow is how far back the subreddit discovery report looks
+const DiscoveryWindow = 7 * 24 * time.Hour
+
+// SubredditEngagement summarizes how a subreddit's homepage posts fared against the filters
+type SubredditEngagement struct {
+	Subreddit   string  `json:"subreddit"`
+	Posts       int     `json:"posts"`        // Distinct posts fetched in the window
+	FilteredOut int     `json:"filtered_out"` // Posts below the score or comment filter
+	AvgScore    float64 `json:"avg_score"`
+	AvgComments float64 `json:"avg_comments"`
+}
+
+// DiscoverSubreddits reports the subreddits whose posts often show up on the homepage
+// but are filtered out, most filtered first. These are candidates for a dedicated feed
+// or for adjusting the thresholds.
+func DiscoverSubreddits(db *OpenGraphDB, config *Config, since time.Time, minPosts int) ([]SubredditEngagement, error) {
+	runs, err := db.GetFetchRunsSince(HomepageSource, since)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, run := range runs {
+		for _, name := range run.Posts {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	// The stored posts carry the scores of their latest fetch
+	posts, err := db.GetSeenPosts(names)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*SubredditEngagement)
+	for _, name := range names {
+		post, ok := posts[name]
+		if !ok || post.Data.Subreddit == "" {
+			continue
+		}
+		s := stats[post.Data.Subreddit]
+		if s == nil {
+			s = &SubredditEngagement{Subreddit: post.Data.Subreddit}
+			stats[post.Data.Subreddit] = s
+		}
+		s.Posts++
+		s.AvgScore += float64(post.Data.Score)
+		s.AvgComments += float64(post.Data.NumComments)
+		if post.Data.Score < config.ScoreFilter || post.Data.NumComments < config.CommentFilter {
+			s.FilteredOut++
+		}
+	}
+
+	var report []SubredditEngagement
+	for _, s := range stats {
+		s.AvgScore /= float64(s.Posts)
+		s.AvgComments /= float64(s.Posts)
+		if s.Posts >= minPosts && s.FilteredOut > 0 {
+			report = append(report, *s)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].FilteredOut != report[j].FilteredOut {
+			return report[i].FilteredOut > report[j].FilteredOut
+		}
+		if report[i].AvgScore != report[j].AvgScore {
+			return report[i].AvgScore > report[j].AvgScore
+		}
+		return report[i].Subreddit < report[j].Subreddit
+	})
+
+	return report, nil
+}
+
+// runDiscover implements the discover subcommand
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	window := fs.Duration("window", DiscoveryWindow, "how far back to look")
+	minPosts := fs.Int("min-posts", 3, "only report subreddits with at least this many posts")
+	limit := fs.Int("n", 20, "maximum number of subreddits to show")
+	fs.Parse(args)
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil {
+		return err
+	}
+
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := DiscoverSubreddits(db, &config, time.Now().Add(-*window), *minPosts)
+	if err != nil {
+		return err
+	}
+	if len(report) == 0 {
+		fmt.Println("No frequently filtered subreddits found")
+		return nil
+	}
+	if len(report) > *limit {
+		report = report[:*limit]
+	}
+
+	fmt.Printf("Subreddits filtered out by min score %d / min comments %d in the last %s:\n\n",
+		config.ScoreFilter, config.CommentFilter, *window)
+	fmt.Printf("%-24s %6s %9s %10s %13s\n", "SUBREDDIT", "POSTS", "FILTERED", "AVG SCORE", "AVG COMMENTS")
+	for _, s := range report {
+		fmt.Printf("%-24s %6d %9d %10.0f %13.0f\n", "r/"+s.Subreddit, s.Posts, s.FilteredOut, s.AvgScore, s.AvgComments)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiscoverSubreddits(t *testing.T) {
+	db := newTestDB(t)
+	config := &Config{ScoreFilter: 100}
+
+	post := func(name, subreddit string, score int) RedditPost {
+		p := seenPost(name, score)
+		p.Data.Subreddit = subreddit
+		return p
+	}
+
+	first := []RedditPost{post("t3_a", "golang", 20), post("t3_b", "golang", 40), post("t3_c", "pics", 5000)}
+	second := []RedditPost{post("t3_b", "golang", 60), post("t3_d", "golang", 500), post("t3_e", "rust", 10)}
+	now := time.Now()
+	for i, run := range [][]RedditPost{first, second} {
+		if err := db.SaveSeenPosts(run); err != nil {
+			t.Fatalf("SaveSeenPosts failed: %v", err)
+		}
+		if err := db.RecordFetchRun(HomepageSource, run, now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("RecordFetchRun failed: %v", err)
+		}
+	}
+
+	report, err := DiscoverSubreddits(db, config, now.Add(-time.Hour), 1)
+	if err != nil {
+		t.Fatalf("DiscoverSubreddits failed: %v", err)
+	}
+	if len(report) != 2 {
+		t.Fatalf("expected golang and rust, got %+v", report)
+	}
+
+	golang := report[0]
+	if golang.Subreddit != "golang" || golang.Posts != 3 || golang.FilteredOut != 2 {
+		t.Errorf("unexpected golang stats: %+v", golang)
+	}
+	// t3_b counts with its latest score
+	if golang.AvgScore != (20+60+500)/3.0 {
+		t.Errorf("expected average of latest scores, got %v", golang.AvgScore)
+	}
+	if report[1].Subreddit != "rust" {
+		t.Errorf("expected rust second, got %+v", report[1])
+	}
+
+	report, err = DiscoverSubreddits(db, config, now.Add(-time.Hour), 2)
+	if err != nil {
+		t.Fatalf("DiscoverSubreddits failed: %v", err)
+	}
+	if len(report) != 1 || report[0].Subreddit != "golang" {
+		t.Errorf("expected min posts to leave only golang, got %+v", report)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// errSkipped marks a doctor check that couldn't run because an earlier one failed
+var errSkipped = errors.New("skipped")
+
+// doctor runs the pipeline step by step to find out why feed generation fails
+type doctor struct {
+	configPath string
+	enrichURL  string
+
+	config Config
+	db     *OpenGraphDB
+	client *http.Client
+	posts  []RedditPost
+	og     *OpenGraphData
+}
+
+// doctorCheck is a single step of the doctor command
+type doctorCheck struct {
+	name        string
+	needsConfig bool // Skipped when the config is invalid
+	run         func(ctx context.Context) (string, error)
+}
+
+// checks returns the steps in the order they depend on each other
+func (d *doctor) checks() []doctorCheck {
+	return []doctorCheck{
+		{"config", false, d.checkConfig},
+		{"database", false, d.checkDatabase},
+		{"network", false, d.checkNetwork},
+		{"auth", true, d.checkAuth},
+		{"fetch", true, d.checkFetch},
+		{"enrich", false, d.checkEnrich},
+		{"render", true, d.checkRender},
+	}
+}
+
+// checkConfig validates the config file and OAuth settings
+func (d *doctor) checkConfig(ctx context.Context) (string, error) {
+	d.config = DefaultConfig()
+	if err := readConfigFile(d.configPath, &d.config); err != nil {
+		return "", err
+	}
+	if err := PreflightOAuthConfig(&d.config); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s, %s app, min score %d, min comments %d, %s feed",
+		d.configPath, appTypeLabel(d.config.AppType), d.config.ScoreFilter, d.config.CommentFilter, d.config.FeedType), nil
+}
+
+// checkDatabase opens the cache database and verifies its integrity
+func (d *doctor) checkDatabase(ctx context.Context) (string, error) {
+	db, err := InitOpenGraphDB()
+	if err != nil {
+		return "", err
+	}
+	d.db = db
+
+	if err := db.CheckIntegrity(); err != nil {
+		return "", err
+	}
+	size, err := db.GetDatabaseSize()
+	if err != nil {
+		return "", err
+	}
+
+	detail := fmt.Sprintf("%s, %d KB, integrity ok", CacheDBPath, size/1024)
+	if b, err := db.GetBackoff("/best"); err == nil && b != nil && time.Now().Before(b.NextAttempt) {
+		detail += fmt.Sprintf("; homepage fetches backing off until %s after %d failures",
+			b.NextAttempt.Format(time.RFC3339), b.Failures)
+	}
+	return detail, nil
+}
+
+// checkNetwork verifies that the Reddit endpoints can be reached. Any HTTP response
+// counts, authentication is checked separately.
+func (d *doctor) checkNetwork(ctx context.Context) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var reached []string
+	for _, endpoint := range []string{"https://www.reddit.com/api/v1/access_token", "https://oauth.reddit.com/api/v1/me"} {
+		req, err := http.NewRequestWithContext(ctx, "HEAD", endpoint, nil)
+		if err != nil {
+			return "", err
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("cannot reach %s: %w", req.URL.Host, err)
+		}
+		resp.Body.Close()
+		reached = append(reached, fmt.Sprintf("%s in %s", req.URL.Host, time.Since(start).Round(time.Millisecond)))
+	}
+	return strings.Join(reached, ", "), nil
+}
+
+// checkAuth gets a fresh token and verifies that it grants the scopes the feed needs
+func (d *doctor) checkAuth(ctx context.Context) (string, error) {
+	if d.config.RefreshToken == "" && d.config.AppType != AppTypeScript {
+		return "", fmt.Errorf("not authenticated yet, run red-rss once to authorize")
+	}
+
+	oauthConfig := newOAuth2Config(&d.config)
+	source := NewRefreshableTokenSource(ctx, oauthConfig, d.config.AppType, &oauth2.Token{RefreshToken: d.config.RefreshToken})
+	token, err := source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token: %w", err)
+	}
+
+	// Keep the fresh token, it also proves the refresh token still works
+	d.config.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		d.config.RefreshToken = token.RefreshToken
+	}
+	d.config.ExpiresAt = token.Expiry
+	if err := saveConfigFile(d.configPath, &d.config); err != nil {
+		return "", err
+	}
+
+	if granted, ok := token.Extra("scope").(string); ok {
+		scopes := strings.Fields(strings.ReplaceAll(granted, ",", " "))
+		var missing []string
+		for _, scope := range oauthConfig.Scopes {
+			if !slices.Contains(scopes, scope) && !slices.Contains(scopes, "*") {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			return "", fmt.Errorf("token lacks scopes %s, authorize again", strings.Join(missing, ", "))
+		}
+	}
+
+	d.client = NewRetryingClient(ctx, source)
+	return fmt.Sprintf("token valid until %s, scopes %s", token.Expiry.Format(time.RFC3339), strings.Join(oauthConfig.Scopes, ", ")), nil
+}
+
+// checkFetch fetches a single homepage post. It ignores any persisted backoff.
+func (d *doctor) checkFetch(ctx context.Context) (string, error) {
+	if d.client == nil {
+		return "", errSkipped
+	}
+
+	api := NewRedditAPI(d.client)
+	api.SetIdentity(d.config.ClientID, d.config.UserAgent)
+	posts, err := api.FetchListing(ctx, "/best", url.Values{"limit": {"1"}})
+	if err != nil {
+		return "", err
+	}
+	if len(posts) == 0 {
+		return "", fmt.Errorf("the homepage listing is empty")
+	}
+
+	d.posts = posts
+	return fmt.Sprintf("r/%s: %s (%d points)", posts[0].Data.Subreddit, posts[0].Data.Title, posts[0].Data.Score), nil
+}
+
+// checkEnrich fetches the OpenGraph data of a known page, bypassing the cache
+func (d *doctor) checkEnrich(ctx context.Context) (string, error) {
+	og, err := NewOpenGraphFetcher(nil).FetchOpenGraphDataContext(ctx, d.enrichURL)
+	if err != nil {
+		return "", err
+	}
+	if og.Title == "" && og.Description == "" {
+		return "", fmt.Errorf("no OpenGraph data found at %s", d.enrichURL)
+	}
+
+	d.og = og
+	return fmt.Sprintf("%s: %s", d.enrichURL, og.Title), nil
+}
+
+// checkRender renders the fetched post, or a sample one, to a temporary feed file
+func (d *doctor) checkRender(ctx context.Context) (string, error) {
+	posts := d.posts
+	if len(posts) == 0 {
+		var sample RedditPost
+		sample.Data = PostData{
+			Name:      "t3_sample",
+			Title:     "Sample post",
+			URL:       d.enrichURL,
+			Permalink: "/r/test/comments/sample/sample_post/",
+			Subreddit: "test",
+			Author:    "red-rss",
+		}
+		posts = []RedditPost{sample}
+	}
+
+	ogData := make(map[string]*OpenGraphData)
+	if d.og != nil {
+		ogData[d.enrichURL] = d.og
+	}
+
+	content, err := NewFeedGenerator(NewOpenGraphFetcher(nil)).RenderFeed(posts, ogData, d.config.FeedType, d.config.EnhancedAtom)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "red-rss-doctor-*.xml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s feed with %d items written to %s", d.config.FeedType, len(posts), f.Name()), nil
+}
+
+// runDoctor implements the doctor subcommand
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	enrichURL := fs.String("url", "https://go.dev/", "page to test OpenGraph enrichment with")
+	fs.Parse(args)
+
+	d := &doctor{configPath: *configPath, enrichURL: *enrichURL}
+	defer func() {
+		if d.db != nil {
+			d.db.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	failed := 0
+	configOK := true
+	for _, check := range d.checks() {
+		var detail string
+		var err error
+		if configOK || !check.needsConfig {
+			detail, err = check.run(ctx)
+		} else {
+			err = errSkipped
+		}
+
+		switch {
+		case errors.Is(err, errSkipped):
+			fmt.Printf("-  %-9s skipped\n", check.name)
+		case err != nil:
+			fmt.Printf("✗  %-9s %v\n", check.name, err)
+			failed++
+			if check.name == "config" {
+				configOK = false
+			}
+		default:
+			fmt.Printf("✓  %-9s %s\n", check.name, detail)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	fmt.Println("\nAll checks passed")
+	return nil
+}
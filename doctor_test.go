@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorRendersSampleFeed(t *testing.T) {
+	d := &doctor{enrichURL: "https://go.dev/", config: Config{FeedType: "atom"}}
+	d.og = &OpenGraphData{URL: d.enrichURL, Title: "The Go Programming Language"}
+
+	detail, err := d.checkRender(context.Background())
+	if err != nil {
+		t.Fatalf("checkRender failed: %v", err)
+	}
+
+	path := detail[strings.LastIndex(detail, " ")+1:]
+	t.Cleanup(func() { os.Remove(path) })
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the sample feed at %s: %v", path, err)
+	}
+	if !strings.Contains(string(content), "Sample post") {
+		t.Errorf("expected the sample post in the feed:\n%s", content)
+	}
+}
+
+func TestDoctorRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"client_id": "not a client id", "feed_type": "rss"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &doctor{configPath: path}
+	if _, err := d.checkConfig(context.Background()); err == nil {
+		t.Error("expected an invalid client ID to fail the config check")
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	if err := newTestDB(t).CheckIntegrity(); err != nil {
+		t.Errorf("expected a fresh database to pass, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainList matches URLs by host against domains and their subdomains. An entry with
+// a path, such as reddit.com/gallery, only matches URLs under that path. Hosts and
+// entries are compared in their ASCII form, so internationalized domains match however
+// they are written.
+type DomainList []string
+
+// DefaultRedditDomains are the domains of Reddit's own pages and media
+var DefaultRedditDomains = DomainList{"reddit.com", "redd.it"}
+
+// DefaultBlockedDomains are the domains whose pages have no useful OpenGraph data
+// without logging in
+var DefaultBlockedDomains = DomainList{
+	"x.com",
+	"twitter.com",
+	"facebook.com",
+	"instagram.com",
+	"linkedin.com",
+	"i.redd.it",          // Reddit image URLs don't have useful OpenGraph
+	"v.redd.it",          // Reddit video URLs don't have useful OpenGraph
+	"reddit.com/gallery", // Reddit gallery URLs don't have useful OpenGraph
+}
+
+// Matches reports whether the host of rawURL is one of the domains or their subdomains
+func (l DomainList) Matches(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, entry := range l {
+		domain, path, _ := strings.Cut(entry, "/")
+		domain = normalizeHost(domain)
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			continue
+		}
+		if path == "" || u.Path == "/"+path || strings.HasPrefix(u.Path, "/"+strings.TrimSuffix(path, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost returns a host name in lowercase ASCII without a trailing dot
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+	return host
+}
+
+// validateDomainList checks that the entries of a domain list are domains that can be
+// registered, as a public suffix such as co.uk would match unrelated sites
+func validateDomainList(name string, l DomainList) error {
+	for _, entry := range l {
+		domain, _, _ := strings.Cut(entry, "/")
+		domain = normalizeHost(domain)
+		if domain == "" || strings.ContainsAny(domain, ":@ ") {
+			return fmt.Errorf("%s has an invalid domain %q", name, entry)
+		}
+		if _, err := publicsuffix.EffectiveTLDPlusOne(domain); err != nil {
+			return fmt.Errorf("%s has %q, which is a public suffix rather than a domain", name, entry)
+		}
+	}
+	return nil
+}
+
+// redditDomains returns the domains treated as Reddit's own
+func (config *Config) redditDomains() DomainList {
+	if config.RedditDomains != nil {
+		return DomainList(config.RedditDomains)
+	}
+	return DefaultRedditDomains
+}
+
+// blockedDomains returns the domains whose pages aren't fetched
+func (config *Config) blockedDomains() DomainList {
+	if config.BlockedDomains != nil {
+		return DomainList(config.BlockedDomains)
+	}
+	return DefaultBlockedDomains
+}
+
+// isRedditURL checks if a URL is a Reddit URL by the default domains
+func isRedditURL(url string) bool {
+	return DefaultRedditDomains.Matches(url)
+}
+
+// isBlockedURL checks if a URL is from a domain that blocks external access by the
+// default domains
+func isBlockedURL(url string) bool {
+	return DefaultBlockedDomains.Matches(url)
+}
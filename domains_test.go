@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDomainListMatches(t *testing.T) {
+	list := DomainList{"bücher.example", "example.org/private"}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://xn--bcher-kva.example/", true},
+		{"https://shop.bücher.example/", true},
+		{"https://BÜCHER.example/", true},
+		{"https://otherbücher.example/", false},
+		{"https://example.org/private/page", true},
+		{"https://example.org/privateer", false},
+		{"https://example.org/", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := list.Matches(tt.url); got != tt.want {
+			t.Errorf("Matches(%q) = %v, expected %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestConfiguredDomains(t *testing.T) {
+	config := DefaultConfig()
+	config.ClientID = "id"
+	config.BlockedDomains = []string{"paywall.example"}
+	if err := validateConfig(&config); err != nil {
+		t.Fatalf("expected a valid config: %v", err)
+	}
+	if !config.blockedDomains().Matches("https://www.paywall.example/a") || config.blockedDomains().Matches("https://x.com/a") {
+		t.Errorf("expected blocked_domains to replace the defaults")
+	}
+	if !config.redditDomains().Matches("https://old.reddit.com/r/golang") {
+		t.Errorf("expected the default Reddit domains")
+	}
+
+	config.BlockedDomains = []string{"co.uk"}
+	if err := validateConfig(&config); err == nil || !strings.Contains(err.Error(), "public suffix") {
+		t.Errorf("expected public suffixes to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// onlyNewPosts drops posts that were in an earlier feed, when only_new_posts is set
+func (p *Pipeline) onlyNewPosts(posts []RedditPost, opts RunOptions) []RedditPost {
+	if !p.config.OnlyNewPosts || p.db == nil || opts.Offline {
+		return posts
+	}
+
+	permalinks := make([]string, 0, len(posts))
+	for _, post := range posts {
+		permalinks = append(permalinks, post.Data.Permalink)
+	}
+	emitted, err := p.db.GetEmittedPosts(runKey(opts), permalinks)
+	if err != nil {
+		slog.Warn("Failed to load emitted posts", "error", err)
+		return posts
+	}
+
+	var fresh []RedditPost
+	for _, post := range posts {
+		if !emitted[post.Data.Permalink] {
+			fresh = append(fresh, post)
+		}
+	}
+	slog.Debug("Dropped posts of earlier feeds", "count", len(posts)-len(fresh))
+	return fresh
+}
+
+// recordEmittedPosts remembers the posts of a generated feed for only_new_posts,
+// tombstones and notifications
+func (p *Pipeline) recordEmittedPosts(posts []RedditPost, opts RunOptions) {
+	needed := p.config.OnlyNewPosts || p.config.RemovedPosts == RemovedTombstone || p.notifying()
+	if !needed || p.db == nil || opts.Offline {
+		return
+	}
+	if err := p.db.SaveEmittedPosts(runKey(opts), posts, time.Now()); err != nil {
+		slog.Warn("Failed to record emitted posts", "error", err)
+	}
+}
+
+// GetEmittedPosts returns which of the permalinks were in an earlier feed
+func (ogDB *OpenGraphDB) GetEmittedPosts(feed string, permalinks []string) (map[string]bool, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	emitted := make(map[string]bool)
+	for _, permalink := range permalinks {
+		var count int
+		err := ogDB.db.QueryRow(`SELECT COUNT(*) FROM posts_seen WHERE feed = ? AND permalink = ?`, feed, permalink).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load emitted posts: %w", err)
+		}
+		emitted[permalink] = count > 0
+	}
+	return emitted, nil
+}
+
+// CountEmittedPosts returns the number of posts recorded for a feed
+func (ogDB *OpenGraphDB) CountEmittedPosts(feed string) (int, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var count int
+	if err := ogDB.db.QueryRow(`SELECT COUNT(*) FROM posts_seen WHERE feed = ?`, feed).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count emitted posts: %w", err)
+	}
+	return count, nil
+}
+
+// SaveEmittedPosts records the permalinks of the posts of a feed, keeping when each
+// was first emitted
+func (ogDB *OpenGraphDB) SaveEmittedPosts(feed string, posts []RedditPost, at time.Time) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, post := range posts {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO posts_seen (feed, permalink, emitted_at) VALUES (?, ?, ?)`,
+			feed, post.Data.Permalink, at.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to save emitted post: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestOnlyNewPosts(t *testing.T) {
+	post := func(name string) RedditPost {
+		p := seenPost(name, 10)
+		p.Data.Permalink = "/r/test/comments/" + name + "/"
+		return p
+	}
+
+	p := NewPipeline(&Config{OnlyNewPosts: true}, nil, newTestDB(t))
+	opts := DefaultRunOptions()
+	first := p.onlyNewPosts([]RedditPost{post("t3_a"), post("t3_b")}, opts)
+	if len(first) != 2 {
+		t.Fatalf("expected both posts in the first feed, got %d", len(first))
+	}
+	p.recordEmittedPosts(first, opts)
+
+	second := p.onlyNewPosts([]RedditPost{post("t3_b"), post("t3_c")}, opts)
+	if len(second) != 1 || second[0].Data.Name != "t3_c" {
+		t.Errorf("expected only t3_c to be new, got %v", second)
+	}
+
+	// Other feeds keep their own record
+	if other := p.onlyNewPosts([]RedditPost{post("t3_a")}, RunOptions{Subreddit: "golang"}); len(other) != 1 {
+		t.Errorf("expected t3_a to be new in r/golang")
+	}
+
+	p.config.OnlyNewPosts = false
+	if all := p.onlyNewPosts([]RedditPost{post("t3_a"), post("t3_b")}, opts); len(all) != 2 {
+		t.Errorf("expected no filtering without only_new_posts")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // event_timezone works in containers without a zone database
+)
+
+// eventTimeLayout formats event times with their time zone
+const eventTimeLayout = "Mon, 02 Jan 2006 15:04 MST"
+
+// EventStarts returns when the event of a scheduled post such as an AMA or a match
+// thread starts, zero for other posts
+func (d *PostData) EventStarts() time.Time {
+	if d.EventStart == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(d.EventStart), 0)
+}
+
+// EventEnds returns when the event of a scheduled post ends, zero when unknown
+func (d *PostData) EventEnds() time.Time {
+	if d.EventEnd == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(d.EventEnd), 0)
+}
+
+// eventLocation returns the time zone event times are shown in
+func (c *Config) eventLocation() *time.Location {
+	if loc, err := time.LoadLocation(c.EventTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// eventLeadTime returns how long before they start event posts are included, 0 to
+// include them right away
+func (c *Config) eventLeadTime() time.Duration {
+	if d, err := time.ParseDuration(c.EventLeadTime); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// eventNear reports whether a post is no event, or one starting within the lead time
+func (p *Pipeline) eventNear(post RedditPost) bool {
+	lead := p.config.eventLeadTime()
+	start := post.Data.EventStarts()
+	if lead == 0 || start.IsZero() {
+		return true
+	}
+	return !p.now().Add(lead).Before(start)
+}
+
+// eventStatus describes when an event takes place in loc, and whether it is live or over
+func eventStatus(data *PostData, loc *time.Location, now time.Time) string {
+	start, end := data.EventStarts().In(loc), data.EventEnds().In(loc)
+	status := start.Format(eventTimeLayout)
+	if !data.EventEnds().IsZero() {
+		if start.YearDay() == end.YearDay() && start.Year() == end.Year() {
+			status += " – " + end.Format("15:04")
+		} else {
+			status += " – " + end.Format(eventTimeLayout)
+		}
+	}
+
+	switch {
+	case data.EventIsLive || (!now.Before(start) && (end.IsZero() || now.Before(end))):
+		return status + " (live)"
+	case !end.IsZero() && !now.Before(end):
+		return status + " (ended)"
+	}
+	return status
+}
+
+// eventText renders the event times for item descriptions
+func eventText(data *PostData, loc *time.Location) string {
+	return fmt.Sprintf("\n\nEvent: %s", eventStatus(data, loc, time.Now()))
+}
+
+// eventHTML renders the event times for enhanced Atom content
+func eventHTML(data *PostData, loc *time.Location) string {
+	return fmt.Sprintf(`<p class="event"><strong>📅 Event:</strong> <time datetime="%s">%s</time></p>`,
+		data.EventStarts().UTC().Format(time.RFC3339), escapeXML(eventStatus(data, loc, time.Now())))
+}
+
+// eventLocation returns the time zone event times are rendered in
+func (fg *FeedGenerator) eventLocation() *time.Location {
+	if fg.options.EventLocation == nil {
+		return time.UTC
+	}
+	return fg.options.EventLocation
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStatus(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := &PostData{EventStart: float64(start.Unix()), EventEnd: float64(start.Add(2 * time.Hour).Unix())}
+	helsinki, err := time.LoadLocation("Europe/Helsinki")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := eventStatus(data, helsinki, start.Add(-time.Hour)), "Sat, 01 Jun 2024 15:00 EEST – 17:00"; got != want {
+		t.Errorf("eventStatus() = %q, want %q", got, want)
+	}
+	if got := eventStatus(data, time.UTC, start.Add(time.Hour)); !strings.HasSuffix(got, "14:00 (live)") {
+		t.Errorf("expected a live event, got %q", got)
+	}
+	if got := eventStatus(data, time.UTC, start.Add(3*time.Hour)); !strings.HasSuffix(got, "(ended)") {
+		t.Errorf("expected an ended event, got %q", got)
+	}
+
+	data.EventEnd = float64(start.Add(36 * time.Hour).Unix())
+	if got := eventStatus(data, time.UTC, start.Add(-time.Hour)); got != "Sat, 01 Jun 2024 12:00 UTC – Mon, 03 Jun 2024 00:00 UTC" {
+		t.Errorf("expected the end date of a multi-day event, got %q", got)
+	}
+}
+
+func TestEventLeadTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := func(name string, start time.Time) RedditPost {
+		post := seenPost(name, 10)
+		post.Data.EventStart = float64(start.Unix())
+		return post
+	}
+	posts := []RedditPost{
+		event("t3_soon", now.Add(time.Hour)),
+		event("t3_later", now.Add(24*time.Hour)),
+		event("t3_live", now.Add(-time.Hour)),
+		seenPost("t3_plain", 10),
+	}
+
+	p := &Pipeline{config: &Config{EventLeadTime: "2h"}, clock: func() time.Time { return now }}
+	stats := FilterStats{}
+	kept := p.applyContentFilters(posts, stats)
+	if len(kept) != 3 || stats[RuleEventLeadTime] != 1 {
+		t.Errorf("expected only the event a day ahead to be held back, got %v, %v", kept, stats)
+	}
+
+	p.config.EventLeadTime = ""
+	if kept := p.applyContentFilters(posts, FilterStats{}); len(kept) != 4 {
+		t.Errorf("expected all posts without a lead time, got %d", len(kept))
+	}
+
+	if err := validateConfig(&Config{ClientID: "id", FeedType: "rss", OutputPath: "a.xml", EventTimezone: "Mars/Olympus"}); err == nil {
+		t.Error("expected an unknown time zone to be rejected")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fediverseScheme is the scheme of instance URLs, replaced in tests
+var fediverseScheme = "https"
+
+// fediverseLimit is the number of posts fetched per community, the most Lemmy serves
+// per page
+const fediverseLimit = 50
+
+// validCommunity matches Lemmy community and kbin magazine names
+var validCommunity = regexp.MustCompile(`^[A-Za-z0-9_]{1,100}$`)
+
+// parseFediverseSource splits a source such as "lemmy:technology@lemmy.world" into
+// its origin, community and instance
+func parseFediverseSource(spec string) (origin, community, instance string, err error) {
+	origin, rest, ok := strings.Cut(spec, ":")
+	if ok && (origin == OriginLemmy || origin == OriginKbin) {
+		community, instance, ok = strings.Cut(rest, "@")
+		if u, err := url.Parse("https://" + instance); ok && err == nil && validCommunity.MatchString(community) &&
+			instance != "" && u.Host == instance && u.User == nil {
+			return origin, community, instance, nil
+		}
+	}
+	return "", "", "", fmt.Errorf(`source must be "homepage", "popular", "all", a subreddit such as "r/golang", a community such as "lemmy:technology@lemmy.world" or "kbin:tech@kbin.social" or "hn:front", got %q`, spec)
+}
+
+// LemmySource is a Lemmy community, fetched from the public API of an instance
+type LemmySource struct {
+	Instance  string // Host of the instance, e.g. "lemmy.world"
+	Community string // Community name, e.g. "technology"
+}
+
+// lemmyPostView is a post of a Lemmy /api/v3/post/list response
+type lemmyPostView struct {
+	Post struct {
+		ID                int    `json:"id"`
+		Name              string `json:"name"`
+		URL               string `json:"url"`
+		Body              string `json:"body"`
+		Published         string `json:"published"`
+		NSFW              bool   `json:"nsfw"`
+		APID              string `json:"ap_id"`
+		ThumbnailURL      string `json:"thumbnail_url"`
+		Removed           bool   `json:"removed"`
+		Deleted           bool   `json:"deleted"`
+		FeaturedCommunity bool   `json:"featured_community"`
+	} `json:"post"`
+	Creator struct {
+		Name    string `json:"name"`
+		ActorID string `json:"actor_id"`
+	} `json:"creator"`
+	Community struct {
+		Name    string `json:"name"`
+		ActorID string `json:"actor_id"`
+		NSFW    bool   `json:"nsfw"`
+	} `json:"community"`
+	Counts struct {
+		Score     int `json:"score"`
+		Upvotes   int `json:"upvotes"`
+		Downvotes int `json:"downvotes"`
+		Comments  int `json:"comments"`
+	} `json:"counts"`
+}
+
+func (s *LemmySource) Name() string {
+	return OriginLemmy + ":" + s.Community + "@" + s.Instance
+}
+
+// Fetch fetches the hot posts of the community
+func (s *LemmySource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	params := url.Values{
+		"community_name": {s.Community},
+		"sort":           {"Hot"},
+		"limit":          {strconv.Itoa(fediverseLimit)},
+	}
+	var listing struct {
+		Posts []lemmyPostView `json:"posts"`
+	}
+	if err := fetchSourceJSON(ctx, fediverseScheme+"://"+s.Instance+"/api/v3/post/list?"+params.Encode(), &listing); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", s.Name(), err)
+	}
+
+	posts := make([]RedditPost, 0, len(listing.Posts))
+	for _, view := range listing.Posts {
+		posts = append(posts, view.redditPost(s.Instance))
+	}
+	slog.Info("Successfully fetched Lemmy posts", "community", s.Name(), "count", len(posts))
+	return posts, nil
+}
+
+// redditPost converts a Lemmy post. The score is upvotes minus downvotes as on Reddit.
+func (v lemmyPostView) redditPost(instance string) RedditPost {
+	discussion := fediverseScheme + "://" + instance + "/post/" + strconv.Itoa(v.Post.ID)
+	community := hostOf(v.Community.ActorID)
+	if community == "" {
+		community = instance
+	}
+	data := PostData{
+		ID:          strconv.Itoa(v.Post.ID),
+		Name:        fediverseName(OriginLemmy, v.Post.APID, discussion),
+		Title:       v.Post.Name,
+		URL:         v.Post.URL,
+		Permalink:   discussion,
+		CreatedUTC:  fediverseTime(v.Post.Published),
+		Score:       v.Counts.Score,
+		NumComments: v.Counts.Comments,
+		Author:      v.Creator.Name,
+		AuthorURL:   v.Creator.ActorID,
+		Subreddit:   v.Community.Name + "@" + community,
+		Over18:      v.Post.NSFW || v.Community.NSFW,
+		Stickied:    v.Post.FeaturedCommunity,
+		UpvoteRatio: upvoteRatio(v.Counts.Upvotes, v.Counts.Downvotes),
+		Selftext:    v.Post.Body,
+		Thumbnail:   v.Post.ThumbnailURL,
+		Origin:      OriginLemmy,
+	}
+	switch {
+	case v.Post.Removed:
+		data.RemovedByCategory = "moderator"
+	case v.Post.Deleted:
+		data.RemovedByCategory = "deleted"
+	}
+	setSourceLink(&data, v.Community.Name)
+	return RedditPost{Kind: "t3", Data: data}
+}
+
+// KbinSource is a kbin or Mbin magazine, fetched from the public API of an instance
+type KbinSource struct {
+	Instance string // Host of the instance, e.g. "kbin.social"
+	Magazine string // Magazine name, e.g. "tech"
+
+	magazineID int // Looked up by name on the first fetch
+}
+
+// kbinEntry is a thread of a kbin /api/magazine/{id}/entries response
+type kbinEntry struct {
+	EntryID  int `json:"entryId"`
+	Magazine struct {
+		Name string `json:"name"`
+		APID string `json:"apId"` // "name@host" for magazines of other instances
+	} `json:"magazine"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Body        string `json:"body"`
+	Upvotes     int    `json:"uv"`
+	Downvotes   int    `json:"dv"`
+	Favourites  int    `json:"favourites"`
+	NumComments int    `json:"numComments"`
+	CreatedAt   string `json:"createdAt"`
+	IsAdult     bool   `json:"isAdult"`
+	IsPinned    bool   `json:"isPinned"`
+	APID        string `json:"apId"`
+	Visibility  string `json:"visibility"`
+	Image       *struct {
+		SourceURL string `json:"sourceUrl"`
+	} `json:"image"`
+}
+
+func (s *KbinSource) Name() string {
+	return OriginKbin + ":" + s.Magazine + "@" + s.Instance
+}
+
+// Fetch fetches the hot threads of the magazine
+func (s *KbinSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	base := fediverseScheme + "://" + s.Instance + "/api/magazine"
+	if s.magazineID == 0 {
+		var magazine struct {
+			MagazineID int `json:"magazineId"`
+		}
+		if err := fetchSourceJSON(ctx, base+"/name/"+url.PathEscape(s.Magazine), &magazine); err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", s.Name(), err)
+		}
+		s.magazineID = magazine.MagazineID
+	}
+
+	params := url.Values{"sort": {"hot"}, "perPage": {strconv.Itoa(fediverseLimit)}}
+	var listing struct {
+		Items []kbinEntry `json:"items"`
+	}
+	if err := fetchSourceJSON(ctx, base+"/"+strconv.Itoa(s.magazineID)+"/entries?"+params.Encode(), &listing); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", s.Name(), err)
+	}
+
+	posts := make([]RedditPost, 0, len(listing.Items))
+	for _, entry := range listing.Items {
+		posts = append(posts, entry.redditPost(s.Instance))
+	}
+	slog.Info("Successfully fetched kbin posts", "magazine", s.Name(), "count", len(posts))
+	return posts, nil
+}
+
+// redditPost converts a kbin thread. Favourites count as upvotes next to boosts, as
+// kbin ranks threads by both.
+func (e kbinEntry) redditPost(instance string) RedditPost {
+	magazine := e.Magazine.Name + "@" + instance
+	if strings.Contains(e.Magazine.APID, "@") {
+		magazine = e.Magazine.APID
+	}
+	discussion := fediverseScheme + "://" + instance + "/m/" + url.PathEscape(e.Magazine.Name) + "/t/" + strconv.Itoa(e.EntryID)
+	upvotes := e.Upvotes + e.Favourites
+	data := PostData{
+		ID:          strconv.Itoa(e.EntryID),
+		Name:        fediverseName(OriginKbin, e.APID, discussion),
+		Title:       e.Title,
+		URL:         e.URL,
+		Permalink:   discussion,
+		CreatedUTC:  fediverseTime(e.CreatedAt),
+		Score:       upvotes - e.Downvotes,
+		NumComments: e.NumComments,
+		Author:      e.User.Username,
+		AuthorURL:   fediverseScheme + "://" + instance + "/u/" + url.PathEscape(e.User.Username),
+		Subreddit:   magazine,
+		Over18:      e.IsAdult,
+		Stickied:    e.IsPinned,
+		UpvoteRatio: upvoteRatio(upvotes, e.Downvotes),
+		Selftext:    e.Body,
+		Origin:      OriginKbin,
+	}
+	if e.Image != nil {
+		data.Thumbnail = e.Image.SourceURL
+	}
+	switch e.Visibility {
+	case "trashed":
+		data.RemovedByCategory = "moderator"
+	case "soft_deleted":
+		data.RemovedByCategory = "deleted"
+	}
+	setSourceLink(&data, e.Magazine.Name)
+	return RedditPost{Kind: "t3", Data: data}
+}
+
+// fediverseName returns the fullname of a fediverse post. The ActivityPub ID is the
+// same on every instance, so a post federated to two instances is only kept once.
+func fediverseName(origin, apID, discussion string) string {
+	if apID == "" {
+		apID = discussion
+	}
+	return origin + ":" + apID
+}
+
+// fediverseTime parses a timestamp of the Lemmy or kbin API. Older Lemmy versions
+// leave out the time zone, which is UTC.
+func fediverseTime(value string) float64 {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return float64(t.Unix())
+		}
+	}
+	return 0
+}
+
+// upvoteRatio returns the share of upvotes, 1 for posts nobody voted on
+func upvoteRatio(upvotes, downvotes int) float64 {
+	if upvotes+downvotes <= 0 {
+		return 1
+	}
+	return float64(upvotes) / float64(upvotes+downvotes)
+}
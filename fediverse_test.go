@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFediverseSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/post/list":
+			if r.URL.Query().Get("community_name") != "technology" {
+				http.Error(w, `{"error":"couldnt_find_community"}`, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"posts": [
+				{"post": {"id": 7, "name": "Lemmy link", "url": "https://www.example.com/a", "published": "2024-05-01T10:00:00.123456Z", "ap_id": "https://lemmy.world/post/7"},
+				 "creator": {"name": "alice", "actor_id": "https://lemmy.world/u/alice"},
+				 "community": {"name": "technology", "actor_id": "https://lemmy.world/c/technology"},
+				 "counts": {"score": 40, "upvotes": 45, "downvotes": 5, "comments": 12}},
+				{"post": {"id": 8, "name": "Lemmy text", "body": "Hello", "published": "2024-05-01T11:00:00.5", "ap_id": "https://lemmy.world/post/8"},
+				 "creator": {"name": "bob"},
+				 "community": {"name": "technology", "actor_id": "https://lemmy.world/c/technology"},
+				 "counts": {"score": 3, "upvotes": 3, "downvotes": 0, "comments": 0}}
+			]}`))
+		case "/api/magazine/name/tech":
+			w.Write([]byte(`{"magazineId": 42}`))
+		case "/api/magazine/42/entries":
+			w.Write([]byte(`{"items": [
+				{"entryId": 9, "magazine": {"name": "tech"}, "user": {"username": "carol"}, "title": "kbin link",
+				 "url": "https://example.org/b", "uv": 4, "dv": 1, "favourites": 20, "numComments": 6,
+				 "createdAt": "2024-05-01T12:00:00+00:00", "image": {"sourceUrl": "https://example.org/b.png"}}
+			]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	orig := fediverseScheme
+	fediverseScheme = "http"
+	defer func() { fediverseScheme = orig }()
+	instance := strings.TrimPrefix(server.URL, "http://")
+
+	p := &Pipeline{config: &Config{Blend: []BlendSource{
+		{Source: "lemmy:technology@" + instance},
+		{Source: "lemmy:missing@" + instance},
+		{Source: "kbin:tech@" + instance},
+	}}}
+	posts, err := p.fetchBlend(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("fetchBlend failed: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts from the working sources, got %d", len(posts))
+	}
+
+	link, kbin, text := posts[0].Data, posts[1].Data, posts[2].Data
+	if link.Name != "lemmy:https://lemmy.world/post/7" || link.Origin != OriginLemmy || link.onReddit() {
+		t.Errorf("unexpected Lemmy post identity: %+v", link)
+	}
+	if link.Score != 40 || link.NumComments != 12 || link.UpvoteRatio != 0.9 || link.Domain != "example.com" {
+		t.Errorf("unexpected Lemmy post counts: %+v", link)
+	}
+	if link.CreatedUTC != 1714557600 || link.community() != "!technology@lemmy.world" {
+		t.Errorf("unexpected Lemmy post metadata: %v %s", link.CreatedUTC, link.community())
+	}
+	if want := server.URL + "/post/7"; link.discussionURL() != want {
+		t.Errorf("expected discussion %s, got %s", want, link.discussionURL())
+	}
+	if !text.IsSelf || text.URL != server.URL+"/post/8" || text.Selftext != "Hello" || text.CreatedUTC == 0 {
+		t.Errorf("unexpected Lemmy text post: %+v", text)
+	}
+
+	if kbin.Origin != OriginKbin || kbin.Score != 23 || kbin.NumComments != 6 || kbin.Thumbnail != "https://example.org/b.png" {
+		t.Errorf("unexpected kbin post: %+v", kbin)
+	}
+	if want := server.URL + "/m/tech/t/9"; kbin.Permalink != want || kbin.Name != OriginKbin+":"+want {
+		t.Errorf("unexpected kbin permalink %s, name %s", kbin.Permalink, kbin.Name)
+	}
+}
+
+func TestValidateSource(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{HomepageFeed, false},
+		{BlendPopular, false},
+		{"r/golang", false},
+		{"lemmy:technology@lemmy.world", false},
+		{"kbin:tech@kbin.social", false},
+		{"hn:front", false},
+		{"hn:best", true},
+		{"golang", true},
+		{"r/", true},
+		{"lemmy:technology", true},
+		{"lemmy:tech nology@lemmy.world", true},
+		{"lemmy:technology@lemmy.world/path", true},
+		{"mastodon:technology@mastodon.social", true},
+	}
+	for _, tt := range tests {
+		if err := validateSource(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("validateSource(%q) = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -13,6 +14,28 @@ import (
 // FeedGenerator handles RSS/Atom feed generation
 type FeedGenerator struct {
 	ogFetcher *OpenGraphFetcher
+	updated   time.Time // Updated time of the feed, zero for the time of rendering
+	clock     Clock     // Time of rendering, the system clock when nil
+	options   FeedOptions
+	image     *ImageSource // Image of the feed, none when nil
+}
+
+// FeedOptions selects optional information rendered into feed items
+type FeedOptions struct {
+	ShowFlair            bool // Author flair and [MOD]/[ADMIN] labels
+	ShowAwards           bool // Award counts and gildings
+	ImageEnclosures      bool // i.redd.it images as enclosures and inline images
+	PreviewEnclosures    bool // Preview images, thumbnails or og:image as enclosures and media:content
+	ReadingTime          bool // Reading time estimates of linked articles
+	MaxDescriptionLength int  // Characters of item descriptions, 0 for no limit
+
+	EventLocation *time.Location // Time zone of event start times, UTC when nil
+	Provenance    *Provenance    // Run the items' provenance footers describe, none when nil
+
+	Subreddit     string // Subreddit the feed is about, empty for the homepage
+	Subscriptions bool   // The feed merges the subscribed subreddits
+	Friends       bool   // The feed is made of posts by friends and followed users
+	Collection    string // SavedFeed, UpvotedFeed or RepliesFeed when the feed is made of the user's saved or upvoted posts or replies
 }
 
 // NewFeedGenerator creates a new feed generator with OpenGraph fetcher
@@ -22,20 +45,114 @@ func NewFeedGenerator(ogFetcher *OpenGraphFetcher) *FeedGenerator {
 	}
 }
 
+// SetOptions sets which optional information is rendered into items
+func (fg *FeedGenerator) SetOptions(options FeedOptions) {
+	fg.options = options
+}
+
+// feedSubject describes the listing the feed is made of
+func (fg *FeedGenerator) feedSubject() string {
+	if fg.options.Subreddit != "" {
+		return "r/" + fg.options.Subreddit
+	}
+	if fg.options.Subscriptions {
+		return "subscribed subreddit"
+	}
+	if fg.options.Friends {
+		return "followed user"
+	}
+	if fg.options.Collection == RepliesFeed {
+		return "reply"
+	}
+	if fg.options.Collection != "" {
+		return fg.options.Collection
+	}
+	return "Reddit homepage"
+}
+
+// feedTitle returns the title of the feed
+func (fg *FeedGenerator) feedTitle() string {
+	if fg.options.Subreddit != "" {
+		return "r/" + fg.options.Subreddit + " Feed"
+	}
+	if fg.options.Subscriptions {
+		return "My Subscribed Subreddits Feed"
+	}
+	if fg.options.Friends {
+		return "My Followed Users Feed"
+	}
+	if fg.options.Collection == SavedFeed {
+		return "My Saved Posts Feed"
+	}
+	if fg.options.Collection == UpvotedFeed {
+		return "My Upvoted Posts Feed"
+	}
+	if fg.options.Collection == RepliesFeed {
+		return "Replies to Me Feed"
+	}
+	return "My Reddit Homepage Feed"
+}
+
+// feedLink returns the Reddit page the feed is made of
+func (fg *FeedGenerator) feedLink() string {
+	if fg.options.Subreddit != "" {
+		return "https://www.reddit.com/r/" + fg.options.Subreddit + "/"
+	}
+	if fg.options.Subscriptions {
+		return "https://www.reddit.com/subreddits/mine/"
+	}
+	if fg.options.Friends {
+		return "https://www.reddit.com/prefs/friends/"
+	}
+	if fg.options.Collection == RepliesFeed {
+		return "https://www.reddit.com/message/inbox/"
+	}
+	if fg.options.Collection != "" {
+		return "https://www.reddit.com/user/me/" + fg.options.Collection + "/"
+	}
+	return "https://www.reddit.com/"
+}
+
+// SetUpdated sets the updated time written to the feed
+func (fg *FeedGenerator) SetUpdated(t time.Time) {
+	fg.updated = t
+}
+
+// SetImage sets the image of the feed, the channel image of RSS and the logo of Atom
+func (fg *FeedGenerator) SetImage(image *ImageSource) {
+	fg.image = image
+}
+
+// SetClock sets the clock the time of rendering comes from
+func (fg *FeedGenerator) SetClock(clock Clock) {
+	fg.clock = clock
+}
+
+// updatedTime returns the updated time of the feed in UTC, so the output doesn't
+// depend on the time zone of the host
+func (fg *FeedGenerator) updatedTime() time.Time {
+	if !fg.updated.IsZero() {
+		return fg.updated.UTC()
+	}
+	if fg.clock != nil {
+		return fg.clock().UTC()
+	}
+	return time.Now().UTC()
+}
+
 // GenerateFeed creates an RSS or Atom feed from the filtered Reddit posts
 func (fg *FeedGenerator) GenerateFeed(posts []RedditPost, feedType string) (*feeds.Feed, error) {
 	if feedType != "rss" && feedType != "atom" {
 		return nil, fmt.Errorf("unsupported feed type: %s", feedType)
 	}
 
-	now := time.Now()
-	feed := &feeds.Feed{
-		Title:       "My Reddit Homepage Feed",
-		Link:        &feeds.Link{Href: "https://www.reddit.com/"},
-		Description: "Filtered Reddit homepage posts generated by GoRedditFeedGenerator",
-		Author:      &feeds.Author{Name: "GoRedditFeedGenerator"},
-		Created:     now,
-		Updated:     now,
+	return fg.BuildFeed(posts, fg.FetchOpenGraph(context.Background(), posts), feedType)
+}
+
+// FetchOpenGraph fetches OpenGraph previews for the links of the posts
+func (fg *FeedGenerator) FetchOpenGraph(ctx context.Context, posts []RedditPost) map[string]*OpenGraphData {
+	if fg.ogFetcher == nil {
+		return nil
 	}
 
 	// Collect URLs for concurrent OpenGraph fetching
@@ -46,20 +163,42 @@ func (fg *FeedGenerator) GenerateFeed(posts []RedditPost, feedType string) (*fee
 			slog.Debug("Collected URL for OpenGraph", "url", post.Data.URL, "title", post.Data.Title)
 		}
 	}
+	urls = append(urls, galleryLinks(posts)...)
+	urls = append(urls, outboundLinks(posts)...)
 
 	// Fetch OpenGraph data concurrently
-	var ogData map[string]*OpenGraphData
-	if fg.ogFetcher != nil {
-		slog.Info("Fetching OpenGraph data", "url_count", len(urls))
-		ogData = fg.ogFetcher.FetchConcurrentOpenGraph(urls)
-		slog.Info("OpenGraph fetch completed", "results_count", len(ogData))
-		for url, og := range ogData {
-			if og != nil {
-				slog.Debug("OpenGraph data fetched", "url", url, "title", og.Title, "has_description", og.Description != "")
-			}
+	slog.Info("Fetching OpenGraph data", "url_count", len(urls))
+	ogData := fg.ogFetcher.FetchConcurrentOpenGraphContext(ctx, urls)
+	slog.Info("OpenGraph fetch completed", "results_count", len(ogData))
+	for _, url := range urls {
+		if og := ogData[url]; og != nil {
+			slog.Debug("OpenGraph data fetched", "url", url, "title", og.Title, "has_description", og.Description != "")
 		}
 	}
 
+	return ogData
+}
+
+// BuildFeed creates an RSS or Atom feed from posts and already fetched OpenGraph data
+func (fg *FeedGenerator) BuildFeed(posts []RedditPost, ogData map[string]*OpenGraphData, feedType string) (*feeds.Feed, error) {
+	if feedType != "rss" && feedType != "atom" {
+		return nil, fmt.Errorf("unsupported feed type: %s", feedType)
+	}
+
+	updated := fg.updatedTime()
+	feed := &feeds.Feed{
+		Title:       fg.feedTitle(),
+		Link:        &feeds.Link{Href: fg.feedLink()},
+		Description: "Filtered " + fg.feedSubject() + " posts generated by GoRedditFeedGenerator",
+		Author:      &feeds.Author{Name: "GoRedditFeedGenerator"},
+		Created:     updated,
+		Updated:     updated,
+	}
+	// RSS limits the size of channel images to 144x400, so it's left out for readers to scale
+	if fg.image != nil {
+		feed.Image = &feeds.Image{Url: fg.image.URL, Title: fg.feedTitle(), Link: fg.feedLink()}
+	}
+
 	// Create feed items
 	for _, post := range posts {
 		item := fg.createFeedItem(post, ogData)
@@ -73,33 +212,70 @@ func (fg *FeedGenerator) GenerateFeed(posts []RedditPost, feedType string) (*fee
 // createFeedItem creates a feed item from a Reddit post
 func (fg *FeedGenerator) createFeedItem(post RedditPost, ogData map[string]*OpenGraphData) *feeds.Item {
 	// Build base description with Reddit metadata
-	description := fmt.Sprintf("Score: %d, Comments: %d, Subreddit: r/%s",
-		post.Data.Score, post.Data.NumComments, post.Data.Subreddit)
+	description := fg.itemSummary(post)
+	if post.Data.Tombstone != "" {
+		description += "\n\n" + tombstoneText(post)
+	}
+	if post.Data.IsSelf && strings.TrimSpace(post.Data.Selftext) != "" {
+		description += "\n\n" + strings.TrimSpace(post.Data.Selftext)
+	}
+	if post.Data.OutboundURL != "" {
+		description += "\n\nLinked page: " + post.Data.OutboundURL
+	}
+	if post.Data.PollData != nil {
+		description += pollText(post.Data.PollData)
+	}
+	if post.Data.EventStart != 0 {
+		description += eventText(&post.Data, fg.eventLocation())
+	}
+	if images := galleryImages(post); len(images) > 0 {
+		description += galleryText(images, ogData)
+	}
+	if len(post.Data.Comments) > 0 {
+		description += commentsText(post.Data.Comments)
+	}
 
 	// Add OpenGraph data if available
 	if ogData != nil {
-		if og, exists := ogData[post.Data.URL]; exists && og != nil {
-			slog.Debug("Adding OpenGraph preview", "url", post.Data.URL, "title", og.Title)
+		if og, exists := ogData[previewLink(post)]; exists && og != nil {
+			slog.Debug("Adding OpenGraph preview", "url", previewLink(post), "title", og.Title)
 			description += fg.formatOpenGraphPreview(og)
+			if fg.options.ReadingTime && og.WordCount > 0 {
+				description += "\nReading time: " + readingTimeLabel(og)
+			}
 		} else {
-			slog.Debug("No OpenGraph data found", "url", post.Data.URL, "exists", exists)
+			slog.Debug("No OpenGraph data found", "url", previewLink(post), "exists", exists)
 		}
 	} else {
 		slog.Debug("No OpenGraph data map available", "url", post.Data.URL)
 	}
 
+	description = truncateText(description, fg.options.MaxDescriptionLength)
+	if post.Data.ArchiveURL != "" {
+		description += "\n\nArchived copy: " + post.Data.ArchiveURL
+	}
+	if fg.options.Provenance != nil {
+		description += provenanceText(post, fg.options.Provenance)
+	}
+
 	// Note: Categories would be added here if supported by gorilla/feeds
 
 	item := &feeds.Item{
-		Title:       post.Data.Title,
+		Title:       fg.itemTitle(post),
 		Link:        &feeds.Link{Href: post.Data.URL},
 		Description: description,
 		Author:      &feeds.Author{Name: post.Data.Author},
-		Created:     time.Unix(int64(post.Data.CreatedUTC), 0),
-		Id:          fmt.Sprintf("https://www.reddit.com%s", post.Data.Permalink),
+		Created:     postTime(post),
+		Updated:     post.Data.ChangedAt,
+		Id:          post.Data.discussionURL(),
 		// Note: Categories not supported by gorilla/feeds
 	}
 
+	if image := fg.itemImage(post, ogData); image != nil {
+		// The size of the image is unknown without downloading it
+		item.Enclosure = &feeds.Enclosure{Url: image.URL, Type: imageType(image.URL), Length: "0"}
+	}
+
 	return item
 }
 
@@ -127,6 +303,53 @@ func (fg *FeedGenerator) formatOpenGraphPreview(og *OpenGraphData) string {
 	return preview.String()
 }
 
+// RenderFeed builds the feed for the posts and serializes it, using the enhanced
+// Atom format when requested for atom feeds
+func (fg *FeedGenerator) RenderFeed(posts []RedditPost, ogData map[string]*OpenGraphData, feedType string, enhancedAtom bool) ([]byte, error) {
+	if feedType == "atom" && enhancedAtom {
+		atomContent, err := fg.BuildCustomAtomFeed(posts, ogData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create custom atom feed: %w", err)
+		}
+		return []byte(withGeneratorComment(atomContent)), nil
+	}
+
+	feed, err := fg.BuildFeed(posts, ogData, feedType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate feed: %w", err)
+	}
+
+	if err := fg.ValidateFeed(feed); err != nil {
+		return nil, fmt.Errorf("feed validation failed: %w", err)
+	}
+
+	var content string
+	switch feedType {
+	case "rss":
+		content, err = feed.ToRss()
+	case "atom":
+		// gorilla/feeds doesn't write the feed image into Atom feeds
+		atom := (&feeds.Atom{Feed: feed}).AtomFeed()
+		if fg.image != nil {
+			atom.Logo = fg.image.URL
+		}
+		content, err = feeds.ToXML(atom)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s feed: %w", feedType, err)
+	}
+
+	return []byte(withGeneratorComment(content)), nil
+}
+
+// FeedContentType returns the HTTP content type for a feed type
+func FeedContentType(feedType string) string {
+	if feedType == "rss" {
+		return "application/rss+xml; charset=utf-8"
+	}
+	return "application/atom+xml; charset=utf-8"
+}
+
 // SaveFeedToFile saves the generated feed to a specified file
 func (fg *FeedGenerator) SaveFeedToFile(feed *feeds.Feed, feedType, outputPath string) error {
 	file, err := os.Create(outputPath)
@@ -271,69 +494,82 @@ type FeedMetadata struct {
 
 // CreateCustomAtomFeed creates a custom Atom feed structure with enhanced features
 func (fg *FeedGenerator) CreateCustomAtomFeed(posts []RedditPost) (string, error) {
-	now := time.Now()
-
-	// Collect URLs for concurrent OpenGraph fetching
-	urls := make([]string, 0, len(posts))
-	for _, post := range posts {
-		if post.Data.URL != "" {
-			urls = append(urls, post.Data.URL)
-		}
-	}
+	return fg.BuildCustomAtomFeed(posts, fg.FetchOpenGraph(context.Background(), posts))
+}
 
-	// Fetch OpenGraph data concurrently
-	var ogData map[string]*OpenGraphData
-	if fg.ogFetcher != nil {
-		slog.Info("Fetching OpenGraph data for custom Atom feed", "url_count", len(urls))
-		ogData = fg.ogFetcher.FetchConcurrentOpenGraph(urls)
-	}
+// BuildCustomAtomFeed creates the enhanced Atom feed from posts and already fetched OpenGraph data
+func (fg *FeedGenerator) BuildCustomAtomFeed(posts []RedditPost, ogData map[string]*OpenGraphData) (string, error) {
+	updated := fg.updatedTime()
 
 	var atom strings.Builder
 	atom.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	atom.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:reddit="http://reddit.com/atom/ns">`)
-	atom.WriteString(`<title>My Reddit Homepage Feed</title>`)
-	atom.WriteString(`<link href="https://www.reddit.com/"/>`)
-	atom.WriteString(`<id>https://www.reddit.com/</id>`)
-	atom.WriteString(fmt.Sprintf(`<updated>%s</updated>`, now.Format(time.RFC3339)))
+	if fg.options.PreviewEnclosures {
+		atom.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:reddit="http://reddit.com/atom/ns" xmlns:media="http://search.yahoo.com/mrss/">`)
+	} else {
+		atom.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:reddit="http://reddit.com/atom/ns">`)
+	}
+	atom.WriteString(fmt.Sprintf(`<title>%s</title>`, escapeXML(fg.feedTitle())))
+	atom.WriteString(fmt.Sprintf(`<link href="%s"/>`, fg.feedLink()))
+	atom.WriteString(fmt.Sprintf(`<id>%s</id>`, fg.feedLink()))
+	atom.WriteString(fmt.Sprintf(`<updated>%s</updated>`, updated.Format(time.RFC3339)))
 	atom.WriteString(`<author><name>GoRedditFeedGenerator</name></author>`)
-	atom.WriteString(`<subtitle>Filtered Reddit homepage posts with enhanced metadata</subtitle>`)
-	atom.WriteString(`<generator uri="https://github.com/your-username/red-rss">Red RSS Generator</generator>`)
+	atom.WriteString(fmt.Sprintf(`<subtitle>Filtered %s posts with enhanced metadata</subtitle>`, fg.feedSubject()))
+	if fg.image != nil {
+		atom.WriteString(fmt.Sprintf(`<logo>%s</logo>`, escapeXML(fg.image.URL)))
+	}
+	atom.WriteString(fmt.Sprintf(`<generator uri="https://github.com/your-username/red-rss" version="%s">Red RSS Generator</generator>`, escapeXML(Version)))
 
 	for _, post := range posts {
 		atom.WriteString(`<entry>`)
-		atom.WriteString(fmt.Sprintf(`<title>%s</title>`, escapeXML(post.Data.Title)))
+		atom.WriteString(fmt.Sprintf(`<title>%s</title>`, escapeXML(fg.itemTitle(post))))
 
 		// Multiple links: Reddit permalink and external URL
 		atom.WriteString(fmt.Sprintf(`<link rel="alternate" type="text/html" href="%s"/>`, escapeXML(post.Data.URL)))
-		atom.WriteString(fmt.Sprintf(`<link rel="replies" type="text/html" href="https://www.reddit.com%s" title="Reddit Discussion"/>`, escapeXML(post.Data.Permalink)))
+		atom.WriteString(fmt.Sprintf(`<link rel="replies" type="text/html" href="%s" title="%s"/>`, escapeXML(post.Data.discussionURL()), post.Data.discussionLabel()))
+		if post.Data.ArchiveURL != "" {
+			atom.WriteString(fmt.Sprintf(`<link rel="related" type="text/html" href="%s" title="Archived Copy"/>`, escapeXML(post.Data.ArchiveURL)))
+		}
 
-		atom.WriteString(fmt.Sprintf(`<id>https://www.reddit.com%s</id>`, escapeXML(post.Data.Permalink)))
-		atom.WriteString(fmt.Sprintf(`<updated>%s</updated>`, time.Unix(int64(post.Data.CreatedUTC), 0).Format(time.RFC3339)))
-		atom.WriteString(fmt.Sprintf(`<published>%s</published>`, time.Unix(int64(post.Data.CreatedUTC), 0).Format(time.RFC3339)))
+		atom.WriteString(fmt.Sprintf(`<id>%s</id>`, escapeXML(post.Data.discussionURL())))
+		atom.WriteString(fmt.Sprintf(`<updated>%s</updated>`, itemUpdated(post).Format(time.RFC3339)))
+		atom.WriteString(fmt.Sprintf(`<published>%s</published>`, postTime(post).Format(time.RFC3339)))
 
 		// Enhanced author information
-		atom.WriteString(fmt.Sprintf(`<author><name>%s</name><uri>https://www.reddit.com/user/%s</uri></author>`, escapeXML(post.Data.Author), escapeXML(post.Data.Author)))
+		if uri := post.Data.authorURL(); uri != "" {
+			atom.WriteString(fmt.Sprintf(`<author><name>%s</name><uri>%s</uri></author>`, escapeXML(post.Data.Author), escapeXML(uri)))
+		} else {
+			atom.WriteString(fmt.Sprintf(`<author><name>%s</name></author>`, escapeXML(post.Data.Author)))
+		}
 
 		// Categories for subreddit
-		atom.WriteString(fmt.Sprintf(`<category term="r/%s" label="r/%s"/>`, escapeXML(post.Data.Subreddit), escapeXML(post.Data.Subreddit)))
+		atom.WriteString(fmt.Sprintf(`<category term="%s" label="%s"/>`, escapeXML(post.Data.community()), escapeXML(post.Data.community())))
+		for _, tag := range post.Data.Tags {
+			atom.WriteString(fmt.Sprintf(`<category term="%s" label="%s"/>`, escapeXML(tag), escapeXML(tag)))
+		}
 
 		// Reddit-specific metadata using custom namespace
 		atom.WriteString(fmt.Sprintf(`<reddit:score>%d</reddit:score>`, post.Data.Score))
 		atom.WriteString(fmt.Sprintf(`<reddit:comments>%d</reddit:comments>`, post.Data.NumComments))
-		atom.WriteString(fmt.Sprintf(`<reddit:subreddit>r/%s</reddit:subreddit>`, escapeXML(post.Data.Subreddit)))
+		atom.WriteString(fmt.Sprintf(`<reddit:subreddit>%s</reddit:subreddit>`, escapeXML(post.Data.community())))
+		if og := ogData[previewLink(post)]; fg.options.ReadingTime && og != nil && og.WordCount > 0 {
+			atom.WriteString(fmt.Sprintf(`<reddit:readingTime words="%d">%d</reddit:readingTime>`, og.WordCount, readingMinutes(og.WordCount)))
+		}
 
 		// Enhanced content with OpenGraph data
 		content := fg.buildEnhancedContent(post, ogData)
 		atom.WriteString(fmt.Sprintf(`<content type="html">%s</content>`, escapeXML(content)))
 
 		// Summary
-		summary := fmt.Sprintf("Score: %d, Comments: %d, Subreddit: r/%s",
-			post.Data.Score, post.Data.NumComments, post.Data.Subreddit)
-		atom.WriteString(fmt.Sprintf(`<summary>%s</summary>`, escapeXML(summary)))
+		atom.WriteString(fmt.Sprintf(`<summary>%s</summary>`, escapeXML(fg.itemSummary(post))))
 
-		// Add thumbnail as enclosure if available from OpenGraph
-		if ogData != nil {
-			if og, exists := ogData[post.Data.URL]; exists && og != nil && og.Image != "" {
+		// Add the post's image or the OpenGraph thumbnail as enclosure
+		if image := fg.itemImage(post, ogData); image != nil {
+			atom.WriteString(fmt.Sprintf(`<link rel="enclosure" type="%s" href="%s"/>`, imageType(image.URL), escapeXML(image.URL)))
+			if fg.options.PreviewEnclosures {
+				atom.WriteString(mediaContentXML(image, post))
+			}
+		} else if ogData != nil {
+			if og, exists := ogData[previewLink(post)]; exists && og != nil && og.Image != "" {
 				atom.WriteString(fmt.Sprintf(`<link rel="enclosure" type="image/jpeg" href="%s"/>`, escapeXML(og.Image)))
 			}
 		}
@@ -351,12 +587,49 @@ func (fg *FeedGenerator) buildEnhancedContent(post RedditPost, ogData map[string
 
 	// Add basic Reddit metadata
 	content.WriteString(fmt.Sprintf(`<div class="reddit-metadata">
-<p><strong>Score:</strong> %d | <strong>Comments:</strong> %d | <strong>Subreddit:</strong> <a href="https://www.reddit.com/r/%s">r/%s</a></p>
-</div>`, post.Data.Score, post.Data.NumComments, post.Data.Subreddit, post.Data.Subreddit))
+<p><strong>Score:</strong> %d | <strong>Comments:</strong> %d | <strong>Subreddit:</strong> <a href="%s">%s</a></p>
+</div>`, post.Data.Score, post.Data.NumComments, escapeXML(post.Data.communityURL()), escapeXML(post.Data.community())))
+
+	if post.Data.Tombstone != "" {
+		content.WriteString(`<p class="tombstone"><em>` + escapeXML(tombstoneText(post)) + `</em></p>`)
+	}
+
+	if body := selftextHTML(post); body != "" {
+		content.WriteString(`<div class="selftext">` + body + `</div>`)
+	}
+
+	if post.Data.PollData != nil {
+		content.WriteString(pollHTML(post.Data.PollData))
+	}
+
+	if post.Data.EventStart != 0 {
+		content.WriteString(eventHTML(&post.Data, fg.eventLocation()))
+	}
+
+	if image := redditImage(post); image != nil && fg.options.ImageEnclosures {
+		content.WriteString(imageHTML(image, post.Data.Title))
+	}
+
+	if images := galleryImages(post); len(images) > 0 {
+		content.WriteString(galleryHTML(images, ogData))
+	}
+
+	if len(post.Data.Comments) > 0 {
+		content.WriteString(commentsHTML(post.Data.Comments))
+	}
+
+	if fg.options.ShowFlair {
+		content.WriteString(fmt.Sprintf(`<p><strong>Author:</strong> <a href="%s">%s</a></p>`,
+			escapeXML(post.Data.authorURL()), escapeXML(authorLabel(post))))
+	}
+
+	if fg.options.ShowAwards && post.Data.TotalAwardsReceived > 0 {
+		content.WriteString(fmt.Sprintf(`<p><strong>Awards:</strong> %s</p>`, escapeXML(awardsLabel(post))))
+	}
 
 	// Add OpenGraph preview if available
 	if ogData != nil {
-		if og, exists := ogData[post.Data.URL]; exists && og != nil {
+		if og, exists := ogData[previewLink(post)]; exists && og != nil {
 			content.WriteString(`<div class="link-preview">`)
 			content.WriteString(`<h3>🔗 Link Preview</h3>`)
 
@@ -376,18 +649,82 @@ func (fg *FeedGenerator) buildEnhancedContent(post RedditPost, ogData map[string
 				content.WriteString(fmt.Sprintf(`<p><em>Source: %s</em></p>`, og.SiteName))
 			}
 
+			if fg.options.ReadingTime && og.WordCount > 0 {
+				content.WriteString(fmt.Sprintf(`<p><strong>Reading time:</strong> %s</p>`, readingTimeLabel(og)))
+			}
+
 			content.WriteString(`</div>`)
 		}
 	}
 
+	// The links stay when the description is truncated
+	body := truncateHTML(content.String(), fg.options.MaxDescriptionLength)
+	content.Reset()
+	content.WriteString(body)
+
 	// Add links section
 	content.WriteString(`<div class="links">`)
-	content.WriteString(fmt.Sprintf(`<p><a href="%s">View External Link</a> | <a href="https://www.reddit.com%s">Reddit Discussion</a></p>`, post.Data.URL, post.Data.Permalink))
+	content.WriteString(fmt.Sprintf(`<p><a href="%s">View External Link</a> | <a href="%s">%s</a>`, post.Data.URL, post.Data.discussionURL(), post.Data.discussionLabel()))
+	if post.Data.ArchiveURL != "" {
+		content.WriteString(fmt.Sprintf(` | <a href="%s">Archived Copy</a>`, escapeXML(post.Data.ArchiveURL)))
+	}
+	content.WriteString(`</p>`)
 	content.WriteString(`</div>`)
 
+	if fg.options.Provenance != nil {
+		content.WriteString(provenanceHTML(post, fg.options.Provenance))
+	}
+
 	return content.String()
 }
 
+// itemTitle returns the title of a post's feed item
+func (fg *FeedGenerator) itemTitle(post RedditPost) string {
+	if fg.options.ShowFlair {
+		if label := distinguishedLabel(post); label != "" {
+			return "[" + label + "] " + post.Data.Title
+		}
+	}
+	return post.Data.Title
+}
+
+// itemSummary returns the one-line plain text summary of a post
+func (fg *FeedGenerator) itemSummary(post RedditPost) string {
+	summary := fmt.Sprintf("Score: %d, Comments: %d, Subreddit: %s",
+		post.Data.Score, post.Data.NumComments, post.Data.community())
+	if fg.options.ShowFlair {
+		summary += ", Author: " + authorLabel(post)
+	}
+	if fg.options.ShowAwards && post.Data.TotalAwardsReceived > 0 {
+		summary += ", Awards: " + awardsLabel(post)
+	}
+	if len(post.Data.Tags) > 0 {
+		summary += ", Tags: " + strings.Join(post.Data.Tags, ", ")
+	}
+	return summary
+}
+
+// distinguishedLabel returns the label of a post distinguished by a moderator or admin
+func distinguishedLabel(post RedditPost) string {
+	switch post.Data.Distinguished {
+	case "":
+		return ""
+	case "moderator":
+		return "MOD"
+	default:
+		return strings.ToUpper(post.Data.Distinguished)
+	}
+}
+
+// authorLabel returns the author's name with their flair
+func authorLabel(post RedditPost) string {
+	label := "u/" + post.Data.Author
+	if flair := strings.TrimSpace(post.Data.AuthorFlairText); flair != "" {
+		label += " (" + flair + ")"
+	}
+	return label
+}
+
 // escapeXML escapes XML special characters
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
@@ -397,3 +734,25 @@ func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "'", "&apos;")
 	return s
 }
+
+// gildingNames are the names of Reddit's gilding kinds, from the highest down
+var gildingNames = []struct{ id, name string }{
+	{"gid_3", "platinum"},
+	{"gid_2", "gold"},
+	{"gid_1", "silver"},
+}
+
+// awardsLabel returns the number of awards a post received with its gildings
+func awardsLabel(post RedditPost) string {
+	label := fmt.Sprintf("%d", post.Data.TotalAwardsReceived)
+	var gildings []string
+	for _, g := range gildingNames {
+		if n := post.Data.Gildings[g.id]; n > 0 {
+			gildings = append(gildings, fmt.Sprintf("%d %s", n, g.name))
+		}
+	}
+	if len(gildings) > 0 {
+		label += " (" + strings.Join(gildings, ", ") + ")"
+	}
+	return label
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Filter rules counted in FilterStats
+const (
+	RuleDedup         = "dedup" // Copies of posts listed more than once, or links posted to several sites
+	RuleBanned        = "banned"
+	RuleOnlyNew       = "only_new"
+	RuleRemoved       = "removed"
+	RuleDistinguished = "distinguished"
+	RulePostType      = "post_type"
+	RuleEventLeadTime = "event_lead_time"
+	RuleMinAwards     = "min_awards"
+	RuleUpvoteRatio   = "min_upvote_ratio"
+	RuleBots          = "bots"
+	RuleScore         = "score"
+	RuleComments      = "comments"
+	RuleLimit         = "limit"
+)
+
+// FilterStats counts the posts each filter rule rejected in a run. A post is counted
+// by the first rule that rejected it. Rules that are configured but rejected nothing
+// are present with 0, so rules that never matter show up as such.
+type FilterStats map[string]int
+
+// record adds rejected posts of a rule, which is kept at 0 when active. A nil
+// FilterStats records nothing.
+func (s FilterStats) record(rule string, rejected int, active bool) {
+	if s != nil && (active || rejected > 0) {
+		s[rule] += rejected
+	}
+}
+
+// applyContentFilters drops posts by what they are rather than how they scored,
+// counting rejections by rule in stats
+func (p *Pipeline) applyContentFilters(posts []RedditPost, stats FilterStats) []RedditPost {
+	var kept []RedditPost
+	rejected := make(map[string]int)
+	for _, post := range posts {
+		if !p.keepDistinguished(post) {
+			rejected[RuleDistinguished]++
+			continue
+		}
+		if !p.keepPostType(post) {
+			rejected[RulePostType]++
+			continue
+		}
+		if !p.eventNear(post) {
+			rejected[RuleEventLeadTime]++
+			continue
+		}
+		if post.Data.TotalAwardsReceived < p.config.MinAwards {
+			rejected[RuleMinAwards]++
+			continue
+		}
+		if post.Data.UpvoteRatio < p.config.MinUpvoteRatio {
+			rejected[RuleUpvoteRatio]++
+			continue
+		}
+		if mode := p.config.BotPosts; mode != "" && mode != BotInclude && p.isBot(post.Data.Author) {
+			if mode == BotExclude {
+				rejected[RuleBots]++
+				continue
+			}
+			post.Data.Title = "[BOT] " + post.Data.Title
+		}
+		kept = append(kept, post)
+	}
+
+	mode := p.config.DistinguishedPosts
+	stats.record(RuleDistinguished, rejected[RuleDistinguished], mode == DistinguishedExclude || mode == DistinguishedOnly)
+	stats.record(RulePostType, rejected[RulePostType], p.config.PostTypes == PostTypesLinks || p.config.PostTypes == PostTypesDiscussions)
+	stats.record(RuleEventLeadTime, rejected[RuleEventLeadTime], p.config.EventLeadTime != "")
+	stats.record(RuleMinAwards, rejected[RuleMinAwards], p.config.MinAwards > 0)
+	stats.record(RuleUpvoteRatio, rejected[RuleUpvoteRatio], p.config.MinUpvoteRatio > 0)
+	stats.record(RuleBots, rejected[RuleBots], p.config.BotPosts == BotExclude)
+
+	if dropped := len(posts) - len(kept); dropped > 0 {
+		slog.Debug("Dropped posts by content filters", "count", dropped)
+	}
+	return kept
+}
+
+// keepDistinguished applies the distinguished_posts filter
+func (p *Pipeline) keepDistinguished(post RedditPost) bool {
+	switch p.config.DistinguishedPosts {
+	case DistinguishedExclude:
+		return post.Data.Distinguished == ""
+	case DistinguishedOnly:
+		return post.Data.Distinguished != ""
+	default:
+		return true
+	}
+}
+
+// keepPostType applies the post_types filter
+func (p *Pipeline) keepPostType(post RedditPost) bool {
+	switch p.config.PostTypes {
+	case PostTypesLinks:
+		return !post.Data.IsSelf
+	case PostTypesDiscussions:
+		return post.Data.IsSelf
+	default:
+		return true
+	}
+}
+
+// FilterStatsWindow is how far back `cache stats` sums filter rejections
+const FilterStatsWindow = 7 * 24 * time.Hour
+
+// RuleStats sums the rejections of a filter rule over several runs
+type RuleStats struct {
+	Rule     string
+	Rejected int
+	Runs     int // Runs in which the rule was active
+}
+
+// recordFilterStats logs the rejections of a run and stores them for `cache stats`
+func (p *Pipeline) recordFilterStats(stats FilterStats, opts RunOptions) {
+	if len(stats) == 0 {
+		return
+	}
+	attrs := []any{"feed", opts.feed()}
+	for _, rule := range slices.Sorted(maps.Keys(stats)) {
+		attrs = append(attrs, rule, stats[rule])
+	}
+	slog.Info("Posts rejected by filter rule", attrs...)
+
+	if p.db == nil || opts.Offline {
+		return
+	}
+	if err := p.db.SaveFilterStats(runKey(opts), stats, time.Now()); err != nil {
+		slog.Warn("Failed to record filter stats", "error", err)
+	}
+}
+
+// SaveFilterStats stores the rejections of a run of a feed
+func (ogDB *OpenGraphDB) SaveFilterStats(feed string, stats FilterStats, at time.Time) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for rule, rejected := range stats {
+		_, err := tx.Exec(`INSERT INTO filter_rejections (feed, rule, run_at, rejected) VALUES (?, ?, ?, ?)`,
+			feed, rule, at.Unix(), rejected)
+		if err != nil {
+			return fmt.Errorf("failed to save filter stats: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// FilterStatsSince sums the rejections of each rule over the runs since a time, the
+// rules rejecting the most posts first
+func (ogDB *OpenGraphDB) FilterStatsSince(since time.Time) ([]RuleStats, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`SELECT rule, SUM(rejected), COUNT(*) FROM filter_rejections WHERE run_at >= ?
+		GROUP BY rule ORDER BY SUM(rejected) DESC, rule`, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load filter stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []RuleStats
+	for rows.Next() {
+		var s RuleStats
+		if err := rows.Scan(&s.Rule, &s.Rejected, &s.Runs); err != nil {
+			return nil, fmt.Errorf("failed to load filter stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDistinguishedFilter(t *testing.T) {
+	mod := seenPost("t3_mod", 10)
+	mod.Data.Distinguished = "moderator"
+	posts := []RedditPost{seenPost("t3_user", 10), mod}
+
+	tests := map[string][]string{
+		"":                   {"t3_user", "t3_mod"},
+		DistinguishedExclude: {"t3_user"},
+		DistinguishedOnly:    {"t3_mod"},
+	}
+	for mode, want := range tests {
+		p := &Pipeline{config: &Config{DistinguishedPosts: mode}}
+		got := p.applyContentFilters(posts, nil)
+		if len(got) != len(want) {
+			t.Errorf("mode %q: expected %v, got %d posts", mode, want, len(got))
+			continue
+		}
+		for i := range want {
+			if got[i].Data.Name != want[i] {
+				t.Errorf("mode %q: expected %v, got %s at %d", mode, want, got[i].Data.Name, i)
+			}
+		}
+	}
+}
+
+func TestPostTypes(t *testing.T) {
+	self := seenPost("t3_self", 10)
+	self.Data.IsSelf = true
+	posts := []RedditPost{seenPost("t3_link", 10), self}
+
+	tests := map[string][]string{
+		"":                   {"t3_link", "t3_self"},
+		PostTypesAll:         {"t3_link", "t3_self"},
+		PostTypesLinks:       {"t3_link"},
+		PostTypesDiscussions: {"t3_self"},
+	}
+	for mode, want := range tests {
+		p := &Pipeline{config: &Config{PostTypes: mode}}
+		stats := FilterStats{}
+		got := p.applyContentFilters(posts, stats)
+		if len(got) != len(want) || got[0].Data.Name != want[0] {
+			t.Errorf("mode %q: expected %v, got %v", mode, want, got)
+		}
+		if rejected, active := stats[RulePostType]; active != (len(want) == 1) || rejected != len(posts)-len(want) {
+			t.Errorf("mode %q: unexpected post_type stats %v", mode, stats)
+		}
+	}
+}
+
+func TestFlairRendering(t *testing.T) {
+	post := seenPost("t3_a", 10)
+	post.Data.Author = "someone"
+	post.Data.AuthorFlairText = "Contributor"
+	post.Data.Distinguished = "moderator"
+	post.Data.Permalink = "/r/test/comments/a/"
+	post.Data.URL = "https://www.reddit.com/r/test/comments/a/"
+
+	fg := NewFeedGenerator(nil)
+	if title := fg.itemTitle(post); title != post.Data.Title {
+		t.Errorf("expected the plain title without show_flair, got %q", title)
+	}
+
+	fg.SetOptions(FeedOptions{ShowFlair: true})
+	if title := fg.itemTitle(post); title != "[MOD] Post t3_a" {
+		t.Errorf("expected the MOD label, got %q", title)
+	}
+
+	content, err := fg.RenderFeed([]RedditPost{post}, nil, "rss", false)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	if !strings.Contains(string(content), "Author: u/someone (Contributor)") {
+		t.Errorf("expected the author flair in the description:\n%s", content)
+	}
+}
+
+func TestAwards(t *testing.T) {
+	awarded := seenPost("t3_awarded", 10)
+	awarded.Data.TotalAwardsReceived = 4
+	awarded.Data.Gildings = map[string]int{"gid_1": 2, "gid_2": 1}
+	posts := []RedditPost{seenPost("t3_plain", 10), awarded}
+
+	p := &Pipeline{config: &Config{MinAwards: 1}}
+	if got := p.applyContentFilters(posts, nil); len(got) != 1 || got[0].Data.Name != "t3_awarded" {
+		t.Errorf("expected only the awarded post with min_awards 1, got %v", got)
+	}
+
+	p.config = &Config{MinUpvoteRatio: 0.85}
+	posts[0].Data.UpvoteRatio = 0.6
+	posts[1].Data.UpvoteRatio = 0.92
+	if got := p.applyContentFilters(posts, nil); len(got) != 1 || got[0].Data.Name != "t3_awarded" {
+		t.Errorf("expected only the well-received post with min_upvote_ratio 0.85, got %v", got)
+	}
+
+	fg := NewFeedGenerator(nil)
+	fg.SetOptions(FeedOptions{ShowAwards: true})
+	if summary := fg.itemSummary(awarded); !strings.HasSuffix(summary, ", Awards: 4 (1 gold, 2 silver)") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if summary := fg.itemSummary(posts[0]); strings.Contains(summary, "Awards") {
+		t.Errorf("expected no awards for a post without any, got %q", summary)
+	}
+}
+
+func TestBotPosts(t *testing.T) {
+	byAuthor := func(name, author string) RedditPost {
+		post := seenPost(name, 10)
+		post.Data.Author = author
+		return post
+	}
+	posts := []RedditPost{
+		byAuthor("t3_human", "someone"),
+		byAuthor("t3_automod", "AutoModerator"),
+		byAuthor("t3_heuristic", "weather_bot"),
+		byAuthor("t3_abbot", "Abbot"),
+		byAuthor("t3_listed", "DailyThreads"),
+	}
+
+	p := &Pipeline{config: &Config{BotPosts: BotExclude, BotAuthors: []string{"dailythreads"}, NotBots: []string{"abbot"}}}
+	got := p.applyContentFilters(posts, nil)
+	if len(got) != 2 || got[0].Data.Name != "t3_human" || got[1].Data.Name != "t3_abbot" {
+		t.Errorf("expected only t3_human and t3_abbot, got %v", got)
+	}
+
+	p.config.BotPosts = BotTag
+	got = p.applyContentFilters(posts, nil)
+	if len(got) != len(posts) {
+		t.Fatalf("expected tag to keep all posts, got %d", len(got))
+	}
+	if got[0].Data.Title != "Post t3_human" || got[1].Data.Title != "[BOT] Post t3_automod" {
+		t.Errorf("unexpected titles %q, %q", got[0].Data.Title, got[1].Data.Title)
+	}
+	if posts[1].Data.Title != "Post t3_automod" {
+		t.Errorf("tagging changed the input posts")
+	}
+}
+
+func TestFilterStats(t *testing.T) {
+	db := newTestDB(t)
+	p := &Pipeline{config: &Config{ScoreFilter: 10, CommentFilter: 5, MinAwards: 1, BotPosts: BotExclude}, db: db}
+	if err := db.SetOverride(PostOverride{Fullname: "t3_banned", Action: OverrideBan}); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(name string, score, comments, awards int) RedditPost {
+		p := seenPost(name, score)
+		p.Data.NumComments = comments
+		p.Data.TotalAwardsReceived = awards
+		return p
+	}
+	posts := []RedditPost{
+		post("t3_banned", 100, 100, 1),
+		post("t3_unawarded", 100, 100, 0),
+		post("t3_low", 1, 0, 1), // Counted by the score rule only
+		post("t3_quiet", 100, 1, 1),
+		post("t3_kept", 100, 100, 1),
+		post("t3_extra", 90, 100, 1),
+	}
+
+	stats := FilterStats{}
+	opts := DefaultRunOptions()
+	opts.Limit = 1
+	kept := p.selectPosts(posts, opts, stats)
+	if len(kept) != 1 || kept[0].Data.Name != "t3_kept" {
+		t.Fatalf("unexpected posts %v", kept)
+	}
+	want := FilterStats{RuleBanned: 1, RuleMinAwards: 1, RuleScore: 1, RuleComments: 1, RuleLimit: 1, RuleBots: 0}
+	if !maps.Equal(stats, want) {
+		t.Errorf("stats = %v, want %v", stats, want)
+	}
+
+	p.recordFilterStats(stats, opts)
+	rules, err := db.FilterStatsSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(want) || rules[len(rules)-1] != (RuleStats{Rule: RuleBots, Rejected: 0, Runs: 1}) {
+		t.Errorf("unexpected stored stats %+v", rules)
+	}
+
+	metrics := string(textfileMetrics(RunMetrics{Rejected: stats}, time.Time{}))
+	if !strings.Contains(metrics, `red_rss_last_run_rejected_posts{rule="score"} 1`) {
+		t.Errorf("metrics lack rejections:\n%s", metrics)
+	}
+}
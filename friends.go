@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FriendsFeed names the feed of posts by friends and followed users
+const FriendsFeed = "friends"
+
+// MaxFriends bounds the users whose posts are fetched, one request each
+const MaxFriends = 50
+
+// friendPostLimit is the number of recent posts fetched per user
+const friendPostLimit = 25
+
+// FetchFriends returns the names of the user's Reddit friends. It needs the
+// mysubreddits scope.
+func (api *RedditAPI) FetchFriends(ctx context.Context) ([]string, error) {
+	resp, err := api.get(ctx, "https://oauth.reddit.com/api/v1/me/friends")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch friends: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch friends: %w", parseRedditError(resp))
+	}
+
+	var list struct {
+		Data struct {
+			Children []struct {
+				Name string `json:"name"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode friends: %w", err)
+	}
+
+	names := make([]string, 0, len(list.Data.Children))
+	for _, friend := range list.Data.Children {
+		if friend.Name != "" {
+			names = append(names, friend.Name)
+		}
+	}
+	return names, nil
+}
+
+// followedUsers returns the friends and followed users, in alphabetical order and
+// bounded by MaxFriends. Following a user subscribes to their profile, which is
+// listed among the subscriptions as u_<name>.
+func (p *Pipeline) followedUsers(ctx context.Context) ([]string, error) {
+	friends, err := p.api.FetchFriends(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := p.api.FetchSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users := friends
+	for _, name := range subscriptions {
+		if user, ok := strings.CutPrefix(name, "u_"); ok {
+			users = append(users, user)
+		}
+	}
+
+	slices.SortFunc(users, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	users = slices.CompactFunc(users, strings.EqualFold)
+	if len(users) > MaxFriends {
+		slog.Warn("Ignoring followed users beyond the limit", "users", len(users), "max", MaxFriends)
+		users = users[:MaxFriends]
+	}
+	return users, nil
+}
+
+// userSource is the recent posts of a user, newest first
+type userSource struct {
+	api  *RedditAPI
+	user string
+}
+
+func (s userSource) Name() string { return "u/" + s.user }
+
+func (s userSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	return s.api.FetchListing(ctx, "/user/"+s.user+"/submitted", url.Values{
+		"limit": {fmt.Sprint(friendPostLimit)},
+		"sort":  {"new"},
+	})
+}
+
+// fetchUserPosts fetches the recent posts of users concurrently, newest first. Users
+// whose posts can't be fetched, e.g. suspended accounts, are left out.
+func (p *Pipeline) fetchUserPosts(ctx context.Context, users []string) ([]RedditPost, error) {
+	sources := make([]Source, len(users))
+	for i, user := range users {
+		sources[i] = userSource{api: p.api, user: user}
+	}
+	listings, err := fetchSources(ctx, sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts of all %d followed users: %w", len(users), err)
+	}
+
+	var posts []RedditPost
+	for _, listing := range listings {
+		posts = append(posts, listing...)
+	}
+	slices.SortFunc(posts, newestFirst)
+	return uniquePosts(posts), nil
+}
+
+// GenerateFriends generates the feed of posts by friends and followed users and
+// writes it next to the homepage feed
+func (p *Pipeline) GenerateFriends(ctx context.Context, opts RunOptions, dir string) error {
+	users, err := p.followedUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		slog.Warn("No friends or followed users, skipping friends feed")
+		return nil
+	}
+
+	opts.Users = users
+	result, err := p.Generate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	path := subredditOutputPath(p.config.OutputPath, dir, FriendsFeed, p.config.FeedType, time.Now())
+	slog.Debug("Generated friends feed", "users", len(users), "path", path, "items", result.Items)
+	return p.config.saveFeed(path, result.Content, result.ContentType)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFollowedUsersPosts(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusOK, ""
+		switch req.URL.Path {
+		case "/api/v1/me/friends":
+			body = `{"kind": "UserList", "data": {"children": [{"name": "bob", "id": "t2_b"}, {"name": "Alice", "id": "t2_a"}]}}`
+		case "/subreddits/mine/subscriber":
+			body = `{"kind": "Listing", "data": {"children": [
+				{"kind": "t5", "data": {"display_name": "golang"}},
+				{"kind": "t5", "data": {"display_name": "u_carol"}},
+				{"kind": "t5", "data": {"display_name": "u_alice"}}]}}`
+		case "/user/Alice/submitted":
+			body = `{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {"name": "t3_a", "created_utc": 100}}]}}`
+		case "/user/bob/submitted":
+			body = `{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {"name": "t3_b", "created_utc": 300}}]}}`
+		case "/user/carol/submitted":
+			status, body = http.StatusNotFound, `{"message": "Not Found", "error": 404}`
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	p := NewPipeline(&Config{FriendsFeed: true}, client, nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	users, err := p.followedUsers(context.Background())
+	if err != nil {
+		t.Fatalf("followedUsers failed: %v", err)
+	}
+	if !slices.Equal(users, []string{"Alice", "bob", "carol"}) {
+		t.Errorf("expected friends and followed users once each, got %v", users)
+	}
+
+	// A suspended account doesn't fail the feed, posts are newest first
+	posts, err := p.fetchUserPosts(context.Background(), users)
+	if err != nil {
+		t.Fatalf("fetchUserPosts failed: %v", err)
+	}
+	var names []string
+	for _, post := range posts {
+		names = append(names, post.Data.Name)
+	}
+	if fmt.Sprint(names) != "[t3_b t3_a]" {
+		t.Errorf("expected posts newest first, got %v", names)
+	}
+}
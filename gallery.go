@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// galleryImage is an image of a gallery post in gallery order
+type galleryImage struct {
+	Image       ImageSource
+	Caption     string
+	OutboundURL string
+}
+
+// galleryImages returns the processed images of a gallery post, or nil for other posts
+func galleryImages(post RedditPost) []galleryImage {
+	if post.Data.GalleryData == nil {
+		return nil
+	}
+
+	var images []galleryImage
+	for _, item := range post.Data.GalleryData.Items {
+		meta, ok := post.Data.MediaMetadata[item.MediaID]
+		if !ok || meta.Status != "valid" {
+			continue
+		}
+		source := meta.Source.URL
+		if source == "" {
+			source = meta.Source.GIF
+		}
+		if source == "" {
+			continue
+		}
+		images = append(images, galleryImage{
+			Image:       ImageSource{URL: source, Width: meta.Source.Width, Height: meta.Source.Height},
+			Caption:     strings.TrimSpace(item.Caption),
+			OutboundURL: item.OutboundURL,
+		})
+	}
+	return images
+}
+
+// galleryLinks returns the outbound links of the gallery images of the posts, to be
+// enriched like the links of link posts
+func galleryLinks(posts []RedditPost) []string {
+	var links []string
+	for _, post := range posts {
+		for _, image := range galleryImages(post) {
+			if image.OutboundURL != "" {
+				links = append(links, image.OutboundURL)
+			}
+		}
+	}
+	return links
+}
+
+// galleryText renders the captions and links of a gallery as plain text
+func galleryText(images []galleryImage, ogData map[string]*OpenGraphData) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("\n\nGallery (%d images):", len(images)))
+	for i, image := range images {
+		line := image.Caption
+		if line == "" {
+			line = fmt.Sprintf("Image %d", i+1)
+		}
+		if image.OutboundURL != "" {
+			line += " → " + linkLabel(image.OutboundURL, ogData)
+		}
+		text.WriteString("\n- " + line)
+	}
+	return text.String()
+}
+
+// galleryHTML renders the images of a gallery with their captions and links
+func galleryHTML(images []galleryImage, ogData map[string]*OpenGraphData) string {
+	var content strings.Builder
+	content.WriteString(`<div class="gallery">`)
+	for _, image := range images {
+		content.WriteString(`<figure>`)
+		content.WriteString(imageHTML(&image.Image, image.Caption))
+		if image.Caption != "" || image.OutboundURL != "" {
+			content.WriteString(`<figcaption>`)
+			content.WriteString(escapeXML(image.Caption))
+			if image.OutboundURL != "" {
+				if image.Caption != "" {
+					content.WriteString(" — ")
+				}
+				content.WriteString(fmt.Sprintf(`<a href="%s">%s</a>`, escapeXML(image.OutboundURL), escapeXML(linkLabel(image.OutboundURL, ogData))))
+			}
+			content.WriteString(`</figcaption>`)
+		}
+		content.WriteString(`</figure>`)
+	}
+	content.WriteString(`</div>`)
+	return content.String()
+}
+
+// linkLabel names a link by the OpenGraph title of its page, or its host
+func linkLabel(link string, ogData map[string]*OpenGraphData) string {
+	if og, ok := ogData[link]; ok && og != nil && og.Title != "" {
+		return og.Title
+	}
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return link
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGallery(t *testing.T) {
+	var post RedditPost
+	err := json.Unmarshal([]byte(`{"kind": "t3", "data": {
+		"name": "t3_gal", "title": "Trip", "url": "https://www.reddit.com/gallery/gal", "permalink": "/r/travel/comments/gal/",
+		"is_gallery": true,
+		"gallery_data": {"items": [
+			{"media_id": "b", "caption": "The harbour", "outbound_url": "https://example.com/harbour"},
+			{"media_id": "a"},
+			{"media_id": "pending"}
+		]},
+		"media_metadata": {
+			"a": {"status": "valid", "e": "Image", "m": "image/jpg", "s": {"u": "https://preview.redd.it/a.jpg?width=800&s=1", "x": 800, "y": 600}},
+			"b": {"status": "valid", "e": "AnimatedImage", "m": "image/gif", "s": {"gif": "https://i.redd.it/b.gif", "x": 400, "y": 300}},
+			"pending": {"status": "unprocessed"}
+		}
+	}}`), &post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images := galleryImages(post)
+	if len(images) != 2 || images[0].Image.URL != "https://i.redd.it/b.gif" || images[1].Image.URL != "https://preview.redd.it/a.jpg?width=800&s=1" {
+		t.Fatalf("unexpected gallery images %+v", images)
+	}
+	if links := galleryLinks([]RedditPost{post}); len(links) != 1 || links[0] != "https://example.com/harbour" {
+		t.Errorf("expected the outbound link to be enriched, got %v", links)
+	}
+
+	ogData := map[string]*OpenGraphData{"https://example.com/harbour": {Title: "Harbour tours"}}
+	if got, want := galleryText(images, ogData), "\n\nGallery (2 images):\n- The harbour → Harbour tours\n- Image 2"; got != want {
+		t.Errorf("galleryText() = %q, want %q", got, want)
+	}
+
+	content, err := NewFeedGenerator(nil).RenderFeed([]RedditPost{post}, ogData, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	if !strings.Contains(string(content), "&lt;figcaption&gt;The harbour — &lt;a href=&quot;https://example.com/harbour&quot;&gt;Harbour tours&lt;/a&gt;") {
+		t.Errorf("expected the caption and link under the image:\n%s", content)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// hackerNewsAPI is the Algolia search API of Hacker News, replaced in tests
+var hackerNewsAPI = "https://hn.algolia.com/api/v1"
+
+// Hacker News sources
+const (
+	hackerNewsPrefix    = "hn"         // Prefix of sources such as "hn:front" and of story fullnames
+	hackerNewsCommunity = "hackernews" // Subreddit of stories in reports such as thresholds
+	hackerNewsLimit     = 50           // Stories fetched per listing
+)
+
+// Hacker News listings, by their name in sources such as "hn:front"
+var hackerNewsListings = map[string]struct{ endpoint, tags string }{
+	"front": {"search", "front_page"},      // Stories on the front page
+	"new":   {"search_by_date", "story"},   // Newest stories
+	"show":  {"search_by_date", "show_hn"}, // Newest Show HN stories
+	"ask":   {"search_by_date", "ask_hn"},  // Newest Ask HN stories
+}
+
+// HackerNewsSource is a Hacker News listing, fetched from the Algolia search API
+type HackerNewsSource struct {
+	Listing string // "front", "new", "show" or "ask"
+}
+
+// hackerNewsHit is a story of an Algolia search response
+type hackerNewsHit struct {
+	ObjectID    string `json:"objectID"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Author      string `json:"author"`
+	Points      int    `json:"points"`
+	NumComments int    `json:"num_comments"`
+	CreatedAt   int64  `json:"created_at_i"`
+	StoryText   string `json:"story_text"` // HTML body of Ask HN and text stories
+}
+
+// parseHackerNewsSource returns the listing of a source such as "hn:front"
+func parseHackerNewsSource(spec string) (string, bool) {
+	listing, ok := strings.CutPrefix(spec, hackerNewsPrefix+":")
+	_, known := hackerNewsListings[listing]
+	return listing, ok && known
+}
+
+func (s *HackerNewsSource) Name() string {
+	return hackerNewsPrefix + ":" + s.Listing
+}
+
+// Fetch fetches the stories of the listing
+func (s *HackerNewsSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	listing := hackerNewsListings[s.Listing]
+	params := url.Values{"tags": {listing.tags}, "hitsPerPage": {strconv.Itoa(hackerNewsLimit)}}
+	var result struct {
+		Hits []hackerNewsHit `json:"hits"`
+	}
+	if err := fetchSourceJSON(ctx, hackerNewsAPI+"/"+listing.endpoint+"?"+params.Encode(), &result); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", s.Name(), err)
+	}
+
+	posts := make([]RedditPost, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if hit.ObjectID != "" && hit.Title != "" {
+			posts = append(posts, hit.redditPost())
+		}
+	}
+	slog.Info("Successfully fetched Hacker News stories", "listing", s.Name(), "count", len(posts))
+	return posts, nil
+}
+
+// redditPost converts a story. Points are its score, and as stories can't be
+// downvoted their upvote ratio is 1.
+func (h hackerNewsHit) redditPost() RedditPost {
+	discussion := "https://news.ycombinator.com/item?id=" + url.QueryEscape(h.ObjectID)
+	data := PostData{
+		ID:          h.ObjectID,
+		Name:        hackerNewsPrefix + ":" + h.ObjectID,
+		Title:       h.Title,
+		URL:         h.URL,
+		Permalink:   discussion,
+		CreatedUTC:  float64(h.CreatedAt),
+		Score:       h.Points,
+		NumComments: h.NumComments,
+		Author:      h.Author,
+		AuthorURL:   "https://news.ycombinator.com/user?id=" + url.QueryEscape(h.Author),
+		Subreddit:   hackerNewsCommunity,
+		UpvoteRatio: 1,
+		Origin:      OriginHackerNews,
+	}
+	if h.StoryText != "" {
+		data.SelftextHTML = html.EscapeString(h.StoryText)
+	}
+	setSourceLink(&data, hackerNewsCommunity)
+	return RedditPost{Kind: "t3", Data: data}
+}
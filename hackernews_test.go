@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHackerNewsSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("tags") != "front_page" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"hits": [
+			{"objectID": "101", "title": "Show HN: A thing", "url": "https://www.example.com/thing", "author": "pg", "points": 250, "num_comments": 80, "created_at_i": 1714557600},
+			{"objectID": "102", "title": "Ask HN: Why?", "author": "dang", "points": 30, "num_comments": 12, "created_at_i": 1714557700, "story_text": "<p>Because &amp; so</p>"},
+			{"objectID": "103"}
+		]}`))
+	}))
+	defer server.Close()
+	orig := hackerNewsAPI
+	hackerNewsAPI = server.URL
+	defer func() { hackerNewsAPI = orig }()
+
+	source := (&Pipeline{config: &Config{}}).newSource("hn:front", nil)
+	posts, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("expected 2 stories, got %d", len(posts))
+	}
+
+	link, ask := posts[0].Data, posts[1].Data
+	if link.Name != "hn:101" || link.Score != 250 || link.NumComments != 80 || link.Domain != "example.com" || link.IsSelf {
+		t.Errorf("unexpected link story: %+v", link)
+	}
+	if link.discussionURL() != "https://news.ycombinator.com/item?id=101" || link.community() != "Hacker News" || link.onReddit() {
+		t.Errorf("unexpected discussion %s of %s", link.discussionURL(), link.community())
+	}
+	if !ask.IsSelf || ask.URL != ask.discussionURL() || selftextHTML(posts[1]) != "<p>Because &amp; so</p>" {
+		t.Errorf("unexpected Ask HN story: %+v", ask)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// FetchRun is the list of posts a listing returned at a point in time
+type FetchRun struct {
+	Source    string
+	FetchedAt time.Time
+	Posts     []string // Fullnames in listing order
+}
+
+// postSnapshot is the score of a post as recorded by a fetch
+type postSnapshot struct {
+	Score       int
+	NumComments int
+}
+
+// recordHistory stores the fetched posts, their current scores and the listing order
+// so the feed can later be rebuilt as of this run
+func (p *Pipeline) recordHistory(source string, posts []RedditPost) {
+	if p.db == nil {
+		return
+	}
+	if err := p.db.SaveSeenPosts(posts); err != nil {
+		slog.Warn("Failed to store seen posts", "error", err)
+		return
+	}
+	if err := p.db.RecordFetchRun(source, posts, time.Now()); err != nil {
+		slog.Warn("Failed to record fetch history", "error", err)
+	}
+}
+
+// RecordFetchRun stores the listing order and score snapshots of a fetch
+func (ogDB *OpenGraphDB) RecordFetchRun(source string, posts []RedditPost, at time.Time) error {
+	names := make([]string, 0, len(posts))
+	for _, post := range posts {
+		if post.Data.Name != "" {
+			names = append(names, post.Data.Name)
+		}
+	}
+	encoded, err := json.Marshal(names)
+	if err != nil {
+		return fmt.Errorf("failed to encode fetch run: %w", err)
+	}
+
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO fetch_runs (source, fetched_at, posts) VALUES (?, ?, ?)`,
+		source, at.Unix(), string(encoded)); err != nil {
+		return fmt.Errorf("failed to save fetch run: %w", err)
+	}
+	for _, post := range posts {
+		if post.Data.Name == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO post_snapshots (fullname, seen_at, score, num_comments) VALUES (?, ?, ?, ?)`,
+			post.Data.Name, at.Unix(), post.Data.Score, post.Data.NumComments); err != nil {
+			return fmt.Errorf("failed to save score snapshot: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFetchRunAsOf returns the last fetch of a source at or before t, or nil if there is none
+func (ogDB *OpenGraphDB) GetFetchRunAsOf(source string, t time.Time) (*FetchRun, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var fetchedAt int64
+	var posts string
+	err := ogDB.db.QueryRow(`SELECT fetched_at, posts FROM fetch_runs WHERE source = ? AND fetched_at <= ?
+		ORDER BY fetched_at DESC, id DESC LIMIT 1`, source, t.Unix()).Scan(&fetchedAt, &posts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fetch run: %w", err)
+	}
+
+	run := &FetchRun{Source: source, FetchedAt: time.Unix(fetchedAt, 0)}
+	if err := json.Unmarshal([]byte(posts), &run.Posts); err != nil {
+		return nil, fmt.Errorf("failed to decode fetch run: %w", err)
+	}
+	return run, nil
+}
+
+// GetFetchRunsSince returns the fetches of a source since the given time, oldest first
+func (ogDB *OpenGraphDB) GetFetchRunsSince(source string, since time.Time) ([]FetchRun, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`SELECT fetched_at, posts FROM fetch_runs WHERE source = ? AND fetched_at >= ?
+		ORDER BY fetched_at, id`, source, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fetch runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []FetchRun
+	for rows.Next() {
+		var fetchedAt int64
+		var posts string
+		if err := rows.Scan(&fetchedAt, &posts); err != nil {
+			return nil, fmt.Errorf("failed to scan fetch run: %w", err)
+		}
+		run := FetchRun{Source: source, FetchedAt: time.Unix(fetchedAt, 0)}
+		if err := json.Unmarshal([]byte(posts), &run.Posts); err != nil {
+			return nil, fmt.Errorf("failed to decode fetch run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// getSnapshotsAsOf returns the latest recorded score of each post at or before t
+func (ogDB *OpenGraphDB) getSnapshotsAsOf(fullnames []string, t time.Time) (map[string]postSnapshot, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	snapshots := make(map[string]postSnapshot, len(fullnames))
+	for _, name := range fullnames {
+		var snapshot postSnapshot
+		err := ogDB.db.QueryRow(`SELECT score, num_comments FROM post_snapshots WHERE fullname = ? AND seen_at <= ?
+			ORDER BY seen_at DESC LIMIT 1`, name, t.Unix()).Scan(&snapshot.Score, &snapshot.NumComments)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load score snapshot: %w", err)
+		}
+		snapshots[name] = snapshot
+	}
+	return snapshots, nil
+}
+
+// Regenerate rebuilds the feed from the item store as it was at asOf, using the
+// posts of the last fetch before that time with the scores recorded back then.
+// The current filters apply, so filter changes can be checked against old data.
+func (p *Pipeline) Regenerate(ctx context.Context, asOf time.Time, opts RunOptions) (*RunResult, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("regenerating requires the cache database")
+	}
+
+	posts, err := LoadPostsAsOf(p.db, HomepageSource, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Regenerating mustn't count as a content change of the live feed
+	if opts.Updated == "" {
+		opts.Updated = UpdatedNewestItem
+	}
+	opts.Offline = true
+	return p.build(ctx, posts, opts, nil)
+}
+
+// LoadPostsAsOf returns the posts of the last fetch of source at or before asOf in
+// listing order, with the scores recorded back then
+func LoadPostsAsOf(db *OpenGraphDB, source string, asOf time.Time) ([]RedditPost, error) {
+	run, err := db.GetFetchRunAsOf(source, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, fmt.Errorf("no stored fetch of %s at or before %s", source, asOf.Format(time.RFC3339))
+	}
+
+	stored, err := db.GetSeenPosts(run.Posts)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := db.getSnapshotsAsOf(run.Posts, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]RedditPost, 0, len(run.Posts))
+	for _, name := range run.Posts {
+		post, ok := stored[name]
+		if !ok {
+			continue
+		}
+		if snapshot, ok := snapshots[name]; ok {
+			post.Data.Score = snapshot.Score
+			post.Data.NumComments = snapshot.NumComments
+		}
+		posts = append(posts, post)
+	}
+	slog.Info("Loaded posts from history", "fetched_at", run.FetchedAt, "posts", len(posts))
+
+	return posts, nil
+}
+
+// parseAsOf parses an RFC 3339 time or a date, which means the end of that day
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// runRegenerate implements the regenerate subcommand
+func runRegenerate(args []string) error {
+	fs := flag.NewFlagSet("regenerate", flag.ExitOnError)
+	asOf := fs.String("as-of", "", "rebuild the feed as it was at this date (YYYY-MM-DD) or time (RFC 3339)")
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	output := fs.String("o", "", "output file (default: the configured output_path)")
+	minPoints := fs.Int("min-points", -1, "minimum score, overrides the config when >= 0")
+	limit := fs.Int("limit", 30, "maximum number of items to include in the feed")
+	fs.Parse(args)
+
+	if *asOf == "" {
+		return fmt.Errorf("-as-of is required")
+	}
+	at, err := parseAsOf(*asOf)
+	if err != nil {
+		return err
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil {
+		return err
+	}
+
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	opts := DefaultRunOptions()
+	opts.MinScore = *minPoints
+	opts.Limit = *limit
+
+	result, err := NewPipeline(&config, nil, db).Regenerate(context.Background(), at, opts)
+	if err != nil {
+		return err
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = config.OutputPath
+	}
+	outputPath = resolveOutputPath(outputPath, "", DefaultTenant, config.FeedType, at)
+	if err := config.saveFeed(outputPath, result.Content, result.ContentType); err != nil {
+		return err
+	}
+
+	fmt.Printf("Regenerated %s feed as of %s with %d items: %s\n",
+		config.FeedType, at.Format(time.RFC3339), result.Items, outputPath)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// historyPost returns a post that renders as a valid feed item
+func historyPost(name string, score int) RedditPost {
+	post := seenPost(name, score)
+	post.Data.Permalink = "/r/test/comments/" + name
+	post.Data.URL = "https://www.reddit.com/r/test/comments/" + name
+	return post
+}
+
+func TestRegenerateAsOf(t *testing.T) {
+	db := newTestDB(t)
+	p := &Pipeline{config: &Config{FeedType: "rss"}, db: db}
+
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	early := []RedditPost{historyPost("t3_a", 50), historyPost("t3_b", 5)}
+	if err := db.SaveSeenPosts(early); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+	if err := db.RecordFetchRun(HomepageSource, early, first); err != nil {
+		t.Fatalf("RecordFetchRun failed: %v", err)
+	}
+
+	// The stored posts now carry the later scores
+	late := []RedditPost{historyPost("t3_b", 500), historyPost("t3_c", 300)}
+	if err := db.SaveSeenPosts(late); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+	if err := db.RecordFetchRun(HomepageSource, late, second); err != nil {
+		t.Fatalf("RecordFetchRun failed: %v", err)
+	}
+
+	opts := RunOptions{MinScore: 10}
+	result, err := p.Regenerate(context.Background(), first.Add(time.Hour), opts)
+	if err != nil {
+		t.Fatalf("Regenerate failed: %v", err)
+	}
+	if result.Items != 1 {
+		t.Errorf("expected only t3_a to pass with its recorded score, got %d items", result.Items)
+	}
+	if !strings.Contains(string(result.Content), "t3_a") {
+		t.Errorf("expected feed to contain t3_a:\n%s", result.Content)
+	}
+
+	result, err = p.Regenerate(context.Background(), second, opts)
+	if err != nil {
+		t.Fatalf("Regenerate failed: %v", err)
+	}
+	if result.Items != 2 {
+		t.Errorf("expected the second run's 2 posts, got %d items", result.Items)
+	}
+
+	if _, err := p.Regenerate(context.Background(), first.Add(-time.Hour), opts); err == nil {
+		t.Error("expected an error before the first recorded fetch")
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	day, err := parseAsOf("2024-06-01")
+	if err != nil {
+		t.Fatalf("parseAsOf failed: %v", err)
+	}
+	if day.Day() != 1 || day.Hour() != 23 {
+		t.Errorf("expected the end of June 1st, got %v", day)
+	}
+
+	exact, err := parseAsOf("2024-06-01T08:00:00Z")
+	if err != nil || !exact.Equal(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected RFC 3339 result %v, %v", exact, err)
+	}
+
+	if _, err := parseAsOf("June 1st"); err == nil {
+		t.Error("expected invalid time to be rejected")
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP cache limits
+const (
+	HTTPCacheRetention    = 30 * 24 * time.Hour // Entries older than this are pruned even if they have validators
+	HTTPCacheMaxBodySize  = 2 * 1024 * 1024     // Larger responses are passed through without caching
+	httpCacheHeader       = "X-Red-Rss-Cache"   // Set on responses served from the cache: "hit" or "revalidated"
+	heuristicFreshnessCap = 24 * time.Hour      // Upper bound for Last-Modified based heuristic freshness
+)
+
+// CachingTransport is an http.RoundTripper implementing a private HTTP cache with
+// RFC 9111 semantics (Cache-Control, Expires, Age, Vary, ETag and Last-Modified
+// revalidation), persisted in the SQLite cache database. Only one variant per URL
+// is stored; a request whose Vary headers differ from the stored variant is a miss.
+type CachingTransport struct {
+	Transport http.RoundTripper
+	db        *OpenGraphDB
+	now       func() time.Time
+}
+
+// NewCachingTransport wraps the transport with a cache stored in db
+func NewCachingTransport(db *OpenGraphDB, transport http.RoundTripper) *CachingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &CachingTransport{
+		Transport: transport,
+		db:        db,
+		now:       time.Now,
+	}
+}
+
+// cachedResponse is a stored response along with the data needed to compute its age
+type cachedResponse struct {
+	Vary         map[string]string
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+// RoundTrip serves the request from cache when fresh, revalidates stale entries
+// and stores cacheable responses
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header.Get("Cache-Control"))
+	if req.Method != http.MethodGet || hasDirective(reqCC, "no-store") {
+		return t.Transport.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	cached, err := t.db.getHTTPCache(key)
+	if err != nil {
+		slog.Warn("Error reading HTTP cache", "url", key, "error", err)
+		cached = nil
+	}
+	if cached != nil && !cached.matchesVary(req) {
+		cached = nil
+	}
+
+	if cached != nil && !hasDirective(reqCC, "no-cache") {
+		age := cached.currentAge(t.now())
+		fresh := age < cached.freshnessLifetime()
+		if maxAge, ok := reqCC["max-age"]; ok {
+			if seconds, err := strconv.Atoi(maxAge); err == nil && age > time.Duration(seconds)*time.Second {
+				fresh = false
+			}
+		}
+		if fresh && !hasDirective(parseCacheControl(cached.Header.Get("Cache-Control")), "no-cache") {
+			slog.Debug("HTTP cache hit", "url", key, "age", age)
+			return cached.toResponse(req, "hit"), nil
+		}
+	}
+
+	outReq := req
+	if cached != nil {
+		outReq = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			outReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := t.now()
+	resp, err := t.Transport.RoundTrip(outReq)
+	if err != nil {
+		// Serve stale content rather than failing, unless the origin forbids it
+		if cached != nil && !hasDirective(parseCacheControl(cached.Header.Get("Cache-Control")), "must-revalidate") {
+			slog.Warn("Revalidation failed, serving stale response", "url", key, "error", err)
+			return cached.toResponse(req, "stale"), nil
+		}
+		return nil, err
+	}
+	responseTime := t.now()
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		cached.refresh(resp.Header, requestTime, responseTime)
+		if err := t.db.saveHTTPCache(key, cached); err != nil {
+			slog.Warn("Failed to update HTTP cache", "url", key, "error", err)
+		}
+		slog.Debug("HTTP cache revalidated", "url", key)
+		return cached.toResponse(req, "revalidated"), nil
+	}
+
+	if !isCacheableResponse(resp) {
+		return resp, nil
+	}
+
+	// Buffer the body so it can be both stored and returned
+	body, err := io.ReadAll(io.LimitReader(resp.Body, HTTPCacheMaxBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if len(body) > HTTPCacheMaxBodySize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cachedResponse{
+		Vary:         varyValues(req, resp.Header),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	if err := t.db.saveHTTPCache(key, entry); err != nil {
+		slog.Warn("Failed to store HTTP cache entry", "url", key, "error", err)
+	}
+
+	return resp, nil
+}
+
+// isCacheableResponse reports whether a response may be stored. Responses without
+// explicit freshness are still stored when they carry validators for revalidation.
+func isCacheableResponse(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if hasDirective(cc, "no-store") {
+		return false
+	}
+
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	_, hasMaxAge := cc["max-age"]
+	return hasMaxAge ||
+		resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+// freshnessLifetime computes how long the response is fresh after it was generated
+func (c *cachedResponse) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(c.Header.Get("Cache-Control"))
+	if maxAge, ok := cc["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	date := c.dateValue()
+	if expires := c.Header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0 // Invalid Expires means already expired
+		}
+		return expiresAt.Sub(date)
+	}
+
+	// Heuristic freshness: 10% of the time since last modification
+	if lastModified := c.Header.Get("Last-Modified"); lastModified != "" {
+		modifiedAt, err := http.ParseTime(lastModified)
+		if err == nil && date.After(modifiedAt) {
+			return min(date.Sub(modifiedAt)/10, heuristicFreshnessCap)
+		}
+	}
+
+	return 0
+}
+
+// currentAge computes the age of the response as defined in RFC 9111 section 4.2.3
+func (c *cachedResponse) currentAge(now time.Time) time.Duration {
+	apparentAge := max(c.ResponseTime.Sub(c.dateValue()), 0)
+
+	var ageValue time.Duration
+	if age, err := strconv.Atoi(c.Header.Get("Age")); err == nil && age > 0 {
+		ageValue = time.Duration(age) * time.Second
+	}
+
+	responseDelay := c.ResponseTime.Sub(c.RequestTime)
+	correctedInitialAge := max(apparentAge, ageValue+responseDelay)
+	return correctedInitialAge + now.Sub(c.ResponseTime)
+}
+
+// dateValue returns the Date header, falling back to the time the response was received
+func (c *cachedResponse) dateValue() time.Time {
+	if date, err := http.ParseTime(c.Header.Get("Date")); err == nil {
+		return date
+	}
+	return c.ResponseTime
+}
+
+// refresh updates the stored entry with headers from a 304 Not Modified response
+func (c *cachedResponse) refresh(header http.Header, requestTime, responseTime time.Time) {
+	for name, values := range header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		c.Header[name] = values
+	}
+	c.RequestTime = requestTime
+	c.ResponseTime = responseTime
+}
+
+// matchesVary reports whether the request selects the stored variant
+func (c *cachedResponse) matchesVary(req *http.Request) bool {
+	for name, value := range c.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// toResponse builds an http.Response from the stored entry
+func (c *cachedResponse) toResponse(req *http.Request, status string) *http.Response {
+	header := c.Header.Clone()
+	header.Set(httpCacheHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode)),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+// varyValues records the request header values named by the response's Vary header
+func varyValues(req *http.Request, header http.Header) map[string]string {
+	values := make(map[string]string)
+	for _, field := range header.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				values[name] = req.Header.Get(name)
+			}
+		}
+	}
+	return values
+}
+
+// parseCacheControl parses a Cache-Control header into lowercase directives
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
+
+// hasDirective reports whether a Cache-Control directive is present
+func hasDirective(directives map[string]string, name string) bool {
+	_, ok := directives[name]
+	return ok
+}
+
+// getHTTPCache loads a stored response by key
+func (ogDB *OpenGraphDB) getHTTPCache(key string) (*cachedResponse, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	row := ogDB.db.QueryRow(`SELECT vary, status, header, body, compressed, encrypted, request_time, response_time
+			  FROM http_cache WHERE key = ?`, key)
+
+	var vary, header string
+	var body []byte
+	var compressed, encrypted bool
+	var requestTime, responseTime int64
+	entry := &cachedResponse{}
+	err := row.Scan(&vary, &entry.StatusCode, &header, &body, &compressed, &encrypted, &requestTime, &responseTime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan HTTP cache entry: %w", err)
+	}
+
+	if body, err = ogDB.openField(body, encrypted); err != nil {
+		return nil, err
+	}
+	if entry.Body, err = decompressField(body, compressed); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(vary), &entry.Vary); err != nil {
+		return nil, fmt.Errorf("failed to decode vary values: %w", err)
+	}
+	if err := json.Unmarshal([]byte(header), &entry.Header); err != nil {
+		return nil, fmt.Errorf("failed to decode headers: %w", err)
+	}
+	entry.RequestTime = time.Unix(requestTime, 0)
+	entry.ResponseTime = time.Unix(responseTime, 0)
+
+	return entry, nil
+}
+
+// saveHTTPCache stores a response, replacing any previous variant for the key
+func (ogDB *OpenGraphDB) saveHTTPCache(key string, entry *cachedResponse) error {
+	vary, err := json.Marshal(entry.Vary)
+	if err != nil {
+		return fmt.Errorf("failed to encode vary values: %w", err)
+	}
+	header, err := json.Marshal(entry.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers: %w", err)
+	}
+	body, compressed, err := compressField(entry.Body)
+	if err != nil {
+		return err
+	}
+
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	body, encrypted, err := ogDB.sealField(body)
+	if err != nil {
+		return err
+	}
+	_, err = ogDB.db.Exec(`INSERT OR REPLACE INTO http_cache
+			  (key, vary, status, header, body, compressed, encrypted, request_time, response_time)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key, string(vary), entry.StatusCode, string(header), body, compressed, encrypted,
+		entry.RequestTime.Unix(), entry.ResponseTime.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save HTTP cache entry: %w", err)
+	}
+
+	return nil
+}
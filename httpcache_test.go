@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *OpenGraphDB {
+	t.Helper()
+	db, err := OpenOpenGraphDB(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCachingTransport(t *testing.T) {
+	var hits, revalidations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			io.WriteString(w, "fresh body")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidations.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			io.WriteString(w, "etag body")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			io.WriteString(w, "private body")
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewCachingTransport(newTestDB(t), http.DefaultTransport)}
+	get := func(path string) (string, string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get(httpCacheHeader)
+	}
+
+	get("/fresh")
+	body, status := get("/fresh")
+	if body != "fresh body" || status != "hit" {
+		t.Errorf("expected cached fresh body, got %q (%s)", body, status)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected 1 origin request for fresh resource, got %d", hits.Load())
+	}
+
+	get("/etag")
+	body, status = get("/etag")
+	if body != "etag body" || status != "revalidated" {
+		t.Errorf("expected revalidated body, got %q (%s)", body, status)
+	}
+	if revalidations.Load() != 1 {
+		t.Errorf("expected 1 conditional request, got %d", revalidations.Load())
+	}
+
+	hits.Store(0)
+	get("/nostore")
+	get("/nostore")
+	if hits.Load() != 2 {
+		t.Errorf("expected no-store responses to bypass the cache, got %d origin requests", hits.Load())
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	cc := parseCacheControl(`public, Max-Age=300, no-cache="Set-Cookie"`)
+	if cc["max-age"] != "300" {
+		t.Errorf("expected max-age 300, got %q", cc["max-age"])
+	}
+	if !hasDirective(cc, "public") || !hasDirective(cc, "no-cache") {
+		t.Errorf("expected public and no-cache directives, got %v", cc)
+	}
+}
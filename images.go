@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// redditImage returns the image of a post linking to an i.redd.it image, with its
+// dimensions from the preview data when Reddit generated one, or nil for other posts
+func redditImage(post RedditPost) *ImageSource {
+	u, err := url.Parse(post.Data.URL)
+	if err != nil || u.Host != "i.redd.it" {
+		return nil
+	}
+
+	image := &ImageSource{URL: post.Data.URL}
+	if post.Data.Preview != nil && len(post.Data.Preview.Images) > 0 {
+		source := post.Data.Preview.Images[0].Source
+		image.Width, image.Height = source.Width, source.Height
+	}
+	return image
+}
+
+// previewImage returns the full-size preview image Reddit generated for a post, or its
+// thumbnail when it has no preview, or nil
+func previewImage(post RedditPost) *ImageSource {
+	if post.Data.Preview != nil && len(post.Data.Preview.Images) > 0 {
+		if source := post.Data.Preview.Images[0].Source; source.URL != "" {
+			return &source
+		}
+	}
+	return thumbnailImage(post)
+}
+
+// thumbnailImage returns the thumbnail of a post, or nil when it has none. Reddit uses
+// placeholders such as "self", "default" and "nsfw" for posts without one.
+func thumbnailImage(post RedditPost) *ImageSource {
+	if !strings.HasPrefix(post.Data.Thumbnail, "https://") && !strings.HasPrefix(post.Data.Thumbnail, "http://") {
+		return nil
+	}
+	return &ImageSource{URL: post.Data.Thumbnail, Width: post.Data.ThumbnailWidth, Height: post.Data.ThumbnailHeight}
+}
+
+// itemImage returns the image attached to the item of a post: the linked i.redd.it
+// image with image_enclosures, then with preview_enclosures the preview or thumbnail
+// of the post, falling back to the og:image of the linked page
+func (fg *FeedGenerator) itemImage(post RedditPost, ogData map[string]*OpenGraphData) *ImageSource {
+	if image := redditImage(post); image != nil && fg.options.ImageEnclosures {
+		return image
+	}
+	if !fg.options.PreviewEnclosures {
+		return nil
+	}
+	if image := previewImage(post); image != nil {
+		return image
+	}
+	if og := ogData[previewLink(post)]; og != nil && og.Image != "" {
+		return &ImageSource{URL: og.Image}
+	}
+	return nil
+}
+
+// mediaContentXML renders an image as Media RSS elements: the image as media:content,
+// and the post's thumbnail as media:thumbnail
+func mediaContentXML(image *ImageSource, post RedditPost) string {
+	xml := fmt.Sprintf(`<media:content url="%s" type="%s" medium="image"%s/>`, escapeXML(image.URL), imageType(image.URL), sizeAttrs(image))
+	if thumbnail := thumbnailImage(post); thumbnail != nil {
+		xml += fmt.Sprintf(`<media:thumbnail url="%s"%s/>`, escapeXML(thumbnail.URL), sizeAttrs(thumbnail))
+	}
+	return xml
+}
+
+// sizeAttrs returns the width and height attributes of an image when known
+func sizeAttrs(image *ImageSource) string {
+	if image.Width > 0 && image.Height > 0 {
+		return fmt.Sprintf(` width="%d" height="%d"`, image.Width, image.Height)
+	}
+	return ""
+}
+
+// imageType returns the MIME type of an image URL by its extension
+func imageType(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
+
+// imageHTML renders an image inline with its dimensions when known
+func imageHTML(image *ImageSource, alt string) string {
+	return fmt.Sprintf(`<p><img src="%s" alt="%s"%s style="max-width: 100%%; height: auto;"/></p>`,
+		escapeXML(image.URL), escapeXML(alt), sizeAttrs(image))
+}
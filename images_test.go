@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImageEnclosures(t *testing.T) {
+	post := seenPost("t3_img", 10)
+	post.Data.URL = "https://i.redd.it/abc123.png"
+	post.Data.Permalink = "/r/pics/comments/img/"
+	post.Data.Preview = &PostPreview{Images: []PreviewImage{{
+		Source: ImageSource{URL: "https://preview.redd.it/abc123.png?width=1200&amp;s=x", Width: 1200, Height: 800},
+	}}}
+
+	fg := NewFeedGenerator(nil)
+	if item := fg.createFeedItem(post, nil); item.Enclosure != nil {
+		t.Errorf("expected no enclosure without image_enclosures")
+	}
+
+	fg.SetOptions(FeedOptions{ImageEnclosures: true})
+	item := fg.createFeedItem(post, nil)
+	if item.Enclosure == nil || item.Enclosure.Url != post.Data.URL || item.Enclosure.Type != "image/png" {
+		t.Fatalf("unexpected enclosure %+v", item.Enclosure)
+	}
+
+	content, err := fg.RenderFeed([]RedditPost{post}, nil, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	for _, want := range []string{
+		`<link rel="enclosure" type="image/png" href="https://i.redd.it/abc123.png"/>`,
+		`width=&quot;1200&quot; height=&quot;800&quot;`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %s in the feed:\n%s", want, content)
+		}
+	}
+
+	other := seenPost("t3_link", 10)
+	other.Data.URL = "https://example.com/a.png"
+	if redditImage(other) != nil {
+		t.Errorf("expected only i.redd.it images")
+	}
+}
+
+func TestPreviewEnclosures(t *testing.T) {
+	post := seenPost("t3_link", 10)
+	post.Data.URL = "https://example.com/article"
+	post.Data.Permalink = "/r/news/comments/link/"
+	post.Data.Thumbnail = "https://b.thumbs.redditmedia.com/abc.jpg"
+	post.Data.ThumbnailWidth, post.Data.ThumbnailHeight = 140, 93
+	post.Data.Preview = &PostPreview{Images: []PreviewImage{{
+		Source: ImageSource{URL: "https://external-preview.redd.it/abc.jpg?s=x", Width: 1200, Height: 800},
+	}}}
+	ogData := map[string]*OpenGraphData{post.Data.URL: {Title: "Article", Image: "https://example.com/og.png"}}
+
+	fg := NewFeedGenerator(nil)
+	if item := fg.createFeedItem(post, ogData); item.Enclosure != nil {
+		t.Errorf("expected no enclosure without preview_enclosures")
+	}
+
+	fg.SetOptions(FeedOptions{PreviewEnclosures: true})
+	content, err := fg.RenderFeed([]RedditPost{post}, ogData, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	for _, want := range []string{
+		`xmlns:media="http://search.yahoo.com/mrss/"`,
+		`<link rel="enclosure" type="image/jpeg" href="https://external-preview.redd.it/abc.jpg?s=x"/>`,
+		`<media:content url="https://external-preview.redd.it/abc.jpg?s=x" type="image/jpeg" medium="image" width="1200" height="800"/>`,
+		`<media:thumbnail url="https://b.thumbs.redditmedia.com/abc.jpg" width="140" height="93"/>`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %s in the feed:\n%s", want, content)
+		}
+	}
+
+	// Without a preview the thumbnail is used, without either the og:image
+	post.Data.Preview = nil
+	if item := fg.createFeedItem(post, ogData); item.Enclosure == nil || item.Enclosure.Url != post.Data.Thumbnail {
+		t.Errorf("expected the thumbnail as enclosure, got %+v", item.Enclosure)
+	}
+	post.Data.Thumbnail = "default"
+	if item := fg.createFeedItem(post, ogData); item.Enclosure == nil || item.Enclosure.Url != "https://example.com/og.png" || item.Enclosure.Type != "image/png" {
+		t.Errorf("expected the og:image as enclosure, got %+v", item.Enclosure)
+	}
+}
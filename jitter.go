@@ -0,0 +1,34 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// ScheduleOptions controls when serve mode generates feeds
+type ScheduleOptions struct {
+	Interval    time.Duration // Time between runs of feeds without a cron schedule
+	MaxInterval time.Duration // Longest interval failing feeds back off to, no backoff when <= Interval
+	Jitter      time.Duration // Maximum random delay added to every scheduled run
+	Splay       time.Duration // Window the first runs after startup are spread over
+}
+
+// jitterDelay returns a random delay below max, or zero without a maximum
+func jitterDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
+
+// splayDelay returns a fixed delay below splay for a key, so every feed keeps its own
+// offset across restarts and feeds don't all start at once
+func splayDelay(key string, splay time.Duration) time.Duration {
+	if splay <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return time.Duration(h.Sum64() % uint64(splay))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// Where OAuth tokens are stored
+const (
+	TokenStorageFile    = "file"    // In the config file
+	TokenStorageKeyring = "keyring" // In the OS keyring, or the config file when there is none
+)
+
+// keyringService names the tokens of red-rss in the OS keyring
+const keyringService = "red-rss"
+
+var (
+	errKeyringUnavailable = errors.New("no OS keyring available")
+	errKeyringNotFound    = errors.New("not found in the OS keyring")
+)
+
+// Keyring stores secrets by account in the OS keyring: the macOS Keychain, the
+// Windows Credential Manager or the Secret Service of libsecret
+type Keyring interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// tokenKeyring is where tokens go with token_storage "keyring"
+var tokenKeyring Keyring = systemKeyring()
+
+// keyringTokens are the tokens of a config as stored in the keyring
+type keyringTokens struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// MarshalJSON leaves the tokens out of the config once they are in the keyring
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	if !c.tokensInKeyring {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		plain
+		AccessToken  string     `json:"access_token,omitempty"`
+		RefreshToken string     `json:"refresh_token,omitempty"`
+		ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	}{plain: plain(c)})
+}
+
+// keyringAccount identifies the tokens of a config file in the keyring, so that
+// tenants and other configs keep their own
+func keyringAccount(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// loadKeyringTokens replaces the tokens of config with the ones in the keyring when
+// token_storage is "keyring". Tokens still in the file are kept when the keyring has
+// none, and move to the keyring when the config is saved next.
+func loadKeyringTokens(path string, config *Config) {
+	if config.TokenStorage != TokenStorageKeyring {
+		return
+	}
+
+	secret, err := tokenKeyring.Get(keyringAccount(path))
+	if errors.Is(err, errKeyringNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Warn("Failed to read tokens from the OS keyring, using the config file", "error", err)
+		return
+	}
+
+	var tokens keyringTokens
+	if err := json.Unmarshal([]byte(secret), &tokens); err != nil {
+		slog.Warn("Ignoring invalid tokens in the OS keyring", "error", err)
+		return
+	}
+	config.AccessToken = tokens.AccessToken
+	config.RefreshToken = tokens.RefreshToken
+	config.ExpiresAt = tokens.ExpiresAt
+	config.tokensInKeyring = true
+}
+
+// saveKeyringTokens stores the tokens of config in the keyring when token_storage is
+// "keyring" and reports whether they are there, so they can be left out of the
+// config file. Without a usable keyring the tokens stay in the file.
+func saveKeyringTokens(path string, config *Config) bool {
+	config.tokensInKeyring = false
+	if config.TokenStorage != TokenStorageKeyring {
+		return false
+	}
+
+	secret, err := json.Marshal(keyringTokens{config.AccessToken, config.RefreshToken, config.ExpiresAt})
+	if err != nil {
+		return false
+	}
+	if err := tokenKeyring.Set(keyringAccount(path), string(secret)); err != nil {
+		slog.Warn("Failed to store tokens in the OS keyring, saving them to the config file", "error", err)
+		return false
+	}
+	config.tokensInKeyring = true
+	return true
+}
+
+// validateTokenStorage checks the token_storage setting
+func validateTokenStorage(storage string) error {
+	switch storage {
+	case "", TokenStorageFile, TokenStorageKeyring:
+		return nil
+	}
+	return fmt.Errorf("token_storage must be 'file' or 'keyring'")
+}
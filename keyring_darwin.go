@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityKeyring stores secrets in the macOS Keychain with the security tool
+type securityKeyring struct{}
+
+func systemKeyring() Keyring {
+	return securityKeyring{}
+}
+
+// security runs the security tool, which exits with 44 for missing items
+func (securityKeyring) security(stdin string, args ...string) (string, error) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return "", errKeyringUnavailable
+	}
+	cmd := exec.Command("security", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+		return "", errKeyringNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("security %s: %w", args[0], err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (k securityKeyring) Get(account string) (string, error) {
+	return k.security("", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+}
+
+// Set passes the secret hex-encoded on stdin, so it doesn't show in the process list
+func (k securityKeyring) Set(account, secret string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %q -a %q -X %x\n", keyringService, account, secret)
+	_, err := k.security(command, "-i")
+	return err
+}
+
+func (k securityKeyring) Delete(account string) error {
+	_, err := k.security("", "delete-generic-password", "-s", keyringService, "-a", account)
+	return err
+}
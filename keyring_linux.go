@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secretToolKeyring stores secrets with the Secret Service through the secret-tool
+// command of libsecret
+type secretToolKeyring struct{}
+
+func systemKeyring() Keyring {
+	return secretToolKeyring{}
+}
+
+// secretTool runs secret-tool, which needs a D-Bus session to reach the Secret Service
+func (secretToolKeyring) secretTool(stdin string, args ...string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil || os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return "", errKeyringUnavailable
+	}
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret-tool %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func (k secretToolKeyring) Get(account string) (string, error) {
+	secret, err := k.secretTool("", "lookup", "service", keyringService, "account", account)
+	var exitErr *exec.ExitError
+	if (errors.As(err, &exitErr) && exitErr.ExitCode() == 1) || (err == nil && secret == "") {
+		return "", errKeyringNotFound
+	}
+	return secret, err
+}
+
+// Set passes the secret on stdin, so it doesn't show in the process list
+func (k secretToolKeyring) Set(account, secret string) error {
+	_, err := k.secretTool(secret, "store", "--label", "red-rss tokens for "+account, "service", keyringService, "account", account)
+	return err
+}
+
+func (k secretToolKeyring) Delete(account string) error {
+	_, err := k.secretTool("", "clear", "service", keyringService, "account", account)
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+// noKeyring is used on systems without a supported OS keyring
+type noKeyring struct{}
+
+func systemKeyring() Keyring {
+	return noKeyring{}
+}
+
+func (noKeyring) Get(account string) (string, error) { return "", errKeyringUnavailable }
+func (noKeyring) Set(account, secret string) error   { return errKeyringUnavailable }
+func (noKeyring) Delete(account string) error        { return errKeyringUnavailable }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryKeyring is an OS keyring in memory, failing every call when unavailable
+type memoryKeyring struct {
+	secrets     map[string]string
+	unavailable bool
+}
+
+func (k *memoryKeyring) Get(account string) (string, error) {
+	if k.unavailable {
+		return "", errKeyringUnavailable
+	}
+	secret, ok := k.secrets[account]
+	if !ok {
+		return "", errKeyringNotFound
+	}
+	return secret, nil
+}
+
+func (k *memoryKeyring) Set(account, secret string) error {
+	if k.unavailable {
+		return errKeyringUnavailable
+	}
+	k.secrets[account] = secret
+	return nil
+}
+
+func (k *memoryKeyring) Delete(account string) error {
+	if k.unavailable {
+		return errKeyringUnavailable
+	}
+	if _, ok := k.secrets[account]; !ok {
+		return errKeyringNotFound
+	}
+	delete(k.secrets, account)
+	return nil
+}
+
+// useKeyring replaces the OS keyring for the duration of a test
+func useKeyring(t *testing.T, k Keyring) {
+	saved := tokenKeyring
+	tokenKeyring = k
+	t.Cleanup(func() { tokenKeyring = saved })
+}
+
+func TestKeyringTokenStorage(t *testing.T) {
+	keyring := &memoryKeyring{secrets: make(map[string]string)}
+	useKeyring(t, keyring)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := DefaultConfig()
+	config.ClientID = "id"
+	config.TokenStorage = TokenStorageKeyring
+	config.RefreshToken = "refresh"
+	config.AccessToken = "access"
+	if err := writeConfigFile(path, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "access_token") || strings.Contains(string(data), "refresh") {
+		t.Errorf("expected no tokens in the config file, got %s", data)
+	}
+	if secret := keyring.secrets[keyringAccount(path)]; !strings.Contains(secret, `"refresh_token":"refresh"`) {
+		t.Errorf("expected the tokens in the keyring, got %q", secret)
+	}
+
+	loaded := DefaultConfig()
+	if err := readConfigFile(path, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.RefreshToken != "refresh" || loaded.AccessToken != "access" {
+		t.Errorf("expected the tokens from the keyring, got %q and %q", loaded.AccessToken, loaded.RefreshToken)
+	}
+
+	// Refreshed tokens replace the ones in the keyring
+	loaded.RefreshToken = "rotated"
+	if err := saveConfigTokens(path, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "rotated") || !strings.Contains(keyring.secrets[keyringAccount(path)], "rotated") {
+		t.Errorf("expected the rotated token in the keyring only, got %s", data)
+	}
+}
+
+func TestKeyringFallback(t *testing.T) {
+	useKeyring(t, &memoryKeyring{unavailable: true})
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := DefaultConfig()
+	config.ClientID = "id"
+	config.TokenStorage = TokenStorageKeyring
+	config.RefreshToken = "refresh"
+	if err := writeConfigFile(path, &config); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"refresh_token": "refresh"`) {
+		t.Errorf("expected the tokens in the config file without a keyring, got %s", data)
+	}
+
+	loaded := DefaultConfig()
+	if err := readConfigFile(path, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.RefreshToken != "refresh" {
+		t.Errorf("expected the tokens from the config file, got %q", loaded.RefreshToken)
+	}
+
+	if err := validateTokenStorage("vault"); err == nil {
+		t.Errorf("expected an invalid token_storage to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ledgerWindow is the window Reddit counts requests per client in
+const ledgerWindow = time.Minute
+
+// RateLedger is a log of Reddit API requests in a SQLite file shared by every red-rss
+// process on the machine, so that invocations with different configs but the same
+// client ID together stay within Reddit's per-client rate limit
+type RateLedger struct {
+	db *sql.DB
+}
+
+// rateLedgers holds the ledgers opened by this process by path. They stay open
+// until the process exits.
+var rateLedgers = struct {
+	sync.Mutex
+	byPath map[string]*RateLedger
+}{byPath: make(map[string]*RateLedger)}
+
+// RateLedgerFor returns the ledger at path, opening it on first use
+func RateLedgerFor(path string) (*RateLedger, error) {
+	rateLedgers.Lock()
+	defer rateLedgers.Unlock()
+	if ledger, ok := rateLedgers.byPath[path]; ok {
+		return ledger, nil
+	}
+
+	ledger, err := OpenRateLedger(path)
+	if err != nil {
+		return nil, err
+	}
+	rateLedgers.byPath[path] = ledger
+	return ledger, nil
+}
+
+// OpenRateLedger opens or creates a ledger file
+func OpenRateLedger(path string) (*RateLedger, error) {
+	// Transactions take the write lock up front, so concurrent processes queue up
+	// instead of failing to upgrade their read locks
+	db, err := sql.Open("sqlite", "file:"+path+"?_txlock=immediate&_pragma=busy_timeout(10000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate limit ledger: %w", err)
+	}
+
+	_, err = db.Exec(`
+	CREATE TABLE IF NOT EXISTS ledger_requests (
+		client_id TEXT,
+		at INTEGER
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ledger_requests ON ledger_requests(client_id, at);
+
+	CREATE TABLE IF NOT EXISTS ledger_pauses (
+		client_id TEXT PRIMARY KEY,
+		until INTEGER
+	);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create rate limit ledger: %w", err)
+	}
+	return &RateLedger{db: db}, nil
+}
+
+// Close closes the ledger file
+func (l *RateLedger) Close() error {
+	return l.db.Close()
+}
+
+// Wait blocks until clientID may make another request within perMinute requests per
+// minute across all processes, and records the request
+func (l *RateLedger) Wait(ctx context.Context, clientID string, perMinute int) error {
+	for {
+		wait, err := l.reserve(clientID, perMinute, time.Now())
+		if err != nil || wait <= 0 {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve records a request at now if the budget allows it, and otherwise returns how
+// long to wait before trying again
+func (l *RateLedger) reserve(clientID string, perMinute int, now time.Time) (time.Duration, error) {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock rate limit ledger: %w", err)
+	}
+	defer tx.Rollback()
+
+	var until int64
+	err = tx.QueryRow(`SELECT until FROM ledger_pauses WHERE client_id = ?`, clientID).Scan(&until)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to read rate limit ledger: %w", err)
+	}
+	if pause := time.UnixMilli(until).Sub(now); pause > 0 {
+		return pause, nil
+	}
+
+	windowStart := now.Add(-ledgerWindow).UnixMilli()
+	if _, err := tx.Exec(`DELETE FROM ledger_requests WHERE client_id = ? AND at <= ?`, clientID, windowStart); err != nil {
+		return 0, fmt.Errorf("failed to prune rate limit ledger: %w", err)
+	}
+
+	var count int
+	var oldest sql.NullInt64
+	err = tx.QueryRow(`SELECT COUNT(*), MIN(at) FROM ledger_requests WHERE client_id = ?`, clientID).Scan(&count, &oldest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rate limit ledger: %w", err)
+	}
+	if count >= perMinute {
+		return time.UnixMilli(oldest.Int64).Add(ledgerWindow).Sub(now), nil
+	}
+
+	if _, err := tx.Exec(`INSERT INTO ledger_requests (client_id, at) VALUES (?, ?)`, clientID, now.UnixMilli()); err != nil {
+		return 0, fmt.Errorf("failed to record request in rate limit ledger: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to record request in rate limit ledger: %w", err)
+	}
+	return 0, nil
+}
+
+// PauseUntil stops every process from making requests for clientID until t
+func (l *RateLedger) PauseUntil(clientID string, t time.Time) error {
+	_, err := l.db.Exec(`INSERT INTO ledger_pauses (client_id, until) VALUES (?, ?)
+		ON CONFLICT(client_id) DO UPDATE SET until = MAX(until, excluded.until)`, clientID, t.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to record pause in rate limit ledger: %w", err)
+	}
+	return nil
+}
+
+// Observe shares a pause with the other processes when Reddit reports that the rate
+// limit is almost exhausted
+func (l *RateLedger) Observe(clientID string, header http.Header) error {
+	if until, ok := rateLimitPause(header); ok {
+		return l.PauseUntil(clientID, until)
+	}
+	return nil
+}
+
+// rateLimitPause returns until when to pause when Reddit's X-Ratelimit headers report
+// that the remaining budget runs low
+func rateLimitPause(header http.Header) (time.Time, bool) {
+	remaining, err := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
+	if err != nil || remaining >= rateLimitLowWatermark {
+		return time.Time{}, false
+	}
+	reset, err := strconv.Atoi(header.Get("X-Ratelimit-Reset"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Now().Add(time.Duration(reset) * time.Second), true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLedgerSharedBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.db")
+	// Two handles on the same file stand in for two processes
+	a, err := OpenRateLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := OpenRateLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	for i, ledger := range []*RateLedger{a, b, a} {
+		if wait, err := ledger.reserve("client", 3, now.Add(time.Duration(i)*time.Second)); err != nil || wait != 0 {
+			t.Fatalf("request %d: expected no wait, got %s, %v", i, wait, err)
+		}
+	}
+	wait, err := b.reserve("client", 3, now.Add(3*time.Second))
+	if err != nil || wait != 57*time.Second {
+		t.Errorf("expected to wait until the first request leaves the window, got %s, %v", wait, err)
+	}
+	if wait, _ := b.reserve("other", 3, now.Add(3*time.Second)); wait != 0 {
+		t.Errorf("expected other client IDs to have their own budget, got %s", wait)
+	}
+	if wait, _ := b.reserve("client", 3, now.Add(61*time.Second)); wait != 0 {
+		t.Errorf("expected the budget to recover after a minute, got %s", wait)
+	}
+
+	// A pause reported to one process applies to all
+	header := http.Header{"X-Ratelimit-Remaining": {"1"}, "X-Ratelimit-Reset": {"30"}}
+	if err := a.Observe("client", header); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := b.reserve("client", 100, time.Now()); wait < 29*time.Second {
+		t.Errorf("expected the shared pause, got %s", wait)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx, "client", 100); err != context.DeadlineExceeded {
+		t.Errorf("expected Wait to give up with the context, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Reddit thing kinds
+const (
+	KindListing   = "Listing"
+	KindComment   = "t1"
+	KindAccount   = "t2"
+	KindPost      = "t3"
+	KindSubreddit = "t5"
+	KindMore      = "more"
+)
+
+// rawListing is a listing whose children are decoded one at a time
+type rawListing struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Children []json.RawMessage `json:"children"`
+		After    string            `json:"after"`
+		Before   string            `json:"before"`
+	} `json:"data"`
+}
+
+// childProbe reads the fields needed to decide whether a child is a usable post
+type childProbe struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Promoted bool `json:"promoted"`
+	} `json:"data"`
+}
+
+// decodeListing decodes a listing response defensively: children that are null,
+// not posts, promoted or malformed are skipped instead of failing the whole decode
+func decodeListing(r io.Reader) (*RedditListing, error) {
+	var raw rawListing
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode listing: %w", err)
+	}
+	if raw.Kind != KindListing {
+		return nil, fmt.Errorf("unexpected response kind %q, expected %q", raw.Kind, KindListing)
+	}
+
+	listing := &RedditListing{Kind: raw.Kind}
+	listing.Data.After = raw.Data.After
+	listing.Data.Before = raw.Data.Before
+	listing.Data.Children = make([]RedditPost, 0, len(raw.Data.Children))
+
+	skipped := 0
+	for i, child := range raw.Data.Children {
+		if len(child) == 0 || bytes.Equal(child, []byte("null")) {
+			skipped++
+			continue
+		}
+
+		var probe childProbe
+		if err := json.Unmarshal(child, &probe); err != nil {
+			slog.Warn("Skipping malformed listing entry", "index", i, "error", err)
+			skipped++
+			continue
+		}
+		if probe.Kind != KindPost {
+			slog.Debug("Skipping non-post listing entry", "index", i, "kind", probe.Kind)
+			skipped++
+			continue
+		}
+		if probe.Data.Promoted {
+			slog.Debug("Skipping promoted listing entry", "index", i)
+			skipped++
+			continue
+		}
+
+		var post RedditPost
+		if err := json.Unmarshal(child, &post); err != nil {
+			slog.Warn("Skipping malformed post", "index", i, "error", err)
+			skipped++
+			continue
+		}
+		unescapeStrings(reflect.ValueOf(&post.Data).Elem())
+		listing.Data.Children = append(listing.Data.Children, post)
+	}
+
+	if skipped > 0 {
+		slog.Debug("Skipped listing entries", "skipped", skipped, "posts", len(listing.Data.Children))
+	}
+	return listing, nil
+}
+
+// unescapeStrings decodes the HTML entities Reddit puts in the strings of API
+// responses, such as &amp; in titles and in the query strings of URLs, for every
+// string reachable from v. Fields ending in _html hold escaped HTML, which is decoded
+// when it is sanitized.
+func unescapeStrings(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if s := v.String(); strings.Contains(s, "&") {
+			v.SetString(html.UnescapeString(s))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			unescapeStrings(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if field.IsExported() && !strings.HasSuffix(key, "_html") {
+				unescapeStrings(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			unescapeStrings(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values aren't addressable, so they are decoded in a copy
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			unescapeStrings(value)
+			v.SetMapIndex(key, value)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeListingSkipsBadChildren(t *testing.T) {
+	body := `{
+		"kind": "Listing",
+		"data": {
+			"after": "t3_c",
+			"before": null,
+			"children": [
+				{"kind": "t3", "data": {"name": "t3_a", "title": "First", "score": 10}},
+				null,
+				{"kind": "t5", "data": {"display_name": "golang"}},
+				{"kind": "t3", "data": {"name": "t3_ad", "title": "Buy now", "promoted": true}},
+				{"kind": "t3", "data": {"name": "t3_bad", "score": "not a number"}},
+				{"kind": "t3", "data": {"name": "t3_c", "title": "Last", "score": 3}}
+			]
+		}
+	}`
+
+	listing, err := decodeListing(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeListing failed: %v", err)
+	}
+
+	children := listing.Data.Children
+	if len(children) != 2 {
+		t.Fatalf("expected 2 posts, got %d", len(children))
+	}
+	if children[0].Data.Name != "t3_a" || children[1].Data.Name != "t3_c" {
+		t.Errorf("unexpected posts: %s, %s", children[0].Data.Name, children[1].Data.Name)
+	}
+	if children[0].Kind != KindPost {
+		t.Errorf("expected kind to be kept, got %q", children[0].Kind)
+	}
+	if listing.Data.After != "t3_c" {
+		t.Errorf("expected after cursor, got %q", listing.Data.After)
+	}
+}
+
+func TestDecodeListingUnescapesStrings(t *testing.T) {
+	body := `{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {
+		"name": "t3_a", "title": "Tom &amp; Jerry &lt;3", "url": "https://example.com/?a=1&amp;b=2",
+		"selftext": "Fish &amp; chips", "selftext_html": "&lt;p&gt;Fish &amp;amp; chips&lt;/p&gt;",
+		"link_flair_text": "Q&amp;A",
+		"preview": {"images": [{"source": {"url": "https://preview.redd.it/x.jpg?width=640&amp;s=abc"}}]},
+		"poll_data": {"options": [{"text": "Yes &amp; no"}]},
+		"media_metadata": {"m1": {"status": "valid", "s": {"u": "https://preview.redd.it/m1.jpg?a=1&amp;b=2"}}}
+	}}]}}`
+
+	listing, err := decodeListing(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("decodeListing failed: %v", err)
+	}
+	d := listing.Data.Children[0].Data
+	if d.Title != "Tom & Jerry <3" || d.URL != "https://example.com/?a=1&b=2" || d.Selftext != "Fish & chips" || d.LinkFlairText != "Q&A" {
+		t.Errorf("expected decoded fields, got %q, %q, %q, %q", d.Title, d.URL, d.Selftext, d.LinkFlairText)
+	}
+	if got := d.Preview.Images[0].Source.URL; got != "https://preview.redd.it/x.jpg?width=640&s=abc" {
+		t.Errorf("expected a decoded preview URL, got %q", got)
+	}
+	if got := d.PollData.Options[0].Text; got != "Yes & no" {
+		t.Errorf("expected a decoded poll option, got %q", got)
+	}
+	if got := d.MediaMetadata["m1"].Source.URL; got != "https://preview.redd.it/m1.jpg?a=1&b=2" {
+		t.Errorf("expected a decoded media URL, got %q", got)
+	}
+	if d.SelftextHTML != "&lt;p&gt;Fish &amp;amp; chips&lt;/p&gt;" {
+		t.Errorf("expected selftext_html to stay escaped, got %q", d.SelftextHTML)
+	}
+}
+
+func TestDecodeListingRejectsNonListing(t *testing.T) {
+	if _, err := decodeListing(strings.NewReader(`{"kind": "t3", "data": {}}`)); err == nil {
+		t.Error("expected error for a non-listing response")
+	}
+	if _, err := decodeListing(strings.NewReader(`<html>`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestDecodeListingFixture(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "best_listing.json"))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	defer file.Close()
+
+	listing, err := decodeListing(file)
+	if err != nil {
+		t.Fatalf("decodeListing failed: %v", err)
+	}
+	posts := listing.Data.Children
+	if len(posts) != 4 {
+		t.Fatalf("expected 4 posts, got %d", len(posts))
+	}
+
+	link := posts[0].Data
+	if link.ID != "1epq2x7" || link.Name != "t3_1epq2x7" || link.Domain != "go.dev" || link.IsSelf {
+		t.Errorf("unexpected link post identity: %+v", link)
+	}
+	if link.LinkFlairText != "announcement" || link.UpvoteRatio != 0.98 {
+		t.Errorf("unexpected flair or upvote ratio: %q, %v", link.LinkFlairText, link.UpvoteRatio)
+	}
+	if link.Preview == nil || len(link.Preview.Images) != 1 || link.Preview.Images[0].Source.Width != 1200 {
+		t.Fatalf("expected preview image, got %+v", link.Preview)
+	}
+	if len(link.Preview.Images[0].Resolutions) != 1 {
+		t.Errorf("expected preview resolutions")
+	}
+	if link.Media != nil {
+		t.Errorf("expected no media for link post")
+	}
+
+	self := posts[1].Data
+	if !self.IsSelf || !self.Stickied || !strings.Contains(self.Selftext, "channels") || self.LinkFlairText != "" {
+		t.Errorf("unexpected self post: %+v", self)
+	}
+
+	video := posts[2].Data
+	if video.Media == nil || video.Media.RedditVideo == nil || video.Media.RedditVideo.Duration != 23 {
+		t.Fatalf("expected reddit video, got %+v", video.Media)
+	}
+	if !strings.HasPrefix(video.Media.RedditVideo.FallbackURL, "https://v.redd.it/") {
+		t.Errorf("unexpected fallback URL %q", video.Media.RedditVideo.FallbackURL)
+	}
+
+	crosspost := posts[3].Data
+	if !crosspost.Over18 || crosspost.CrosspostParent != "t3_1eoaaaa" {
+		t.Errorf("unexpected crosspost: over_18=%v parent=%q", crosspost.Over18, crosspost.CrosspostParent)
+	}
+	if crosspost.Media == nil || crosspost.Media.OEmbed == nil || crosspost.Media.OEmbed.ProviderName != "YouTube" {
+		t.Errorf("expected oEmbed media, got %+v", crosspost.Media)
+	}
+}
+
+// pagedListing serves a listing of three pages of two posts each, where t3_2 moves
+// to the second page while paging
+func pagedListing(t *testing.T, requests *[]string) *http.Client {
+	pages := map[string]struct{ posts, after string }{
+		"":     {`"t3_1", "t3_2"`, "t3_2"},
+		"t3_2": {`"t3_2", "t3_3"`, "t3_3"},
+		"t3_3": {`"t3_4", "t3_5"`, ""},
+	}
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		*requests = append(*requests, req.URL.RawQuery)
+		page, ok := pages[req.URL.Query().Get("after")]
+		if !ok {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		var children []string
+		for _, name := range strings.Split(page.posts, ", ") {
+			children = append(children, fmt.Sprintf(`{"kind": "t3", "data": {"name": %s}}`, name))
+		}
+		body := fmt.Sprintf(`{"kind": "Listing", "data": {"after": %q, "children": [%s]}}`, page.after, strings.Join(children, ","))
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+}
+
+func TestFetchPages(t *testing.T) {
+	var requests []string
+	api := NewRedditAPI(pagedListing(t, &requests))
+	api.rateLimiter = NewRateLimiter(0)
+	api.limiter = NewFairLimiter(6000, 10)
+
+	posts, err := api.FetchPages(context.Background(), "/best", 2, "", nil)
+	if err != nil {
+		t.Fatalf("FetchPages failed: %v", err)
+	}
+	if len(posts) != 3 || posts[2].Data.Name != "t3_3" {
+		t.Errorf("expected t3_1..t3_3 without duplicates, got %v", posts)
+	}
+	if len(requests) != 2 || requests[1] != "after=t3_2&count=2&limit=100" {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	// Paging stops at the end of the listing
+	requests = nil
+	if posts, err := api.FetchConcurrentHomepage(MaxListingPages); err != nil || len(posts) != 5 || len(requests) != 3 {
+		t.Errorf("expected 5 posts in 3 requests, got %d in %d: %v", len(posts), len(requests), err)
+	}
+}
+
+func TestFetchHomepagePagesResumes(t *testing.T) {
+	var requests []string
+	p := NewPipeline(&Config{MaxPages: 3}, pagedListing(t, &requests), nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	// An interrupted run fetched the first page
+	checkpoint := &Checkpoint{Posts: []RedditPost{seenPost("t3_1", 1), seenPost("t3_2", 1)}, After: "t3_2", Pages: 1}
+	posts, err := p.fetchHomepagePages(context.Background(), checkpoint)
+	if err != nil {
+		t.Fatalf("fetchHomepagePages failed: %v", err)
+	}
+	if len(posts) != 5 || len(requests) != 2 || checkpoint.Pages != 3 {
+		t.Errorf("expected the remaining 2 pages to complete 5 posts, got %d posts, requests %v, %d pages", len(posts), requests, checkpoint.Pages)
+	}
+}
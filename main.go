@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -10,39 +12,68 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/feeds"
 	"golang.org/x/oauth2"
 )
 
-const (
-	Version = "1.0.0"
-)
-
 func main() {
 	// Set up structured logging
 	setupLogging()
+	InitTracing()
+
+	// Subcommands don't parse -non-interactive, but honor the environment variable
+	NonInteractive = os.Getenv(NonInteractiveEnv) != ""
+
+	// Subcommands have their own flags and bypass the one-shot run, which can also be
+	// named explicitly with "fetch"
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "fetch" {
+		args = args[1:]
+	} else if handled, err := runCommand(args); handled {
+		ShutdownTracing()
+		if err != nil {
+			slog.Error("Command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Parse command-line flags
 	var (
-		configURL  = flag.String("config", "", "URL to load remote configuration from")
-		configPath = flag.String("config-file", "", "path to local configuration file (optional)")
-		version    = flag.Bool("version", false, "Show version information")
-		debug      = flag.Bool("debug", false, "enable debug logging")
-		outDir     = flag.String("outdir", ".", "directory where the RSS feed file will be saved")
-		minPoints  = flag.Int("min-points", 50, "minimum points threshold for items to include in RSS feed")
-		limit      = flag.Int("limit", 30, "maximum number of items to include in RSS feed")
+		configURL      = flag.String("config", "", "path to the configuration file, or URL to load remote configuration from")
+		configPath     = flag.String("config-file", "", "path to local configuration file, or a comma-separated list merged in order (optional)")
+		version        = flag.Bool("version", false, "Show version information")
+		jsonOutput     = flag.Bool("json", false, "with -version, print build information as JSON")
+		debug          = flag.Bool("debug", false, "enable debug logging")
+		outDir         = flag.String("outdir", ".", "directory where the RSS feed file will be saved")
+		minPoints      = flag.Int("min-points", 50, "minimum points threshold for items to include in RSS feed")
+		limit          = flag.Int("limit", 30, "maximum number of items to include in RSS feed")
+		backfill       = flag.String("backfill", "", "seed the feed with historical posts before generating, e.g. top:month:50")
+		headless       = flag.Bool("headless", false, "print the authorization URL and read the redirect from stdin instead of opening a browser")
+		nonInteractive = flag.Bool("non-interactive", os.Getenv(NonInteractiveEnv) != "", "never prompt or start browser authorization, fail instead (default from "+NonInteractiveEnv+")")
+		splay          = flag.Duration("splay", 0, "wait a random time up to this long before fetching, e.g. 5m for runs started on the hour")
+		once           = flag.Bool("once", false, "generate the feeds once even when the config has a schedule")
 	)
-	flag.Parse()
+	flag.Var(&ConfigOverrides, "set", "override a config key for this run, e.g. -set score_filter=100 (repeatable)")
+	flag.CommandLine.Parse(args)
 
 	if *version {
-		fmt.Printf("GoRedditFeedGenerator version %s\n", Version)
+		if *jsonOutput {
+			json.NewEncoder(os.Stdout).Encode(CurrentBuild())
+			return
+		}
+		fmt.Printf("GoRedditFeedGenerator version %s\n", CurrentBuild())
 		return
 	}
+	resolveAppPaths()
 
 	if *debug {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
+	HeadlessAuth = *headless
+	NonInteractive = *nonInteractive
 
 	slog.Debug("Starting GoRedditFeedGenerator", "version", Version)
 
@@ -50,24 +81,24 @@ func main() {
 	InitializeDefaultConfig()
 
 	// Load configuration
-	var configToLoad string
 	if *configPath != "" {
-		configToLoad = *configPath
-	} else {
-		configToLoad = *configURL
+		ConfigPath = *configPath
 	}
-	err := LoadConfig(configToLoad)
+	if *configURL != "" && !isRemoteConfig(*configURL) {
+		ConfigPath, *configURL = *configURL, ""
+	}
+	err := LoadConfig(*configURL)
 	if err != nil {
 		slog.Warn("Could not load config, creating new one", "error", err)
-
-		// Interactive configuration setup
-		if err := setupInteractiveConfig(); err != nil {
+		if err := setupConfig(err); err != nil {
 			slog.Error("Failed to set up configuration", "error", err)
 			os.Exit(1)
 		}
+	}
 
-		if err := SaveConfig(); err != nil {
-			slog.Error("Failed to save configuration", "error", err)
+	if len(ConfigOverrides) > 0 {
+		if err := applyConfigOverrides(&GlobalConfig, ConfigOverrides); err != nil {
+			slog.Error("Invalid -set override", "error", err)
 			os.Exit(1)
 		}
 	}
@@ -75,18 +106,109 @@ func main() {
 	// Initialize OAuth2 configuration
 	InitializeOAuth2Config()
 
+	// -outdir replaces the directory of the configured output path
+	run := oneShotRun{Debug: *debug, MigratePaths: *configPath == "" && *configURL == ""}
+	if *outDir != "." {
+		run.OutputDir = *outDir
+	}
+
+	// Filter posts using command-line flags if provided, otherwise use config
+	run.Opts = DefaultRunOptions()
+	if *minPoints != 50 { // 50 is the default, so if it's different, use the flag
+		run.Opts.MinScore = *minPoints
+	}
+	run.Opts.Limit = *limit
+
+	if *backfill != "" {
+		spec, err := ParseBackfillSpec(*backfill)
+		if err != nil {
+			slog.Error("Invalid backfill", "error", err)
+			os.Exit(1)
+		}
+		run.Backfill = &spec
+	}
+
+	// SIGINT and SIGTERM stop the run, leaving the previous feeds in place
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	// With a schedule the process keeps running and generates the feeds on it
+	if GlobalConfig.Schedule != "" && !*once {
+		if err := runScheduled(ctx, run); err != nil {
+			slog.Error("Scheduled runs stopped", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	run.Splay = *splay
+	if err := generateOnce(ctx, run); err != nil {
+		slog.Error("Failed to generate feed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// oneShotRun holds the command-line options of a run generating all feeds
+type oneShotRun struct {
+	OutputDir string        // Replaces the directory of the configured output path, empty to keep it
+	Opts      RunOptions    // Options of the homepage feed, shared by the other feeds
+	Backfill  *BackfillSpec // Seeds the homepage feed with historical posts first, nil for none
+	Splay     time.Duration // Maximum random delay before fetching
+	Debug     bool          // Print a success message
+
+	// Move the files older versions left in the working directory once the run is
+	// locked, only for runs using the default config
+	MigratePaths bool
+}
+
+// generateOnce authenticates and generates all configured feeds once. Failures of feeds
+// other than the homepage feed are logged and counted in the metrics, but don't fail
+// the run. Paused, locked and backed off runs are skipped without an error. Canceling
+// ctx stops the run without writing the feeds that aren't complete yet.
+func generateOnce(ctx context.Context, run oneShotRun) error {
 	// Authenticate or refresh token
 	if err := handleAuthentication(); err != nil {
-		slog.Error("Authentication failed", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	if pause := ReadPause(PausePath); pause.Paused {
+		slog.Warn("Skipping feed generation while paused", "file", PausePath, "reason", pause.Reason)
+		return nil
+	}
+
+	// Keep overlapping runs from generating the same feed, and clean up after crashed ones
+	lock, err := AcquireRunLock(RunLockPath)
+	if errors.Is(err, ErrRunLocked) {
+		slog.Warn("Skipping feed generation", "reason", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock run: %w", err)
+	}
+	defer lock.Release()
+	if run.MigratePaths {
+		migrateAppPaths()
+	}
+
+	if delay := jitterDelay(run.Splay); delay > 0 {
+		slog.Debug("Delaying run", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("feed generation interrupted: %w", context.Cause(ctx))
+		}
+	}
+
+	outputDir := run.OutputDir
+	if outputURL(GlobalConfig.OutputPath) == nil {
+		recoverTempFeeds(filepath.Dir(resolveOutputPath(GlobalConfig.OutputPath, outputDir, DefaultTenant, GlobalConfig.FeedType, time.Now())))
 	}
 
 	// Initialize OpenGraph database
 	slog.Debug("Initializing OpenGraph cache database")
-	db, err := InitOpenGraphDB()
+	db, err := OpenCacheDB(&GlobalConfig, CacheDBPath)
 	if err != nil {
-		slog.Error("Failed to initialize OpenGraph database", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize OpenGraph database: %w", err)
 	}
 	defer db.Close()
 
@@ -96,95 +218,121 @@ func main() {
 	}
 
 	// Create authenticated HTTP client
-	ctx := context.Background()
 	client := CreateAuthenticatedClient(ctx, Token)
+	opts := run.Opts
 
-	// Create Reddit API client
-	redditAPI := NewRedditAPI(client)
+	// Report the outcome of the run to the node_exporter textfile collector
+	started := time.Now()
+	writeMetrics := func(m RunMetrics) {
+		if GlobalConfig.MetricsFile == "" {
+			return
+		}
+		m.Started, m.Finished = started, time.Now()
+		if err := WriteTextfileMetrics(GlobalConfig.MetricsFile, m); err != nil {
+			slog.Warn("Failed to write metrics", "error", err)
+		}
+	}
 
-	// Fetch Reddit homepage posts
-	slog.Debug("Fetching Reddit homepage posts")
-	posts, err := redditAPI.FetchRedditHomepage()
+	// Fetch, filter, enrich and render the feed
+	pipeline := NewPipeline(&GlobalConfig, client, db)
+	if run.Backfill != nil {
+		if _, err := pipeline.Backfill(ctx, *run.Backfill); err != nil {
+			return fmt.Errorf("failed to backfill feed: %w", err)
+		}
+	}
+	result, err := pipeline.Generate(ctx, opts)
+	ShutdownTracing()
 	if err != nil {
-		slog.Error("Failed to fetch Reddit homepage", "error", err)
-		os.Exit(1)
+		writeMetrics(RunMetrics{Errors: 1})
 	}
-	slog.Debug("Fetched Reddit posts", "count", len(posts))
-
-	// Filter posts using command-line flags if provided, otherwise use config
-	minScore := GlobalConfig.ScoreFilter
-	if *minPoints != 50 { // 50 is the default, so if it's different, use the flag
-		minScore = *minPoints
+	if NeedsReauth(err) {
+		// Drop the rejected tokens so the next run authenticates from scratch
+		GlobalConfig.AccessToken = ""
+		GlobalConfig.RefreshToken = ""
+		if saveErr := SaveConfig(); saveErr != nil {
+			slog.Warn("Failed to clear rejected tokens", "error", saveErr)
+		}
+		return fmt.Errorf("reddit rejected the saved tokens, run again to re-authenticate: %w", err)
 	}
-
-	filteredPosts := FilterPosts(posts, minScore, GlobalConfig.CommentFilter)
-	slog.Debug("Filtered posts", "count", len(filteredPosts), "minScore", minScore, "minComments", GlobalConfig.CommentFilter)
-
-	// Apply limit if specified
-	if *limit > 0 && len(filteredPosts) > *limit {
-		filteredPosts = filteredPosts[:*limit]
-		slog.Debug("Limited posts", "count", len(filteredPosts), "limit", *limit)
+	if errors.Is(err, ErrBackoff) {
+		// An earlier run hit errors, leave the previous feed in place until the backoff ends
+		slog.Warn("Skipping feed generation", "reason", err)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	// Create OpenGraph fetcher
-	ogFetcher := NewOpenGraphFetcher(db)
-
-	// Create feed generator
-	feedGenerator := NewFeedGenerator(ogFetcher)
-
-	// Generate feed
-	slog.Debug("Generating feed", "type", GlobalConfig.FeedType, "enhanced", GlobalConfig.EnhancedAtom)
+	outputPath := resolveOutputPath(GlobalConfig.OutputPath, outputDir, DefaultTenant, GlobalConfig.FeedType, time.Now())
 
-	// Determine output path
-	outputPath := GlobalConfig.OutputPath
-	if *outDir != "." {
-		// Extract filename from the configured output path and combine with outDir
-		filename := filepath.Base(outputPath)
-		outputPath = filepath.Join(*outDir, filename)
+	metrics := RunMetrics{Items: result.Items, Fetched: result.Fetched, Rejected: result.Rejected}
+	if err := GlobalConfig.saveFeed(outputPath, result.Content, result.ContentType); err != nil {
+		metrics.Errors++
+		writeMetrics(metrics)
+		return fmt.Errorf("failed to save feed to file: %w", err)
 	}
 
-	// Use enhanced Atom feed if enabled and feed type is atom
-	if GlobalConfig.FeedType == "atom" && GlobalConfig.EnhancedAtom {
-		slog.Debug("Using enhanced Atom feed generation")
-		if err := feedGenerator.SaveCustomAtomFeedToFile(filteredPosts, outputPath); err != nil {
-			slog.Error("Failed to save enhanced Atom feed to file", "error", err)
-			os.Exit(1)
+	if ctx.Err() == nil && len(GlobalConfig.Subreddits) > 0 {
+		if err := pipeline.GenerateSubreddits(ctx, opts, outputDir); err != nil {
+			slog.Error("Failed to generate subreddit feeds", "error", err)
+			metrics.Errors++
 		}
-
-		// Display success message
-		slog.Debug("Enhanced Atom feed generation completed successfully",
-			"path", outputPath,
-			"items", len(filteredPosts))
-	} else {
-		// Use standard feed generation
-		feed, err := feedGenerator.GenerateFeed(filteredPosts, GlobalConfig.FeedType)
-		if err != nil {
-			slog.Error("Failed to generate feed", "error", err)
-			os.Exit(1)
+		ShutdownTracing()
+	}
+	if ctx.Err() == nil && GlobalConfig.Subscriptions != "" {
+		if err := pipeline.GenerateSubscriptions(ctx, opts, outputDir); err != nil {
+			slog.Error("Failed to generate subscription feeds", "error", err)
+			metrics.Errors++
 		}
-
-		// Validate feed
-		if err := feedGenerator.ValidateFeed(feed); err != nil {
-			slog.Error("Feed validation failed", "error", err)
-			os.Exit(1)
+		ShutdownTracing()
+	}
+	if ctx.Err() == nil && len(GlobalConfig.Feeds) > 0 {
+		if err := GenerateFeedProfiles(ctx, &GlobalConfig, client, db, opts, outputDir); err != nil {
+			slog.Error("Failed to generate feeds", "error", err)
+			metrics.Errors++
 		}
-
-		if err := feedGenerator.SaveFeedToFile(feed, GlobalConfig.FeedType, outputPath); err != nil {
-			slog.Error("Failed to save feed to file", "error", err)
-			os.Exit(1)
+		ShutdownTracing()
+	}
+	if ctx.Err() == nil && GlobalConfig.FriendsFeed {
+		if err := pipeline.GenerateFriends(ctx, opts, outputDir); err != nil {
+			slog.Error("Failed to generate friends feed", "error", err)
+			metrics.Errors++
 		}
-
-		// Display success message
-		slog.Debug("Feed generation completed successfully",
-			"type", GlobalConfig.FeedType,
-			"path", outputPath,
-			"items", len(feed.Items))
+		ShutdownTracing()
 	}
+	for _, collection := range GlobalConfig.collections() {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := pipeline.GenerateCollection(ctx, opts, outputDir, collection); err != nil {
+			slog.Error("Failed to generate "+collection+" feed", "error", err)
+			metrics.Errors++
+		}
+		ShutdownTracing()
+	}
+	if ctx.Err() == nil && len(GlobalConfig.CompositeFeeds) > 0 {
+		if err := GenerateCompositeFeeds(&GlobalConfig, outputDir, DefaultTenant); err != nil {
+			slog.Error("Failed to generate composite feeds", "error", err)
+			metrics.Errors++
+		}
+	}
+	writeMetrics(metrics)
+	if ctx.Err() != nil {
+		return fmt.Errorf("feed generation interrupted, the remaining feeds were skipped: %w", context.Cause(ctx))
+	}
+
+	// Display success message
+	slog.Debug("Feed generation completed successfully",
+		"type", GlobalConfig.FeedType,
+		"enhanced", GlobalConfig.EnhancedAtom,
+		"path", outputPath,
+		"items", result.Items)
 
 	// Only show success message when debug mode is enabled
-	if *debug {
+	if run.Debug {
 		fmt.Printf("🎉 Successfully generated %s feed and saved to %s\n", GlobalConfig.FeedType, outputPath)
 	}
+	return nil
 }
 
 // setupLogging configures structured logging
@@ -201,6 +349,43 @@ func setupLogging() {
 	slog.SetDefault(slog.New(handler))
 }
 
+// setupConfig creates the config of a first run. When the -set overrides complete the
+// defaults, e.g. -set client_id=..., nothing is prompted for. Otherwise the user is
+// asked, unless the run is non-interactive or stdin isn't a terminal.
+func setupConfig(loadErr error) error {
+	config := DefaultConfig()
+	if err := applyEnvOverrides(&config, os.Environ()); err != nil {
+		return err
+	}
+	if len(ConfigOverrides) > 0 {
+		err := applyConfigOverrides(&config, ConfigOverrides)
+		if err == nil {
+			slog.Info("Using the config given by -set overrides")
+			GlobalConfig = config
+			return SaveConfig()
+		}
+		// Overrides only lacking a client_id are completed by the prompts
+		if !errors.Is(err, ErrClientIDRequired) {
+			return fmt.Errorf("invalid -set override: %w", err)
+		}
+	}
+
+	if NonInteractive || !stdinIsTerminal() {
+		return fmt.Errorf("no usable config and can't prompt for one, set %s or -set client_id=...: %w",
+			configEnvName("client_id"), loadErr)
+	}
+	if err := setupInteractiveConfig(); err != nil {
+		return err
+	}
+	return SaveConfig()
+}
+
+// stdinIsTerminal reports whether prompts on stdin can be answered
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // setupInteractiveConfig prompts the user for configuration values
 func setupInteractiveConfig() error {
 	// Prompt user for client ID
@@ -208,7 +393,20 @@ func setupInteractiveConfig() error {
 		fmt.Print("Enter Reddit Client ID (from your Reddit app settings): ")
 		fmt.Scanln(&GlobalConfig.ClientID)
 	}
-	GlobalConfig.ClientSecret = "" // Ensure it's empty for installed apps
+
+	// Prompt user for app type; only installed apps work without a secret
+	var appTypeInput string
+	fmt.Print("Enter Reddit app type (installed, web, script) [installed]: ")
+	fmt.Scanln(&appTypeInput)
+	switch appTypeInput {
+	case AppTypeWeb, AppTypeScript:
+		GlobalConfig.AppType = appTypeInput
+		fmt.Print("Enter Reddit Client Secret: ")
+		fmt.Scanln(&GlobalConfig.ClientSecret)
+	default:
+		GlobalConfig.AppType = AppTypeInstalled
+		GlobalConfig.ClientSecret = "" // Ensure it's empty for installed apps
+	}
 
 	// Prompt user for score filter
 	var scoreInput string
@@ -234,7 +432,7 @@ func setupInteractiveConfig() error {
 		GlobalConfig.CommentFilter = comments
 	}
 
-	GlobalConfig.RedirectURI = fmt.Sprintf("http://localhost:%s/callback", AuthPort)
+	GlobalConfig.RedirectURI = expectedRedirectURI()
 	GlobalConfig.FeedType = "atom"         // Default feed type
 	GlobalConfig.EnhancedAtom = true       // Enable enhanced Atom features
 	GlobalConfig.OutputPath = "reddit.xml" // Default output path
@@ -244,7 +442,24 @@ func setupInteractiveConfig() error {
 
 // handleAuthentication manages OAuth2 authentication flow
 func handleAuthentication() error {
+	if GlobalConfig.AppType == AppTypeScript {
+		Token = &oauth2.Token{
+			AccessToken: GlobalConfig.AccessToken,
+			Expiry:      GlobalConfig.ExpiresAt,
+		}
+		if Token.Valid() {
+			slog.Debug("Script app access token is still valid")
+			return nil
+		}
+		slog.Debug("Authenticating script app with password grant")
+		return AuthenticateScriptApp()
+	}
+
 	if GlobalConfig.RefreshToken == "" {
+		if NonInteractive {
+			return fmt.Errorf("no refresh token and authorization is disabled in non-interactive mode, set %s or run red-rss auth -headless once",
+				configEnvName("refresh_token"))
+		}
 		slog.Debug("No refresh token found, starting browser authentication")
 		return AuthenticateUser()
 	}
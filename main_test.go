@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +19,9 @@ func TestIsRedditURL(t *testing.T) {
 		{"https://example.com", false},
 		{"https://github.com/golang/go", false},
 		{"https://news.ycombinator.com", false},
+		{"https://REDDIT.COM./r/golang", true},
+		{"https://notreddit.com.evil.tld/r/golang", false},
+		{"https://example.com/?ref=reddit.com", false},
 	}
 
 	for _, test := range tests {
@@ -38,6 +45,10 @@ func TestIsBlockedURL(t *testing.T) {
 		{"https://github.com/golang/go", false},
 		{"https://news.ycombinator.com", false},
 		{"https://reddit.com/r/golang", false},
+		{"https://myx.community/post", false},
+		{"https://mobile.twitter.com/user/status/123", true},
+		{"https://www.reddit.com/gallery/abc123", true},
+		{"https://www.reddit.com/r/gallery", false},
 	}
 
 	for _, test := range tests {
@@ -140,28 +151,10 @@ func TestParseOpenGraphTagsNoTitle(t *testing.T) {
 
 func TestFilterPosts(t *testing.T) {
 	posts := []RedditPost{
-		{Data: struct {
-			Title       string  `json:"title"`
-			URL         string  `json:"url"`
-			Permalink   string  `json:"permalink"`
-			CreatedUTC  float64 `json:"created_utc"`
-			Score       int     `json:"score"`
-			NumComments int     `json:"num_comments"`
-			Author      string  `json:"author"`
-			Subreddit   string  `json:"subreddit"`
-		}{
+		{Data: PostData{
 			Title: "High Score Post", Score: 100, NumComments: 50,
 		}},
-		{Data: struct {
-			Title       string  `json:"title"`
-			URL         string  `json:"url"`
-			Permalink   string  `json:"permalink"`
-			CreatedUTC  float64 `json:"created_utc"`
-			Score       int     `json:"score"`
-			NumComments int     `json:"num_comments"`
-			Author      string  `json:"author"`
-			Subreddit   string  `json:"subreddit"`
-		}{
+		{Data: PostData{
 			Title: "Low Score Post", Score: 5, NumComments: 2,
 		}},
 	}
@@ -175,3 +168,43 @@ func TestFilterPosts(t *testing.T) {
 		t.Errorf("Expected 'High Score Post', got '%s'", filtered[0].Data.Title)
 	}
 }
+
+func TestNonInteractiveSetup(t *testing.T) {
+	useKeyring(t, &memoryKeyring{secrets: make(map[string]string)})
+	savedConfig, savedPath, savedOverrides := GlobalConfig, ConfigPath, ConfigOverrides
+	t.Cleanup(func() {
+		GlobalConfig, ConfigPath, ConfigOverrides, NonInteractive = savedConfig, savedPath, savedOverrides, false
+	})
+	ConfigPath = filepath.Join(t.TempDir(), "config.json")
+	NonInteractive = true
+	missing := errors.New("no config file")
+
+	ConfigOverrides = nil
+	if err := setupConfig(missing); err == nil || !strings.Contains(err.Error(), "RED_RSS_CLIENT_ID") {
+		t.Errorf("expected an error naming the missing variable instead of a prompt, got %v", err)
+	}
+
+	// An invalid override fails rather than being ignored
+	ConfigOverrides = configAssignments{"client_id=abc", "feed_type=json"}
+	if err := setupConfig(missing); err == nil || !strings.Contains(err.Error(), "-set") || !strings.Contains(err.Error(), "feed_type") {
+		t.Errorf("expected the error of the invalid override, got %v", err)
+	}
+
+	// -set overrides completing the defaults need no prompts
+	ConfigOverrides = configAssignments{"client_id=abc", "score_filter=100"}
+	if err := setupConfig(missing); err != nil {
+		t.Fatalf("setupConfig failed: %v", err)
+	}
+	if GlobalConfig.ClientID != "abc" || GlobalConfig.ScoreFilter != 100 || GlobalConfig.FeedType != "atom" {
+		t.Errorf("expected the defaults with the overrides, got %+v", GlobalConfig)
+	}
+	if data, _ := os.ReadFile(ConfigPath); strings.Contains(string(data), "abc") {
+		t.Errorf("expected the overrides to stay out of the config file, got %s", data)
+	}
+
+	// Without a refresh token, authorization fails instead of waiting for a browser
+	GlobalConfig.RefreshToken = ""
+	if err := handleAuthentication(); err == nil || !strings.Contains(err.Error(), "RED_RSS_REFRESH_TOKEN") {
+		t.Errorf("expected an error naming the refresh token variable, got %v", err)
+	}
+}
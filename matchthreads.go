@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Match thread defaults
+const (
+	DefaultMatchThreadInterval = 2 * time.Minute // Refresh interval of feeds with live match threads
+	MatchThreadLiveWindow      = 3 * time.Hour   // How long a match thread without event times counts as live
+)
+
+var (
+	// matchThreadTitle matches the titles and flairs of match and game threads
+	matchThreadTitle = regexp.MustCompile(`(?i)\b(match|game|live)\s+thread\b`)
+	// postMatchTitle matches threads about games that are over
+	postMatchTitle = regexp.MustCompile(`(?i)\bpost[- ]?(match|game)\b`)
+	// matchScoreLine matches score lines such as "Arsenal 2-1 Chelsea". Times such as
+	// 15:00 and dates aren't scores.
+	matchScoreLine = regexp.MustCompile(`^(\p{L}[^\n]{0,39}?)\s+(\d{1,3})\s*[-–]\s*(\d{1,3})\s+(\p{L}[^\n]{0,39}?)$`)
+)
+
+// isMatchThread reports whether a post is a match or game thread, including post-match threads
+func isMatchThread(post RedditPost) bool {
+	for _, text := range []string{post.Data.Title, post.Data.LinkFlairText} {
+		if matchThreadTitle.MatchString(text) || postMatchTitle.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchThreadLive reports whether the game of a match thread is going on at now:
+// during its event times, or within MatchThreadLiveWindow of its posting without them
+func matchThreadLive(post RedditPost, now time.Time) bool {
+	if !isMatchThread(post) || postMatchTitle.MatchString(post.Data.Title) {
+		return false
+	}
+	if post.Data.EventIsLive {
+		return true
+	}
+	if start := post.Data.EventStarts(); !start.IsZero() {
+		end := post.Data.EventEnds()
+		if end.IsZero() {
+			end = start.Add(MatchThreadLiveWindow)
+		}
+		return !now.Before(start) && now.Before(end)
+	}
+	return now.Sub(postTime(post)) < MatchThreadLiveWindow
+}
+
+// matchScore returns the first score line of a match thread body, e.g.
+// "Arsenal 2-1 Chelsea", with markdown emphasis, headings and table pipes removed
+func matchScore(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+			if strings.ContainsRune("*_#|>", r) {
+				return ' '
+			}
+			return r
+		}, line)), " ")
+		if m := matchScoreLine.FindStringSubmatch(line); m != nil {
+			return fmt.Sprintf("%s %s-%s %s", m[1], m[2], m[3], m[4])
+		}
+	}
+	return ""
+}
+
+// matchThreadInterval returns the refresh interval of feeds with live match threads
+func (c *Config) matchThreadInterval() time.Duration {
+	if d, err := time.ParseDuration(c.MatchThreadInterval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMatchThreadInterval
+}
+
+// refreshMatchThreads updates the body and counts of live match threads, which change
+// during the game after the post was fetched, e.g. for pinned or stored posts
+func (p *Pipeline) refreshMatchThreads(ctx context.Context, posts []RedditPost) []RedditPost {
+	if !p.config.MatchThreads {
+		return posts
+	}
+	var live []string
+	for _, post := range posts {
+		if matchThreadLive(post, p.now()) {
+			live = append(live, post.Data.Name)
+		}
+	}
+	if len(live) == 0 {
+		return posts
+	}
+
+	current, err := p.api.FetchInfo(ctx, live)
+	if err != nil {
+		slog.Warn("Failed to refresh match threads", "error", err)
+		return posts
+	}
+
+	threads := make(map[string]PostData, len(current))
+	for _, post := range current {
+		threads[post.Data.Name] = post.Data
+	}
+	for i := range posts {
+		if thread, ok := threads[posts[i].Data.Name]; ok {
+			posts[i].Data.Selftext = thread.Selftext
+			posts[i].Data.SelftextHTML = thread.SelftextHTML
+			posts[i].Data.Score = thread.Score
+			posts[i].Data.NumComments = thread.NumComments
+			posts[i].Data.EventIsLive = thread.EventIsLive
+		}
+	}
+
+	slog.Debug("Refreshed match threads", "threads", len(threads))
+	return posts
+}
+
+// scoreMatchThreads adds the current score to the titles of match threads. Items whose
+// score changed get it as their updated time, so readers show the new score.
+func (p *Pipeline) scoreMatchThreads(posts []RedditPost, opts RunOptions) []RedditPost {
+	if !p.config.MatchThreads {
+		return posts
+	}
+	live := false
+	for i, post := range posts {
+		if !isMatchThread(post) {
+			continue
+		}
+		live = live || matchThreadLive(post, p.now())
+		score := matchScore(post.Data.Selftext)
+		if score == "" {
+			continue
+		}
+		posts[i].Data.Title = post.Data.Title + " (" + score + ")"
+		if p.db == nil || opts.Offline {
+			continue
+		}
+		changed, err := p.db.RecordMatchScore(post.Data.Name, score, p.now())
+		if err != nil {
+			slog.Warn("Failed to record match score", "post", post.Data.Name, "error", err)
+			continue
+		}
+		posts[i].Data.ChangedAt = changed
+	}
+	if !opts.Offline {
+		liveMatches.set(opts, live)
+	}
+	return posts
+}
+
+// liveMatches remembers the feeds whose last run had live match threads, so that
+// scheduled runs refresh them every match_thread_interval
+var liveMatches = &liveMatchFeeds{feeds: make(map[string]bool)}
+
+// liveMatchFeeds is the set of feeds with live match threads by consumer, scheduled
+// feed and feed. Per-subreddit subscription feeds are all scheduled as subscriptions.
+type liveMatchFeeds struct {
+	mu    sync.Mutex
+	feeds map[string]bool
+}
+
+// liveMatchPrefix identifies the feeds of a consumer scheduled as a feed, all of the
+// consumer's feeds when it is empty
+func liveMatchPrefix(consumer, schedule string) string {
+	if consumer == "" {
+		consumer = DefaultTenant
+	}
+	if schedule == "" {
+		return consumer + "/"
+	}
+	return consumer + "/" + schedule + "/"
+}
+
+// set records whether the feed of a run has live match threads
+func (l *liveMatchFeeds) set(opts RunOptions, live bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := liveMatchPrefix(opts.Consumer, opts.scheduledFeed()) + opts.feed()
+	if live {
+		l.feeds[key] = true
+	} else {
+		delete(l.feeds, key)
+	}
+}
+
+// clear forgets the feeds of a consumer scheduled as a feed, e.g. before a run of
+// subscriptions, whose subreddits may have changed
+func (l *liveMatchFeeds) clear(consumer, schedule string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := liveMatchPrefix(consumer, schedule)
+	for key := range l.feeds {
+		if strings.HasPrefix(key, prefix) {
+			delete(l.feeds, key)
+		}
+	}
+}
+
+// live reports whether a feed of the consumer, as scheduled, has live match threads,
+// any feed when feed is empty
+func (l *liveMatchFeeds) live(consumer, feed string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := liveMatchPrefix(consumer, feed)
+	for key := range l.feeds {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordMatchScore stores the score of a match thread and returns when it last changed
+func (ogDB *OpenGraphDB) RecordMatchScore(fullname, score string, at time.Time) (time.Time, error) {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	var previous string
+	var changedAt int64
+	err := ogDB.db.QueryRow(`SELECT score, changed_at FROM match_scores WHERE fullname = ?`, fullname).Scan(&previous, &changedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to load match score: %w", err)
+	}
+	if err == nil && previous == score {
+		return time.Unix(changedAt, 0).UTC(), nil
+	}
+
+	if _, err := ogDB.db.Exec(`INSERT OR REPLACE INTO match_scores (fullname, score, changed_at) VALUES (?, ?, ?)`,
+		fullname, score, at.Unix()); err != nil {
+		return time.Time{}, fmt.Errorf("failed to save match score: %w", err)
+	}
+	return at.UTC().Truncate(time.Second), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchScore(t *testing.T) {
+	tests := map[string]string{
+		"**Arsenal 2-1 Chelsea**\n\nGoals: Saka 12'":         "Arsenal 2-1 Chelsea",
+		"# Kick-off 15:00 GMT\n\n## Real Madrid 0 – 0 Barça": "Real Madrid 0-0 Barça",
+		"| Lakers 101 - 99 Celtics |":                        "Lakers 101-99 Celtics",
+		"Match on 2024-06-01 at 15:00":                       "",
+		"No score yet":                                       "",
+	}
+	for body, want := range tests {
+		if got := matchScore(body); got != want {
+			t.Errorf("matchScore(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestScoreMatchThreads(t *testing.T) {
+	now := time.Date(2024, 6, 1, 15, 30, 0, 0, time.UTC)
+	thread := historyPost("t3_match", 100)
+	thread.Data.Title = "Match Thread: Arsenal vs Chelsea"
+	thread.Data.CreatedUTC = float64(now.Add(-time.Hour).Unix())
+	thread.Data.Selftext = "**Arsenal 1-0 Chelsea**"
+	postMatch := historyPost("t3_post", 100)
+	postMatch.Data.Title = "Post Match Thread: Everton 0-0 Fulham"
+	postMatch.Data.CreatedUTC = thread.Data.CreatedUTC
+
+	if !matchThreadLive(thread, now) || matchThreadLive(postMatch, now) || !isMatchThread(postMatch) {
+		t.Errorf("expected only the match thread to be live")
+	}
+	if matchThreadLive(thread, now.Add(MatchThreadLiveWindow)) {
+		t.Errorf("expected the match thread to be over after the live window")
+	}
+
+	p := &Pipeline{config: &Config{MatchThreads: true}, db: newTestDB(t), clock: func() time.Time { return now }}
+	opts := RunOptions{Subreddit: "soccer"}
+	t.Cleanup(func() { liveMatches.set(opts, false) })
+
+	posts := p.scoreMatchThreads([]RedditPost{thread, postMatch}, opts)
+	if posts[0].Data.Title != "Match Thread: Arsenal vs Chelsea (Arsenal 1-0 Chelsea)" || !posts[0].Data.ChangedAt.Equal(now) {
+		t.Errorf("expected the score in the title and the item changed now, got %q at %v", posts[0].Data.Title, posts[0].Data.ChangedAt)
+	}
+	if !liveMatches.live(DefaultTenant, "soccer") || liveMatches.live(DefaultTenant, HomepageFeed) || !liveMatches.live(DefaultTenant, "") {
+		t.Errorf("expected only the soccer feed to have live match threads")
+	}
+
+	// The updated time stays until the score changes
+	p.clock = func() time.Time { return now.Add(2 * time.Minute) }
+	posts = p.scoreMatchThreads([]RedditPost{thread}, opts)
+	if !posts[0].Data.ChangedAt.Equal(now) {
+		t.Errorf("expected the unchanged score to keep its time, got %v", posts[0].Data.ChangedAt)
+	}
+	thread.Data.Selftext = "**Arsenal 1-1 Chelsea**"
+	posts = p.scoreMatchThreads([]RedditPost{thread}, opts)
+	if !posts[0].Data.ChangedAt.Equal(now.Add(2*time.Minute)) || !itemUpdated(posts[0]).Equal(now.Add(2*time.Minute)) {
+		t.Errorf("expected the new score to update the item, got %v", posts[0].Data.ChangedAt)
+	}
+
+	content, err := NewFeedGenerator(nil).RenderFeed(posts, nil, "atom", true)
+	if err != nil || !strings.Contains(string(content), "<updated>2024-06-01T15:32:00Z</updated>") {
+		t.Errorf("expected the entry updated at the score change, got %v:\n%s", err, content)
+	}
+
+	// Feeds with live match threads are refreshed sooner in serve mode
+	tenant := &Tenant{Name: DefaultTenant, config: Config{MatchThreads: true, Subreddits: []string{"soccer"}}}
+	every := ScheduleOptions{Interval: 30 * time.Minute}
+	if next := tenant.nextRunLocked("soccer", &feedRun{}, now, every); !next.Equal(now.Add(DefaultMatchThreadInterval)) {
+		t.Errorf("expected the soccer feed to run again in %s, got %v", DefaultMatchThreadInterval, next)
+	}
+	if next := tenant.nextRunLocked(HomepageFeed, &feedRun{}, now, every); !next.Equal(now.Add(every.Interval)) {
+		t.Errorf("expected the homepage feed to keep its interval, got %v", next)
+	}
+
+	// Per-subreddit subscription feeds are live as the scheduled subscriptions feed,
+	// while any of them is
+	football := RunOptions{Consumer: "alice", Subreddit: "football", Schedule: SubscriptionsFeed}
+	chess := RunOptions{Consumer: "alice", Subreddit: "chess", Schedule: SubscriptionsFeed}
+	t.Cleanup(func() { liveMatches.clear("alice", "") })
+	p.scoreMatchThreads([]RedditPost{thread}, football)
+	p.scoreMatchThreads([]RedditPost{postMatch}, chess)
+	tenant = &Tenant{Name: "alice", config: Config{MatchThreads: true, Subscriptions: SubscriptionsPerSubreddit}}
+	if next := tenant.nextRunLocked(SubscriptionsFeed, &feedRun{}, now, every); !next.Equal(now.Add(DefaultMatchThreadInterval)) {
+		t.Errorf("expected the subscriptions to run again in %s, got %v", DefaultMatchThreadInterval, next)
+	}
+	if liveMatches.live("alice", "football") {
+		t.Error("expected the subreddit feed to be live only as the subscriptions feed")
+	}
+	liveMatches.clear("alice", SubscriptionsFeed)
+	if liveMatches.live("alice", SubscriptionsFeed) {
+		t.Error("expected clear to forget the subscription feeds")
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+// MergedFeedTitle is the title of merged feeds without -title
+const MergedFeedTitle = "Merged Reddit feeds"
+
+// CompositeFeed is a feed merged from other feeds of the config after they are generated
+type CompositeFeed struct {
+	Name  string   `json:"name"`  // Names the feed in file names
+	Feeds []string `json:"feeds"` // Feeds merged: "homepage", subreddit, profile or collection names
+	Title string   `json:"title"` // Feed title, "Merged Reddit feeds" by default
+	Limit int      `json:"limit"` // Items kept at most, 0 for all
+}
+
+// FeedItem is an item read back from a generated RSS or Atom feed
+type FeedItem struct {
+	ID          string
+	Title       string
+	Link        string
+	Author      string
+	Description string
+	Content     string
+	Published   time.Time
+	Enclosure   *feeds.Enclosure
+
+	Type       string            // Type of the feed the item was read from, "rss" or "atom"
+	Raw        string            // The item's elements as written, kept when merged into a feed of its type
+	Namespaces map[string]string // Namespace prefixes the feed declared, e.g. reddit and media
+}
+
+// rssDocument is the part of an RSS 2.0 feed that is merged
+type rssDocument struct {
+	Items []struct {
+		Raw         string `xml:",innerxml"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		GUID        string `xml:"guid"`
+		Author      string `xml:"author"`
+		Description string `xml:"description"`
+		Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		PubDate     string `xml:"pubDate"`
+		Enclosure   *struct {
+			URL    string `xml:"url,attr"`
+			Length string `xml:"length,attr"`
+			Type   string `xml:"type,attr"`
+		} `xml:"enclosure"`
+	} `xml:"channel>item"`
+}
+
+// atomDocument is the part of an Atom feed that is merged
+type atomDocument struct {
+	Entries []struct {
+		Raw   string `xml:",innerxml"`
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href   string `xml:"href,attr"`
+			Rel    string `xml:"rel,attr"`
+			Type   string `xml:"type,attr"`
+			Length string `xml:"length,attr"`
+		} `xml:"link"`
+		Author    string `xml:"author>name"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// ParseFeed reads the items of an RSS 2.0 or Atom feed and its type, "rss" or "atom"
+func ParseFeed(data []byte) ([]FeedItem, string, error) {
+	var root struct {
+		XMLName xml.Name
+		Attrs   []xml.Attr `xml:",any,attr"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, "", fmt.Errorf("failed to parse feed: %w", err)
+	}
+	namespaces := make(map[string]string)
+	for _, attr := range root.Attrs {
+		if attr.Name.Space == "xmlns" {
+			namespaces[attr.Name.Local] = attr.Value
+		}
+	}
+
+	var items []FeedItem
+	switch root.XMLName.Local {
+	case "rss":
+		var doc rssDocument
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, "", fmt.Errorf("failed to parse RSS feed: %w", err)
+		}
+		for _, i := range doc.Items {
+			item := FeedItem{ID: i.GUID, Title: i.Title, Link: i.Link, Author: i.Author, Description: i.Description, Content: i.Content, Published: parseFeedTime(i.PubDate),
+				Type: "rss", Raw: i.Raw, Namespaces: namespaces}
+			if i.Enclosure != nil {
+				item.Enclosure = &feeds.Enclosure{Url: i.Enclosure.URL, Length: i.Enclosure.Length, Type: i.Enclosure.Type}
+			}
+			items = append(items, item)
+		}
+		return items, "rss", nil
+	case "feed":
+		var doc atomDocument
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, "", fmt.Errorf("failed to parse Atom feed: %w", err)
+		}
+		for _, e := range doc.Entries {
+			item := FeedItem{ID: e.ID, Title: e.Title, Author: e.Author, Description: e.Summary, Content: e.Content, Published: parseFeedTime(cmp.Or(e.Published, e.Updated)),
+				Type: "atom", Raw: e.Raw, Namespaces: namespaces}
+			for _, link := range e.Links {
+				switch link.Rel {
+				case "", "alternate":
+					item.Link = cmp.Or(item.Link, link.Href)
+				case "enclosure":
+					item.Enclosure = &feeds.Enclosure{Url: link.Href, Length: link.Length, Type: link.Type}
+				}
+			}
+			items = append(items, item)
+		}
+		return items, "atom", nil
+	}
+	return nil, "", fmt.Errorf("not an RSS or Atom feed: <%s>", root.XMLName.Local)
+}
+
+// parseFeedTime parses the date of a feed item, the zero time when it has none
+func parseFeedTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// MergeFeedItems merges the items of several feeds newest first, ties broken by ID.
+// An item whose ID or link was already seen in an earlier feed is left out, so posts
+// in several feeds are only included once. A positive limit keeps that many items.
+func MergeFeedItems(lists [][]FeedItem, limit int) []FeedItem {
+	var merged []FeedItem
+	ids, links := make(map[string]bool), make(map[string]bool)
+	for _, items := range lists {
+		for _, item := range items {
+			if (item.ID != "" && ids[item.ID]) || (item.Link != "" && links[item.Link]) {
+				continue
+			}
+			ids[item.ID], links[item.Link] = true, true
+			merged = append(merged, item)
+		}
+	}
+
+	slices.SortStableFunc(merged, func(a, b FeedItem) int {
+		return cmp.Or(b.Published.Compare(a.Published), cmp.Compare(a.ID, b.ID))
+	})
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// RenderMergedFeed renders merged items as an RSS or Atom feed. Items read from a feed
+// of the same type are written as they were, keeping categories and the reddit and
+// media elements; the others are converted. Its updated time is the time of the newest
+// item, so merging the same feeds always renders the same bytes.
+func RenderMergedFeed(items []FeedItem, title, feedType string) ([]byte, error) {
+	feed := &feeds.Feed{
+		Title:       title,
+		Link:        &feeds.Link{Href: "https://www.reddit.com/"},
+		Description: title + " generated by GoRedditFeedGenerator",
+		Author:      &feeds.Author{Name: "GoRedditFeedGenerator"},
+	}
+	for _, item := range items {
+		if item.Published.After(feed.Updated) {
+			feed.Updated = item.Published
+		}
+		entry := &feeds.Item{
+			Id:          item.ID,
+			Title:       item.Title,
+			Link:        &feeds.Link{Href: item.Link},
+			Description: item.Description,
+			Content:     item.Content,
+			Created:     item.Published,
+			Enclosure:   item.Enclosure,
+		}
+		if item.Author != "" {
+			entry.Author = &feeds.Author{Name: item.Author}
+		}
+		feed.Items = append(feed.Items, entry)
+	}
+	feed.Created = feed.Updated
+
+	// Items of the other type are written as gorilla/feeds converts them
+	var converted []any
+	namespaces := make(map[string]string)
+	var element string
+	switch feedType {
+	case "rss":
+		for _, item := range (&feeds.Rss{Feed: feed}).RssFeed().Items {
+			converted = append(converted, item)
+		}
+		namespaces["content"] = "http://purl.org/rss/1.0/modules/content/"
+		element = "item"
+	case "atom":
+		for _, entry := range (&feeds.Atom{Feed: feed}).AtomFeed().Entries {
+			converted = append(converted, entry)
+		}
+		element = "entry"
+	default:
+		return nil, fmt.Errorf("unsupported feed type: %s", feedType)
+	}
+
+	var body strings.Builder
+	for i, item := range items {
+		if item.Type == feedType && item.Raw != "" && addNamespaces(namespaces, item.Namespaces) {
+			body.WriteString("<" + element + ">" + item.Raw + "</" + element + ">")
+			continue
+		}
+		data, err := xml.Marshal(converted[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s feed: %w", feedType, err)
+		}
+		body.Write(data)
+	}
+
+	var declarations strings.Builder
+	for _, prefix := range slices.Sorted(maps.Keys(namespaces)) {
+		fmt.Fprintf(&declarations, ` xmlns:%s="%s"`, prefix, escapeXML(namespaces[prefix]))
+	}
+
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	if feedType == "rss" {
+		fmt.Fprintf(&out, `<rss version="2.0"%s><channel>`, declarations.String())
+		fmt.Fprintf(&out, `<title>%s</title><link>%s</link><description>%s</description>`,
+			escapeXML(feed.Title), feed.Link.Href, escapeXML(feed.Description))
+		if !feed.Updated.IsZero() {
+			fmt.Fprintf(&out, `<lastBuildDate>%s</lastBuildDate>`, feed.Updated.Format(time.RFC1123Z))
+		}
+		out.WriteString(body.String() + `</channel></rss>`)
+	} else {
+		fmt.Fprintf(&out, `<feed xmlns="http://www.w3.org/2005/Atom"%s>`, declarations.String())
+		fmt.Fprintf(&out, `<title>%s</title><id>%s</id><updated>%s</updated><link href="%s"/>`,
+			escapeXML(feed.Title), feed.Link.Href, feed.Updated.Format(time.RFC3339), feed.Link.Href)
+		fmt.Fprintf(&out, `<author><name>%s</name></author><subtitle>%s</subtitle>`,
+			escapeXML(feed.Author.Name), escapeXML(feed.Description))
+		out.WriteString(body.String() + `</feed>`)
+	}
+	return []byte(withGeneratorComment(out.String())), nil
+}
+
+// addNamespaces adds the namespace prefixes of an item to those of the merged feed. It
+// reports false, adding none, when a prefix is already bound to another namespace.
+func addNamespaces(namespaces, add map[string]string) bool {
+	for prefix, uri := range add {
+		if bound, ok := namespaces[prefix]; ok && bound != uri {
+			return false
+		}
+	}
+	maps.Copy(namespaces, add)
+	return true
+}
+
+// mergeFeedFiles reads and merges feed files, returning the type of the first one
+func mergeFeedFiles(paths []string, limit int) ([]FeedItem, string, error) {
+	lists := make([][]FeedItem, 0, len(paths))
+	var firstType string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read feed: %w", err)
+		}
+		items, feedType, err := ParseFeed(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", path, err)
+		}
+		firstType = cmp.Or(firstType, feedType)
+		lists = append(lists, items)
+	}
+	return MergeFeedItems(lists, limit), firstType, nil
+}
+
+// runMerge implements the merge subcommand
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("o", "", "file the merged feed is written to, standard output if empty")
+	feedType := fs.String("type", "", `"rss" or "atom", by default the type of the first feed`)
+	title := fs.String("title", MergedFeedTitle, "title of the merged feed")
+	limit := fs.Int("limit", 0, "items kept at most, 0 for all")
+	fs.Parse(args)
+
+	// Flags may follow the feeds, e.g. merge a.xml b.xml -o all.xml
+	var paths []string
+	for fs.NArg() > 0 {
+		paths = append(paths, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	if len(paths) < 2 {
+		return fmt.Errorf("usage: red-rss merge feedA.xml feedB.xml [...] [-o all.xml] [-type rss|atom] [-title title] [-limit n]")
+	}
+
+	items, firstType, err := mergeFeedFiles(paths, *limit)
+	if err != nil {
+		return err
+	}
+	*feedType = cmp.Or(*feedType, firstType)
+	content, err := RenderMergedFeed(items, *title, *feedType)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	if err := writeOutput(*output, content, FeedContentType(*feedType)); err != nil {
+		return err
+	}
+	slog.Info("Merged feeds", "feeds", len(paths), "items", len(items), "path", *output)
+	return nil
+}
+
+// validCompositeFeed matches composite feed names and the names of the feeds they merge
+var validCompositeFeed = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validateCompositeFeeds checks the names of the composite feeds and that the feeds
+// they merge are generated
+func validateCompositeFeeds(config *Config) error {
+	names := make(map[string]bool)
+	for _, profile := range config.Feeds {
+		names[profile.Name] = true
+	}
+	for _, composite := range config.CompositeFeeds {
+		if !validCompositeFeed.MatchString(composite.Name) || composite.Name == HomepageFeed || names[composite.Name] {
+			return fmt.Errorf("composite_feeds has an invalid, reserved or repeated name %q", composite.Name)
+		}
+		names[composite.Name] = true
+		if len(composite.Feeds) < 2 {
+			return fmt.Errorf("composite feed %q needs at least two feeds", composite.Name)
+		}
+		for _, feed := range composite.Feeds {
+			if !validCompositeFeed.MatchString(feed) || feed == composite.Name {
+				return fmt.Errorf("composite feed %q has an invalid feed %q", composite.Name, feed)
+			}
+			// Composite feeds are generated in order, so only earlier ones can be merged
+			if !config.hasFeed(feed) && !names[feed] {
+				return fmt.Errorf("composite feed %q merges unknown feed %q, expected a feed of the config or an earlier composite feed", composite.Name, feed)
+			}
+		}
+		if composite.Limit < 0 {
+			return fmt.Errorf("composite feed %q has a negative limit", composite.Name)
+		}
+	}
+	return nil
+}
+
+// feedPath returns where a feed of the config, by name, is written for a tenant
+func (c *Config) feedPath(dir, tenant, name string, t time.Time) (string, error) {
+	if name == HomepageFeed {
+		return resolveOutputPath(c.OutputPath, dir, tenant, c.FeedType, t), nil
+	}
+	if profile, ok := c.feedProfile(name); ok {
+		config, err := profileConfig(c, profile)
+		if err != nil {
+			return "", err
+		}
+		if config.OutputPath != c.OutputPath {
+			return resolveOutputPath(config.OutputPath, dir, name, config.FeedType, t), nil
+		}
+		return subredditOutputPath(c.OutputPath, dir, name, config.FeedType, t), nil
+	}
+	return subredditOutputPath(c.OutputPath, dir, name, c.FeedType, t), nil
+}
+
+// GenerateCompositeFeeds merges the feeds of each composite feed from the files the
+// run wrote, and writes it next to them. A failing composite doesn't stop the others.
+func GenerateCompositeFeeds(config *Config, dir, tenant string) error {
+	failed := 0
+	now := time.Now()
+	for _, composite := range config.CompositeFeeds {
+		if err := config.generateCompositeFeed(composite, dir, tenant, now); err != nil {
+			slog.Error("Failed to generate composite feed", "feed", composite.Name, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d composite feeds failed", failed, len(config.CompositeFeeds))
+	}
+	return nil
+}
+
+// generateCompositeFeed merges and writes one composite feed
+func (c *Config) generateCompositeFeed(composite CompositeFeed, dir, tenant string, now time.Time) error {
+	paths := make([]string, 0, len(composite.Feeds))
+	for _, name := range composite.Feeds {
+		path, err := c.feedPath(dir, tenant, name, now)
+		if err != nil {
+			return err
+		}
+		if outputURL(path) != nil {
+			return fmt.Errorf("feed %q is written to %s, composite feeds can only merge local files", name, path)
+		}
+		paths = append(paths, path)
+	}
+
+	items, _, err := mergeFeedFiles(paths, composite.Limit)
+	if err != nil {
+		return err
+	}
+	content, err := RenderMergedFeed(items, cmp.Or(composite.Title, MergedFeedTitle), c.FeedType)
+	if err != nil {
+		return err
+	}
+
+	path := subredditOutputPath(c.OutputPath, dir, composite.Name, c.FeedType, now)
+	slog.Debug("Generated composite feed", "feed", composite.Name, "path", path, "items", len(items))
+	return c.saveFeed(path, content, FeedContentType(c.FeedType))
+}
+
+// compositesMerging returns the composite feeds merging a feed, directly or through an
+// earlier composite feed, in the order they are generated
+func (c *Config) compositesMerging(feed string) []CompositeFeed {
+	merging := map[string]bool{feed: true}
+	var composites []CompositeFeed
+	for _, composite := range c.CompositeFeeds {
+		if slices.ContainsFunc(composite.Feeds, func(name string) bool { return merging[name] }) {
+			merging[composite.Name] = true
+			composites = append(composites, composite)
+		}
+	}
+	return composites
+}
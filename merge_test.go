@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renderTestFeed renders posts named after their creation time as a feed
+func renderTestFeed(t *testing.T, feedType string, created ...int) []byte {
+	t.Helper()
+	var posts []RedditPost
+	for _, c := range created {
+		post := seenPost("t3_"+string(rune('a'+c)), 10)
+		post.Data.CreatedUTC = float64(1714557600 + c*60)
+		post.Data.Permalink = "/r/test/comments/" + string(rune('a'+c)) + "/"
+		post.Data.URL = "https://example.com/" + string(rune('a'+c))
+		posts = append(posts, post)
+	}
+	content, err := NewFeedGenerator(nil).RenderFeed(posts, nil, feedType, false)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	return content
+}
+
+func TestMergeFeeds(t *testing.T) {
+	rss, rssType, err := ParseFeed(renderTestFeed(t, "rss", 1, 3))
+	if err != nil || rssType != "rss" {
+		t.Fatalf("failed to parse RSS feed: %v, %s", err, rssType)
+	}
+	atom, atomType, err := ParseFeed(renderTestFeed(t, "atom", 2, 3, 0))
+	if err != nil || atomType != "atom" {
+		t.Fatalf("failed to parse Atom feed: %v, %s", err, atomType)
+	}
+	if len(rss) != 2 || rss[0].Link != "https://example.com/b" || rss[0].Published.Unix() != 1714557660 {
+		t.Fatalf("unexpected RSS items: %+v", rss)
+	}
+
+	merged := MergeFeedItems([][]FeedItem{rss, atom}, 0)
+	var links []string
+	for _, item := range merged {
+		links = append(links, strings.TrimPrefix(item.Link, "https://example.com/"))
+	}
+	if got := strings.Join(links, ","); got != "d,c,b,a" {
+		t.Errorf("expected the posts newest first and once each, got %s", got)
+	}
+	if limited := MergeFeedItems([][]FeedItem{rss, atom}, 2); len(limited) != 2 || limited[1].Link != "https://example.com/c" {
+		t.Errorf("expected the two newest posts, got %+v", limited)
+	}
+
+	for _, feedType := range []string{"rss", "atom"} {
+		content, err := RenderMergedFeed(merged, MergedFeedTitle, feedType)
+		if err != nil {
+			t.Fatalf("RenderMergedFeed(%s) failed: %v", feedType, err)
+		}
+		again, _ := RenderMergedFeed(merged, MergedFeedTitle, feedType)
+		if string(content) != string(again) {
+			t.Errorf("expected %s rendering to be deterministic", feedType)
+		}
+		if items, _, err := ParseFeed(content); err != nil || len(items) != 4 || items[0].Link != "https://example.com/d" {
+			t.Errorf("expected the merged %s feed to read back, got %+v, %v", feedType, items, err)
+		}
+	}
+
+	post := seenPost("t3_e", 10)
+	post.Data.Tags = []string{"release"}
+	enhanced, err := NewFeedGenerator(nil).RenderFeed([]RedditPost{post}, nil, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	entries, _, err := ParseFeed(enhanced)
+	if err != nil {
+		t.Fatalf("failed to parse enhanced Atom feed: %v", err)
+	}
+	content, err := RenderMergedFeed(MergeFeedItems([][]FeedItem{entries, rss}, 0), MergedFeedTitle, "atom")
+	if err != nil {
+		t.Fatalf("RenderMergedFeed failed: %v", err)
+	}
+	for _, want := range []string{`xmlns:reddit="http://reddit.com/atom/ns"`, `<category term="release"`, `<reddit:score>10</reddit:score>`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q kept in the merged feed:\n%s", want, content)
+		}
+	}
+	if items, _, err := ParseFeed(content); err != nil || len(items) != 3 {
+		t.Errorf("expected the merged feed to read back, got %+v, %v", items, err)
+	}
+
+	if _, _, err := ParseFeed([]byte("<html></html>")); err == nil {
+		t.Error("expected an error for a document that isn't a feed")
+	}
+}
+
+func TestGenerateCompositeFeeds(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		OutputPath: "reddit.xml",
+		FeedType:   "atom",
+		Feeds:      []FeedProfile{{Name: "golang", Source: "r/golang"}},
+		CompositeFeeds: []CompositeFeed{
+			{Name: "all", Feeds: []string{HomepageFeed, "golang"}, Title: "Everything"},
+		},
+	}
+	if err := validateCompositeFeeds(config); err != nil {
+		t.Fatalf("validateCompositeFeeds failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "reddit.xml"), renderTestFeed(t, "atom", 0, 1), 0644)
+	os.WriteFile(filepath.Join(dir, "reddit-golang.xml"), renderTestFeed(t, "rss", 1, 2), 0644)
+
+	if err := GenerateCompositeFeeds(config, dir, DefaultTenant); err != nil {
+		t.Fatalf("GenerateCompositeFeeds failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "reddit-all.xml"))
+	if err != nil {
+		t.Fatalf("expected the composite feed next to the others: %v", err)
+	}
+	items, feedType, err := ParseFeed(content)
+	if err != nil || feedType != "atom" || len(items) != 3 || !strings.Contains(string(content), "Everything") {
+		t.Errorf("unexpected composite feed (%s, %v):\n%s", feedType, err, content)
+	}
+
+	config.CompositeFeeds[0].Feeds = []string{"golang", "missing"}
+	if err := GenerateCompositeFeeds(config, dir, DefaultTenant); err == nil {
+		t.Error("expected an error when a merged feed wasn't written")
+	}
+
+	for _, composite := range []CompositeFeed{
+		{Name: "golang", Feeds: []string{HomepageFeed, "rust"}},
+		{Name: "one", Feeds: []string{HomepageFeed}},
+		{Name: "self", Feeds: []string{HomepageFeed, "self"}},
+		{Name: "langs", Feeds: []string{HomepageFeed, "rust"}},
+	} {
+		config.CompositeFeeds = []CompositeFeed{composite}
+		if err := validateCompositeFeeds(config); err == nil {
+			t.Errorf("expected %+v to be invalid", composite)
+		}
+	}
+
+	config.Subreddits = []string{"rust"}
+	config.CompositeFeeds = []CompositeFeed{
+		{Name: "langs", Feeds: []string{"golang", "rust"}},
+		{Name: "all", Feeds: []string{HomepageFeed, "langs"}},
+		{Name: "news", Feeds: []string{HomepageFeed, "golang"}},
+	}
+	if err := validateCompositeFeeds(config); err != nil {
+		t.Errorf("expected subreddits and earlier composite feeds to be merged, got %v", err)
+	}
+	var merging []string
+	for _, composite := range config.compositesMerging("rust") {
+		merging = append(merging, composite.Name)
+	}
+	if got := strings.Join(merging, ","); got != "langs,all" {
+		t.Errorf("expected the composite feeds merging rust directly or not, got %s", got)
+	}
+}
+
+func TestTenantCompositeFeeds(t *testing.T) {
+	dir := t.TempDir()
+	tenant := &Tenant{Name: "alice", outputDir: dir, config: Config{
+		OutputPath:     "{feed}.xml",
+		FeedType:       "rss",
+		Subreddits:     []string{"golang"},
+		CompositeFeeds: []CompositeFeed{{Name: "all", Feeds: []string{HomepageFeed, "golang"}}},
+	}}
+
+	os.WriteFile(filepath.Join(dir, "alice.xml"), renderTestFeed(t, "rss", 0), 0644)
+	tenant.generateCompositeFeeds(&tenant.config, HomepageFeed)
+	if _, err := os.Stat(filepath.Join(dir, "all.xml")); err == nil {
+		t.Fatal("expected the composite feed to wait for all of its feeds")
+	}
+
+	os.WriteFile(filepath.Join(dir, "golang.xml"), renderTestFeed(t, "rss", 1), 0644)
+	tenant.generateCompositeFeeds(&tenant.config, "golang")
+	content, err := os.ReadFile(filepath.Join(dir, "all.xml"))
+	if err != nil {
+		t.Fatalf("expected the composite feed after its last feed was generated: %v", err)
+	}
+	if items, _, err := ParseFeed(content); err != nil || len(items) != 2 {
+		t.Errorf("expected the tenant's homepage and subreddit feeds merged, got %+v, %v", items, err)
+	}
+}
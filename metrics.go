@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricLastSuccess is carried over from the previous metrics file by failed runs
+const metricLastSuccess = "red_rss_last_success_timestamp_seconds"
+
+// RunMetrics summarizes a one-shot run for the node_exporter textfile collector
+type RunMetrics struct {
+	Started  time.Time
+	Finished time.Time
+	Items    int         // Items in the written feed
+	Fetched  int         // Posts fetched from Reddit
+	Errors   int         // Failed feeds, 0 for a successful run
+	Rejected FilterStats // Posts of the feed rejected by each filter rule
+}
+
+// textfileMetrics renders run metrics in the Prometheus text format
+func textfileMetrics(m RunMetrics, lastSuccess time.Time) []byte {
+	var b bytes.Buffer
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	gauge("red_rss_last_run_timestamp_seconds", "When the last run finished.", float64(m.Finished.Unix()))
+	gauge("red_rss_last_run_duration_seconds", "How long the last run took.", m.Finished.Sub(m.Started).Seconds())
+	if !lastSuccess.IsZero() {
+		gauge(metricLastSuccess, "When the last run without errors finished.", float64(lastSuccess.Unix()))
+	}
+	gauge("red_rss_last_run_errors", "Feeds that failed in the last run.", float64(m.Errors))
+	gauge("red_rss_last_run_items", "Items emitted to the feed by the last run.", float64(m.Items))
+	gauge("red_rss_last_run_fetched_posts", "Posts fetched from Reddit by the last run.", float64(m.Fetched))
+	if len(m.Rejected) > 0 {
+		const name = "red_rss_last_run_rejected_posts"
+		fmt.Fprintf(&b, "# HELP %s Posts rejected by each filter rule in the last run.\n# TYPE %s gauge\n", name, name)
+		for _, rule := range slices.Sorted(maps.Keys(m.Rejected)) {
+			fmt.Fprintf(&b, "%s{rule=%q} %d\n", name, rule, m.Rejected[rule])
+		}
+	}
+	return b.Bytes()
+}
+
+// readLastSuccess returns the last success time recorded in a metrics file, or zero
+func readLastSuccess(path string) time.Time {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), metricLastSuccess+" ")
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return time.Unix(int64(seconds), 0)
+		}
+	}
+	return time.Time{}
+}
+
+// WriteTextfileMetrics replaces the metrics file at path, which the textfile collector
+// reads when it ends in .prom. The file is renamed into place so the collector never
+// sees a partial file.
+func WriteTextfileMetrics(path string, m RunMetrics) error {
+	lastSuccess := m.Finished
+	if m.Errors > 0 {
+		lastSuccess = readLastSuccess(path)
+	}
+	if err := writeFeedFile(path, textfileMetrics(m, lastSuccess)); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTextfileMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "red_rss.prom")
+	started := time.Unix(1700000000, 0)
+
+	ok := RunMetrics{Started: started, Finished: started.Add(1500 * time.Millisecond), Items: 25, Fetched: 100}
+	if err := WriteTextfileMetrics(path, ok); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"# TYPE red_rss_last_run_timestamp_seconds gauge\nred_rss_last_run_timestamp_seconds 1700000001\n",
+		"red_rss_last_run_duration_seconds 1.5\n",
+		"red_rss_last_success_timestamp_seconds 1700000001\n",
+		"red_rss_last_run_errors 0\n",
+		"red_rss_last_run_items 25\n",
+		"red_rss_last_run_fetched_posts 100\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in metrics:\n%s", want, content)
+		}
+	}
+
+	// A failed run keeps the time of the last successful one
+	failed := RunMetrics{Started: started.Add(time.Hour), Finished: started.Add(time.Hour + time.Second), Errors: 1}
+	if err := WriteTextfileMetrics(path, failed); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	content, _ = os.ReadFile(path)
+	for _, want := range []string{
+		"red_rss_last_run_timestamp_seconds 1700003601\n",
+		"red_rss_last_success_timestamp_seconds 1700000001\n",
+		"red_rss_last_run_errors 1\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in metrics:\n%s", want, content)
+		}
+	}
+
+	// Without an earlier success there is no success time at all
+	other := filepath.Join(t.TempDir(), "red_rss.prom")
+	if err := WriteTextfileMetrics(other, failed); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	if content, _ := os.ReadFile(other); strings.Contains(string(content), metricLastSuccess) {
+		t.Errorf("expected no success time after only failures:\n%s", content)
+	}
+}
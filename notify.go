@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NotifyTopTitles is the number of item titles in a notification
+const NotifyTopTitles = 3
+
+// notifyClient sends notifications, it never carries Reddit credentials
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// Notification summarizes the items a run added to a feed
+type Notification struct {
+	Feed   string   `json:"feed"`
+	Count  int      `json:"count"`  // Number of new items
+	Titles []string `json:"titles"` // Titles of the highest scoring new items
+	Text   string   `json:"text"`   // Summary for chat webhooks that only show text
+}
+
+// Notifier delivers notifications about new feed items
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewItem is a new feed item delivered on its own by an ItemNotifier
+type NewItem struct {
+	Feed  string
+	Post  RedditPost
+	Image string // og:image of the linked page or Reddit's preview image, empty for none
+}
+
+// ItemNotifier delivers each new feed item as a message of its own, e.g. to a chat
+type ItemNotifier interface {
+	Name() string // Identifies the notifier in the record of delivered items
+	NotifyItem(ctx context.Context, item NewItem) error
+}
+
+// WebhookNotifier posts notifications as JSON to a URL
+type WebhookNotifier struct {
+	URL string
+}
+
+// Notify posts the notification to the webhook
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "red-rss/"+Version)
+
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// validateNotifyWebhook checks that notify_webhook is an HTTP URL
+func validateNotifyWebhook(webhook string) error {
+	if webhook == "" {
+		return nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("notify_webhook must be an http or https URL")
+	}
+	return nil
+}
+
+// newNotification summarizes the new items of a feed, highest scoring first
+func newNotification(feed string, posts []RedditPost) Notification {
+	top := slices.Clone(posts)
+	slices.SortFunc(top, highestScoreFirst)
+
+	n := Notification{Feed: feed, Count: len(posts)}
+	for _, post := range top[:min(NotifyTopTitles, len(top))] {
+		n.Titles = append(n.Titles, post.Data.Title)
+	}
+
+	noun := "items"
+	if n.Count == 1 {
+		noun = "item"
+	}
+	n.Text = fmt.Sprintf("%d new %s in %s: %s", n.Count, noun, feed, strings.Join(n.Titles, "; "))
+	if n.Count > len(n.Titles) {
+		n.Text += fmt.Sprintf(" and %d more", n.Count-len(n.Titles))
+	}
+	return n
+}
+
+// newItems returns the new items of a feed oldest first, the order chats show them in
+func newItems(feed string, posts []RedditPost, ogData map[string]*OpenGraphData) []NewItem {
+	sorted := slices.Clone(posts)
+	slices.SortFunc(sorted, func(a, b RedditPost) int { return newestFirst(b, a) })
+
+	items := make([]NewItem, 0, len(sorted))
+	for _, post := range sorted {
+		item := NewItem{Feed: feed, Post: post}
+		if og := ogData[previewLink(post)]; og != nil && og.Image != "" {
+			item.Image = og.Image
+		} else if image := previewImage(post); image != nil {
+			item.Image = image.URL
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// notifier returns the configured notifier, or nil when notifications are off
+func (p *Pipeline) notifier() Notifier {
+	if p.config.NotifyWebhook == "" {
+		return nil
+	}
+	return &WebhookNotifier{URL: p.config.NotifyWebhook}
+}
+
+// itemNotifiers returns the configured notifiers of single items
+func (p *Pipeline) itemNotifiers() []ItemNotifier {
+	var notifiers []ItemNotifier
+	if p.config.TelegramBotToken != "" {
+		notifiers = append(notifiers, &TelegramNotifier{Token: p.config.TelegramBotToken, ChatID: p.config.TelegramChatID})
+	}
+	if p.config.DiscordWebhook != "" {
+		notifiers = append(notifiers, discordNotifier(p.config.DiscordWebhook))
+	}
+	return notifiers
+}
+
+// notifying reports whether any notifications are configured
+func (p *Pipeline) notifying() bool {
+	return p.notifier() != nil || len(p.itemNotifiers()) > 0
+}
+
+// notifyNewPosts sends a notification about the posts that weren't in an earlier
+// generation of the feed, and each post not yet delivered to the item notifiers. It
+// must run before the posts are recorded as emitted, which keeps posts from being
+// notified about again. The first generation of a feed sends nothing, as every item
+// would be new.
+func (p *Pipeline) notifyNewPosts(ctx context.Context, posts []RedditPost, ogData map[string]*OpenGraphData, opts RunOptions) {
+	if !p.notifying() || p.db == nil || opts.Offline || len(posts) == 0 {
+		return
+	}
+
+	for _, notifier := range p.itemNotifiers() {
+		p.deliverItems(ctx, notifier, newItems(opts.feed(), posts, ogData))
+	}
+
+	notifier := p.notifier()
+	if notifier == nil {
+		return
+	}
+	key := runKey(opts)
+	count, err := p.db.CountEmittedPosts(key)
+	if err != nil {
+		slog.Warn("Failed to load emitted posts", "error", err)
+		return
+	}
+	if count == 0 {
+		slog.Debug("Not notifying about the first generation of a feed", "feed", opts.feed())
+		return
+	}
+
+	permalinks := make([]string, 0, len(posts))
+	for _, post := range posts {
+		permalinks = append(permalinks, post.Data.Permalink)
+	}
+	emitted, err := p.db.GetEmittedPosts(key, permalinks)
+	if err != nil {
+		slog.Warn("Failed to load emitted posts", "error", err)
+		return
+	}
+	var fresh []RedditPost
+	for _, post := range posts {
+		if !emitted[post.Data.Permalink] {
+			fresh = append(fresh, post)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	if err := notifier.Notify(ctx, newNotification(opts.feed(), fresh)); err != nil {
+		slog.Warn("Failed to notify about new items", "feed", opts.feed(), "error", err)
+	} else {
+		slog.Debug("Notified about new items", "feed", opts.feed(), "count", len(fresh))
+	}
+}
+
+// deliverItems sends the items of a feed that weren't delivered to a notifier yet and
+// records them, so a post is never sent twice even when the feed is reset, and one
+// that failed is sent by the next run. The first delivery of a feed to a notifier only
+// records the items, as every item would be new.
+func (p *Pipeline) deliverItems(ctx context.Context, notifier ItemNotifier, items []NewItem) {
+	if len(items) == 0 {
+		return
+	}
+	feed := items[0].Feed
+	count, err := p.db.CountDelivered(notifier.Name(), feed)
+	if err != nil {
+		slog.Warn("Failed to load delivered items", "error", err)
+		return
+	}
+	seeding := count == 0
+	if seeding {
+		slog.Debug("Not sending the first generation of a feed", "notifier", notifier.Name(), "feed", feed)
+	}
+
+	for _, item := range items {
+		delivered, err := p.db.IsDelivered(notifier.Name(), item.Feed, item.Post.Data.Permalink)
+		if err != nil {
+			slog.Warn("Failed to load delivered items", "error", err)
+			return
+		}
+		if delivered {
+			continue
+		}
+		if !seeding {
+			if err := notifier.NotifyItem(ctx, item); err != nil {
+				slog.Warn("Failed to send new item", "notifier", notifier.Name(), "feed", item.Feed, "post", item.Post.Data.Name, "error", err)
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+		}
+		if err := p.db.MarkDelivered(notifier.Name(), item.Feed, item.Post.Data.Permalink, time.Now()); err != nil {
+			slog.Warn("Failed to record delivered item", "error", err)
+		}
+	}
+}
+
+// CountDelivered returns the number of posts of a feed recorded as delivered to a notifier
+func (ogDB *OpenGraphDB) CountDelivered(sink, feed string) (int, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var count int
+	err := ogDB.db.QueryRow(`SELECT COUNT(*) FROM item_deliveries WHERE sink = ? AND feed = ?`, sink, feed).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load delivered items: %w", err)
+	}
+	return count, nil
+}
+
+// IsDelivered reports whether a post of a feed was delivered to a notifier
+func (ogDB *OpenGraphDB) IsDelivered(sink, feed, permalink string) (bool, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var count int
+	err := ogDB.db.QueryRow(`SELECT COUNT(*) FROM item_deliveries WHERE sink = ? AND feed = ? AND permalink = ?`,
+		sink, feed, permalink).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to load delivered items: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkDelivered records that a post of a feed was delivered to a notifier
+func (ogDB *OpenGraphDB) MarkDelivered(sink, feed, permalink string, at time.Time) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR IGNORE INTO item_deliveries (sink, feed, permalink, delivered_at) VALUES (?, ?, ?, ?)`,
+		sink, feed, permalink, at.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save delivered item: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewNotification(t *testing.T) {
+	posts := []RedditPost{seenPost("t3_a", 5), seenPost("t3_b", 50), seenPost("t3_c", 1), seenPost("t3_d", 20)}
+	n := newNotification("golang", posts)
+	if n.Count != 4 || len(n.Titles) != NotifyTopTitles {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+	if n.Titles[0] != "Post t3_b" || n.Titles[1] != "Post t3_d" || n.Titles[2] != "Post t3_a" {
+		t.Errorf("expected titles by score, got %v", n.Titles)
+	}
+	if want := "4 new items in golang: Post t3_b; Post t3_d; Post t3_a and 1 more"; n.Text != want {
+		t.Errorf("expected text %q, got %q", want, n.Text)
+	}
+}
+
+func TestNotifyNewPosts(t *testing.T) {
+	var received []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("failed to decode notification: %v", err)
+		}
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	post := func(name string) RedditPost {
+		p := seenPost(name, 10)
+		p.Data.Permalink = "/r/test/comments/" + name + "/"
+		return p
+	}
+
+	p := NewPipeline(&Config{NotifyWebhook: server.URL}, nil, newTestDB(t))
+	opts := DefaultRunOptions()
+	run := func(posts ...RedditPost) {
+		p.notifyNewPosts(context.Background(), posts, nil, opts)
+		p.recordEmittedPosts(posts, opts)
+	}
+
+	// The first generation only sets the baseline
+	run(post("t3_a"), post("t3_b"))
+	if len(received) != 0 {
+		t.Fatalf("expected no notification for the first generation, got %v", received)
+	}
+
+	run(post("t3_b"), post("t3_c"))
+	if len(received) != 1 || received[0].Count != 1 || received[0].Titles[0] != "Post t3_c" || received[0].Feed != HomepageFeed {
+		t.Fatalf("expected a notification about t3_c, got %v", received)
+	}
+
+	// Unchanged feeds send nothing
+	run(post("t3_b"), post("t3_c"))
+	if len(received) != 1 {
+		t.Errorf("expected no notification without new items, got %v", received[1:])
+	}
+}
+
+func TestValidateNotifyWebhook(t *testing.T) {
+	for webhook, ok := range map[string]bool{
+		"":                         true,
+		"https://example.com/hook": true,
+		"ftp://example.com/hook":   false,
+		"example.com/hook":         false,
+		"https://":                 false,
+	} {
+		if err := validateNotifyWebhook(webhook); (err == nil) != ok {
+			t.Errorf("validateNotifyWebhook(%q) = %v", webhook, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -15,89 +16,87 @@ import (
 	"golang.org/x/net/html/charset"
 )
 
+// maxOpenGraphDescription is the length descriptions of linked pages are truncated to
+const maxOpenGraphDescription = 500
+
+// maxOpenGraphTitle is the length titles of linked pages are truncated to
+const maxOpenGraphTitle = 200
+
 // OpenGraphFetcher handles concurrent OpenGraph metadata fetching
 type OpenGraphFetcher struct {
-	client *http.Client
-	mu     sync.RWMutex
-	cache  map[string]*OpenGraphData
-	db     *OpenGraphDB
+	client     *http.Client
+	db         *OpenGraphDB
+	scheduler  *WorkScheduler
+	checkpoint *Checkpoint
+	reddit     DomainList // Links to Reddit, which have no useful OpenGraph data
+	blocked    DomainList // Links whose pages aren't fetched
+	hostDelay  time.Duration
+	cacheOnly  bool // Never fetch pages, only use cached previews
 }
 
-// NewOpenGraphFetcher creates a new OpenGraph fetcher with database backing
+// NewOpenGraphFetcher creates a new OpenGraph fetcher with database backing. When a
+// database is available, outbound requests go through the persistent HTTP cache.
 func NewOpenGraphFetcher(db *OpenGraphDB) *OpenGraphFetcher {
-	return &OpenGraphFetcher{
-		client: &http.Client{
-			Timeout: 8 * time.Second, // 8 second timeout as requested (5-10 seconds)
-		},
-		cache: make(map[string]*OpenGraphData),
-		db:    db,
+	client := &http.Client{
+		Timeout: 8 * time.Second, // 8 second timeout as requested (5-10 seconds)
 	}
-}
-
-// FetchOpenGraphData fetches OpenGraph metadata from a URL with enhanced error handling
-func (ogf *OpenGraphFetcher) FetchOpenGraphData(url string) (*OpenGraphData, error) {
-	// Validate URL format
-	if !isValidURL(url) {
-		return nil, fmt.Errorf("invalid URL format: %s", url)
+	if db != nil {
+		client.Transport = NewCachingTransport(db, http.DefaultTransport)
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	return &OpenGraphFetcher{
+		client:    client,
+		db:        db,
+		scheduler: DefaultScheduler,
+		reddit:    DefaultRedditDomains,
+		blocked:   DefaultBlockedDomains,
 	}
+}
 
-	// Set a comprehensive User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GoRedditFeedGenerator/1.0)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Connection", "keep-alive")
+// SetDomains sets the domains of Reddit and the blocked domains, whose pages aren't fetched
+func (ogf *OpenGraphFetcher) SetDomains(reddit, blocked DomainList) {
+	ogf.reddit = reddit
+	ogf.blocked = blocked
+}
 
-	resp, err := ogf.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch URL: %w", err)
-	}
-	defer resp.Body.Close()
+// SetHostDelay sets the time between starting fetches from the same host, so a run
+// with many links to one site doesn't hit it in parallel. Cached pages aren't delayed.
+func (ogf *OpenGraphFetcher) SetHostDelay(d time.Duration) {
+	ogf.hostDelay = d
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
-	}
+// SetCacheOnly makes the fetcher use cached previews only, without fetching pages
+func (ogf *OpenGraphFetcher) SetCacheOnly(cacheOnly bool) {
+	ogf.cacheOnly = cacheOnly
+}
 
-	// Check content type
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "text/html") && !strings.Contains(contentType, "application/xhtml") {
-		slog.Debug("Skipping non-HTML content", "url", url, "content_type", contentType)
-		return nil, fmt.Errorf("unsupported content type: %s", contentType)
-	}
+// SetCheckpoint makes the fetcher skip URLs already attempted by an interrupted run
+// and record newly attempted ones in the checkpoint
+func (ogf *OpenGraphFetcher) SetCheckpoint(cp *Checkpoint) {
+	ogf.checkpoint = cp
+}
 
-	// Handle compression (gzip/deflate)
-	var reader io.ReadCloser
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-		}
-		defer reader.Close()
-	default:
-		reader = resp.Body
-	}
+// FetchOpenGraphData fetches OpenGraph metadata from a URL with enhanced error handling
+func (ogf *OpenGraphFetcher) FetchOpenGraphData(url string) (*OpenGraphData, error) {
+	return ogf.FetchOpenGraphDataContext(context.Background(), url)
+}
 
-	// Read response body with size limit
-	const maxBodySize = 1024 * 1024 // 1MB limit
-	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+// FetchOpenGraphDataContext fetches OpenGraph metadata, aborting when ctx is cancelled
+func (ogf *OpenGraphFetcher) FetchOpenGraphDataContext(ctx context.Context, url string) (og *OpenGraphData, err error) {
+	ctx, span := StartSpan(ctx, "opengraph GET", SpanKindClient)
+	span.SetAttributes("url.full", url, "server.address", hostOf(url))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
 
-	// Convert body to UTF-8 string with proper encoding detection
-	htmlContent, err := ogf.convertToUTF8(body, resp.Header.Get("Content-Type"))
+	htmlContent, err := ogf.fetchHTML(ctx, url, span)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert content to UTF-8: %w", err)
+		return nil, err
 	}
 
 	// Parse OpenGraph tags
-	og, err := ogf.parseOpenGraphTags(htmlContent)
+	og, err = ogf.parseOpenGraphTags(htmlContent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse OpenGraph tags: %w", err)
 	}
@@ -105,6 +104,7 @@ func (ogf *OpenGraphFetcher) FetchOpenGraphData(url string) (*OpenGraphData, err
 	// Set metadata
 	now := time.Now()
 	og.URL = url
+	og.Canonical = resolveCanonical(url, og.Canonical)
 	og.FetchedAt = now
 	og.ExpiresAt = now.Add(time.Duration(OpenGraphCacheHours) * time.Hour)
 
@@ -130,6 +130,10 @@ func (ogf *OpenGraphFetcher) parseOpenGraphTags(htmlContent string) (*OpenGraphD
 			switch n.Data {
 			case "meta":
 				ogf.processMetaTag(n, og)
+			case "link":
+				if og.Canonical == "" && strings.EqualFold(attr(n, "rel"), "canonical") {
+					og.Canonical = attr(n, "href")
+				}
 			case "title":
 				if og.Title == "" && n.FirstChild != nil {
 					og.Title = strings.TrimSpace(n.FirstChild.Data)
@@ -144,6 +148,7 @@ func (ogf *OpenGraphFetcher) parseOpenGraphTags(htmlContent string) (*OpenGraphD
 	}
 
 	extractMeta(doc)
+	og.WordCount = articleWordCount(doc)
 
 	// Apply fallbacks if primary OpenGraph tags are missing
 	ogf.applyFallbacks(og, htmlContent)
@@ -244,7 +249,7 @@ func (ogf *OpenGraphFetcher) extractFirstParagraph(htmlContent string) string {
 			extractText(n)
 
 			result := strings.TrimSpace(text.String())
-			if len(result) > 20 { // Only return if it's substantial
+			if textLength(result) > 20 { // Only return if it's substantial
 				return result
 			}
 		}
@@ -263,14 +268,10 @@ func (ogf *OpenGraphFetcher) extractFirstParagraph(htmlContent string) string {
 // cleanupOpenGraphData validates and cleans up OpenGraph data
 func (ogf *OpenGraphFetcher) cleanupOpenGraphData(og *OpenGraphData) *OpenGraphData {
 	// Truncate long descriptions
-	if len(og.Description) > 500 {
-		og.Description = og.Description[:497] + "..."
-	}
+	og.Description = truncateText(og.Description, maxOpenGraphDescription)
 
 	// Truncate long titles
-	if len(og.Title) > 200 {
-		og.Title = og.Title[:197] + "..."
-	}
+	og.Title = truncateText(og.Title, maxOpenGraphTitle)
 
 	// Validate image URL
 	if og.Image != "" && !isValidURL(og.Image) {
@@ -293,14 +294,19 @@ func (ogf *OpenGraphFetcher) cleanupOpenGraphData(og *OpenGraphData) *OpenGraphD
 
 // GetOpenGraphPreview gets OpenGraph data for a URL, using cache when possible
 func (ogf *OpenGraphFetcher) GetOpenGraphPreview(url string) *OpenGraphData {
+	return ogf.GetOpenGraphPreviewContext(context.Background(), url)
+}
+
+// GetOpenGraphPreviewContext gets OpenGraph data for a URL, aborting the fetch when ctx is cancelled
+func (ogf *OpenGraphFetcher) GetOpenGraphPreviewContext(ctx context.Context, url string) *OpenGraphData {
 	// Check if it's a Reddit URL - skip OpenGraph for Reddit links
-	if isRedditURL(url) {
+	if ogf.reddit.Matches(url) {
 		slog.Debug("Skipping Reddit URL", "url", url)
 		return nil
 	}
 
 	// Check if it's a blocked URL - skip OpenGraph for blocked domains
-	if isBlockedURL(url) {
+	if ogf.blocked.Matches(url) {
 		slog.Debug("Skipping blocked URL", "url", url)
 		return nil
 	}
@@ -316,11 +322,24 @@ func (ogf *OpenGraphFetcher) GetOpenGraphPreview(url string) *OpenGraphData {
 		}
 	}
 
+	if ogf.cacheOnly {
+		return nil
+	}
+
+	// Failed fetches are not cached, so don't retry them when resuming a run
+	if ogf.checkpoint != nil && ogf.checkpoint.Enriched[url] {
+		slog.Debug("Skipping URL attempted by interrupted run", "url", url)
+		return nil
+	}
+
 	// Fetch new OpenGraph data
 	slog.Info("Fetching OpenGraph data", "url", url)
-	og, err := ogf.FetchOpenGraphData(url)
+	og, err := ogf.FetchOpenGraphDataContext(ctx, url)
 	if err != nil {
 		slog.Warn("Failed to fetch OpenGraph data", "url", url, "error", err)
+		if ctx.Err() == nil {
+			ogf.markAttempted(url)
+		}
 		return nil
 	}
 
@@ -333,67 +352,153 @@ func (ogf *OpenGraphFetcher) GetOpenGraphPreview(url string) *OpenGraphData {
 			slog.Warn("Failed to cache OpenGraph data", "url", url, "error", err)
 		}
 	}
+	ogf.markAttempted(url)
 
 	return og
 }
 
+// markAttempted records the URL in the checkpoint of the current run, if any
+func (ogf *OpenGraphFetcher) markAttempted(url string) {
+	if ogf.checkpoint == nil || ogf.db == nil {
+		return
+	}
+	if err := ogf.db.MarkCheckpointURL(ogf.checkpoint.Key, url); err != nil {
+		slog.Warn("Failed to update checkpoint", "url", url, "error", err)
+	}
+}
+
 // FetchConcurrentOpenGraph fetches OpenGraph data for multiple URLs concurrently
 func (ogf *OpenGraphFetcher) FetchConcurrentOpenGraph(urls []string) map[string]*OpenGraphData {
+	return ogf.FetchConcurrentOpenGraphContext(context.Background(), urls)
+}
+
+// FetchConcurrentOpenGraphContext fetches OpenGraph data for multiple URLs concurrently,
+// tracing each fetch as a child of ctx
+func (ogf *OpenGraphFetcher) FetchConcurrentOpenGraphContext(ctx context.Context, urls []string) map[string]*OpenGraphData {
 	if len(urls) == 0 {
 		return nil
 	}
 
-	type result struct {
-		url string
-		og  *OpenGraphData
-	}
+	data := make(map[string]*OpenGraphData)
+	var mu sync.Mutex
 
-	results := make(chan result, len(urls))
-	var wg sync.WaitGroup
+	slog.Info("Starting concurrent OpenGraph fetch", "total_urls", len(urls))
+	tasks := ogf.OpenGraphTasks(urls, func(u string, og *OpenGraphData) {
+		mu.Lock()
+		defer mu.Unlock()
+		data[u] = og
+	})
+	ogf.scheduler.RunBatch(ctx, tasks)
 
-	// Limit concurrent requests
-	const maxConcurrent = 5
-	semaphore := make(chan struct{}, maxConcurrent)
+	return data
+}
 
-	slog.Info("Starting concurrent OpenGraph fetch", "total_urls", len(urls))
-	for _, url := range urls {
+// OpenGraphTasks builds scheduler tasks that fetch OpenGraph data for the URLs, calling
+// store for each successful result. Earlier URLs get higher priority.
+func (ogf *OpenGraphFetcher) OpenGraphTasks(urls []string, store func(url string, og *OpenGraphData)) []Task {
+	tasks := make([]Task, 0, len(urls))
+	for i, url := range urls {
 		if url == "" {
 			continue
 		}
 
-		wg.Add(1)
-		go func(u string) {
-			defer wg.Done()
+		u := url
+		tasks = append(tasks, Task{
+			Host:     hostOf(u),
+			Priority: len(urls) - i,
+			Delay:    ogf.delayFor(u),
+			Run: func(ctx context.Context) {
+				slog.Debug("Processing URL for OpenGraph", "url", u)
+				og := ogf.GetOpenGraphPreviewContext(ctx, u)
+				if og == nil {
+					slog.Debug("No OpenGraph preview obtained", "url", u)
+					return
+				}
+				slog.Debug("OpenGraph preview obtained", "url", u, "title", og.Title)
+				store(u, og)
+			},
+		})
+	}
+	return tasks
+}
 
-			semaphore <- struct{}{}        // Acquire
-			defer func() { <-semaphore }() // Release
+// delayFor returns the host delay that fetching a URL needs: none for URLs that are
+// skipped or answered from the cache, as they don't reach the site
+func (ogf *OpenGraphFetcher) delayFor(url string) time.Duration {
+	if ogf.hostDelay <= 0 || ogf.reddit.Matches(url) || ogf.blocked.Matches(url) {
+		return 0
+	}
+	if ogf.db != nil {
+		if cached, _ := ogf.db.GetCachedOpenGraph(url); cached != nil {
+			return 0
+		}
+	}
+	return ogf.hostDelay
+}
 
-			slog.Debug("Processing URL for OpenGraph", "url", u)
-			og := ogf.GetOpenGraphPreview(u)
-			if og != nil {
-				slog.Debug("OpenGraph preview obtained", "url", u, "title", og.Title)
-			} else {
-				slog.Debug("No OpenGraph preview obtained", "url", u)
-			}
-			results <- result{url: u, og: og}
-		}(url)
+// fetchHTML fetches an HTML page and converts it to UTF-8, recording the response on span
+func (ogf *OpenGraphFetcher) fetchHTML(ctx context.Context, url string, span *Span) (string, error) {
+	// Validate URL format
+	if !isValidURL(url) {
+		return "", fmt.Errorf("invalid URL format: %s", url)
 	}
 
-	// Close results channel when all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
 
-	// Collect results
-	data := make(map[string]*OpenGraphData)
-	for res := range results {
-		if res.og != nil {
-			data[res.url] = res.og
+	// Set a comprehensive User-Agent
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GoRedditFeedGenerator/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("Connection", "keep-alive")
+
+	resp, err := ogf.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+	span.SetAttributes("http.response.status_code", resp.StatusCode, "http.cache", resp.Header.Get(httpCacheHeader))
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	// Check content type
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "text/html") && !strings.Contains(contentType, "application/xhtml") {
+		slog.Debug("Skipping non-HTML content", "url", url, "content_type", contentType)
+		return "", fmt.Errorf("unsupported content type: %s", contentType)
+	}
+
+	// Handle compression (gzip/deflate)
+	var reader io.ReadCloser
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		reader, err = gzip.NewReader(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to create gzip reader: %w", err)
 		}
+		defer reader.Close()
+	default:
+		reader = resp.Body
 	}
 
-	return data
+	// Read response body with size limit
+	const maxBodySize = 1024 * 1024 // 1MB limit
+	body, err := io.ReadAll(io.LimitReader(reader, maxBodySize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Convert body to UTF-8 string with proper encoding detection
+	htmlContent, err := ogf.convertToUTF8(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert content to UTF-8: %w", err)
+	}
+	return htmlContent, nil
 }
 
 // isValidURL checks if a URL is valid
@@ -402,32 +507,6 @@ func isValidURL(urlStr string) bool {
 	return err == nil && u.Scheme != "" && u.Host != ""
 }
 
-// isRedditURL checks if a URL is a Reddit URL
-func isRedditURL(url string) bool {
-	return strings.Contains(url, "reddit.com") || strings.Contains(url, "redd.it")
-}
-
-// isBlockedURL checks if a URL is from a domain that blocks external access
-func isBlockedURL(url string) bool {
-	blockedDomains := []string{
-		"x.com",
-		"twitter.com",
-		"facebook.com",
-		"instagram.com",
-		"linkedin.com",
-		"i.redd.it",          // Reddit image URLs don't have useful OpenGraph
-		"v.redd.it",          // Reddit video URLs don't have useful OpenGraph
-		"reddit.com/gallery", // Reddit gallery URLs don't have useful OpenGraph
-	}
-
-	for _, domain := range blockedDomains {
-		if strings.Contains(url, domain) {
-			return true
-		}
-	}
-	return false
-}
-
 // convertToUTF8 converts response body to UTF-8 string with proper encoding detection
 func (ogf *OpenGraphFetcher) convertToUTF8(body []byte, contentType string) (string, error) {
 	// Try to detect encoding from content type or HTML meta tags
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"cmp"
+	"strings"
+)
+
+// Posts are sorted with explicit tie-breakers so that the same input always gives
+// the same feed, whatever order the posts were fetched in. Ties on the sort key are
+// broken by fullname, which is unique per post.
+
+// newestFirst orders posts by creation time, newest first, then by fullname
+func newestFirst(a, b RedditPost) int {
+	if c := cmp.Compare(b.Data.CreatedUTC, a.Data.CreatedUTC); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Data.Name, b.Data.Name)
+}
+
+// highestScoreFirst orders posts by score, highest first, then as newestFirst
+func highestScoreFirst(a, b RedditPost) int {
+	if c := cmp.Compare(b.Data.Score, a.Data.Score); c != 0 {
+		return c
+	}
+	return newestFirst(a, b)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewestFirstBreaksTies(t *testing.T) {
+	a := seenPost("t3_a", 10)
+	b := seenPost("t3_b", 10)
+	c := seenPost("t3_c", 10)
+	a.Data.CreatedUTC, b.Data.CreatedUTC, c.Data.CreatedUTC = 1000, 1000, 2000
+
+	for _, posts := range [][]RedditPost{{a, b, c}, {b, a, c}, {c, b, a}} {
+		slices.SortFunc(posts, newestFirst)
+		var names []string
+		for _, post := range posts {
+			names = append(names, post.Data.Name)
+		}
+		if got := strings.Join(names, ","); got != "t3_c,t3_a,t3_b" {
+			t.Errorf("expected the same order whatever the input order, got %s", got)
+		}
+	}
+}
+
+func TestBuildReproducible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Page ` + r.URL.Path + `"></head></html>`))
+	}))
+	defer server.Close()
+
+	generatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	render := func() *RunResult {
+		p := NewPipeline(&Config{FeedType: "atom", FeedUpdated: UpdatedNow}, nil, nil)
+		p.SetClock(FixedClock(generatedAt))
+		var posts []RedditPost
+		for _, name := range []string{"t3_a", "t3_b", "t3_c"} {
+			post := seenPost(name, 10)
+			post.Data.URL = server.URL + "/" + name
+			posts = append(posts, post)
+		}
+		result, err := p.build(context.Background(), posts, RunOptions{MinScore: 0, Offline: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first, second := render(), render()
+	if !bytes.Equal(first.Content, second.Content) {
+		t.Errorf("expected identical output for the same input:\n%s\n%s", first.Content, second.Content)
+	}
+	if !first.GeneratedAt.Equal(generatedAt) || !bytes.Contains(first.Content, []byte("2024-05-01T12:00:00Z")) {
+		t.Errorf("expected the time of the injected clock, got %v", first.GeneratedAt)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// outputTimeout bounds uploading a feed to a remote output backend
+const outputTimeout = time.Minute
+
+// OutputBackend stores generated feeds at remote locations named by URLs
+type OutputBackend interface {
+	Write(ctx context.Context, u *url.URL, content []byte, contentType string) error
+}
+
+// outputBackends are the output backends by URL scheme. Paths without a scheme are
+// local files.
+var outputBackends = map[string]func() (OutputBackend, error){
+	"s3": newS3BackendFromEnv,
+}
+
+// outputURL parses an output path that names a remote location, returning nil for
+// local paths. Windows drive letters don't count as schemes.
+func outputURL(path string) *url.URL {
+	scheme, _, ok := strings.Cut(path, "://")
+	if !ok || len(scheme) < 2 {
+		return nil
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// validateOutputURL checks that a remote output path has a known scheme and a location
+func validateOutputURL(path string) error {
+	u := outputURL(path)
+	if u == nil {
+		return nil
+	}
+	if _, ok := outputBackends[u.Scheme]; !ok {
+		return fmt.Errorf("output_path has unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("output_path %q needs a bucket and a key", path)
+	}
+	return nil
+}
+
+// writeOutput writes a generated feed to a local file or, for output paths that are
+// URLs such as s3://bucket/key, uploads it with its content type
+func writeOutput(path string, content []byte, contentType string) error {
+	u := outputURL(path)
+	if u == nil {
+		return writeFeedFile(path, content)
+	}
+
+	newBackend, ok := outputBackends[u.Scheme]
+	if !ok {
+		return fmt.Errorf("unsupported output scheme %q", u.Scheme)
+	}
+	backend, err := newBackend()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outputTimeout)
+	defer cancel()
+	if err := backend.Write(ctx, u, content, contentType); err != nil {
+		return fmt.Errorf("failed to upload feed to %s: %w", u.Redacted(), err)
+	}
+	return nil
+}
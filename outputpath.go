@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// outputPlaceholder matches a variable in output_path, e.g. {yyyy}
+var outputPlaceholder = regexp.MustCompile(`\{([A-Za-z]+)\}`)
+
+// outputVariables are the variables output_path can contain, resolved for a feed
+// generated at a given time
+var outputVariables = map[string]func(feed, feedType string, t time.Time) string{
+	"feed": func(feed, _ string, _ time.Time) string { return feed },
+	"type": func(_, feedType string, _ time.Time) string { return feedType },
+	"date": func(_, _ string, t time.Time) string { return t.Format("2006-01-02") },
+	"yyyy": func(_, _ string, t time.Time) string { return t.Format("2006") },
+	"MM":   func(_, _ string, t time.Time) string { return t.Format("01") },
+	"dd":   func(_, _ string, t time.Time) string { return t.Format("02") },
+	"HH":   func(_, _ string, t time.Time) string { return t.Format("15") },
+	"mm":   func(_, _ string, t time.Time) string { return t.Format("04") },
+}
+
+// validateOutputPath checks that output_path only uses known variables
+func validateOutputPath(template string) error {
+	for _, match := range outputPlaceholder.FindAllStringSubmatch(template, -1) {
+		if _, ok := outputVariables[match[1]]; !ok {
+			return fmt.Errorf("output_path has unknown variable {%s}", match[1])
+		}
+	}
+	return nil
+}
+
+// resolveOutputPath returns where a feed generated at t is written. The variables of
+// the output_path template are replaced with their values in local time. A non-empty
+// dir replaces the fixed leading directories of the template, keeping the ones made
+// of variables, except for remote output paths such as s3://bucket/key.
+func resolveOutputPath(template, dir, feed, feedType string, t time.Time) string {
+	if dir != "" && outputURL(template) == nil {
+		segments := strings.Split(filepath.ToSlash(template), "/")
+		for len(segments) > 1 && !outputPlaceholder.MatchString(segments[0]) {
+			segments = segments[1:]
+		}
+		// Keep the feed inside dir
+		segments = slices.DeleteFunc(segments, func(s string) bool { return s == ".." })
+		template = filepath.Join(dir, filepath.Join(segments...))
+	}
+
+	return outputPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if variable, ok := outputVariables[placeholder[1:len(placeholder)-1]]; ok {
+			return variable(feed, feedType, t)
+		}
+		return placeholder
+	})
+}
+
+// feedTempPattern names the temporary files feeds are written to before they are
+// renamed into place
+const feedTempPattern = ".red-rss-feed-*.tmp"
+
+// writeFeedFile writes a feed, creating the directories a templated path needs. The
+// feed is written to a temporary file that replaces the old one, so readers of the
+// file never see a partial feed.
+func writeFeedFile(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create feed directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, feedTempPattern)
+	if err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	defer os.Remove(f.Name()) // Fails harmlessly after the rename
+
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveOutputPath(t *testing.T) {
+	at := time.Date(2024, 6, 1, 9, 5, 0, 0, time.Local)
+	tests := []struct {
+		template, dir, want string
+	}{
+		{"reddit.xml", "", "reddit.xml"},
+		{"feeds/{feed}/{yyyy}/{MM}/feed.xml", "", "feeds/alice/2024/06/feed.xml"},
+		{"feed-{date}-{HH}{mm}.{type}", "", "feed-2024-06-01-0905.atom"},
+		{"/var/www/reddit.xml", "out", "out/reddit.xml"},
+		{"feeds/{yyyy}/{dd}.xml", "out", "out/2024/01.xml"},
+		{"{feed}/../../escape.xml", "out", "out/alice/escape.xml"},
+		{"{unknown}.xml", "", "{unknown}.xml"},
+	}
+	for _, tt := range tests {
+		got := resolveOutputPath(tt.template, tt.dir, "alice", "atom", at)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("resolveOutputPath(%q, %q) = %q, want %q", tt.template, tt.dir, got, tt.want)
+		}
+	}
+
+	if err := validateOutputPath("feeds/{feed}/{yyyy}.xml"); err != nil {
+		t.Errorf("expected known variables to be valid, got %v", err)
+	}
+	if err := validateOutputPath("feed-{year}.xml"); err == nil {
+		t.Error("expected an unknown variable to be rejected")
+	}
+}
+
+func TestWriteFeedFileCreatesDirectories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice", "2024", "feed.xml")
+	if err := writeFeedFile(path, []byte("<feed/>")); err != nil {
+		t.Fatalf("writeFeedFile failed: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Override actions
+const (
+	OverridePin = "pin" // Always include the post
+	OverrideBan = "ban" // Never include the post
+)
+
+// PostOverride forces a post into or out of generated feeds regardless of filters
+type PostOverride struct {
+	Fullname  string
+	Action    string
+	CreatedAt time.Time
+	ExpiresAt time.Time // Zero for no expiry
+}
+
+// parsePostRef returns the fullname of the post a permalink, redd.it link, fullname
+// or bare post ID refers to
+func parsePostRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, KindPost+"_") {
+		return ref, nil
+	}
+
+	if strings.Contains(ref, "/") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid permalink %q: %w", ref, err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if strings.HasSuffix(u.Host, "redd.it") && len(parts) == 1 && parts[0] != "" {
+			return KindPost + "_" + parts[0], nil
+		}
+		for i, part := range parts {
+			if part == "comments" && i+1 < len(parts) && parts[i+1] != "" {
+				return KindPost + "_" + parts[i+1], nil
+			}
+		}
+		return "", fmt.Errorf("%q is not a Reddit post permalink", ref)
+	}
+
+	if ref == "" {
+		return "", fmt.Errorf("empty post reference")
+	}
+	return KindPost + "_" + ref, nil
+}
+
+// SetOverride pins or bans a post, replacing any earlier override of it
+func (ogDB *OpenGraphDB) SetOverride(o PostOverride) error {
+	var expiresAt int64
+	if !o.ExpiresAt.IsZero() {
+		expiresAt = o.ExpiresAt.Unix()
+	}
+
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO post_overrides (fullname, action, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		o.Fullname, o.Action, time.Now().Unix(), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save override: %w", err)
+	}
+	return nil
+}
+
+// RemoveOverride removes the pin or ban of a post, reporting whether there was one
+func (ogDB *OpenGraphDB) RemoveOverride(fullname string) (bool, error) {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	res, err := ogDB.db.Exec(`DELETE FROM post_overrides WHERE fullname = ?`, fullname)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove override: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// GetOverrides returns the overrides that haven't expired at the given time
+func (ogDB *OpenGraphDB) GetOverrides(at time.Time) ([]PostOverride, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`SELECT fullname, action, created_at, expires_at FROM post_overrides
+		WHERE expires_at = 0 OR expires_at > ? ORDER BY created_at, rowid`, at.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []PostOverride
+	for rows.Next() {
+		var o PostOverride
+		var createdAt, expiresAt int64
+		if err := rows.Scan(&o.Fullname, &o.Action, &createdAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan override: %w", err)
+		}
+		o.CreatedAt = time.Unix(createdAt, 0)
+		if expiresAt != 0 {
+			o.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// applyOverrides drops banned posts and returns the pinned ones separately, in the
+// order they were pinned. Pinned posts that weren't fetched come from the item store.
+func (p *Pipeline) applyOverrides(posts []RedditPost, stats FilterStats) (rest, pinned []RedditPost) {
+	if p.db == nil {
+		return posts, nil
+	}
+
+	overrides, err := p.db.GetOverrides(time.Now())
+	if err != nil {
+		slog.Warn("Failed to load pinned and banned posts", "error", err)
+		return posts, nil
+	}
+	if len(overrides) == 0 {
+		return posts, nil
+	}
+
+	actions := make(map[string]string, len(overrides))
+	for _, o := range overrides {
+		actions[o.Fullname] = o.Action
+	}
+
+	fetched := make(map[string]RedditPost)
+	for _, post := range posts {
+		switch actions[post.Data.Name] {
+		case OverrideBan:
+			stats.record(RuleBanned, 1, true)
+		case OverridePin:
+			fetched[post.Data.Name] = post
+		default:
+			rest = append(rest, post)
+		}
+	}
+
+	var missing []string
+	for _, o := range overrides {
+		if _, ok := fetched[o.Fullname]; !ok && o.Action == OverridePin {
+			missing = append(missing, o.Fullname)
+		}
+	}
+	stored, err := p.db.GetSeenPosts(missing)
+	if err != nil {
+		slog.Warn("Failed to load pinned posts", "error", err)
+	}
+
+	for _, o := range overrides {
+		if o.Action != OverridePin {
+			continue
+		}
+		if post, ok := fetched[o.Fullname]; ok {
+			post.Data.Matched = append(post.Data.Matched, "pinned")
+			pinned = append(pinned, post)
+		} else if post, ok := stored[o.Fullname]; ok {
+			post.Data.Matched = append(post.Data.Matched, "pinned from the item store")
+			pinned = append(pinned, post)
+		} else {
+			slog.Debug("Pinned post not fetched yet", "post", o.Fullname)
+		}
+	}
+
+	slog.Debug("Applied overrides", "pinned", len(pinned), "banned", len(posts)-len(rest)-len(fetched))
+	return rest, pinned
+}
+
+// runPin implements the pin subcommand
+func runPin(args []string) error {
+	return runOverride(OverridePin, args)
+}
+
+// runBan implements the ban subcommand
+func runBan(args []string) error {
+	return runOverride(OverrideBan, args)
+}
+
+// runOverride pins, bans, lists or removes post overrides
+func runOverride(action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	expiry := fs.Duration("for", 0, "remove the "+action+" after this long, e.g. 72h (default: never)")
+	remove := fs.Bool("remove", false, "remove the "+action+" of the post instead")
+	list := fs.Bool("list", false, "list the current pins and bans")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: red-rss %s [-for duration] [-remove] <permalink>\n       red-rss %s -list\n", action, action)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	db, err := InitOpenGraphDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *list {
+		overrides, err := db.GetOverrides(time.Now())
+		if err != nil {
+			return err
+		}
+		for _, o := range overrides {
+			expires := "never"
+			if !o.ExpiresAt.IsZero() {
+				expires = o.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-4s %-12s expires %s\n", o.Action, o.Fullname, expires)
+		}
+		return nil
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one post permalink")
+	}
+	fullname, err := parsePostRef(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		removed, err := db.RemoveOverride(fullname)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("%s was not pinned or banned\n", fullname)
+			return nil
+		}
+		fmt.Printf("Removed override of %s\n", fullname)
+		return nil
+	}
+
+	o := PostOverride{Fullname: fullname, Action: action}
+	if *expiry > 0 {
+		o.ExpiresAt = time.Now().Add(*expiry)
+	}
+	if err := db.SetOverride(o); err != nil {
+		return err
+	}
+
+	if o.ExpiresAt.IsZero() {
+		fmt.Printf("%s %sned\n", fullname, action)
+	} else {
+		fmt.Printf("%s %sned until %s\n", fullname, action, o.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePostRef(t *testing.T) {
+	tests := map[string]string{
+		"https://www.reddit.com/r/golang/comments/abc123/some_title/": "t3_abc123",
+		"/r/golang/comments/abc123/":                                  "t3_abc123",
+		"https://redd.it/abc123":                                      "t3_abc123",
+		"t3_abc123":                                                   "t3_abc123",
+		"abc123":                                                      "t3_abc123",
+	}
+	for ref, want := range tests {
+		got, err := parsePostRef(ref)
+		if err != nil || got != want {
+			t.Errorf("parsePostRef(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+
+	for _, invalid := range []string{"", "https://www.reddit.com/r/golang/"} {
+		if _, err := parsePostRef(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	db := newTestDB(t)
+	p := &Pipeline{config: &Config{}, db: db}
+
+	stored := seenPost("t3_old", 1)
+	if err := db.SaveSeenPosts([]RedditPost{stored}); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+
+	for _, o := range []PostOverride{
+		{Fullname: "t3_low", Action: OverridePin},
+		{Fullname: "t3_old", Action: OverridePin},
+		{Fullname: "t3_spam", Action: OverrideBan},
+		{Fullname: "t3_top", Action: OverrideBan, ExpiresAt: time.Now().Add(-time.Minute)},
+	} {
+		if err := db.SetOverride(o); err != nil {
+			t.Fatalf("SetOverride failed: %v", err)
+		}
+	}
+
+	posts := []RedditPost{seenPost("t3_top", 900), seenPost("t3_spam", 800), seenPost("t3_low", 1)}
+	rest, pinned := p.applyOverrides(posts, nil)
+
+	if len(rest) != 1 || rest[0].Data.Name != "t3_top" {
+		t.Errorf("expected only t3_top to remain after the expired ban, got %v", rest)
+	}
+	if len(pinned) != 2 || pinned[0].Data.Name != "t3_low" || pinned[1].Data.Name != "t3_old" {
+		t.Errorf("expected t3_low and the stored t3_old to be pinned, got %v", pinned)
+	}
+
+	removed, err := db.RemoveOverride("t3_spam")
+	if err != nil || !removed {
+		t.Fatalf("RemoveOverride = %v, %v", removed, err)
+	}
+	rest, _ = p.applyOverrides(posts, nil)
+	if len(rest) != 2 {
+		t.Errorf("expected t3_spam back after removing the ban, got %d posts", len(rest))
+	}
+}
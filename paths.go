@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// appDirName names the directories of red-rss in the config and data directories
+const appDirName = "red-rss"
+
+// Where red-rss keeps its files without -config-file. They start out relative to the
+// working directory, as older versions kept them, and resolveAppPaths points them at
+// the config and data directories of the user.
+var (
+	DefaultConfigPath = ConfigFileName  // Config file
+	CacheDBPath       = OpenGraphDBFile // Cache database
+	PausePath         = PauseFile       // Pause file, next to the cache database
+	RunLockPath       = RunLockFile     // Run lock, next to the cache database
+	ServeLockPath     = ServeLockFile   // Lock of a running serve, next to the cache database
+)
+
+// legacyFile is a file older versions left in the working directory, used where it
+// is until migrateAppPaths moves it
+type legacyFile struct {
+	legacy, path  string            // Path in the working directory and the new path
+	keyringPrefix string            // Prefix of the secrets of the file in the OS keyring
+	use           func(path string) // Points the default path at the file
+}
+
+// legacyFiles lists the files resolveAppPaths found in the working directory
+var legacyFiles []legacyFile
+
+// configHome returns XDG_CONFIG_HOME, or the config directory of the OS: ~/.config,
+// ~/Library/Application Support on macOS and %AppData% on Windows
+func configHome() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	return os.UserConfigDir()
+}
+
+// dataHome returns XDG_DATA_HOME, or the data directory of the OS: ~/.local/share,
+// ~/Library/Application Support on macOS and %LocalAppData% on Windows
+func dataHome() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return dir, nil
+		}
+		return "", errors.New("%LocalAppData% is not defined")
+	case "darwin", "ios":
+		return os.UserConfigDir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+// resolveAppPaths points the default paths at the config and data directories of
+// the user. Files older versions left in the working directory keep being used, so a
+// run never picks up the files of another deployment, until migrateAppPaths moves
+// them. When the directories can't be determined, the working directory is kept.
+func resolveAppPaths() {
+	legacyFiles = nil
+	configDir, err := configHome()
+	if err == nil {
+		configDir = filepath.Join(configDir, appDirName)
+		DefaultConfigPath = preferLegacy(ConfigFileName, filepath.Join(configDir, ConfigFileName), "", func(path string) {
+			if ConfigPath == DefaultConfigPath {
+				ConfigPath = path
+			}
+			DefaultConfigPath = path
+		})
+		ConfigPath = DefaultConfigPath
+	} else {
+		slog.Warn("No config directory, using the working directory", "error", err)
+	}
+
+	dataDir, err := dataHome()
+	if err == nil {
+		dataDir = filepath.Join(dataDir, appDirName)
+		err = os.MkdirAll(dataDir, 0700)
+	}
+	if err != nil {
+		slog.Warn("No data directory, using the working directory", "error", err)
+		DefaultAuditLog = NewAuditLog(AuditLogFile, AuditLogMaxSize, AuditLogRotations)
+		return
+	}
+
+	// The run lock stays next to a database in the working directory, so runs of older
+	// versions and runs about to move it exclude each other
+	CacheDBPath = preferLegacy(OpenGraphDBFile, filepath.Join(dataDir, OpenGraphDBFile), "cache-key:", func(path string) {
+		CacheDBPath = path
+	})
+	RunLockPath = filepath.Join(filepath.Dir(CacheDBPath), RunLockFile)
+	ServeLockPath = filepath.Join(filepath.Dir(CacheDBPath), ServeLockFile)
+	PausePath = preferLegacy(PauseFile, filepath.Join(dataDir, PauseFile), "", func(path string) {
+		PausePath = path
+	})
+
+	auditPath := preferLegacy(AuditLogFile, filepath.Join(dataDir, AuditLogFile), "", func(path string) {
+		DefaultAuditLog = NewAuditLog(path, AuditLogMaxSize, AuditLogRotations)
+	})
+	DefaultAuditLog = NewAuditLog(auditPath, AuditLogMaxSize, AuditLogRotations)
+	if auditPath == AuditLogFile {
+		for i := 1; i <= AuditLogRotations; i++ {
+			rotated := fmt.Sprintf("%s.%d", AuditLogFile, i)
+			preferLegacy(rotated, filepath.Join(dataDir, rotated), "", func(string) {})
+		}
+	}
+}
+
+// preferLegacy returns legacy when it exists in the working directory, recording it
+// for migrateAppPaths, and path otherwise
+func preferLegacy(legacy, path, keyringPrefix string, use func(path string)) string {
+	if _, err := os.Stat(legacy); err != nil {
+		return path
+	}
+	slog.Warn("Using file in the working directory", "file", legacy, "new_location", path)
+	legacyFiles = append(legacyFiles, legacyFile{legacy: legacy, path: path, keyringPrefix: keyringPrefix, use: use})
+	return legacy
+}
+
+// migrateAppPaths moves the files resolveAppPaths found in the working directory to
+// the config and data directories. It's called by one-shot and scheduled runs without
+// an explicit config, while they hold the run lock. Nothing moves while serve holds
+// the serve lock, as it has the database and config open.
+func migrateAppPaths() {
+	if len(legacyFiles) == 0 {
+		return
+	}
+	lock, err := AcquireRunLock(ServeLockPath)
+	if err != nil {
+		slog.Warn("Keeping files in the working directory while serve uses them", "error", err)
+		return
+	}
+	defer lock.Release()
+
+	for _, file := range legacyFiles {
+		if migrateLegacyFile(file.legacy, file.path, file.keyringPrefix) {
+			file.use(file.path)
+		}
+	}
+	legacyFiles = nil
+}
+
+// migrateLegacyFile moves a file from the working directory to its new path, unless
+// there already is a file there, and reports whether it did. A database moves with its
+// journal files. The secrets of the file in the OS keyring, kept under its path behind
+// keyringPrefix, follow it.
+func migrateLegacyFile(legacy, path, keyringPrefix string) bool {
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Keeping file in the working directory, its new location is taken", "file", legacy, "new_location", path)
+		return false
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return false
+	}
+	oldAccount := keyringPrefix + keyringAccount(legacy)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		slog.Warn("Failed to move file to its new location", "file", legacy, "error", err)
+		return false
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if suffix != "" && !strings.HasSuffix(legacy, ".db") {
+			break
+		}
+		if err := moveFile(legacy+suffix, path+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to move file to its new location", "file", legacy+suffix, "error", err)
+			return false
+		}
+	}
+	if secret, err := tokenKeyring.Get(oldAccount); err == nil {
+		if err := tokenKeyring.Set(keyringPrefix+keyringAccount(path), secret); err == nil {
+			tokenKeyring.Delete(oldAccount)
+		}
+	}
+	slog.Info("Moved file to its new location", "from", legacy, "to", path)
+	return true
+}
+
+// moveFile renames a file, copying it when it moves to another file system
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return fmt.Errorf("failed to copy %s: %w", from, err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveAppPaths(t *testing.T) {
+	keyring := &memoryKeyring{secrets: make(map[string]string)}
+	useKeyring(t, keyring)
+	for _, path := range []*string{&DefaultConfigPath, &ConfigPath, &CacheDBPath, &PausePath, &RunLockPath, &ServeLockPath} {
+		saved := *path
+		t.Cleanup(func() { *path = saved })
+	}
+	savedAudit := DefaultAuditLog
+	t.Cleanup(func() { DefaultAuditLog = savedAudit })
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	work := t.TempDir()
+	t.Chdir(work)
+	for _, name := range []string{ConfigFileName, OpenGraphDBFile, OpenGraphDBFile + "-wal", AuditLogFile} {
+		if err := os.WriteFile(name, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keyring.secrets[keyringAccount(ConfigFileName)] = "tokens"
+	keyring.secrets["cache-key:"+keyringAccount(OpenGraphDBFile)] = "key"
+
+	resolveAppPaths()
+	if ConfigPath != ConfigFileName || CacheDBPath != OpenGraphDBFile || RunLockPath != RunLockFile || DefaultAuditLog.path != AuditLogFile {
+		t.Errorf("expected the files in the working directory to be used until they are moved, got %s, %s, %s and %s",
+			ConfigPath, CacheDBPath, RunLockPath, DefaultAuditLog.path)
+	}
+	if entries, _ := os.ReadDir(work); len(entries) != 4 {
+		t.Errorf("expected resolving the paths to move nothing, got %v", entries)
+	}
+
+	migrateAppPaths()
+
+	wantConfig := filepath.Join(home, "config", appDirName, ConfigFileName)
+	wantDB := filepath.Join(home, "data", appDirName, OpenGraphDBFile)
+	if DefaultConfigPath != wantConfig || ConfigPath != wantConfig {
+		t.Errorf("expected config at %s, got %s and %s", wantConfig, DefaultConfigPath, ConfigPath)
+	}
+	if CacheDBPath != wantDB {
+		t.Errorf("expected the database in the data directory, got %s", CacheDBPath)
+	}
+	for _, path := range []string{wantConfig, wantDB, wantDB + "-wal", DefaultAuditLog.path} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != filepath.Base(path) {
+			t.Errorf("expected %s to be moved, got %q, %v", path, data, err)
+		}
+	}
+	if entries, _ := os.ReadDir(work); len(entries) != 0 {
+		t.Errorf("expected the working directory to be empty, got %v", entries)
+	}
+	if keyring.secrets[keyringAccount(wantConfig)] != "tokens" || keyring.secrets["cache-key:"+keyringAccount(wantDB)] != "key" {
+		t.Errorf("expected the keyring secrets to follow the files: %v", keyring.secrets)
+	}
+	if len(keyring.secrets) != 2 {
+		t.Errorf("expected the old keyring entries to be removed: %v", keyring.secrets)
+	}
+
+	resolveAppPaths()
+	if ConfigPath != wantConfig || RunLockPath != filepath.Join(home, "data", appDirName, RunLockFile) {
+		t.Errorf("expected the moved files to be used, got %s and %s", ConfigPath, RunLockPath)
+	}
+
+	// The files of another deployment are used where they are, and a file already in
+	// the new location is never replaced
+	os.WriteFile(ConfigFileName, []byte("other"), 0600)
+	resolveAppPaths()
+	migrateAppPaths()
+	if data, _ := os.ReadFile(wantConfig); string(data) != ConfigFileName || ConfigPath != ConfigFileName {
+		t.Errorf("expected the other config to be used in place, got %s with %q", ConfigPath, data)
+	}
+
+	// Files serve has open aren't moved from under it, until it stops
+	os.Remove(wantDB)
+	os.WriteFile(ConfigFileName, []byte(`{"client_id": "id", "feed_type": "rss", "output_path": "reddit.xml"}`), 0600)
+	manager := NewTenantManager("", "")
+	if err := manager.AddDefault(ConfigFileName, OpenGraphDBFile, "."); err != nil {
+		t.Fatal(err)
+	}
+	resolveAppPaths()
+	migrateAppPaths()
+	if _, err := os.Stat(OpenGraphDBFile); err != nil || CacheDBPath != OpenGraphDBFile {
+		t.Errorf("expected the open database to stay in place, got %s: %v", CacheDBPath, err)
+	}
+	manager.Close()
+	migrateAppPaths()
+	if _, err := os.Stat(OpenGraphDBFile); !os.IsNotExist(err) || CacheDBPath != wantDB {
+		t.Errorf("expected the database to move once serve stopped, got %s: %v", CacheDBPath, err)
+	}
+}
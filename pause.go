@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// PauseFile pauses scheduled runs while it exists, next to the cache database. Its
+// content, if any, is the reason shown in the status.
+const PauseFile = "red-rss.pause"
+
+// PauseState tells whether scheduled runs are paused and why
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitzero"`
+}
+
+// ReadPause returns the pause state of the pause file at path
+func ReadPause(path string) PauseState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return PauseState{}
+	}
+	reason, _ := os.ReadFile(path)
+	return PauseState{Paused: true, Reason: strings.TrimSpace(string(reason)), Since: info.ModTime()}
+}
+
+// Pause creates the pause file with a reason
+func Pause(path, reason string) error {
+	if err := os.WriteFile(path, []byte(reason+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to pause: %w", err)
+	}
+	slog.Warn("Scheduled runs paused", "reason", reason)
+	return nil
+}
+
+// Resume removes the pause file
+func Resume(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to resume: %w", err)
+	}
+	slog.Info("Scheduled runs resumed")
+	return nil
+}
+
+// handlePause pauses scheduled runs, with an optional JSON body: {"reason": ...}
+func (s *FeedServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := Pause(s.pauseFile, request.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ReadPause(s.pauseFile))
+}
+
+// handleResume resumes scheduled runs
+func (s *FeedServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	if err := Resume(s.pauseFile); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ReadPause(s.pauseFile))
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RunOptions holds per-run overrides of the configuration
+type RunOptions struct {
+	MinScore int    // Minimum score, overrides the config when >= 0
+	Limit    int    // Maximum number of items, 0 for no limit
+	Consumer string // Name used in the shared Reddit API budget, e.g. the tenant
+	Updated  string // Feed updated time semantics, overrides the config when set
+	Offline  bool   // Don't contact Reddit, e.g. when rebuilding a feed from history
+	Cached   bool   // Only use cached previews of linked pages instead of fetching them
+
+	Subreddit     string   // Subreddit to generate the feed of instead of the homepage
+	Subscriptions []string // Subscribed subreddits to generate the merged feed of instead
+	Users         []string // Users to generate the feed of their posts of instead
+	Collection    string   // SavedFeed, UpvotedFeed or RepliesFeed to generate the feed of the user's saved or upvoted posts or replies instead
+	Profile       string   // Name of the feeds entry the run generates, empty for the base feeds
+	Schedule      string   // Feed the run is scheduled as when it isn't its own, e.g. subscriptions for per-subreddit feeds
+}
+
+// DefaultRunOptions returns options that use the configuration as-is
+func DefaultRunOptions() RunOptions {
+	return RunOptions{MinScore: -1}
+}
+
+// source names the listing the run fetches in the item store
+func (opts RunOptions) source() string {
+	switch {
+	case opts.Subreddit != "":
+		return subredditSource(opts.Subreddit)
+	case len(opts.Subscriptions) > 0:
+		return SubscriptionsFeed
+	case len(opts.Users) > 0:
+		return FriendsFeed
+	case opts.Collection != "":
+		return opts.Collection
+	}
+	return HomepageSource
+}
+
+// feed names the feed of the run as in schedules and tag rules
+func (opts RunOptions) feed() string {
+	switch {
+	case opts.Profile != "":
+		return opts.Profile
+	case opts.Subreddit != "":
+		return opts.Subreddit
+	case len(opts.Subscriptions) > 0:
+		return SubscriptionsFeed
+	case len(opts.Users) > 0:
+		return FriendsFeed
+	case opts.Collection != "":
+		return opts.Collection
+	}
+	return HomepageFeed
+}
+
+// scheduledFeed names the feed the run is scheduled as in schedules
+func (opts RunOptions) scheduledFeed() string {
+	if opts.Schedule != "" {
+		return opts.Schedule
+	}
+	return opts.feed()
+}
+
+// RunResult is the outcome of a single feed generation
+type RunResult struct {
+	Content     []byte      // Serialized feed
+	ContentType string      // HTTP content type of the feed
+	Items       int         // Number of items in the feed
+	Fetched     int         // Number of posts fetched from Reddit
+	Rejected    FilterStats // Posts rejected by each filter rule
+	GeneratedAt time.Time   // When the feed was generated
+}
+
+// Pipeline runs fetch → filter → enrich → render for a single configuration. It
+// only uses the configuration it was created with, so several pipelines can run
+// side by side for different users.
+type Pipeline struct {
+	config *Config
+	api    *RedditAPI
+	db     *OpenGraphDB
+
+	commentLimiter *RateLimiter // Paces comment fetches of top_comments
+	clock          Clock        // Timestamps written to the feed
+}
+
+// NewPipeline creates a pipeline using an authenticated client and cache database
+func NewPipeline(config *Config, client *http.Client, db *OpenGraphDB) *Pipeline {
+	api := NewRedditAPI(client)
+	api.SetIdentity(config.ClientID, config.UserAgent)
+	if db != nil {
+		api.SetBackoffStore(db)
+	}
+	if config.RateLimitLedger != "" {
+		if ledger, err := RateLedgerFor(config.RateLimitLedger); err != nil {
+			slog.Warn("Failed to open rate limit ledger, limiting this process only", "error", err)
+		} else {
+			api.SetRateLedger(ledger)
+		}
+	}
+
+	return &Pipeline{
+		config:         config,
+		api:            api,
+		db:             db,
+		commentLimiter: newCommentLimiter(config),
+		clock:          defaultClock(),
+	}
+}
+
+// SetClock sets the clock the timestamps written to the feed come from
+func (p *Pipeline) SetClock(clock Clock) {
+	p.clock = clock
+}
+
+// now returns the current time of the pipeline's clock
+func (p *Pipeline) now() time.Time {
+	if p.clock == nil {
+		return time.Now()
+	}
+	return p.clock()
+}
+
+// Generate fetches, filters and enriches posts and renders the configured feed. Each
+// stage is traced as a child span of ctx.
+func (p *Pipeline) Generate(ctx context.Context, opts RunOptions) (result *RunResult, err error) {
+	if opts.Consumer != "" {
+		p.api.SetConsumer(opts.Consumer)
+	}
+
+	ctx, span := StartSpan(ctx, "generate", SpanKindInternal)
+	span.SetAttributes("tenant", opts.Consumer, "feed.type", p.config.FeedType)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	checkpoint := p.loadCheckpoint(opts)
+
+	fetchCtx, fetchSpan := StartSpan(ctx, "fetch", SpanKindInternal)
+	var posts []RedditPost
+	if checkpoint.FetchDone {
+		posts = checkpoint.Posts
+		checkpoint.Resumed = true
+		slog.Info("Resuming interrupted run", "posts", len(posts), "enriched", len(checkpoint.Enriched))
+		fetchSpan.SetAttributes("resumed", true)
+	} else {
+		slog.Debug("Fetching Reddit posts", "source", opts.source())
+		posts, err = p.fetchPosts(fetchCtx, opts, checkpoint)
+	}
+	fetchSpan.SetAttributes("posts", len(posts))
+	fetchSpan.RecordError(err)
+	fetchSpan.End()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Reddit posts: %w", err)
+	}
+	slog.Debug("Fetched Reddit posts", "count", len(posts))
+
+	if !checkpoint.FetchDone {
+		checkpoint.Posts = posts
+		checkpoint.Pages = max(checkpoint.Pages, 1)
+		checkpoint.FetchDone = true
+		p.saveCheckpoint(checkpoint)
+	}
+
+	fetched := len(posts)
+	if opts.source() == HomepageSource {
+		posts = p.withSeeds(posts)
+	}
+	if !checkpoint.Resumed {
+		p.recordHistory(opts.source(), posts)
+	}
+
+	result, err = p.build(ctx, posts, opts, checkpoint)
+	if err != nil {
+		// Only an interrupted run resumes, one that failed otherwise would fail the same
+		// way on the checkpoint every time
+		if ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+			p.clearCheckpoint(checkpoint)
+		}
+		return nil, err
+	}
+	result.Fetched = fetched
+
+	if p.db != nil {
+		p.clearCheckpoint(checkpoint)
+		p.pruneHistory()
+	}
+
+	return result, nil
+}
+
+// build filters, enriches and renders posts into the configured feed. The checkpoint,
+// if any, lets enrichment skip URLs already attempted by an interrupted run.
+func (p *Pipeline) build(ctx context.Context, posts []RedditPost, opts RunOptions, checkpoint *Checkpoint) (*RunResult, error) {
+	_, filterSpan := StartSpan(ctx, "filter", SpanKindInternal)
+	stats := FilterStats{}
+	duplicates := 0
+	for _, post := range posts {
+		duplicates += post.Data.Duplicates
+	}
+	stats.record(RuleDedup, duplicates, false)
+	fresh := p.onlyNewPosts(p.normalizeTitles(posts), opts)
+	stats.record(RuleOnlyNew, len(posts)-len(fresh), p.config.OnlyNewPosts)
+	filteredPosts := p.tagPosts(p.selectPosts(fresh, opts, stats), opts)
+	kept := len(filteredPosts)
+	filteredPosts = p.handleRemovedPosts(ctx, filteredPosts, opts)
+	stats.record(RuleRemoved, kept-len(filteredPosts), p.config.RemovedPosts == RemovedDrop)
+	if !opts.Offline {
+		filteredPosts = p.refreshPolls(ctx, filteredPosts)
+		filteredPosts = p.refreshMatchThreads(ctx, filteredPosts)
+	}
+	filteredPosts = p.scoreMatchThreads(filteredPosts, opts)
+	filteredPosts = p.attachComments(ctx, filteredPosts, opts)
+	filterSpan.SetAttributes("posts.in", len(posts), "posts.out", len(filteredPosts))
+	filterSpan.End()
+
+	ogFetcher := NewOpenGraphFetcher(p.db)
+	ogFetcher.SetCheckpoint(checkpoint)
+	ogFetcher.SetCacheOnly(opts.Cached)
+	ogFetcher.SetDomains(p.config.redditDomains(), p.config.blockedDomains())
+	ogFetcher.SetHostDelay(p.config.hostDelay())
+	feedGenerator := NewFeedGenerator(ogFetcher)
+	feedGenerator.SetClock(p.clock)
+	feedGenerator.SetUpdated(p.feedUpdated(filteredPosts, opts))
+	runID := newRunID()
+	feedOptions := p.feedOptions(opts)
+	feedOptions.Provenance = p.provenance(opts, runID)
+	feedGenerator.SetOptions(feedOptions)
+
+	enrichCtx, enrichSpan := StartSpan(ctx, "enrich", SpanKindInternal)
+	filteredPosts = p.canonicalizeLinks(p.useSelfPostLinks(filteredPosts))
+	ogData := feedGenerator.FetchOpenGraph(enrichCtx, filteredPosts)
+	filteredPosts = p.applyCanonicalLinks(filteredPosts, ogData)
+	filteredPosts = p.archivePages(enrichCtx, filteredPosts, ogFetcher, opts.Offline)
+	feedGenerator.SetImage(p.channelImage(enrichCtx, filteredPosts, ogData, ogFetcher))
+	enrichSpan.SetAttributes("previews", len(ogData))
+	enrichSpan.End()
+
+	// A run stopped during enrichment would render items without their previews, the
+	// checkpoint lets the next run pick up from here instead
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("feed generation interrupted: %w", context.Cause(ctx))
+	}
+
+	slog.Debug("Generating feed", "type", p.config.FeedType, "enhanced", p.config.EnhancedAtom, "run", runID)
+	_, renderSpan := StartSpan(ctx, "render", SpanKindInternal)
+	renderSpan.SetAttributes("run.id", runID)
+	content, err := feedGenerator.RenderFeed(filteredPosts, ogData, p.config.FeedType, p.config.EnhancedAtom)
+	renderSpan.SetAttributes("bytes", len(content))
+	renderSpan.RecordError(err)
+	renderSpan.End()
+	if err != nil {
+		return nil, err
+	}
+	p.notifyNewPosts(ctx, filteredPosts, ogData, opts)
+	p.recordEmittedPosts(filteredPosts, opts)
+	p.recordFilterStats(stats, opts)
+
+	return &RunResult{
+		Content:     content,
+		ContentType: FeedContentType(p.config.FeedType),
+		Items:       len(filteredPosts),
+		Fetched:     len(posts),
+		Rejected:    stats,
+		GeneratedAt: p.now(),
+	}, nil
+}
+
+// selectPosts returns the posts that go into the feed: pinned posts first, then the
+// posts passing the filters, up to the limit. Rejections are counted in stats.
+func (p *Pipeline) selectPosts(posts []RedditPost, opts RunOptions, stats FilterStats) []RedditPost {
+	minScore, minComments := p.engagementThresholds(opts)
+
+	rest, pinned := p.applyOverrides(posts, stats)
+	rest = p.applyContentFilters(rest, stats)
+	rest = p.applyFilterRules(rest, stats)
+	filteredPosts := filterByEngagement(rest, minScore, minComments, stats)
+	slog.Debug("Filtered posts", "count", len(filteredPosts), "minScore", minScore, "minComments", minComments)
+
+	// Apply limit if specified, pinned posts always stay
+	if opts.Limit > 0 && len(pinned)+len(filteredPosts) > opts.Limit {
+		limited := filteredPosts[:max(opts.Limit-len(pinned), 0)]
+		stats.record(RuleLimit, len(filteredPosts)-len(limited), true)
+		filteredPosts = limited
+		slog.Debug("Limited posts", "count", len(filteredPosts), "limit", opts.Limit)
+	}
+	return append(pinned, filteredPosts...)
+}
+
+// feedOptions returns how items are rendered according to the config
+func (p *Pipeline) feedOptions(opts RunOptions) FeedOptions {
+	return FeedOptions{
+		Subreddit:            opts.Subreddit,
+		Subscriptions:        len(opts.Subscriptions) > 0,
+		Friends:              len(opts.Users) > 0,
+		Collection:           opts.Collection,
+		ShowFlair:            p.config.ShowFlair,
+		ShowAwards:           p.config.ShowAwards,
+		ImageEnclosures:      p.config.ImageEnclosures,
+		PreviewEnclosures:    p.config.PreviewEnclosures,
+		ReadingTime:          p.config.ReadingTime,
+		MaxDescriptionLength: p.config.MaxDescriptionLength,
+		EventLocation:        p.config.eventLocation(),
+	}
+}
+
+// runKey identifies the feed of a run in persisted run state. Entries of feeds are
+// keyed by name, as several can share a source.
+func runKey(opts RunOptions) string {
+	consumer := opts.Consumer
+	if consumer == "" {
+		consumer = DefaultTenant
+	}
+	if opts.Profile != "" {
+		return consumer + ":feeds/" + opts.Profile
+	}
+	return consumer + ":" + opts.source()
+}
+
+// loadCheckpoint returns the progress of an interrupted run to resume, or a fresh
+// checkpoint when there is nothing to resume
+func (p *Pipeline) loadCheckpoint(opts RunOptions) *Checkpoint {
+	key := runKey(opts)
+	fresh := &Checkpoint{Key: key, Enriched: make(map[string]bool)}
+
+	if p.db == nil {
+		return fresh
+	}
+
+	checkpoint, err := p.db.LoadCheckpoint(key)
+	if err != nil {
+		slog.Warn("Failed to load checkpoint, starting over", "error", err)
+	}
+	if checkpoint != nil && err == nil {
+		return checkpoint
+	}
+
+	// Drop leftovers of expired or unreadable checkpoints
+	if err := p.db.ClearCheckpoint(key); err != nil {
+		slog.Warn("Failed to clear checkpoint", "error", err)
+	}
+	return fresh
+}
+
+// clearCheckpoint removes the fetch progress of a run that completed or can't resume
+func (p *Pipeline) clearCheckpoint(checkpoint *Checkpoint) {
+	if p.db == nil {
+		return
+	}
+	if err := p.db.ClearCheckpoint(checkpoint.Key); err != nil {
+		slog.Warn("Failed to clear checkpoint", "error", err)
+	}
+}
+
+// saveCheckpoint persists fetch progress so an interrupted run can resume
+func (p *Pipeline) saveCheckpoint(checkpoint *Checkpoint) {
+	if p.db == nil {
+		return
+	}
+	if err := p.db.SaveCheckpoint(checkpoint); err != nil {
+		slog.Warn("Failed to save checkpoint", "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// refreshPolls updates the results of open polls, which change after the post was
+// fetched, e.g. for pinned or merged posts
+func (p *Pipeline) refreshPolls(ctx context.Context, posts []RedditPost) []RedditPost {
+	var open []string
+	for _, post := range posts {
+		if post.Data.PollData != nil && post.Data.PollData.Open() {
+			open = append(open, post.Data.Name)
+		}
+	}
+	if len(open) == 0 {
+		return posts
+	}
+
+	current, err := p.api.FetchInfo(ctx, open)
+	if err != nil {
+		slog.Warn("Failed to refresh poll results", "error", err)
+		return posts
+	}
+
+	polls := make(map[string]*PollData, len(current))
+	for _, post := range current {
+		if post.Data.PollData != nil {
+			polls[post.Data.Name] = post.Data.PollData
+		}
+	}
+	for i := range posts {
+		if poll, ok := polls[posts[i].Data.Name]; ok {
+			posts[i].Data.PollData = poll
+		}
+	}
+
+	slog.Debug("Refreshed poll results", "polls", len(polls))
+	return posts
+}
+
+// pollStatus describes the number of votes and when the poll closes or closed
+func pollStatus(poll *PollData) string {
+	status := fmt.Sprintf("%d votes", poll.TotalVoteCount)
+	if poll.VotingEndTimestamp == 0 {
+		return status
+	}
+	if poll.Open() {
+		return status + ", open until " + poll.VotingEnds().UTC().Format(time.RFC1123)
+	}
+	return status + ", closed"
+}
+
+// pollOptionText renders an option with its share of the votes when known
+func pollOptionText(poll *PollData, option PollOption) string {
+	if option.VoteCount == nil {
+		return option.Text
+	}
+	percent := 0.0
+	if poll.TotalVoteCount > 0 {
+		percent = float64(*option.VoteCount) / float64(poll.TotalVoteCount) * 100
+	}
+	return fmt.Sprintf("%s: %d (%.0f%%)", option.Text, *option.VoteCount, percent)
+}
+
+// pollText renders a poll as plain text for item descriptions
+func pollText(poll *PollData) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("\n\nPoll (%s):", pollStatus(poll)))
+	for _, option := range poll.Options {
+		text.WriteString("\n- " + pollOptionText(poll, option))
+	}
+	return text.String()
+}
+
+// pollHTML renders a poll for enhanced Atom content
+func pollHTML(poll *PollData) string {
+	var html strings.Builder
+	html.WriteString(`<div class="poll"><h3>📊 Poll</h3><ul>`)
+	for _, option := range poll.Options {
+		html.WriteString(fmt.Sprintf(`<li>%s</li>`, escapeXML(pollOptionText(poll, option))))
+	}
+	html.WriteString(fmt.Sprintf(`</ul><p><em>%s</em></p></div>`, escapeXML(pollStatus(poll))))
+	return html.String()
+}
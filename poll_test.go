@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshPolls(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/api/info" || req.URL.Query().Get("id") != "t3_open" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		body := `{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_open", "poll_data": {"total_vote_count": 10,
+				"options": [{"id": "1", "text": "Yes", "vote_count": 7}, {"id": "2", "text": "No", "vote_count": 3}]}}}
+		]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	p := NewPipeline(&Config{}, client, nil)
+	open := seenPost("t3_open", 1)
+	open.Data.PollData = &PollData{
+		TotalVoteCount:     1,
+		VotingEndTimestamp: float64(time.Now().Add(time.Hour).UnixMilli()),
+		Options:            []PollOption{{ID: "1", Text: "Yes"}, {ID: "2", Text: "No"}},
+	}
+	closed := seenPost("t3_closed", 1)
+	closed.Data.PollData = &PollData{TotalVoteCount: 5, VotingEndTimestamp: float64(time.Now().Add(-time.Hour).UnixMilli())}
+
+	posts := p.refreshPolls(context.Background(), []RedditPost{open, closed, seenPost("t3_plain", 1)})
+	if poll := posts[0].Data.PollData; poll.TotalVoteCount != 10 || *poll.Options[0].VoteCount != 7 {
+		t.Errorf("expected the open poll to be refreshed, got %+v", poll)
+	}
+	if posts[1].Data.PollData.TotalVoteCount != 5 {
+		t.Errorf("expected the closed poll to be left alone")
+	}
+}
+
+func TestPollText(t *testing.T) {
+	yes, no := 3, 1
+	poll := &PollData{
+		TotalVoteCount:     4,
+		VotingEndTimestamp: float64(time.Now().Add(-time.Hour).UnixMilli()),
+		Options:            []PollOption{{Text: "Yes", VoteCount: &yes}, {Text: "No", VoteCount: &no}},
+	}
+	want := "\n\nPoll (4 votes, closed):\n- Yes: 3 (75%)\n- No: 1 (25%)"
+	if got := pollText(poll); got != want {
+		t.Errorf("pollText() = %q, want %q", got, want)
+	}
+
+	// Reddit hides the counts from users who haven't voted
+	poll.Options = []PollOption{{Text: "Yes"}, {Text: "No"}}
+	if got := pollText(poll); !strings.HasSuffix(got, "\n- Yes\n- No") {
+		t.Errorf("expected options without counts, got %q", got)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RuleFilterPrefix prefixes the names of filter rules in FilterStats, e.g.
+// "filter_rule:politics"
+const RuleFilterPrefix = "filter_rule:"
+
+// RuleKeepOnly counts the posts no keep rule matched
+const RuleKeepOnly = RuleFilterPrefix + "keep"
+
+// presetFiles holds the filter presets shipped with red-rss, one JSON file each
+//
+//go:embed presets/*.json
+var presetFiles embed.FS
+
+// FilterRule drops the posts matching any of its keywords, pattern, subreddits or
+// domains, or with keep, every post that no keep rule matches
+type FilterRule struct {
+	Name       string     `json:"name"`       // Counted under this name in the filter stats
+	Keywords   []string   `json:"keywords"`   // Whole words in the title, flair or text, case-insensitive
+	Pattern    string     `json:"pattern"`    // Regular expression matched against the title, flair and text
+	Subreddits []string   `json:"subreddits"` // Subreddits, case-insensitive
+	Domains    DomainList `json:"domains"`    // Domains of linked pages, including their subdomains
+	Keep       bool       `json:"keep"`       // Keep only posts matching a keep rule instead of dropping matches
+}
+
+// FilterPreset is a named bundle of filter rules enabled with filter_presets
+type FilterPreset struct {
+	Name        string       `json:"-"`
+	Description string       `json:"description"`
+	Rules       []FilterRule `json:"rules"`
+}
+
+// filterPresets returns the shipped presets by name
+func filterPresets() map[string]FilterPreset {
+	entries, err := presetFiles.ReadDir("presets")
+	if err != nil {
+		panic(err)
+	}
+	presets := make(map[string]FilterPreset, len(entries))
+	for _, entry := range entries {
+		data, err := presetFiles.ReadFile("presets/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		var preset FilterPreset
+		if err := json.Unmarshal(data, &preset); err != nil {
+			panic(fmt.Sprintf("invalid filter preset %s: %v", entry.Name(), err))
+		}
+		preset.Name = strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))
+		presets[preset.Name] = preset
+	}
+	return presets
+}
+
+// presetNames returns the names of the shipped presets in alphabetical order
+func presetNames() []string {
+	var names []string
+	for name := range filterPresets() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runConfigPresets lists the filter presets that filter_presets can enable
+func runConfigPresets(args []string) error {
+	fs := flag.NewFlagSet("config presets", flag.ExitOnError)
+	fs.Parse(args)
+
+	presets := filterPresets()
+	for _, name := range presetNames() {
+		fmt.Printf("%-12s %s\n", name, presets[name].Description)
+	}
+	return nil
+}
+
+// filterRules returns the rules of the enabled presets followed by the config's own
+func (c *Config) filterRules() ([]FilterRule, error) {
+	presets := filterPresets()
+	var rules []FilterRule
+	for _, name := range c.FilterPresets {
+		preset, ok := presets[name]
+		if !ok {
+			return nil, fmt.Errorf("filter_presets has unknown preset %q, available: %s", name, strings.Join(presetNames(), ", "))
+		}
+		rules = append(rules, preset.Rules...)
+	}
+	return append(rules, c.FilterRules...), nil
+}
+
+// filterMatcher is a compiled filter rule
+type filterMatcher struct {
+	rule FilterRule
+	re   *regexp.Regexp
+}
+
+// matches reports whether a post matches any criterion of the rule
+func (m filterMatcher) matches(post PostData) bool {
+	if slices.ContainsFunc(m.rule.Subreddits, func(name string) bool { return strings.EqualFold(subredditName(name), post.Subreddit) }) {
+		return true
+	}
+	if !post.IsSelf && m.rule.Domains.Matches(post.URL) {
+		return true
+	}
+	return m.re != nil && m.re.MatchString(ruleText(post))
+}
+
+// compileFilterRules checks and compiles filter rules
+func compileFilterRules(rules []FilterRule) ([]filterMatcher, error) {
+	matchers := make([]filterMatcher, 0, len(rules))
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return nil, fmt.Errorf("filter_rules[%d] has no name", i)
+		}
+		re, err := keywordRegexp(rule.Keywords, rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("filter rule %s has an invalid pattern: %w", rule.Name, err)
+		}
+		if re == nil && len(rule.Subreddits) == 0 && len(rule.Domains) == 0 {
+			return nil, fmt.Errorf("filter rule %s needs keywords, a pattern, subreddits or domains", rule.Name)
+		}
+		if err := validateDomainList("filter rule "+rule.Name, rule.Domains); err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, filterMatcher{rule: rule, re: re})
+	}
+	return matchers, nil
+}
+
+// applyFilterRules drops the posts matched by the rules of filter_presets and
+// filter_rules, counting each under the first rule that dropped it
+func (p *Pipeline) applyFilterRules(posts []RedditPost, stats FilterStats) []RedditPost {
+	rules, err := p.config.filterRules()
+	if err == nil && len(rules) == 0 {
+		return posts
+	}
+	var matchers []filterMatcher
+	if err == nil {
+		matchers, err = compileFilterRules(rules)
+	}
+	if err != nil {
+		slog.Warn("Ignoring invalid filter rules", "error", err)
+		return posts
+	}
+
+	var drop, keep []filterMatcher
+	for _, m := range matchers {
+		if m.rule.Keep {
+			keep = append(keep, m)
+		} else {
+			drop = append(drop, m)
+		}
+	}
+
+	var kept []RedditPost
+	rejected := make(map[string]int)
+	for _, post := range posts {
+		if i := slices.IndexFunc(drop, func(m filterMatcher) bool { return m.matches(post.Data) }); i >= 0 {
+			rejected[RuleFilterPrefix+drop[i].rule.Name]++
+			continue
+		}
+		if len(keep) > 0 && !slices.ContainsFunc(keep, func(m filterMatcher) bool { return m.matches(post.Data) }) {
+			rejected[RuleKeepOnly]++
+			continue
+		}
+		for _, m := range keep {
+			if m.matches(post.Data) {
+				post.Data.Matched = append(post.Data.Matched, "keep rule "+m.rule.Name)
+			}
+		}
+		kept = append(kept, post)
+	}
+
+	recorded := make(map[string]bool)
+	for _, m := range drop {
+		if name := RuleFilterPrefix + m.rule.Name; !recorded[name] {
+			stats.record(name, rejected[name], true)
+			recorded[name] = true
+		}
+	}
+	stats.record(RuleKeepOnly, rejected[RuleKeepOnly], len(keep) > 0)
+	return kept
+}
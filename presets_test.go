@@ -0,0 +1,79 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestFilterPresetsCompile(t *testing.T) {
+	for name, preset := range filterPresets() {
+		if preset.Description == "" {
+			t.Errorf("preset %s has no description", name)
+		}
+		if _, err := compileFilterRules(preset.Rules); err != nil {
+			t.Errorf("preset %s doesn't compile: %v", name, err)
+		}
+	}
+	for _, name := range []string{"tech-only", "no-politics", "high-signal"} {
+		if _, ok := filterPresets()[name]; !ok {
+			t.Errorf("expected the %s preset", name)
+		}
+	}
+}
+
+func TestApplyFilterRules(t *testing.T) {
+	post := func(name, subreddit, url string) RedditPost {
+		p := seenPost(name, 10)
+		p.Data.Subreddit = subreddit
+		p.Data.URL = url
+		return p
+	}
+	golang := post("t3_go", "golang", "https://go.dev/blog/")
+	politics := post("t3_pol", "politics", "https://example.com/a")
+	election := post("t3_vote", "technology", "https://github.com/a/b")
+	election.Data.Title = "Election results are in"
+	meme := post("t3_meme", "ProgrammerHumor", "https://i.redd.it/x.png")
+	crypto := post("t3_coin", "technology", "https://example.com/b")
+	crypto.Data.Title = "Bitcoin hits a new high"
+	recipe := post("t3_food", "cooking", "https://example.com/c")
+	posts := []RedditPost{golang, politics, election, meme, crypto, recipe}
+
+	p := &Pipeline{config: &Config{
+		FilterPresets: []string{"no-politics", "high-signal", "tech-only"},
+		FilterRules:   []FilterRule{{Name: "crypto", Keywords: []string{"bitcoin", "crypto"}}},
+	}}
+	stats := FilterStats{}
+	got := p.applyFilterRules(posts, stats)
+	if len(got) != 1 || got[0].Data.Name != "t3_go" {
+		t.Fatalf("expected only the golang post, got %v", got)
+	}
+
+	want := FilterStats{
+		RuleFilterPrefix + "no-politics":           2,
+		RuleFilterPrefix + "high-signal-memes":     1,
+		RuleFilterPrefix + "high-signal-media":     0,
+		RuleFilterPrefix + "high-signal-clickbait": 0,
+		RuleFilterPrefix + "crypto":                1,
+		RuleKeepOnly:                               1,
+	}
+	if !maps.Equal(stats, want) {
+		t.Errorf("expected stats %v, got %v", want, stats)
+	}
+}
+
+func TestValidateFilterRules(t *testing.T) {
+	tests := map[string]Config{
+		"unknown preset": {FilterPresets: []string{"no-sports"}},
+		"no name":        {FilterRules: []FilterRule{{Keywords: []string{"a"}}}},
+		"no criterion":   {FilterRules: []FilterRule{{Name: "empty"}}},
+		"bad pattern":    {FilterRules: []FilterRule{{Name: "bad", Pattern: "("}}},
+		"public suffix":  {FilterRules: []FilterRule{{Name: "tld", Domains: DomainList{"co.uk"}}}},
+	}
+	for name, config := range tests {
+		if _, err := config.filterRules(); err == nil {
+			if _, err := compileFilterRules(config.FilterRules); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	}
+}
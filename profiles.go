@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// validProfileName matches feed profile names, which are used in file names
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,49}$`)
+
+// FeedProfile is an additional feed generated from the same config, with its own
+// source and config keys overriding the base config, e.g. filters, feed_type or
+// output_path
+type FeedProfile struct {
+	Name   string          `json:"name"`   // Names the feed in file names, schedules and tag rules
+	Source string          `json:"source"` // "homepage", "r/golang" or a multireddit such as "r/golang+rust"
+	Config json.RawMessage `json:"config"` // Config keys that differ from the base config
+}
+
+// subreddit returns the subreddit or multireddit of the profile's source, empty for the homepage
+func (f FeedProfile) subreddit() string {
+	if f.Source == HomepageFeed {
+		return ""
+	}
+	return subredditName(f.Source)
+}
+
+// profileConfig returns the base config with the profile's keys applied
+func profileConfig(base *Config, profile FeedProfile) (*Config, error) {
+	config, err := cloneConfig(base)
+	if err != nil {
+		return nil, fmt.Errorf("feed %q: %w", profile.Name, err)
+	}
+	config.Feeds = nil
+	if len(profile.Config) > 0 {
+		if err := decodeConfig(profile.Config, config); err != nil {
+			return nil, fmt.Errorf("feed %q: %w", profile.Name, err)
+		}
+	}
+	if len(config.Feeds) > 0 {
+		return nil, fmt.Errorf("feed %q: feeds can't be nested", profile.Name)
+	}
+	return config, nil
+}
+
+// cloneConfig returns a deep copy of config. Decoding JSON into a shallow copy would
+// write into the slices and maps it shares with config.
+func cloneConfig(config *Config) (*Config, error) {
+	type plain Config
+	data, err := json.Marshal((*plain)(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	clone := &Config{tokensInKeyring: config.tokensInKeyring, envOverridden: config.envOverridden}
+	if err := json.Unmarshal(data, (*plain)(clone)); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	return clone, nil
+}
+
+// validateFeedProfiles checks the names and sources of the feeds and their configs
+func validateFeedProfiles(base *Config) error {
+	names := map[string]bool{HomepageFeed: true, SubscriptionsFeed: true, FriendsFeed: true, SavedFeed: true, UpvotedFeed: true, RepliesFeed: true}
+	for _, profile := range base.Feeds {
+		if !validProfileName.MatchString(profile.Name) {
+			return fmt.Errorf("feeds has an invalid name %q, use letters, digits, _ and -", profile.Name)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("feeds has the name %q more than once or uses a reserved name", profile.Name)
+		}
+		names[profile.Name] = true
+
+		if profile.Source != HomepageFeed {
+			for _, name := range strings.Split(profile.subreddit(), "+") {
+				if !strings.HasPrefix(profile.Source, "r/") || !validSubreddit.MatchString(name) {
+					return fmt.Errorf("feed %q: source must be %q, r/<subreddit> or r/<subreddit>+<subreddit>, got %q", profile.Name, HomepageFeed, profile.Source)
+				}
+			}
+		}
+
+		config, err := profileConfig(base, profile)
+		if err != nil {
+			return err
+		}
+		if err := validateConfig(config); err != nil {
+			return fmt.Errorf("feed %q: %w", profile.Name, err)
+		}
+	}
+	return nil
+}
+
+// feedProfile returns the profile of a feed by name
+func (c *Config) feedProfile(name string) (FeedProfile, bool) {
+	for _, profile := range c.Feeds {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return FeedProfile{}, false
+}
+
+// GenerateFeedProfile generates the feed of a profile and writes it next to the base
+// feed, sharing the client and cache database of the base feed. An output_path set by
+// the profile is used as is, otherwise the profile name is added to the base one.
+func GenerateFeedProfile(ctx context.Context, base *Config, profile FeedProfile, client *http.Client, db *OpenGraphDB, opts RunOptions, dir string) error {
+	config, err := profileConfig(base, profile)
+	if err != nil {
+		return err
+	}
+
+	opts.Profile = profile.Name
+	opts.Subreddit = profile.subreddit()
+	result, err := NewPipeline(config, client, db).Generate(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("feed %q: %w", profile.Name, err)
+	}
+
+	path := subredditOutputPath(config.OutputPath, dir, profile.Name, config.FeedType, time.Now())
+	if config.OutputPath != base.OutputPath {
+		path = resolveOutputPath(config.OutputPath, dir, profile.Name, config.FeedType, time.Now())
+	}
+	slog.Debug("Generated feed", "feed", profile.Name, "path", path, "items", result.Items)
+	return config.saveFeed(path, result.Content, result.ContentType)
+}
+
+// GenerateFeedProfiles generates every feed of the feeds config. A failing feed
+// doesn't stop the others.
+func GenerateFeedProfiles(ctx context.Context, base *Config, client *http.Client, db *OpenGraphDB, opts RunOptions, dir string) error {
+	failed := 0
+	for _, profile := range base.Feeds {
+		if err := GenerateFeedProfile(ctx, base, profile, client, db, opts, dir); err != nil {
+			slog.Error("Failed to generate feed", "feed", profile.Name, "error", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds failed", failed, len(base.Feeds))
+	}
+	return nil
+}
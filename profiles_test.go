@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFeedProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		feeds   string
+		wantErr string
+	}{
+		{"valid", `[{"name": "golang", "source": "r/golang", "config": {"score_filter": 100, "feed_type": "rss"}},
+			{"name": "langs", "source": "r/golang+rust"}, {"name": "all", "source": "homepage"}]`, ""},
+		{"reserved name", `[{"name": "homepage", "source": "homepage"}]`, "reserved"},
+		{"duplicate name", `[{"name": "a", "source": "homepage"}, {"name": "a", "source": "r/golang"}]`, "more than once"},
+		{"bad name", `[{"name": "../a", "source": "homepage"}]`, "invalid name"},
+		{"bad source", `[{"name": "a", "source": "golang"}]`, "source must be"},
+		{"bad multireddit", `[{"name": "a", "source": "r/golang+"}]`, "source must be"},
+		{"unknown key", `[{"name": "a", "source": "homepage", "config": {"scor_filter": 1}}]`, "did you mean"},
+		{"invalid value", `[{"name": "a", "source": "homepage", "config": {"feed_type": "html"}}]`, "feed_type"},
+		{"nested", `[{"name": "a", "source": "homepage", "config": {"feeds": [{"name": "b", "source": "homepage"}]}}]`, "nested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ClientID = "abcDEF123_-xyz"
+			if err := json.Unmarshal([]byte(tt.feeds), &config.Feeds); err != nil {
+				t.Fatalf("bad test feeds: %v", err)
+			}
+			err := validateConfig(&config)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProfileConfigCopiesBase(t *testing.T) {
+	base := DefaultConfig()
+	base.Subreddits = []string{"golang", "rust"}
+	base.FilterPresets = []string{"no-politics"}
+	base.tokensInKeyring = true
+	profile := FeedProfile{Name: "linux", Source: HomepageFeed,
+		Config: json.RawMessage(`{"subreddits": ["linux"], "filter_presets": ["no-memes"]}`)}
+
+	config, err := profileConfig(&base, profile)
+	if err != nil {
+		t.Fatalf("profileConfig failed: %v", err)
+	}
+	if strings.Join(config.Subreddits, ",") != "linux" || strings.Join(config.FilterPresets, ",") != "no-memes" || !config.tokensInKeyring {
+		t.Errorf("expected the profile's keys applied, got %v and %v", config.Subreddits, config.FilterPresets)
+	}
+	if strings.Join(base.Subreddits, ",") != "golang,rust" || strings.Join(base.FilterPresets, ",") != "no-politics" {
+		t.Errorf("expected the base config to be left alone, got %v and %v", base.Subreddits, base.FilterPresets)
+	}
+}
+
+func TestGenerateFeedProfile(t *testing.T) {
+	var paths []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		body := `{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_a", "title": "Popular", "score": 500, "url": "https://www.reddit.com/r/golang/comments/a/", "permalink": "/r/golang/comments/a/", "is_self": true}},
+			{"kind": "t3", "data": {"name": "t3_b", "title": "Quiet", "score": 5, "url": "https://www.reddit.com/r/golang/comments/b/", "permalink": "/r/golang/comments/b/", "is_self": true}}]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	dir := t.TempDir()
+	base := &Config{FeedType: "atom", OutputPath: "reddit.xml"}
+	profiles := []FeedProfile{
+		{Name: "golang", Source: "r/golang", Config: json.RawMessage(`{"score_filter": 100, "feed_type": "rss"}`)},
+		{Name: "langs", Source: "r/golang+rust", Config: json.RawMessage(`{"output_path": "langs.xml"}`)},
+	}
+	for _, profile := range profiles {
+		if err := GenerateFeedProfile(context.Background(), base, profile, client, newTestDB(t), DefaultRunOptions(), dir); err != nil {
+			t.Fatalf("GenerateFeedProfile(%s) failed: %v", profile.Name, err)
+		}
+	}
+
+	if strings.Join(paths, " ") != "/r/golang/hot /r/golang+rust/hot" {
+		t.Errorf("unexpected requests %v", paths)
+	}
+	golang, err := os.ReadFile(filepath.Join(dir, "reddit-golang.xml"))
+	if err != nil {
+		t.Fatalf("expected feed named after the profile: %v", err)
+	}
+	if !strings.Contains(string(golang), "<rss") || !strings.Contains(string(golang), "Popular") || strings.Contains(string(golang), "Quiet") {
+		t.Errorf("expected RSS feed filtered by the profile's score_filter:\n%s", golang)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "langs.xml")); err != nil {
+		t.Errorf("expected the profile's own output_path: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provenance describes the run that generated a feed, shown in the footer of its
+// items with show_provenance
+type Provenance struct {
+	RunID       string    // Identifies the run in debug logs and traces
+	Feed        string    // Feed name as in schedules, e.g. "homepage" or a subreddit
+	Source      string    // Listing the posts were fetched from, e.g. "best" or "r/golang"
+	CapturedAt  time.Time // When the scores were captured
+	MinScore    int       // score_filter the items passed, 0 for none
+	MinComments int       // comment_filter the items passed, 0 for none
+}
+
+// newRunID returns a random identifier of a run
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// provenance returns the provenance of the run's items, or nil when show_provenance
+// is off
+func (p *Pipeline) provenance(opts RunOptions, runID string) *Provenance {
+	if !p.config.ShowProvenance {
+		return nil
+	}
+	// Collections pass any score, which isn't a threshold to show
+	minScore, minComments := p.engagementThresholds(opts)
+	return &Provenance{
+		RunID:       runID,
+		Feed:        opts.feed(),
+		Source:      opts.source(),
+		CapturedAt:  p.now().UTC(),
+		MinScore:    max(minScore, 0),
+		MinComments: max(minComments, 0),
+	}
+}
+
+// provenanceNotes returns why a post is in the feed: where it came from, the filters
+// it passed and the rules that matched it
+func provenanceNotes(post RedditPost, prov *Provenance) []string {
+	notes := []string{"feed " + prov.Feed}
+	if prov.Source != prov.Feed {
+		notes[0] += " from " + prov.Source
+	}
+	notes = append(notes, fmt.Sprintf("score %d and %d comments at %s",
+		post.Data.Score, post.Data.NumComments, prov.CapturedAt.Format("2006-01-02 15:04 MST")))
+
+	var passed []string
+	if prov.MinScore > 0 {
+		passed = append(passed, fmt.Sprintf("score_filter %d", prov.MinScore))
+	}
+	if prov.MinComments > 0 {
+		passed = append(passed, fmt.Sprintf("comment_filter %d", prov.MinComments))
+	}
+	if len(passed) > 0 {
+		notes = append(notes, "passed "+strings.Join(passed, " and "))
+	}
+
+	notes = append(notes, post.Data.Matched...)
+	for _, tag := range post.Data.Tags {
+		notes = append(notes, "tagged "+tag)
+	}
+	return append(notes, "run "+prov.RunID)
+}
+
+// provenanceText returns the plain text provenance footer of an item
+func provenanceText(post RedditPost, prov *Provenance) string {
+	return "\n\nWhy you're seeing this: " + strings.Join(provenanceNotes(post, prov), " · ")
+}
+
+// provenanceHTML returns the provenance footer of an enhanced Atom entry
+func provenanceHTML(post RedditPost, prov *Provenance) string {
+	return `<p class="provenance"><small>Why you're seeing this: ` +
+		escapeXML(strings.Join(provenanceNotes(post, prov), " · ")) + `</small></p>`
+}
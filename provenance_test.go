@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenance(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	post := historyPost("t3_a", 120)
+	post.Data.Title = "Go 1.23 released"
+	post.Data.NumComments = 30
+
+	config := &Config{
+		FeedType:       "rss",
+		ScoreFilter:    100,
+		ShowProvenance: true,
+		FilterRules:    []FilterRule{{Name: "go", Keywords: []string{"go"}, Keep: true}},
+		TagRules:       []TagRule{{Tag: "release", Keywords: []string{"released"}}},
+	}
+	p := &Pipeline{config: config, clock: func() time.Time { return now }}
+	opts := DefaultRunOptions()
+	opts.Offline = true
+	opts.Subreddit = "golang"
+
+	result, err := p.build(context.Background(), []RedditPost{post}, opts, nil)
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	want := regexp.MustCompile(`Why you&#39;re seeing this: feed golang from r/golang · score 120 and 30 comments at 2024-06-01 12:00 UTC · ` +
+		`passed score_filter 100 · keep rule go · tagged release · run [0-9a-f]{8}`)
+	if !want.Match(result.Content) {
+		t.Errorf("expected the provenance footer in the item:\n%s", result.Content)
+	}
+
+	config.FeedType, config.EnhancedAtom = "atom", true
+	result, err = p.build(context.Background(), []RedditPost{post}, opts, nil)
+	if err != nil || !strings.Contains(string(result.Content), `class=&quot;provenance&quot;`) {
+		t.Errorf("expected the provenance footer in the enhanced entry, got %v:\n%s", err, result.Content)
+	}
+
+	config.ShowProvenance = false
+	result, err = p.build(context.Background(), []RedditPost{post}, opts, nil)
+	if err != nil || strings.Contains(string(result.Content), "seeing this") {
+		t.Errorf("expected no provenance when disabled, got %v:\n%s", err, result.Content)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default budget for the shared Reddit API limiter. Reddit allows 100 requests per
+// minute per client ID; staying below leaves headroom for token refreshes.
+const (
+	DefaultRedditRequestsPerMinute = 60
+	DefaultRedditBurst             = 5
+	rateLimitLowWatermark          = 5 // Pause everyone when Reddit reports fewer remaining requests
+)
+
+// DefaultRedditLimiter is shared by every RedditAPI in the process that uses the
+// default client identity, so all tenants and feeds draw from one budget
+var DefaultRedditLimiter = NewFairLimiter(DefaultRedditRequestsPerMinute, DefaultRedditBurst)
+
+// redditLimiters holds one budget per Reddit client ID, since Reddit rate limits
+// each OAuth client separately
+var redditLimiters = struct {
+	sync.Mutex
+	perMinute, burst int
+	byClient         map[string]*FairLimiter
+}{
+	perMinute: DefaultRedditRequestsPerMinute,
+	burst:     DefaultRedditBurst,
+	byClient:  make(map[string]*FairLimiter),
+}
+
+// RedditLimiterFor returns the budget shared by all API clients using clientID.
+// An empty client ID uses DefaultRedditLimiter.
+func RedditLimiterFor(clientID string) *FairLimiter {
+	if clientID == "" {
+		return DefaultRedditLimiter
+	}
+
+	redditLimiters.Lock()
+	defer redditLimiters.Unlock()
+	limiter, ok := redditLimiters.byClient[clientID]
+	if !ok {
+		limiter = NewFairLimiter(redditLimiters.perMinute, redditLimiters.burst)
+		redditLimiters.byClient[clientID] = limiter
+	}
+	return limiter
+}
+
+// SetRedditRate changes the request budget of every client ID
+func SetRedditRate(perMinute, burst int) {
+	redditLimiters.Lock()
+	defer redditLimiters.Unlock()
+	if perMinute > 0 {
+		redditLimiters.perMinute = perMinute
+	}
+	if burst > 0 {
+		redditLimiters.burst = burst
+	}
+	DefaultRedditLimiter.SetRate(perMinute, burst)
+	for _, limiter := range redditLimiters.byClient {
+		limiter.SetRate(perMinute, burst)
+	}
+}
+
+// redditRate returns the request budget per minute of each client ID
+func redditRate() int {
+	redditLimiters.Lock()
+	defer redditLimiters.Unlock()
+	return redditLimiters.perMinute
+}
+
+// FairLimiter is a token bucket shared by several consumers. When requests have to
+// wait, consumers are served round-robin so a consumer with a long queue cannot
+// starve the others.
+type FairLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // Tokens per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	queues      map[string][]*limiterWaiter
+	order       []string // Consumers with waiters, in round-robin order
+	timer       *time.Timer
+}
+
+// limiterWaiter is a queued request for a token
+type limiterWaiter struct {
+	ready     chan struct{}
+	cancelled bool
+}
+
+// NewFairLimiter creates a limiter allowing perMinute requests with the given burst
+func NewFairLimiter(perMinute, burst int) *FairLimiter {
+	if perMinute <= 0 {
+		perMinute = DefaultRedditRequestsPerMinute
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &FairLimiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		queues: make(map[string][]*limiterWaiter),
+	}
+}
+
+// SetRate changes the request budget
+func (l *FairLimiter) SetRate(perMinute, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(time.Now())
+	if perMinute > 0 {
+		l.rate = float64(perMinute) / 60
+	}
+	if burst > 0 {
+		l.burst = float64(burst)
+	}
+	l.tokens = min(l.tokens, l.burst)
+}
+
+// Wait blocks until the consumer may make a request or ctx is done
+func (l *FairLimiter) Wait(ctx context.Context, consumer string) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.refillLocked(now)
+	if len(l.order) == 0 && l.tokens >= 1 && !now.Before(l.pausedUntil) {
+		l.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+
+	waiter := &limiterWaiter{ready: make(chan struct{})}
+	if len(l.queues[consumer]) == 0 {
+		l.order = append(l.order, consumer)
+	}
+	l.queues[consumer] = append(l.queues[consumer], waiter)
+	l.scheduleLocked(now)
+	l.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-waiter.ready:
+			// Token was granted concurrently; hand it back
+			l.tokens = min(l.tokens+1, l.burst)
+		default:
+			waiter.cancelled = true
+		}
+		return ctx.Err()
+	}
+}
+
+// PauseUntil stops granting tokens to every consumer until t
+func (l *FairLimiter) PauseUntil(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+		l.tokens = 0
+	}
+}
+
+// Observe adjusts the limiter to Reddit's X-Ratelimit headers, pausing all
+// consumers until the window resets when the remaining budget runs low
+func (l *FairLimiter) Observe(header http.Header) {
+	if until, ok := rateLimitPause(header); ok {
+		slog.Warn("Reddit rate limit almost exhausted, pausing API calls",
+			"remaining", header.Get("X-Ratelimit-Remaining"), "reset_seconds", header.Get("X-Ratelimit-Reset"))
+		l.PauseUntil(until)
+	}
+}
+
+// refillLocked adds tokens for the time elapsed since the last refill
+func (l *FairLimiter) refillLocked(now time.Time) {
+	if now.Before(l.pausedUntil) {
+		l.last = now
+		return
+	}
+	start := l.last
+	if start.Before(l.pausedUntil) {
+		start = l.pausedUntil
+	}
+	l.tokens = min(l.tokens+now.Sub(start).Seconds()*l.rate, l.burst)
+	l.last = now
+}
+
+// scheduleLocked grants available tokens round-robin and arms a timer for the next one
+func (l *FairLimiter) scheduleLocked(now time.Time) {
+	for len(l.order) > 0 && l.tokens >= 1 && !now.Before(l.pausedUntil) {
+		consumer := l.order[0]
+		l.order = l.order[1:]
+
+		queue := l.queues[consumer]
+		granted := false
+		for len(queue) > 0 && !granted {
+			waiter := queue[0]
+			queue = queue[1:]
+			if waiter.cancelled {
+				continue
+			}
+			close(waiter.ready)
+			l.tokens--
+			granted = true
+		}
+
+		if len(queue) > 0 {
+			l.queues[consumer] = queue
+			l.order = append(l.order, consumer)
+		} else {
+			delete(l.queues, consumer)
+		}
+	}
+
+	if len(l.order) == 0 || l.timer != nil {
+		return
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if now.Before(l.pausedUntil) {
+		wait = l.pausedUntil.Sub(now)
+	}
+	l.timer = time.AfterFunc(wait, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		now := time.Now()
+		l.refillLocked(now)
+		l.scheduleLocked(now)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFairLimiterRoundRobin(t *testing.T) {
+	limiter := NewFairLimiter(6000, 1) // 100 per second, no burst beyond one
+	ctx := context.Background()
+
+	// Drain the initial token so every request below has to queue
+	limiter.Wait(ctx, "noisy")
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	record := func(consumer string) {
+		defer wg.Done()
+		if err := limiter.Wait(ctx, consumer); err != nil {
+			t.Errorf("wait failed: %v", err)
+			return
+		}
+		mu.Lock()
+		order = append(order, consumer)
+		mu.Unlock()
+	}
+
+	wg.Add(8)
+	for i := 0; i < 6; i++ {
+		go record("noisy")
+	}
+	time.Sleep(5 * time.Millisecond) // Let the noisy consumer queue up first
+	go record("quiet")
+	go record("quiet")
+	wg.Wait()
+
+	// The quiet consumer must not wait behind the whole noisy queue
+	lastQuiet := 0
+	for i, consumer := range order {
+		if consumer == "quiet" {
+			lastQuiet = i
+		}
+	}
+	if lastQuiet > 4 {
+		t.Errorf("quiet consumer was starved: %v", order)
+	}
+}
+
+func TestFairLimiterCancel(t *testing.T) {
+	limiter := NewFairLimiter(1, 1)
+	limiter.Wait(context.Background(), "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "a"); err == nil {
+		t.Errorf("expected context error while waiting for a token")
+	}
+}
+
+func TestFairLimiterObserve(t *testing.T) {
+	limiter := NewFairLimiter(6000, 5)
+	header := http.Header{}
+	header.Set("X-Ratelimit-Remaining", "2.0")
+	header.Set("X-Ratelimit-Reset", "60")
+	limiter.Observe(header)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "a"); err == nil {
+		t.Errorf("expected limiter to be paused after low remaining budget")
+	}
+}
+
+func TestRedditLimiterPerClientID(t *testing.T) {
+	if RedditLimiterFor("") != DefaultRedditLimiter {
+		t.Errorf("expected empty client ID to use the default limiter")
+	}
+
+	first := RedditLimiterFor("client-one")
+	if RedditLimiterFor("client-one") != first {
+		t.Errorf("expected the same client ID to share a budget")
+	}
+	if RedditLimiterFor("client-two") == first {
+		t.Errorf("expected distinct client IDs to get separate budgets")
+	}
+
+	api := NewRedditAPI(http.DefaultClient)
+	api.SetIdentity("client-one", "linux:red-rss-work:1.0 (by /u/someone)")
+	if api.limiter != first || api.userAgent != "linux:red-rss-work:1.0 (by /u/someone)" {
+		t.Errorf("SetIdentity did not apply the client identity")
+	}
+}
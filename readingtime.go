@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ReadingWordsPerMinute is the reading speed reading time estimates assume
+const ReadingWordsPerMinute = 200
+
+// articleWordCount counts the words of the paragraphs of a page's main text: those in
+// its <article>, or else its <main>, or else the whole page outside navigation,
+// headers, footers and sidebars
+func articleWordCount(doc *html.Node) int {
+	root := findElement(doc, "article")
+	if root == nil {
+		root = findElement(doc, "main")
+	}
+	if root == nil {
+		root = doc
+	}
+
+	words := 0
+	var walk func(*html.Node, bool)
+	walk = func(n *html.Node, inParagraph bool) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "nav", "header", "footer", "aside", "script", "style", "noscript":
+				return
+			case "p", "li", "blockquote", "pre":
+				inParagraph = true
+			}
+		}
+		if n.Type == html.TextNode && inParagraph {
+			words += len(strings.Fields(n.Data))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inParagraph)
+		}
+	}
+	walk(root, false)
+	return words
+}
+
+// findElement returns the first element with the given tag, depth first
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// readingMinutes estimates the minutes it takes to read a number of words
+func readingMinutes(words int) int {
+	return max(1, (words+ReadingWordsPerMinute-1)/ReadingWordsPerMinute)
+}
+
+// readingTimeLabel describes the reading time of a linked article
+func readingTimeLabel(og *OpenGraphData) string {
+	return fmt.Sprintf("%d min read (%d words)", readingMinutes(og.WordCount), og.WordCount)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadingTime(t *testing.T) {
+	page := `<html><head><title>Long read</title></head><body>
+<nav><p>Home About Contact</p></nav>
+<article><h1>Title words</h1><p>` + strings.Repeat("word ", 450) + `</p><aside><p>Related stories here</p></aside></article>
+<footer><p>Copyright notice</p></footer></body></html>`
+
+	og, err := NewOpenGraphFetcher(nil).parseOpenGraphTags(page)
+	if err != nil {
+		t.Fatalf("parseOpenGraphTags failed: %v", err)
+	}
+	if og.WordCount != 450 {
+		t.Errorf("expected 450 words, got %d", og.WordCount)
+	}
+	if got := readingMinutes(og.WordCount); got != 3 {
+		t.Errorf("expected 3 minutes, got %d", got)
+	}
+	if got := readingMinutes(10); got != 1 {
+		t.Errorf("expected short articles to take a minute, got %d", got)
+	}
+
+	post := seenPost("t3_long", 10)
+	post.Data.URL = "https://example.com/long"
+	post.Data.Permalink = "/r/test/comments/long/"
+	og.URL = post.Data.URL
+	ogData := map[string]*OpenGraphData{post.Data.URL: og}
+
+	fg := NewFeedGenerator(nil)
+	if item := fg.createFeedItem(post, ogData); strings.Contains(item.Description, "Reading time") {
+		t.Errorf("expected no reading time without reading_time")
+	}
+
+	fg.SetOptions(FeedOptions{ReadingTime: true})
+	if item := fg.createFeedItem(post, ogData); !strings.Contains(item.Description, "Reading time: 3 min read (450 words)") {
+		t.Errorf("expected the reading time in the description:\n%s", item.Description)
+	}
+	content, err := fg.RenderFeed([]RedditPost{post}, ogData, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	if !strings.Contains(string(content), `<reddit:readingTime words="450">3</reddit:readingTime>`) {
+		t.Errorf("expected a reading time element in the feed:\n%s", content)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	RunLockFile   = "red-rss.lock"       // Held by a feed generation run, next to the cache database
+	ServeLockFile = "red-rss.serve.lock" // Held by serve while it uses the default tenant, next to its cache database
+	StaleLockAge  = 2 * time.Hour        // A lock this old is stale even if its process ID was reused
+	tempFeedAge   = 10 * time.Minute     // Temporary feed files this old were left by a crashed run
+)
+
+// ErrRunLocked is returned when another feed generation run holds the run lock
+var ErrRunLocked = errors.New("another run is in progress")
+
+// RunLock keeps overlapping runs, e.g. from a slow cron job, from generating the same
+// feed at the same time
+type RunLock struct {
+	path string
+}
+
+// runLockInfo is the content of a lock file
+type runLockInfo struct {
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// AcquireRunLock takes the run lock. A lock left behind by a run that crashed or was
+// killed is recovered.
+func AcquireRunLock(path string) (*RunLock, error) {
+	data, err := json.Marshal(runLockInfo{PID: os.Getpid(), StartedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write run lock: %w", err)
+			}
+			return &RunLock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create run lock: %w", err)
+		}
+
+		info, stale := readRunLock(path)
+		if !stale {
+			return nil, fmt.Errorf("%w: process %d started at %s holds %s", ErrRunLocked, info.PID, info.StartedAt.Format(time.RFC3339), path)
+		}
+		slog.Warn("Recovered stale run lock", "path", path, "pid", info.PID, "started_at", info.StartedAt)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale run lock: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s keeps reappearing", ErrRunLocked, path)
+}
+
+// Release gives up the run lock
+func (l *RunLock) Release() {
+	if err := os.Remove(l.path); err != nil {
+		slog.Warn("Failed to remove run lock", "path", l.path, "error", err)
+	}
+}
+
+// readRunLock returns the holder of a lock and whether the lock is stale: its process
+// is gone, it is older than StaleLockAge or it is unreadable
+func readRunLock(path string) (runLockInfo, bool) {
+	var info runLockInfo
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &info) != nil || info.PID <= 0 {
+		// An empty lock is left by a crash between creating and writing it
+		stat, statErr := os.Stat(path)
+		return info, statErr != nil || time.Since(stat.ModTime()) > tempFeedAge
+	}
+	return info, time.Since(info.StartedAt) > StaleLockAge || !processRunning(info.PID)
+}
+
+// processRunning reports whether a process exists. Where that can't be checked the
+// process is assumed to be running and the lock goes stale by age.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || !(errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH))
+}
+
+// recoverTempFeeds removes the temporary feed files crashed runs left in dir
+func recoverTempFeeds(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, feedTempPattern))
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < tempFeedAge {
+			continue // Possibly being written by a running generation
+		}
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove orphaned temporary feed file", "path", path, "error", err)
+			continue
+		}
+		slog.Warn("Removed orphaned temporary feed file", "path", path, "size", info.Size())
+	}
+}
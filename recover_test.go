@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RunLockFile)
+
+	lock, err := AcquireRunLock(path)
+	if err != nil {
+		t.Fatalf("AcquireRunLock failed: %v", err)
+	}
+	if _, err := AcquireRunLock(path); !errors.Is(err, ErrRunLocked) {
+		t.Errorf("expected a held lock to be refused, got %v", err)
+	}
+	lock.Release()
+
+	// Locks of processes that are gone, and old ones, are recovered
+	stale := []runLockInfo{
+		{PID: 1 << 30, StartedAt: time.Now()},
+		{PID: os.Getpid(), StartedAt: time.Now().Add(-StaleLockAge - time.Minute)},
+	}
+	for _, info := range stale {
+		data, _ := json.Marshal(info)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		lock, err := AcquireRunLock(path)
+		if err != nil {
+			t.Fatalf("expected the stale lock %+v to be recovered, got %v", info, err)
+		}
+		lock.Release()
+	}
+}
+
+func TestRecoverTempFeeds(t *testing.T) {
+	dir := t.TempDir()
+	orphan := filepath.Join(dir, ".red-rss-feed-1.tmp")
+	current := filepath.Join(dir, ".red-rss-feed-2.tmp")
+	feed := filepath.Join(dir, "reddit.xml")
+	for _, path := range []string{orphan, current, feed} {
+		if err := os.WriteFile(path, []byte("<rss/>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(orphan, old, old)
+	os.Chtimes(feed, old, old)
+
+	recoverTempFeeds(dir)
+	if _, err := os.Stat(orphan); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the orphaned temporary file to be removed")
+	}
+	for _, path := range []string{current, feed} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
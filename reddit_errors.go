@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reddit API error kinds, matched with errors.Is
+var (
+	ErrRedditUnauthorized    = errors.New("reddit: unauthorized")     // Token invalid or revoked, re-authenticate
+	ErrRedditForbidden       = errors.New("reddit: forbidden")        // Private or quarantined content, skip the source
+	ErrRedditBlocked         = errors.New("reddit: blocked")          // Client or network blocked by Reddit, skip the source
+	ErrRedditSubredditBanned = errors.New("reddit: subreddit banned") // Banned or missing subreddit, skip the source
+	ErrRedditRateLimited     = errors.New("reddit: rate limited")     // Too many requests, retry later
+	ErrRedditServer          = errors.New("reddit: server error")     // Transient server failure, retry
+	ErrRedditRequest         = errors.New("reddit: request failed")   // Any other non-OK response
+)
+
+// RedditAPIError is a non-OK Reddit API response with the details from its JSON body
+type RedditAPIError struct {
+	StatusCode int
+	Message    string        // "message" field of the error body, or the HTTP status text
+	Reason     string        // "reason" field, e.g. "banned", "private" or "quarantined"
+	RetryAfter time.Duration // Delay requested by Reddit for rate limited requests
+	Kind       error         // One of the ErrReddit* values
+}
+
+func (e *RedditAPIError) Error() string {
+	msg := fmt.Sprintf("%s (HTTP %d", e.Kind, e.StatusCode)
+	if e.Reason != "" {
+		msg += ", reason: " + e.Reason
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg + ")"
+}
+
+func (e *RedditAPIError) Unwrap() error {
+	return e.Kind
+}
+
+// Retryable reports whether the same request may succeed when retried
+func (e *RedditAPIError) Retryable() bool {
+	return e.Kind == ErrRedditRateLimited || e.Kind == ErrRedditServer
+}
+
+// SkipSource reports whether the source can't be fetched and should be skipped
+func SkipSource(err error) bool {
+	return errors.Is(err, ErrRedditForbidden) || errors.Is(err, ErrRedditBlocked) ||
+		errors.Is(err, ErrRedditSubredditBanned)
+}
+
+// NeedsReauth reports whether the user has to authenticate again
+func NeedsReauth(err error) bool {
+	return errors.Is(err, ErrRedditUnauthorized)
+}
+
+// redditErrorBody is the JSON error format of the Reddit API
+type redditErrorBody struct {
+	Error   any    `json:"error"`
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+// parseRedditError converts a non-OK response into a RedditAPIError
+func parseRedditError(resp *http.Response) *RedditAPIError {
+	apiErr := &RedditAPIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var parsed redditErrorBody
+	isJSON := json.Unmarshal(body, &parsed) == nil
+	if isJSON {
+		if parsed.Message != "" {
+			apiErr.Message = parsed.Message
+		}
+		apiErr.Reason = parsed.Reason
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		apiErr.Kind = ErrRedditUnauthorized
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRedditRateLimited
+		apiErr.RetryAfter = retryAfter(resp.Header)
+	case apiErr.Reason == "banned":
+		apiErr.Kind = ErrRedditSubredditBanned
+	case resp.StatusCode == http.StatusForbidden && !isJSON && strings.Contains(strings.ToLower(string(body)), "blocked"):
+		// Blocked clients get an HTML page instead of a JSON error
+		apiErr.Kind = ErrRedditBlocked
+		apiErr.Message = "request blocked by Reddit, check the User-Agent and network"
+	case resp.StatusCode == http.StatusForbidden:
+		apiErr.Kind = ErrRedditForbidden
+	case resp.StatusCode >= 500:
+		apiErr.Kind = ErrRedditServer
+	default:
+		apiErr.Kind = ErrRedditRequest
+	}
+
+	return apiErr
+}
+
+// retryAfter returns the delay requested by Retry-After or X-Ratelimit-Reset
+func retryAfter(header http.Header) time.Duration {
+	for _, name := range []string{"Retry-After", "X-Ratelimit-Reset"} {
+		if seconds, err := strconv.ParseFloat(header.Get(name), 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func errorResponse(status int, body string, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestParseRedditError(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      *http.Response
+		kind      error
+		retryable bool
+		skip      bool
+		reauth    bool
+	}{
+		{"unauthorized", errorResponse(401, `{"message": "Unauthorized", "error": 401}`, nil), ErrRedditUnauthorized, false, false, true},
+		{"private", errorResponse(403, `{"reason": "private", "message": "Forbidden", "error": 403}`, nil), ErrRedditForbidden, false, true, false},
+		{"banned", errorResponse(404, `{"reason": "banned", "message": "Not Found", "error": 404}`, nil), ErrRedditSubredditBanned, false, true, false},
+		{"blocked", errorResponse(403, `<html><body>You've been blocked by network security.</body></html>`, nil), ErrRedditBlocked, false, true, false},
+		{"rate limited", errorResponse(429, `{"message": "Too Many Requests", "error": 429}`, http.Header{"X-Ratelimit-Reset": {"42"}}), ErrRedditRateLimited, true, false, false},
+		{"server", errorResponse(503, `upstream connect error`, nil), ErrRedditServer, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := parseRedditError(tt.resp)
+			err := error(apiErr)
+
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("expected %v, got %v", tt.kind, err)
+			}
+			if apiErr.Retryable() != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", apiErr.Retryable(), tt.retryable)
+			}
+			if SkipSource(err) != tt.skip {
+				t.Errorf("SkipSource() = %v, want %v", SkipSource(err), tt.skip)
+			}
+			if NeedsReauth(err) != tt.reauth {
+				t.Errorf("NeedsReauth() = %v, want %v", NeedsReauth(err), tt.reauth)
+			}
+		})
+	}
+}
+
+func TestParseRedditErrorDetails(t *testing.T) {
+	apiErr := parseRedditError(errorResponse(429, `{"message": "Too Many Requests", "error": 429}`,
+		http.Header{"X-Ratelimit-Reset": {"42"}}))
+	if apiErr.RetryAfter != 42*time.Second {
+		t.Errorf("expected RetryAfter from X-Ratelimit-Reset, got %v", apiErr.RetryAfter)
+	}
+
+	apiErr = parseRedditError(errorResponse(404, `{"reason": "banned", "message": "Not Found", "error": 404}`, nil))
+	if apiErr.Reason != "banned" || !strings.Contains(apiErr.Error(), "banned") {
+		t.Errorf("expected reason in error, got %q", apiErr.Error())
+	}
+	if !isRateLimitError(parseRedditError(errorResponse(429, "", nil))) {
+		t.Errorf("expected 429 to count as rate limit error")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RemovedCheckInterval is how often each post in the feed is checked for removal
+const RemovedCheckInterval = time.Hour
+
+// infoBatchSize is the number of posts /api/info accepts per request
+const infoBatchSize = 100
+
+// postRemoval is the last known removal state of a post
+type postRemoval struct {
+	Reason    string // Empty while the post is up
+	CheckedAt time.Time
+}
+
+// removalReason returns why a post is gone from Reddit, or "" if it is still up
+func removalReason(post RedditPost) string {
+	if post.Data.RemovedByCategory != "" {
+		return post.Data.RemovedByCategory
+	}
+	switch {
+	case post.Data.Author == "[deleted]" || post.Data.Selftext == "[deleted]":
+		return "deleted"
+	case post.Data.Selftext == "[removed]":
+		return "removed"
+	}
+	return ""
+}
+
+// FetchInfo fetches the current state of posts by fullname
+func (api *RedditAPI) FetchInfo(ctx context.Context, fullnames []string) ([]RedditPost, error) {
+	var posts []RedditPost
+	for start := 0; start < len(fullnames); start += infoBatchSize {
+		batch := fullnames[start:min(start+infoBatchSize, len(fullnames))]
+		page, err := api.FetchListing(ctx, "/api/info", url.Values{"id": {strings.Join(batch, ",")}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch post info: %w", err)
+		}
+		posts = append(posts, page...)
+	}
+	return posts, nil
+}
+
+// handleRemovedPosts checks whether the posts of the feed were removed or deleted
+// on Reddit and drops, annotates or tombstones them according to the config. Posts
+// marked in the listing need no check; the others are checked at most every
+// RemovedCheckInterval per post, and not at all in offline runs.
+func (p *Pipeline) handleRemovedPosts(ctx context.Context, posts []RedditPost, opts RunOptions) []RedditPost {
+	mode := p.config.RemovedPosts
+	if mode == "" || mode == RemovedKeep || p.db == nil {
+		return posts
+	}
+
+	names := make([]string, 0, len(posts))
+	for _, post := range posts {
+		names = append(names, post.Data.Name)
+	}
+	removals, err := p.db.GetRemovals(names)
+	if err != nil {
+		slog.Warn("Failed to load removed posts", "error", err)
+		return posts
+	}
+
+	now := time.Now()
+	var due []string
+	for _, post := range posts {
+		if reason := removalReason(post); reason != "" {
+			removals[post.Data.Name] = postRemoval{Reason: reason, CheckedAt: now}
+			if err := p.db.SaveRemoval(post.Data.Name, removals[post.Data.Name]); err != nil {
+				slog.Warn("Failed to save removed post", "post", post.Data.Name, "error", err)
+			}
+		} else if r, ok := removals[post.Data.Name]; post.Data.onReddit() && (!ok || now.Sub(r.CheckedAt) > RemovedCheckInterval) {
+			due = append(due, post.Data.Name)
+		}
+	}
+	if len(due) > 0 && !opts.Offline {
+		current, err := p.api.FetchInfo(ctx, due)
+		if err != nil {
+			slog.Warn("Failed to check for removed posts", "error", err)
+		}
+		for _, post := range current {
+			r := postRemoval{Reason: removalReason(post), CheckedAt: now}
+			removals[post.Data.Name] = r
+			if err := p.db.SaveRemoval(post.Data.Name, r); err != nil {
+				slog.Warn("Failed to save removed post", "post", post.Data.Name, "error", err)
+			}
+		}
+	}
+
+	emitted := make(map[string]bool)
+	if mode == RemovedTombstone {
+		var permalinks []string
+		for _, post := range posts {
+			if removals[post.Data.Name].Reason != "" {
+				permalinks = append(permalinks, post.Data.Permalink)
+			}
+		}
+		if emitted, err = p.db.GetEmittedPosts(runKey(opts), permalinks); err != nil {
+			slog.Warn("Failed to load emitted posts", "error", err)
+		}
+	}
+
+	var kept []RedditPost
+	for _, post := range posts {
+		reason := removals[post.Data.Name].Reason
+		switch {
+		case reason == "":
+			kept = append(kept, post)
+		case mode == RemovedTombstone && emitted[post.Data.Permalink]:
+			kept = append(kept, tombstone(post, reason))
+		case mode == RemovedDrop || mode == RemovedTombstone:
+			slog.Debug("Dropping removed post", "post", post.Data.Name, "reason", reason)
+		default:
+			post.Data.Title = removalLabel(reason) + " " + post.Data.Title
+			kept = append(kept, post)
+		}
+	}
+	return kept
+}
+
+// removalLabel returns the title prefix of a removed post
+func removalLabel(reason string) string {
+	if reason == "deleted" {
+		return "[deleted]"
+	}
+	return "[removed]"
+}
+
+// tombstone replaces the content of a removed post that was already in a feed, so
+// readers learn about the removal without keeping the removed content around
+func tombstone(post RedditPost, reason string) RedditPost {
+	post.Data.Title = removalLabel(reason) + " " + post.Data.Title
+	post.Data.Tombstone = reason
+	post.Data.URL = post.Data.discussionURL()
+	post.Data.Selftext = ""
+	post.Data.SelftextHTML = ""
+	post.Data.PollData = nil
+	post.Data.Preview = nil
+	post.Data.Thumbnail = ""
+	post.Data.IsGallery = false
+	post.Data.GalleryData = nil
+	post.Data.MediaMetadata = nil
+	return post
+}
+
+// tombstoneText describes the removal of a tombstoned post
+func tombstoneText(post RedditPost) string {
+	if post.Data.Tombstone == "deleted" {
+		return "This post was deleted by its author after it appeared in this feed."
+	}
+	return fmt.Sprintf("This post was removed from Reddit (%s) after it appeared in this feed.", post.Data.Tombstone)
+}
+
+// GetRemovals returns the last known removal state of the given posts
+func (ogDB *OpenGraphDB) GetRemovals(fullnames []string) (map[string]postRemoval, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	removals := make(map[string]postRemoval, len(fullnames))
+	for _, name := range fullnames {
+		var r postRemoval
+		var checkedAt int64
+		err := ogDB.db.QueryRow(`SELECT reason, checked_at FROM post_removals WHERE fullname = ?`, name).Scan(&r.Reason, &checkedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load removed post: %w", err)
+		}
+		r.CheckedAt = time.Unix(checkedAt, 0)
+		removals[name] = r
+	}
+	return removals, nil
+}
+
+// SaveRemoval stores the removal state of a post
+func (ogDB *OpenGraphDB) SaveRemoval(fullname string, r postRemoval) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO post_removals (fullname, reason, checked_at) VALUES (?, ?, ?)`,
+		fullname, r.Reason, r.CheckedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save removed post: %w", err)
+	}
+	return nil
+}
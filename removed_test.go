@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc serves HTTP requests with a function instead of the network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHandleRemovedPosts(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if req.URL.Path != "/api/info" || req.URL.Query().Get("id") != "t3_up,t3_mod,t3_gone" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		body := `{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_up", "title": "Up", "author": "a"}},
+			{"kind": "t3", "data": {"name": "t3_mod", "title": "Mod", "author": "b", "removed_by_category": "moderator"}},
+			{"kind": "t3", "data": {"name": "t3_gone", "title": "Gone", "author": "[deleted]"}}
+		]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	p := NewPipeline(&Config{RemovedPosts: RemovedAnnotate}, client, newTestDB(t))
+	posts := []RedditPost{seenPost("t3_up", 1), seenPost("t3_mod", 1), seenPost("t3_gone", 1)}
+
+	annotated := p.handleRemovedPosts(context.Background(), posts, DefaultRunOptions())
+	if len(annotated) != 3 {
+		t.Fatalf("expected annotate to keep all posts, got %d", len(annotated))
+	}
+	if annotated[0].Data.Title != "Post t3_up" || annotated[1].Data.Title != "[removed] Post t3_mod" ||
+		annotated[2].Data.Title != "[deleted] Post t3_gone" {
+		t.Errorf("unexpected titles: %q, %q, %q", annotated[0].Data.Title, annotated[1].Data.Title, annotated[2].Data.Title)
+	}
+
+	// Recently checked posts aren't checked again
+	p.config.RemovedPosts = RemovedDrop
+	dropped := p.handleRemovedPosts(context.Background(), posts, DefaultRunOptions())
+	if len(dropped) != 1 || dropped[0].Data.Name != "t3_up" {
+		t.Errorf("expected only t3_up to remain, got %v", dropped)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single info request, got %d", n)
+	}
+}
+
+func TestHandleRemovedPostsTombstone(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request %s", req.URL)
+		return nil, io.EOF
+	})}
+
+	db := newTestDB(t)
+	p := NewPipeline(&Config{RemovedPosts: RemovedTombstone}, client, db)
+	opts := DefaultRunOptions()
+	opts.Offline = true
+
+	posts := []RedditPost{seenPost("t3_up", 1), seenPost("t3_old", 1), seenPost("t3_new", 1)}
+	for i := range posts {
+		posts[i].Data.Permalink = "/r/test/comments/" + posts[i].Data.Name + "/"
+	}
+	posts[1].Data.RemovedByCategory = "moderator"
+	posts[1].Data.Selftext = "[removed]"
+	posts[2].Data.Author = "[deleted]"
+	if err := db.SaveEmittedPosts(runKey(opts), posts[1:2], time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Listing markers need no info request, so this works offline
+	kept := p.handleRemovedPosts(context.Background(), posts, opts)
+	if len(kept) != 2 || kept[0].Data.Name != "t3_up" || kept[1].Data.Name != "t3_old" {
+		t.Fatalf("expected t3_up and the tombstone of t3_old, got %v", kept)
+	}
+	old := kept[1].Data
+	if old.Title != "[removed] Post t3_old" || old.Tombstone != "moderator" || old.Selftext != "" ||
+		old.URL != "https://www.reddit.com/r/test/comments/t3_old/" {
+		t.Errorf("unexpected tombstone %+v", old)
+	}
+	if !strings.Contains(tombstoneText(kept[1]), "moderator") {
+		t.Errorf("unexpected tombstone text %q", tombstoneText(kept[1]))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Render rebuilds a feed from the posts of its last stored fetch with the current
+// templates and config. Nothing is fetched, not even previews of linked pages, so
+// templates can be iterated on without using API quota.
+func (p *Pipeline) Render(ctx context.Context, opts RunOptions) (*RunResult, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("rendering requires the cache database")
+	}
+
+	posts, err := LoadPostsAsOf(p.db, opts.source(), p.now())
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Offline = true
+	opts.Cached = true
+	return p.build(ctx, posts, opts, nil)
+}
+
+// renderOptions returns the config and run options that render the named feed
+func renderOptions(base *Config, name string) (*Config, RunOptions, error) {
+	opts := DefaultRunOptions()
+	switch name {
+	case HomepageFeed:
+		return base, opts, nil
+	case SavedFeed, UpvotedFeed, RepliesFeed:
+		opts.Collection = name
+		return base, opts, nil
+	case SubscriptionsFeed:
+		// Only the source of the stored fetch matters, the listings aren't fetched
+		opts.Subscriptions = []string{name}
+		return base, opts, nil
+	case FriendsFeed:
+		opts.Users = []string{name}
+		return base, opts, nil
+	}
+
+	if profile, ok := base.feedProfile(name); ok {
+		config, err := profileConfig(base, profile)
+		if err != nil {
+			return nil, opts, err
+		}
+		opts.Profile = profile.Name
+		opts.Subreddit = profile.subreddit()
+		return config, opts, nil
+	}
+	for _, subreddit := range strings.Split(name, "+") {
+		if !validSubreddit.MatchString(subreddit) {
+			return nil, opts, fmt.Errorf("unknown feed %q", name)
+		}
+	}
+	opts.Subreddit = name
+	return base, opts, nil
+}
+
+// runRender implements the render subcommand
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	feed := fs.String("feed", HomepageFeed, "feed to render: homepage, a feeds entry, a subreddit, subscriptions, friends, saved or upvoted")
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	output := fs.String("o", "", "output file (default: where the feed is normally written)")
+	limit := fs.Int("limit", 30, "maximum number of items to include in the feed")
+	fs.Parse(args)
+
+	base := DefaultConfig()
+	if err := readConfigFile(*configPath, &base); err != nil {
+		return err
+	}
+	config, opts, err := renderOptions(&base, *feed)
+	if err != nil {
+		return err
+	}
+	opts.Limit = *limit
+
+	db, err := OpenCacheDB(&base, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := NewPipeline(config, nil, db).Render(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		if outputPath, err = base.feedPath("", DefaultTenant, *feed, time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := config.saveFeed(outputPath, result.Content, result.ContentType); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rendered %s %s feed with %d items: %s\n", *feed, config.FeedType, result.Items, outputPath)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderFromStore(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`<html><head><meta property="og:title" content="Fetched"></head></html>`))
+	}))
+	defer server.Close()
+
+	db := newTestDB(t)
+	cached := historyPost("t3_a", 50)
+	cached.Data.URL = "https://example.com/cached"
+	uncached := historyPost("t3_b", 40)
+	uncached.Data.URL = server.URL + "/uncached"
+	posts := []RedditPost{cached, uncached}
+	if err := db.SaveSeenPosts(posts); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+	if err := db.RecordFetchRun(subredditSource("golang"), posts, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("RecordFetchRun failed: %v", err)
+	}
+	if err := db.SaveCachedOpenGraph(&OpenGraphData{
+		URL:         cached.Data.URL,
+		Title:       "Cached",
+		Description: "A cached preview",
+		FetchedAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("SaveCachedOpenGraph failed: %v", err)
+	}
+
+	config, opts, err := renderOptions(&Config{FeedType: "rss"}, "golang")
+	if err != nil {
+		t.Fatalf("renderOptions failed: %v", err)
+	}
+	result, err := NewPipeline(config, nil, db).Render(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.Items != 2 {
+		t.Errorf("expected both stored posts, got %d items", result.Items)
+	}
+	if !strings.Contains(string(result.Content), "A cached preview") {
+		t.Errorf("expected the cached preview in the feed:\n%s", result.Content)
+	}
+	if hits != 0 {
+		t.Errorf("expected no pages to be fetched, got %d requests", hits)
+	}
+
+	if _, opts, _ := renderOptions(config, HomepageFeed); opts.source() != HomepageSource {
+		t.Errorf("expected the homepage source, got %s", opts.source())
+	}
+	if _, err := NewPipeline(config, nil, db).Render(context.Background(), DefaultRunOptions()); err == nil {
+		t.Error("expected an error without a stored homepage fetch")
+	}
+	if _, _, err := renderOptions(config, "no such feed"); err == nil {
+		t.Error("expected an error for an unknown feed")
+	}
+}
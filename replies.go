@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// RepliesFeed names the feed of replies to the user's posts and comments
+const RepliesFeed = "replies"
+
+// replyLimit is the number of most recent inbox messages fetched for replies
+const replyLimit = 100
+
+// Inbox message types of replies
+const (
+	replyToComment = "comment_reply"
+	replyToPost    = "post_reply"
+)
+
+// inboxMessage is an item of the inbox: a private message, a reply or a mention
+type inboxMessage struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Author     string  `json:"author"`
+	Subreddit  string  `json:"subreddit"`
+	Body       string  `json:"body"`
+	BodyHTML   string  `json:"body_html"`
+	LinkTitle  string  `json:"link_title"`
+	Context    string  `json:"context"` // Permalink of the reply with its parent comments
+	Score      int     `json:"score"`
+	CreatedUTC float64 `json:"created_utc"`
+}
+
+// FetchReplies fetches the replies to the user's posts and comments from the inbox,
+// most recent first, without marking them read. It needs the privatemessages scope.
+func (api *RedditAPI) FetchReplies(ctx context.Context) ([]RedditPost, error) {
+	params := url.Values{"limit": {fmt.Sprint(replyLimit)}, "mark": {"false"}}
+	resp, err := api.get(ctx, "https://oauth.reddit.com/message/inbox?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch replies: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch replies: %w", parseRedditError(resp))
+	}
+
+	var inbox struct {
+		Data struct {
+			Children []struct {
+				Data inboxMessage `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inbox); err != nil {
+		return nil, fmt.Errorf("failed to decode replies: %w", err)
+	}
+
+	var posts []RedditPost
+	for _, child := range inbox.Data.Children {
+		unescapeStrings(reflect.ValueOf(&child.Data).Elem())
+		if child.Data.Type == replyToComment || child.Data.Type == replyToPost {
+			posts = append(posts, child.Data.post())
+		}
+	}
+	return posts, nil
+}
+
+// post converts a reply to an item linking to the reply in its thread
+func (m inboxMessage) post() RedditPost {
+	parent := "comment"
+	if m.Type == replyToPost {
+		parent = "post"
+	}
+	return RedditPost{Kind: "t1", Data: PostData{
+		ID:           m.ID,
+		Name:         m.Name,
+		Title:        fmt.Sprintf("u/%s replied to your %s in \"%s\"", m.Author, parent, m.LinkTitle),
+		URL:          "https://www.reddit.com" + m.Context,
+		Permalink:    m.Context,
+		CreatedUTC:   m.CreatedUTC,
+		Score:        m.Score,
+		Author:       m.Author,
+		Subreddit:    m.Subreddit,
+		Domain:       "self." + m.Subreddit,
+		IsSelf:       true,
+		Selftext:     m.Body,
+		SelftextHTML: m.BodyHTML,
+	}}
+}
+
+// engagementThresholds returns the minimum score and comments of posts in the feed of
+// the run. Every reply, saved or upvoted post is of interest however few votes it has.
+func (p *Pipeline) engagementThresholds(opts RunOptions) (int, int) {
+	switch opts.Collection {
+	case RepliesFeed, SavedFeed, UpvotedFeed:
+		return math.MinInt, 0
+	}
+	minScore := p.config.ScoreFilter
+	if opts.MinScore >= 0 {
+		minScore = opts.MinScore
+	}
+	return minScore, p.config.CommentFilter
+}
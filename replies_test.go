@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateReplies(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/message/inbox" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		if req.URL.Query().Get("mark") != "false" {
+			t.Errorf("expected the inbox to stay unread, got %s", req.URL)
+		}
+		body := `{"kind": "Listing", "data": {"children": [
+			{"kind": "t1", "data": {"id": "c1", "name": "t1_c1", "type": "comment_reply", "author": "bob", "subreddit": "golang",
+				"body": "Good point", "link_title": "Generics in Go: Q&amp;A", "context": "/r/golang/comments/a/generics/c1/?context=3", "score": -2, "created_utc": 200}},
+			{"kind": "t1", "data": {"id": "c2", "name": "t1_c2", "type": "post_reply", "author": "carol", "subreddit": "golang",
+				"body": "Thanks for sharing", "link_title": "My project", "context": "/r/golang/comments/b/project/c2/?context=3", "score": 1, "created_utc": 100}},
+			{"kind": "t4", "data": {"id": "m1", "name": "t4_m1", "author": "dave", "body": "Private message", "created_utc": 50}},
+			{"kind": "t1", "data": {"id": "c3", "name": "t1_c3", "type": "username_mention", "author": "erin", "body": "Mention", "created_utc": 40}}]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	dir := t.TempDir()
+	p := NewPipeline(&Config{FeedType: "rss", OutputPath: "reddit.xml", RepliesFeed: true, ScoreFilter: 10, CommentFilter: 5}, client, nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	if err := p.GenerateCollection(context.Background(), DefaultRunOptions(), dir, RepliesFeed); err != nil {
+		t.Fatalf("GenerateCollection failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "reddit-replies.xml"))
+	if err != nil {
+		t.Fatalf("expected the replies feed next to the homepage feed: %v", err)
+	}
+	for _, want := range []string{
+		"Replies to Me Feed",
+		`u/bob replied to your comment in &#34;Generics in Go: Q&amp;A&#34;`,
+		`u/carol replied to your post in &#34;My project&#34;`,
+		"https://www.reddit.com/r/golang/comments/a/generics/c1/?context=3",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in the feed:\n%s", want, content)
+		}
+	}
+	for _, unwanted := range []string{"Private message", "Mention"} {
+		if strings.Contains(string(content), unwanted) {
+			t.Errorf("expected only replies in the feed, got %q:\n%s", unwanted, content)
+		}
+	}
+
+	if scopes := newOAuth2Config(&Config{RepliesFeed: true}).Scopes; !strings.Contains(strings.Join(scopes, " "), "privatemessages") {
+		t.Errorf("expected the replies feed to request the privatemessages scope, got %v", scopes)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DefaultHistoryMaxAge is how long fetch history is kept without history_max_age
+const DefaultHistoryMaxAge = 90 * 24 * time.Hour
+
+// RetentionPolicy limits how much fetch history is kept. Zero values don't limit.
+type RetentionPolicy struct {
+	MaxAge   time.Duration // Drop history older than this
+	MaxPosts int           // Keep at most this many stored posts, the most recently seen ones
+	MaxSize  int64         // Drop the oldest history until the history tables are smaller, in bytes
+}
+
+// PruneResult is the history deleted by a prune
+type PruneResult struct {
+	Runs      int64
+	Snapshots int64
+	Posts     int64
+	Vacuumed  bool // The database file was compacted to the size limit
+}
+
+// historyTables hold the fetch history that history_max_size_mb limits, unlike the
+// caches in the same database
+var historyTables = []string{"fetch_runs", "post_snapshots", "seen_posts", "seeded_posts", "post_removals",
+	"posts_seen", "item_deliveries", "filter_rejections", "match_scores"}
+
+// Empty reports whether nothing was deleted
+func (r PruneResult) Empty() bool {
+	return r.Runs == 0 && r.Snapshots == 0 && r.Posts == 0
+}
+
+// retentionPolicy returns the retention policy of a config
+func retentionPolicy(config *Config) RetentionPolicy {
+	policy := RetentionPolicy{
+		MaxAge:   DefaultHistoryMaxAge,
+		MaxPosts: config.HistoryMaxPosts,
+		MaxSize:  int64(config.HistoryMaxSizeMB) * 1024 * 1024,
+	}
+	if config.HistoryMaxAge != "" {
+		// Validated when the config was loaded
+		policy.MaxAge, _ = time.ParseDuration(config.HistoryMaxAge)
+	}
+	return policy
+}
+
+// pruneHistory applies the configured retention policy after a run
+func (p *Pipeline) pruneHistory() {
+	if p.db == nil {
+		return
+	}
+	result, err := p.db.PruneHistory(retentionPolicy(p.config), time.Now())
+	if err != nil {
+		slog.Warn("Failed to prune history", "error", err)
+		return
+	}
+	if !result.Empty() {
+		slog.Info("Pruned history", "runs", result.Runs, "snapshots", result.Snapshots, "posts", result.Posts)
+	}
+}
+
+// PruneHistory deletes the fetch history the policy doesn't keep. Pinned and banned
+// posts are kept regardless.
+func (ogDB *OpenGraphDB) PruneHistory(policy RetentionPolicy, now time.Time) (PruneResult, error) {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	var result PruneResult
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		if err := ogDB.pruneBefore(cutoff, &result); err != nil {
+			return result, err
+		}
+		// Image sizes are a cache rather than history, so only their age drops them
+		if _, err := ogDB.db.Exec(`DELETE FROM image_sizes WHERE checked_at < ?`, cutoff.Unix()); err != nil {
+			return result, fmt.Errorf("failed to prune image sizes: %w", err)
+		}
+	}
+
+	if policy.MaxPosts > 0 {
+		var cutoff int64
+		err := ogDB.db.QueryRow(`SELECT last_seen FROM seen_posts ORDER BY last_seen DESC LIMIT 1 OFFSET ?`, policy.MaxPosts).Scan(&cutoff)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return result, fmt.Errorf("failed to find post limit: %w", err)
+		}
+		if err == nil {
+			if err := ogDB.pruneBefore(time.Unix(cutoff+1, 0), &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if policy.MaxSize > 0 {
+		if err := ogDB.pruneToSize(policy.MaxSize, &result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// pruneBefore deletes the history recorded before cutoff
+func (ogDB *OpenGraphDB) pruneBefore(cutoff time.Time, result *PruneResult) error {
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []struct {
+		query string
+		count *int64
+	}{
+		{`DELETE FROM fetch_runs WHERE fetched_at < ?`, &result.Runs},
+		{`DELETE FROM post_snapshots WHERE seen_at < ?`, &result.Snapshots},
+		{`DELETE FROM seen_posts WHERE last_seen < ? AND fullname NOT IN (SELECT fullname FROM post_overrides)`, &result.Posts},
+		{`DELETE FROM seeded_posts WHERE seeded_at < ?`, nil},
+		{`DELETE FROM post_removals WHERE checked_at < ?`, nil},
+		{`DELETE FROM posts_seen WHERE emitted_at < ?`, nil},
+		{`DELETE FROM item_deliveries WHERE delivered_at < ?`, nil},
+		{`DELETE FROM filter_rejections WHERE run_at < ?`, nil},
+		{`DELETE FROM match_scores WHERE changed_at < ?`, nil},
+	}
+	for _, step := range steps {
+		res, err := tx.Exec(step.query, cutoff.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to prune history: %w", err)
+		}
+		if step.count != nil {
+			n, _ := res.RowsAffected()
+			*step.count += n
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to prune history: %w", err)
+	}
+	return nil
+}
+
+// pruneToSize deletes the oldest tenth of the stored posts and the history up to them
+// until the history tables use less than maxSize bytes, then compacts the file once
+func (ogDB *OpenGraphDB) pruneToSize(maxSize int64, result *PruneResult) error {
+	start := *result
+	for {
+		used, err := ogDB.historySize()
+		if err != nil {
+			return err
+		}
+		if used <= maxSize {
+			break
+		}
+
+		var count int
+		if err := ogDB.db.QueryRow(`SELECT COUNT(*) FROM seen_posts`).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count posts: %w", err)
+		}
+		if count == 0 {
+			slog.Warn("History exceeds history_max_size_mb without any stored posts left", "bytes", used)
+			break
+		}
+
+		var cutoff int64
+		err = ogDB.db.QueryRow(`SELECT last_seen FROM seen_posts ORDER BY last_seen ASC LIMIT 1 OFFSET ?`, count/10).Scan(&cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to find oldest posts: %w", err)
+		}
+		before := *result
+		if err := ogDB.pruneBefore(time.Unix(cutoff+1, 0), result); err != nil {
+			return err
+		}
+		if *result == before {
+			break // Only pinned posts left
+		}
+	}
+
+	if *result == start {
+		return nil
+	}
+	if _, err := ogDB.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+	result.Vacuumed = true
+	return nil
+}
+
+// historySize returns the bytes the rows of the history tables and their indexes use,
+// leaving out the free space deleted rows leave on their pages until the next VACUUM
+func (ogDB *OpenGraphDB) historySize() (int64, error) {
+	query := `SELECT COALESCE(SUM(pgsize - unused), 0) FROM dbstat WHERE name IN
+		(SELECT name FROM sqlite_master WHERE tbl_name IN (?` + strings.Repeat(", ?", len(historyTables)-1) + `))`
+	args := make([]any, len(historyTables))
+	for i, table := range historyTables {
+		args[i] = table
+	}
+	var used int64
+	if err := ogDB.db.QueryRow(query, args...).Scan(&used); err != nil {
+		return 0, fmt.Errorf("failed to get history size: %w", err)
+	}
+	return used, nil
+}
+
+// runHistory implements the history subcommand
+func runHistory(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return fmt.Errorf("usage: red-rss history prune [-max-age 2160h] [-max-posts n] [-max-size-mb n]")
+	}
+
+	fs := flag.NewFlagSet("history prune", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	maxAge := fs.Duration("max-age", 0, "drop history older than this (default: history_max_age)")
+	maxPosts := fs.Int("max-posts", 0, "keep at most this many stored posts (default: history_max_posts)")
+	maxSizeMB := fs.Int("max-size-mb", 0, "shrink the history tables below this size (default: history_max_size_mb)")
+	fs.Parse(args[1:])
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil {
+		return err
+	}
+	policy := retentionPolicy(&config)
+	if *maxAge > 0 {
+		policy.MaxAge = *maxAge
+	}
+	if *maxPosts > 0 {
+		policy.MaxPosts = *maxPosts
+	}
+	if *maxSizeMB > 0 {
+		policy.MaxSize = int64(*maxSizeMB) * 1024 * 1024
+	}
+
+	db, err := InitOpenGraphDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.PruneHistory(policy, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %d fetch runs, %d score snapshots and %d stored posts\n", result.Runs, result.Snapshots, result.Posts)
+	if size, err := db.GetDatabaseSize(); err == nil {
+		fmt.Printf("Database size: %d KB\n", size/1024)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneHistory(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	old := now.Add(-100 * 24 * time.Hour)
+
+	posts := []RedditPost{seenPost("t3_old", 1), seenPost("t3_pinned", 1), seenPost("t3_new", 1), seenPost("t3_newer", 1)}
+	if err := db.SaveSeenPosts(posts); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordFetchRun(HomepageSource, posts[:2], old); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordFetchRun(HomepageSource, posts[2:], now); err != nil {
+		t.Fatal(err)
+	}
+	for name, at := range map[string]time.Time{"t3_old": old, "t3_pinned": old, "t3_new": now.Add(-time.Hour)} {
+		if _, err := db.db.Exec(`UPDATE seen_posts SET last_seen = ? WHERE fullname = ?`, at.Unix(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SetOverride(PostOverride{Fullname: "t3_pinned", Action: OverridePin, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.PruneHistory(RetentionPolicy{MaxAge: DefaultHistoryMaxAge}, now)
+	if err != nil {
+		t.Fatalf("PruneHistory failed: %v", err)
+	}
+	if result.Runs != 1 || result.Snapshots != 2 || result.Posts != 1 {
+		t.Errorf("expected the old run, its snapshots and the unpinned old post to go, got %+v", result)
+	}
+	if run, err := db.GetFetchRunAsOf(HomepageSource, old.Add(time.Minute)); err != nil || run != nil {
+		t.Errorf("expected the old run to be gone, got %v, %v", run, err)
+	}
+
+	// The most recently seen posts are kept, pinned ones regardless
+	result, err = db.PruneHistory(RetentionPolicy{MaxPosts: 1}, now)
+	if err != nil {
+		t.Fatalf("PruneHistory failed: %v", err)
+	}
+	if result.Posts != 1 {
+		t.Errorf("expected one post over the limit to go, got %+v", result)
+	}
+	stored, err := db.GetSeenPosts([]string{"t3_pinned", "t3_new", "t3_newer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || stored["t3_new"].Data.Name != "" {
+		t.Errorf("expected t3_pinned and t3_newer to remain, got %v", stored)
+	}
+}
+
+func TestPruneToSizeCountsHistoryOnly(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	const limit = 256 * 1024
+
+	// Caches beyond the limit don't cost any history
+	for i := range 100 {
+		og := &OpenGraphData{URL: fmt.Sprintf("https://example.com/%d", i), Description: strings.Repeat("x", 8*1024),
+			FetchedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := db.SaveCachedOpenGraph(og); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SaveSeenPosts([]RedditPost{seenPost("t3_a", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	image := &ImageSource{URL: "https://example.com/icon.png", Width: 64, Height: 64}
+	if err := db.SaveImageSize(image, now.Add(-time.Hour*24)); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := db.PruneHistory(RetentionPolicy{MaxSize: limit}, now); err != nil || !result.Empty() {
+		t.Fatalf("expected the history to be kept next to a large cache, got %+v, %v", result, err)
+	}
+
+	// History over the limit is pruned oldest first until it fits
+	for i := range 200 {
+		post := seenPost(fmt.Sprintf("t3_%d", i), 1)
+		noise := make([]byte, 2048)
+		rand.Read(noise)
+		post.Data.Selftext = hex.EncodeToString(noise)
+		if err := db.SaveSeenPosts([]RedditPost{post}); err != nil {
+			t.Fatal(err)
+		}
+		db.db.Exec(`UPDATE seen_posts SET last_seen = ? WHERE fullname = ?`, now.Add(time.Duration(i-200)*time.Minute).Unix(), post.Data.Name)
+	}
+	result, err := db.PruneHistory(RetentionPolicy{MaxSize: limit}, now)
+	if err != nil || result.Posts == 0 || !result.Vacuumed {
+		t.Fatalf("expected old posts to be pruned, got %+v, %v", result, err)
+	}
+	if used, err := db.historySize(); err != nil || used > limit {
+		t.Errorf("expected the history below %d bytes, got %d, %v", limit, used, err)
+	}
+	if stored, _ := db.GetSeenPosts([]string{"t3_0", "t3_199"}); len(stored) != 1 || stored["t3_199"].Data.Name == "" {
+		t.Errorf("expected the oldest posts to go first, got %v", stored)
+	}
+	if og, _ := db.GetCachedOpenGraph("https://example.com/0"); og == nil {
+		t.Error("expected the cache to be kept")
+	}
+	if cached, _ := db.GetImageSize(image.URL); cached == nil {
+		t.Error("expected image sizes to be kept while shrinking the history")
+	}
+
+	if _, err := db.PruneHistory(RetentionPolicy{MaxAge: time.Hour}, now); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := db.GetImageSize(image.URL); cached != nil {
+		t.Error("expected image sizes older than history_max_age to be dropped")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Feeds of the posts the user saved or upvoted
+const (
+	SavedFeed   = "saved"
+	UpvotedFeed = "upvoted"
+)
+
+// collectionPostLimit is the number of most recently saved or upvoted posts fetched
+const collectionPostLimit = 100
+
+// FetchUsername returns the name of the authenticated user. It needs the identity scope.
+func (api *RedditAPI) FetchUsername(ctx context.Context) (string, error) {
+	resp, err := api.get(ctx, "https://oauth.reddit.com/api/v1/me")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch user: %w", parseRedditError(resp))
+	}
+
+	var me struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&me); err != nil {
+		return "", fmt.Errorf("failed to decode user: %w", err)
+	}
+	if me.Name == "" {
+		return "", fmt.Errorf("failed to fetch user: no name in the response")
+	}
+	return me.Name, nil
+}
+
+// FetchUserCollection fetches the posts a user saved or upvoted, most recent first.
+// Saved comments are left out. It needs the history scope.
+func (api *RedditAPI) FetchUserCollection(ctx context.Context, user, collection string) ([]RedditPost, error) {
+	return api.FetchListing(ctx, "/user/"+user+"/"+collection, url.Values{
+		"limit": {fmt.Sprint(collectionPostLimit)},
+		"type":  {"links"},
+	})
+}
+
+// collections returns the enabled feeds of saved and upvoted posts and replies
+func (config *Config) collections() []string {
+	var names []string
+	if config.SavedFeed {
+		names = append(names, SavedFeed)
+	}
+	if config.UpvotedFeed {
+		names = append(names, UpvotedFeed)
+	}
+	if config.RepliesFeed {
+		names = append(names, RepliesFeed)
+	}
+	return names
+}
+
+// fetchCollection fetches the posts the authenticated user saved or upvoted, or the
+// replies to the user
+func (p *Pipeline) fetchCollection(ctx context.Context, collection string) ([]RedditPost, error) {
+	if collection == RepliesFeed {
+		return p.api.FetchReplies(ctx)
+	}
+	user, err := p.api.FetchUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.api.FetchUserCollection(ctx, user, collection)
+}
+
+// GenerateCollection generates the feed of saved or upvoted posts or replies and writes
+// it next to the homepage feed
+func (p *Pipeline) GenerateCollection(ctx context.Context, opts RunOptions, dir, collection string) error {
+	opts.Collection = collection
+	result, err := p.Generate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	path := subredditOutputPath(p.config.OutputPath, dir, collection, p.config.FeedType, time.Now())
+	slog.Debug("Generated "+collection+" feed", "path", path, "items", result.Items)
+	return p.config.saveFeed(path, result.Content, result.ContentType)
+}
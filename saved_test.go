@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCollection(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := ""
+		switch req.URL.Path {
+		case "/api/v1/me":
+			body = `{"name": "alice"}`
+		case "/user/alice/saved":
+			if req.URL.Query().Get("type") != "links" {
+				t.Errorf("expected saved comments to be left out, got %s", req.URL)
+			}
+			body = `{"kind": "Listing", "data": {"children": [
+				{"kind": "t3", "data": {"name": "t3_a", "title": "Saved post", "url": "https://www.reddit.com/r/golang/comments/a/saved_post/", "permalink": "/r/golang/comments/a/saved_post/", "is_self": true, "created_utc": 100}}]}}`
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	dir := t.TempDir()
+	// Saved posts pass any score and comments
+	p := NewPipeline(&Config{FeedType: "rss", OutputPath: "reddit.xml", SavedFeed: true, ScoreFilter: 10, CommentFilter: 5}, client, nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	if err := p.GenerateCollection(context.Background(), DefaultRunOptions(), dir, SavedFeed); err != nil {
+		t.Fatalf("GenerateCollection failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "reddit-saved.xml"))
+	if err != nil {
+		t.Fatalf("expected the saved feed next to the homepage feed: %v", err)
+	}
+	for _, want := range []string{"My Saved Posts Feed", "Saved post"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in the feed:\n%s", want, content)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// nextScheduledRun returns when the feeds are next generated after a run that ended
+// at t. A schedule is an interval such as "15m" or a cron expression.
+func nextScheduledRun(schedule string, t time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(schedule); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("schedule must be a positive interval such as \"15m\"")
+		}
+		return t.Add(d), nil
+	}
+
+	cron, err := ParseCron(schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("schedule must be an interval such as \"15m\" or a cron expression: %w", err)
+	}
+	next := cron.Next(t)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never runs", schedule)
+	}
+	return next, nil
+}
+
+// MinScheduledRunWindow is the shortest time a scheduled run may take before the
+// systemd watchdog considers it hung
+const MinScheduledRunWindow = 15 * time.Minute
+
+// scheduledRunWindow returns how long a run started at start may take: until the next
+// run would be due, and at least MinScheduledRunWindow
+func scheduledRunWindow(schedule string, start time.Time) time.Duration {
+	next, err := nextScheduledRun(schedule, start)
+	if err != nil {
+		return MinScheduledRunWindow
+	}
+	return max(next.Sub(start), MinScheduledRunWindow)
+}
+
+// scheduleJitter returns the maximum random delay added to scheduled runs
+func (c *Config) scheduleJitter() time.Duration {
+	if d, err := time.ParseDuration(c.ScheduleJitter); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+// runScheduled generates the feeds right away and then on the configured schedule
+// until the process is stopped. Tokens are refreshed before each run when needed.
+// Failed runs are retried at the next scheduled time, but tokens Reddit rejected
+// stop the loop, as they need authorizing again. Canceling ctx stops the loop, and
+// the run in progress without writing incomplete feeds. The systemd watchdog is
+// pinged while a run is within its window and while waiting for the next one.
+func runScheduled(ctx context.Context, run oneShotRun) error {
+	jitter := GlobalConfig.scheduleJitter()
+	slog.Info("Generating feeds on schedule", "schedule", GlobalConfig.Schedule, "jitter", jitter)
+	notifySystemd("READY=1")
+	var deadline atomic.Int64 // When the loop is overdue unless it moved on
+	startWatchdog(func() bool { return time.Now().UnixNano() < deadline.Load() })
+	for ctx.Err() == nil {
+		deadline.Store(time.Now().Add(scheduledRunWindow(GlobalConfig.Schedule, time.Now())).UnixNano())
+		err := generateOnce(ctx, run)
+		if ctx.Err() != nil {
+			break
+		}
+		if NeedsReauth(err) {
+			return err
+		}
+		if err != nil {
+			slog.Error("Failed to generate feed", "error", err)
+		}
+		run.Backfill = nil // Seeding the feed once is enough
+
+		next, err := nextScheduledRun(GlobalConfig.Schedule, time.Now())
+		if err != nil {
+			return err
+		}
+		if GlobalConfig.MatchThreads && liveMatches.live(DefaultTenant, "") {
+			next = earliest(next, time.Now().Add(GlobalConfig.matchThreadInterval()))
+		}
+		next = next.Add(jitterDelay(jitter))
+		deadline.Store(next.Add(time.Minute).UnixNano())
+		slog.Info("Waiting for the next run", "at", next.Format(time.RFC3339))
+		notifySystemd("STATUS=Next run at " + next.Format(time.RFC3339))
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+		}
+	}
+	notifySystemd("STOPPING=1")
+	slog.Info("Stopped scheduled runs", "reason", context.Cause(ctx))
+	return nil
+}
+
+// earliest returns the earlier of two times
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextScheduledRun(t *testing.T) {
+	ended := time.Date(2024, 6, 1, 12, 7, 30, 0, time.UTC)
+	tests := map[string]time.Time{
+		"15m":          ended.Add(15 * time.Minute),
+		"*/15 * * * *": time.Date(2024, 6, 1, 12, 15, 0, 0, time.UTC),
+		"@daily":       time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+	}
+	for schedule, want := range tests {
+		got, err := nextScheduledRun(schedule, ended)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("nextScheduledRun(%q) = %v, %v; want %v", schedule, got, err, want)
+		}
+	}
+
+	for _, schedule := range []string{"0s", "-5m", "every hour", "0 0 30 2 *"} {
+		if _, err := nextScheduledRun(schedule, ended); err == nil {
+			t.Errorf("expected schedule %q to be rejected", schedule)
+		}
+	}
+
+	if window := scheduledRunWindow("2h", ended); window != 2*time.Hour {
+		t.Errorf("expected a run to take up to its interval, got %v", window)
+	}
+	if window := scheduledRunWindow("*/15 * * * *", ended); window != MinScheduledRunWindow {
+		t.Errorf("expected runs to take at least %v, got %v", MinScheduledRunWindow, window)
+	}
+
+	config := Config{ClientID: "id", FeedType: "rss", OutputPath: "a.xml", Schedule: "15m", ScheduleJitter: "2m"}
+	if err := validateConfig(&config); err != nil || config.scheduleJitter() != 2*time.Minute {
+		t.Errorf("expected a valid schedule with 2m jitter, got %v, %v", config.scheduleJitter(), err)
+	}
+	config.Schedule = "hourly"
+	if err := validateConfig(&config); err == nil {
+		t.Error("expected an invalid schedule to be rejected")
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default limits for the shared enrichment scheduler
+const (
+	DefaultMaxConcurrent = 5                      // Maximum enrichment tasks running at once
+	DefaultMaxPerHost    = 2                      // Maximum enrichment tasks running against a single host
+	DefaultHostDelay     = 500 * time.Millisecond // Time between starting OpenGraph fetches from the same host
+)
+
+// DefaultScheduler is shared by all enrichers so their limits apply globally
+var DefaultScheduler = NewWorkScheduler(DefaultMaxConcurrent, DefaultMaxPerHost)
+
+// Task is a unit of enrichment work run by the WorkScheduler
+type Task struct {
+	Host     string                    // Host the task talks to, used for per-host limits ("" for none)
+	Priority int                       // Higher priority tasks are started first
+	Deadline time.Time                 // Task is skipped if not started by then and cancelled when reached
+	Delay    time.Duration             // Time before the next task may start against Host, for politeness
+	Run      func(ctx context.Context) // Work to perform
+	Skipped  func()                    // Optional callback when the task is dropped without running
+
+	ctx  context.Context
+	seq  uint64
+	done func()
+}
+
+// WorkScheduler runs tasks with a global concurrency limit, per-host concurrency
+// limits, per-host delays between task starts, priority ordering and deadline
+// awareness. Enrichers submit their work here instead of managing their own semaphores.
+type WorkScheduler struct {
+	mu            sync.Mutex
+	queue         []*Task
+	inFlight      map[string]int
+	nextStart     map[string]time.Time // Earliest start of the next task per host, by Delay
+	wake          *time.Timer          // Dispatches again when a delayed host becomes available
+	running       int
+	seq           uint64
+	maxConcurrent int
+	maxPerHost    int
+}
+
+// NewWorkScheduler creates a scheduler with the given global and per-host limits
+func NewWorkScheduler(maxConcurrent, maxPerHost int) *WorkScheduler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrent
+	}
+	if maxPerHost <= 0 {
+		maxPerHost = maxConcurrent
+	}
+	return &WorkScheduler{
+		inFlight:      make(map[string]int),
+		nextStart:     make(map[string]time.Time),
+		maxConcurrent: maxConcurrent,
+		maxPerHost:    maxPerHost,
+	}
+}
+
+// RunBatch submits the tasks and blocks until every one of them has run or been skipped
+func (s *WorkScheduler) RunBatch(ctx context.Context, tasks []Task) {
+	var wg sync.WaitGroup
+	wg.Add(len(tasks))
+
+	s.mu.Lock()
+	for i := range tasks {
+		task := tasks[i]
+		task.ctx = ctx
+		task.done = wg.Done
+		s.seq++
+		task.seq = s.seq
+		s.queue = append(s.queue, &task)
+	}
+	s.sortQueueLocked()
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	wg.Wait()
+}
+
+// sortQueueLocked orders pending tasks by priority, then submission order
+func (s *WorkScheduler) sortQueueLocked() {
+	sort.SliceStable(s.queue, func(i, j int) bool {
+		if s.queue[i].Priority != s.queue[j].Priority {
+			return s.queue[i].Priority > s.queue[j].Priority
+		}
+		return s.queue[i].seq < s.queue[j].seq
+	})
+}
+
+// dispatchLocked starts as many eligible tasks as the limits allow
+func (s *WorkScheduler) dispatchLocked() {
+	now := time.Now()
+	var wakeAt time.Time
+	defer func() { s.wakeLocked(wakeAt, now) }()
+
+	i := 0
+	for i < len(s.queue) && s.running < s.maxConcurrent {
+		task := s.queue[i]
+
+		// Drop tasks that can no longer complete in time
+		if task.ctx.Err() != nil || (!task.Deadline.IsZero() && now.After(task.Deadline)) {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			slog.Debug("Skipping expired task", "host", task.Host, "priority", task.Priority)
+			if task.Skipped != nil {
+				task.Skipped()
+			}
+			task.done()
+			continue
+		}
+
+		if task.Host != "" && s.inFlight[task.Host] >= s.maxPerHost {
+			i++
+			continue
+		}
+		if next := s.nextStart[task.Host]; task.Host != "" && now.Before(next) {
+			if wakeAt.IsZero() || next.Before(wakeAt) {
+				wakeAt = next
+			}
+			i++
+			continue
+		}
+
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		s.running++
+		if task.Host != "" {
+			s.inFlight[task.Host]++
+			if task.Delay > 0 {
+				s.nextStart[task.Host] = now.Add(task.Delay)
+			}
+		}
+		go s.execute(task)
+	}
+
+	for host, next := range s.nextStart {
+		if !now.Before(next) {
+			delete(s.nextStart, host)
+		}
+	}
+}
+
+// wakeLocked makes sure the scheduler dispatches again at wakeAt, when a task waiting
+// for the delay of its host may start. A zero wakeAt needs no wake-up.
+func (s *WorkScheduler) wakeLocked(wakeAt, now time.Time) {
+	if wakeAt.IsZero() {
+		return
+	}
+	if s.wake != nil {
+		s.wake.Stop()
+	}
+	s.wake = time.AfterFunc(wakeAt.Sub(now), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.dispatchLocked()
+	})
+}
+
+// execute runs a single task and frees its slots afterwards
+func (s *WorkScheduler) execute(task *Task) {
+	ctx := task.ctx
+	if !task.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, task.Deadline)
+		defer cancel()
+	}
+
+	task.Run(ctx)
+
+	s.mu.Lock()
+	s.running--
+	if task.Host != "" {
+		s.inFlight[task.Host]--
+		if s.inFlight[task.Host] == 0 {
+			delete(s.inFlight, task.Host)
+		}
+	}
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	task.done()
+}
+
+// hostOf returns the host part of a URL for per-host scheduling
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
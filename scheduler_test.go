@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkSchedulerLimits(t *testing.T) {
+	scheduler := NewWorkScheduler(3, 1)
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	perHost := make(map[string]int)
+	maxPerHost := 0
+
+	var tasks []Task
+	for i := 0; i < 12; i++ {
+		host := []string{"a.example", "b.example", "c.example", "d.example"}[i%4]
+		tasks = append(tasks, Task{
+			Host: host,
+			Run: func(ctx context.Context) {
+				mu.Lock()
+				running++
+				perHost[host]++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				if perHost[host] > maxPerHost {
+					maxPerHost = perHost[host]
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				perHost[host]--
+				mu.Unlock()
+			},
+		})
+	}
+
+	scheduler.RunBatch(context.Background(), tasks)
+
+	if maxRunning > 3 {
+		t.Errorf("expected at most 3 concurrent tasks, got %d", maxRunning)
+	}
+	if maxPerHost > 1 {
+		t.Errorf("expected at most 1 task per host, got %d", maxPerHost)
+	}
+}
+
+func TestWorkSchedulerPriority(t *testing.T) {
+	scheduler := NewWorkScheduler(1, 1)
+
+	var order []int
+	var mu sync.Mutex
+	var tasks []Task
+	for _, priority := range []int{1, 5, 3} {
+		p := priority
+		tasks = append(tasks, Task{
+			Priority: p,
+			Run: func(ctx context.Context) {
+				mu.Lock()
+				order = append(order, p)
+				mu.Unlock()
+			},
+		})
+	}
+
+	scheduler.RunBatch(context.Background(), tasks)
+
+	expected := []int{5, 3, 1}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestWorkSchedulerDeadline(t *testing.T) {
+	scheduler := NewWorkScheduler(1, 1)
+
+	var ran, skipped atomic.Int32
+	tasks := []Task{
+		{
+			Priority: 2,
+			Run: func(ctx context.Context) {
+				time.Sleep(20 * time.Millisecond)
+				ran.Add(1)
+			},
+		},
+		{
+			Priority: 1,
+			Deadline: time.Now().Add(5 * time.Millisecond),
+			Run:      func(ctx context.Context) { ran.Add(1) },
+			Skipped:  func() { skipped.Add(1) },
+		},
+	}
+
+	scheduler.RunBatch(context.Background(), tasks)
+
+	if ran.Load() != 1 || skipped.Load() != 1 {
+		t.Errorf("expected 1 run and 1 skipped task, got %d run and %d skipped", ran.Load(), skipped.Load())
+	}
+}
+
+func TestWorkSchedulerHostDelay(t *testing.T) {
+	scheduler := NewWorkScheduler(5, 5)
+	const delay = 30 * time.Millisecond
+
+	var mu sync.Mutex
+	starts := make(map[string][]time.Time)
+	var tasks []Task
+	for i := 0; i < 6; i++ {
+		host := []string{"a.example", "b.example"}[i%2]
+		tasks = append(tasks, Task{
+			Host:  host,
+			Delay: delay,
+			Run: func(ctx context.Context) {
+				mu.Lock()
+				starts[host] = append(starts[host], time.Now())
+				mu.Unlock()
+			},
+		})
+	}
+
+	begin := time.Now()
+	scheduler.RunBatch(context.Background(), tasks)
+
+	for host, times := range starts {
+		if len(times) != 3 {
+			t.Fatalf("expected 3 tasks for %s, got %d", host, len(times))
+		}
+		for i := 1; i < len(times); i++ {
+			if gap := times[i].Sub(times[i-1]); gap < delay {
+				t.Errorf("tasks for %s started %v apart, want at least %v", host, gap, delay)
+			}
+		}
+	}
+	// Different hosts don't wait for each other
+	if elapsed := time.Since(begin); elapsed >= 5*delay {
+		t.Errorf("batch took %v, hosts were delayed serially", elapsed)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HomepageSource names the user's "best" homepage listing in the item store
+const HomepageSource = "best"
+
+// Differential fetching
+const (
+	DefaultFullFetchInterval = 6 * time.Hour // Full refetch interval so scores and removals catch up
+	DifferentialMaxPosts     = 100           // Posts kept in a differentially updated feed of a single page
+)
+
+// MaxListingPages is the number of 100 post pages Reddit serves of a listing
+const MaxListingPages = 10
+
+// SourceCursor remembers where the last fetch of a listing ended
+type SourceCursor struct {
+	Source      string    // Listing name, e.g. "best"
+	Newest      string    // Fullname of the newest post seen
+	Posts       []string  // Fullnames of the posts in the last feed, in listing order
+	FullFetchAt time.Time // When the listing was last fetched in full
+}
+
+// SaveSeenPosts stores the posts, keeping the time each one was first seen
+func (ogDB *OpenGraphDB) SaveSeenPosts(posts []RedditPost) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	tx, err := ogDB.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, post := range posts {
+		if post.Data.Name == "" {
+			continue
+		}
+		data, err := json.Marshal(post)
+		if err != nil {
+			return fmt.Errorf("failed to encode post: %w", err)
+		}
+		data, compressed, err := compressField(data)
+		if err != nil {
+			return err
+		}
+		data, encrypted, err := ogDB.sealField(data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO seen_posts (fullname, post, compressed, encrypted, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(fullname) DO UPDATE SET post = excluded.post, compressed = excluded.compressed, encrypted = excluded.encrypted, last_seen = excluded.last_seen`,
+			post.Data.Name, data, compressed, encrypted, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to save seen post: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSeenPosts returns the stored posts with the given fullnames, keyed by fullname
+func (ogDB *OpenGraphDB) GetSeenPosts(fullnames []string) (map[string]RedditPost, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	posts := make(map[string]RedditPost, len(fullnames))
+	for _, name := range fullnames {
+		var data []byte
+		var compressed, encrypted bool
+		err := ogDB.db.QueryRow(`SELECT post, compressed, encrypted FROM seen_posts WHERE fullname = ?`, name).Scan(&data, &compressed, &encrypted)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load seen post: %w", err)
+		}
+		if data, err = ogDB.openField(data, encrypted); err != nil {
+			return nil, err
+		}
+		if data, err = decompressField(data, compressed); err != nil {
+			return nil, err
+		}
+
+		var post RedditPost
+		if err := json.Unmarshal(data, &post); err != nil {
+			return nil, fmt.Errorf("failed to decode seen post: %w", err)
+		}
+		posts[name] = post
+	}
+
+	return posts, nil
+}
+
+// GetSourceCursor returns the cursor of a listing, or nil if it was never fetched
+func (ogDB *OpenGraphDB) GetSourceCursor(source string) (*SourceCursor, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	var posts []byte
+	var fullFetchAt int64
+	var encrypted bool
+	cursor := &SourceCursor{Source: source}
+	err := ogDB.db.QueryRow(`SELECT newest, posts, full_fetch_at, encrypted FROM source_cursors WHERE source = ?`, source).
+		Scan(&cursor.Newest, &posts, &fullFetchAt, &encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source cursor: %w", err)
+	}
+
+	cursor.FullFetchAt = time.Unix(fullFetchAt, 0)
+	if posts, err = ogDB.openField(posts, encrypted); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(posts, &cursor.Posts); err != nil {
+		return nil, fmt.Errorf("failed to decode source cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// SaveSourceCursor stores the cursor of a listing
+func (ogDB *OpenGraphDB) SaveSourceCursor(cursor *SourceCursor) error {
+	posts, err := json.Marshal(cursor.Posts)
+	if err != nil {
+		return fmt.Errorf("failed to encode source cursor: %w", err)
+	}
+
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	posts, encrypted, err := ogDB.sealField(posts)
+	if err != nil {
+		return err
+	}
+	_, err = ogDB.db.Exec(`INSERT OR REPLACE INTO source_cursors (source, newest, posts, full_fetch_at, encrypted) VALUES (?, ?, ?, ?, ?)`,
+		cursor.Source, cursor.Newest, posts, cursor.FullFetchAt.Unix(), encrypted)
+	if err != nil {
+		return fmt.Errorf("failed to save source cursor: %w", err)
+	}
+	return nil
+}
+
+// fetchPosts fetches the homepage listing, blended with other listings when configured,
+// or the subreddit, subscriptions or followed users of the run. The pipeline stores the fetched posts in the item store
+// afterwards.
+func (p *Pipeline) fetchPosts(ctx context.Context, opts RunOptions, checkpoint *Checkpoint) ([]RedditPost, error) {
+	if opts.Subreddit != "" {
+		return p.fetchMultireddit(ctx, strings.Split(opts.Subreddit, "+"))
+	}
+	if len(opts.Subscriptions) > 0 {
+		return p.fetchSubscriptionPosts(ctx, opts.Subscriptions)
+	}
+	if len(opts.Users) > 0 {
+		return p.fetchUserPosts(ctx, opts.Users)
+	}
+	if opts.Collection != "" {
+		return p.fetchCollection(ctx, opts.Collection)
+	}
+	if len(p.config.Blend) > 0 {
+		return p.fetchBlend(ctx, checkpoint)
+	}
+	return p.fetchHomepage(ctx, checkpoint)
+}
+
+// fetchHomepage fetches the homepage. In differential mode only posts submitted since
+// the newest one of the previous run are fetched from the homepage sorted by new and
+// merged with the stored posts of the last feed, with a full fetch of the ranked
+// homepage every FullFetchInterval.
+func (p *Pipeline) fetchHomepage(ctx context.Context, checkpoint *Checkpoint) ([]RedditPost, error) {
+	if !p.config.DifferentialFetch || p.db == nil {
+		return p.fetchHomepagePages(ctx, checkpoint)
+	}
+
+	source := HomepageSource
+	cursor, err := p.db.GetSourceCursor(source)
+	if err != nil {
+		slog.Warn("Failed to load source cursor, doing a full fetch", "error", err)
+	}
+
+	var posts []RedditPost
+	if cursor == nil || cursor.Newest == "" || time.Since(cursor.FullFetchAt) >= p.config.fullFetchInterval() {
+		posts, err = p.fetchHomepagePages(ctx, checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &SourceCursor{Source: source, FullFetchAt: time.Now()}
+		slog.Debug("Full fetch", "posts", len(posts))
+	} else {
+		newPosts, err := p.api.FetchRedditHomepageBefore(ctx, cursor.Newest)
+		if err != nil {
+			return nil, err
+		}
+		previous, err := p.db.GetSeenPosts(cursor.Posts)
+		if err != nil {
+			return nil, err
+		}
+		posts = mergeNewPosts(newPosts, cursor.Posts, previous, max(DifferentialMaxPosts, p.config.maxPages()*100))
+		slog.Debug("Differential fetch", "new", len(newPosts), "posts", len(posts))
+	}
+
+	if newest := newestPost(posts); newest != "" {
+		cursor.Newest = newest
+	}
+	cursor.Posts = make([]string, 0, len(posts))
+	for _, post := range posts {
+		cursor.Posts = append(cursor.Posts, post.Data.Name)
+	}
+	if err := p.db.SaveSourceCursor(cursor); err != nil {
+		slog.Warn("Failed to save source cursor", "error", err)
+	}
+
+	return posts, nil
+}
+
+// fetchHomepagePages fetches up to max_pages pages of the homepage. Each page is saved
+// to the checkpoint, so an interrupted run continues with the next page.
+func (p *Pipeline) fetchHomepagePages(ctx context.Context, checkpoint *Checkpoint) ([]RedditPost, error) {
+	previous := slices.Clone(checkpoint.Posts)
+	if checkpoint.Pages > 0 {
+		if checkpoint.After == "" {
+			return previous, nil
+		}
+		slog.Info("Resuming paged fetch", "pages", checkpoint.Pages, "posts", len(previous))
+	}
+
+	posts, err := p.api.FetchPages(ctx, "/best", p.config.maxPages()-checkpoint.Pages, checkpoint.After, func(page []RedditPost, after string) {
+		checkpoint.Posts = append(checkpoint.Posts, page...)
+		checkpoint.After = after
+		checkpoint.Pages++
+		p.saveCheckpoint(checkpoint)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Reddit homepage: %w", err)
+	}
+
+	slog.Info("Successfully fetched Reddit homepage posts", "count", len(previous)+len(posts), "pages", checkpoint.Pages)
+	return uniquePosts(append(previous, posts...)), nil
+}
+
+// newestPost returns the fullname of the most recently submitted post, the cursor of
+// the next differential fetch on /new
+func newestPost(posts []RedditPost) string {
+	var newest *RedditPost
+	for i := range posts {
+		if newest == nil || posts[i].Data.CreatedUTC > newest.Data.CreatedUTC {
+			newest = &posts[i]
+		}
+	}
+	if newest == nil {
+		return ""
+	}
+	return newest.Data.Name
+}
+
+// mergeNewPosts puts newly fetched posts in front of the previous feed's posts,
+// dropping duplicates and keeping at most maxPosts
+func mergeNewPosts(newPosts []RedditPost, previousOrder []string, previous map[string]RedditPost, maxPosts int) []RedditPost {
+	merged := make([]RedditPost, 0, len(newPosts)+len(previousOrder))
+	seen := make(map[string]bool, len(newPosts)+len(previousOrder))
+
+	for _, post := range newPosts {
+		if !seen[post.Data.Name] {
+			seen[post.Data.Name] = true
+			merged = append(merged, post)
+		}
+	}
+	for _, name := range previousOrder {
+		post, ok := previous[name]
+		if ok && !seen[name] {
+			seen[name] = true
+			merged = append(merged, post)
+		}
+	}
+
+	if len(merged) > maxPosts {
+		merged = merged[:maxPosts]
+	}
+	return merged
+}
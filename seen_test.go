@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seenPost(name string, score int) RedditPost {
+	var post RedditPost
+	post.Data.Name = name
+	post.Data.Title = "Post " + name
+	post.Data.Score = score
+	return post
+}
+
+func TestMergeNewPosts(t *testing.T) {
+	previous := map[string]RedditPost{
+		"t3_b": seenPost("t3_b", 10),
+		"t3_c": seenPost("t3_c", 5),
+	}
+	newPosts := []RedditPost{seenPost("t3_a", 1), seenPost("t3_b", 20)}
+
+	merged := mergeNewPosts(newPosts, []string{"t3_b", "t3_c", "t3_gone"}, previous, DifferentialMaxPosts)
+
+	var names []string
+	for _, post := range merged {
+		names = append(names, post.Data.Name)
+	}
+	if len(names) != 3 || names[0] != "t3_a" || names[1] != "t3_b" || names[2] != "t3_c" {
+		t.Fatalf("unexpected merge order: %v", names)
+	}
+	if merged[1].Data.Score != 20 {
+		t.Errorf("expected freshly fetched copy to win, got score %d", merged[1].Data.Score)
+	}
+}
+
+func TestSeenPostsAndCursor(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SaveSeenPosts([]RedditPost{seenPost("t3_a", 1), seenPost("t3_b", 2)}); err != nil {
+		t.Fatalf("SaveSeenPosts failed: %v", err)
+	}
+	posts, err := db.GetSeenPosts([]string{"t3_a", "t3_missing"})
+	if err != nil {
+		t.Fatalf("GetSeenPosts failed: %v", err)
+	}
+	if len(posts) != 1 || posts["t3_a"].Data.Title != "Post t3_a" {
+		t.Errorf("unexpected seen posts: %+v", posts)
+	}
+
+	if cursor, err := db.GetSourceCursor("best"); err != nil || cursor != nil {
+		t.Fatalf("expected no cursor before the first fetch, got %+v, %v", cursor, err)
+	}
+
+	fullFetch := time.Now().Truncate(time.Second)
+	cursor := &SourceCursor{Source: "best", Newest: "t3_a", Posts: []string{"t3_a", "t3_b"}, FullFetchAt: fullFetch}
+	if err := db.SaveSourceCursor(cursor); err != nil {
+		t.Fatalf("SaveSourceCursor failed: %v", err)
+	}
+	loaded, err := db.GetSourceCursor("best")
+	if err != nil || loaded == nil {
+		t.Fatalf("GetSourceCursor failed: %v", err)
+	}
+	if loaded.Newest != "t3_a" || len(loaded.Posts) != 2 || !loaded.FullFetchAt.Equal(fullFetch) {
+		t.Errorf("unexpected cursor: %+v", loaded)
+	}
+}
+
+func TestDifferentialFetchUsesNew(t *testing.T) {
+	var requests []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.Path+"?"+req.URL.RawQuery)
+		// The ranked homepage lists an older post first
+		children := `{"kind": "t3", "data": {"name": "t3_old", "created_utc": 100}}, {"kind": "t3", "data": {"name": "t3_recent", "created_utc": 200}}`
+		if req.URL.Path == "/new" {
+			children = `{"kind": "t3", "data": {"name": "t3_newer", "created_utc": 300}}`
+		}
+		body := `{"kind": "Listing", "data": {"children": [` + children + `]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	p := NewPipeline(&Config{DifferentialFetch: true, MaxPages: 1}, client, newTestDB(t))
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	for range 2 {
+		posts, err := p.fetchHomepage(context.Background(), &Checkpoint{Enriched: map[string]bool{}})
+		if err != nil {
+			t.Fatalf("fetchHomepage failed: %v", err)
+		}
+		p.db.SaveSeenPosts(posts)
+	}
+	if len(requests) != 2 || !strings.HasPrefix(requests[0], "/best?") || requests[1] != "/new?before=t3_recent&limit=100" {
+		t.Errorf("expected a full fetch of /best, then /new since the newest post, got %v", requests)
+	}
+}
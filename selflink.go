@@ -0,0 +1,109 @@
+package main
+
+import (
+	"html"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Use of the outbound link of "link + commentary" self posts
+const (
+	SelfPostLinkIgnore  = "ignore"  // Leave self posts as they are
+	SelfPostLinkPreview = "preview" // Show a preview of the linked page with the post body
+	SelfPostLinkItem    = "link"    // Link the item to the linked page, the body stays in the description
+)
+
+// bareURLPattern finds links in the markdown of self posts without rendered HTML
+var bareURLPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"]+`)
+
+// selfPostLinks returns the distinct external links in the body of a self post
+func selfPostLinks(post RedditPost, reddit DomainList) []string {
+	var candidates []string
+	if post.Data.SelftextHTML != "" {
+		doc, err := nethtml.Parse(strings.NewReader(html.UnescapeString(post.Data.SelftextHTML)))
+		if err == nil {
+			var walk func(*nethtml.Node)
+			walk = func(n *nethtml.Node) {
+				if n.Type == nethtml.ElementNode && n.DataAtom == atom.A {
+					candidates = append(candidates, strings.TrimSpace(attr(n, "href")))
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+			}
+			walk(doc)
+		}
+	} else {
+		for _, link := range bareURLPattern.FindAllString(post.Data.Selftext, -1) {
+			candidates = append(candidates, strings.TrimRight(link, ".,;:!?'"))
+		}
+	}
+
+	var links []string
+	for _, link := range candidates {
+		if !isValidURL(link) || reddit.Matches(link) || !strings.HasPrefix(strings.ToLower(link), "http") {
+			continue
+		}
+		if !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// outboundLink returns the link of a self post whose body links to a single
+// external page, or "" for other posts
+func outboundLink(post RedditPost, reddit DomainList) string {
+	if !post.Data.IsSelf || post.Data.Tombstone != "" || post.Data.PollData != nil || post.Data.IsGallery {
+		return ""
+	}
+	if links := selfPostLinks(post, reddit); len(links) == 1 {
+		return links[0]
+	}
+	return ""
+}
+
+// useSelfPostLinks applies self_post_links to the self posts linking to a single page
+func (p *Pipeline) useSelfPostLinks(posts []RedditPost) []RedditPost {
+	mode := p.config.SelfPostLinks
+	if mode == "" || mode == SelfPostLinkIgnore {
+		return posts
+	}
+	for i, post := range posts {
+		link := outboundLink(post, p.config.redditDomains())
+		if link == "" {
+			continue
+		}
+		slog.Debug("Found the outbound link of a self post", "post", post.Data.Name, "link", link)
+		if mode == SelfPostLinkItem {
+			posts[i].Data.URL = link
+		} else {
+			posts[i].Data.OutboundURL = link
+		}
+	}
+	return posts
+}
+
+// outboundLinks returns the outbound links of self posts found by useSelfPostLinks
+func outboundLinks(posts []RedditPost) []string {
+	var links []string
+	for _, post := range posts {
+		if post.Data.OutboundURL != "" {
+			links = append(links, post.Data.OutboundURL)
+		}
+	}
+	return links
+}
+
+// previewLink returns the link whose OpenGraph preview is shown with a post
+func previewLink(post RedditPost) string {
+	if post.Data.OutboundURL != "" {
+		return post.Data.OutboundURL
+	}
+	return post.Data.URL
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSelfPostLinks(t *testing.T) {
+	selfPost := func(name, body, bodyHTML string) RedditPost {
+		post := seenPost(name, 10)
+		post.Data.IsSelf = true
+		post.Data.URL = "https://www.reddit.com/r/test/comments/" + name + "/"
+		post.Data.Selftext = body
+		post.Data.SelftextHTML = bodyHTML
+		return post
+	}
+
+	single := selfPost("t3_single", "", `&lt;div class="md"&gt;&lt;p&gt;Worth a read: &lt;a href="https://example.com/article"&gt;the article&lt;/a&gt;, see also &lt;a href="/r/golang"&gt;r/golang&lt;/a&gt;&lt;/p&gt;&lt;/div&gt;`)
+	markdown := selfPost("t3_markdown", "Found this https://example.com/post. And again https://example.com/post", "")
+	two := selfPost("t3_two", "https://example.com/a vs https://example.org/b", "")
+	plain := selfPost("t3_plain", "Just text", "")
+
+	if links := selfPostLinks(single, DefaultRedditDomains); !slices.Equal(links, []string{"https://example.com/article"}) {
+		t.Errorf("expected only the external link, got %v", links)
+	}
+	if link := outboundLink(markdown, DefaultRedditDomains); link != "https://example.com/post" {
+		t.Errorf("expected the link from the markdown, got %q", link)
+	}
+	if outboundLink(two, DefaultRedditDomains) != "" || outboundLink(plain, DefaultRedditDomains) != "" {
+		t.Errorf("expected no outbound link for posts with several or no links")
+	}
+
+	p := &Pipeline{config: &Config{SelfPostLinks: SelfPostLinkPreview}}
+	posts := p.useSelfPostLinks([]RedditPost{single, two})
+	if posts[0].Data.OutboundURL != "https://example.com/article" || posts[0].Data.URL != single.Data.URL || posts[1].Data.OutboundURL != "" {
+		t.Errorf("expected a preview link for the single link post only, got %+v", posts[0].Data)
+	}
+	if links := outboundLinks(posts); !slices.Equal(links, []string{"https://example.com/article"}) {
+		t.Errorf("expected the outbound link to be enriched, got %v", links)
+	}
+
+	fg := NewFeedGenerator(nil)
+	og := &OpenGraphData{Title: "The Article", Description: "About things"}
+	item := fg.createFeedItem(posts[0], map[string]*OpenGraphData{"https://example.com/article": og})
+	if !strings.Contains(item.Description, "Linked page: https://example.com/article") || !strings.Contains(item.Description, "Title: The Article") {
+		t.Errorf("expected the linked page and its preview, got %q", item.Description)
+	}
+
+	single.Data.OutboundURL = ""
+	p.config.SelfPostLinks = SelfPostLinkItem
+	posts = p.useSelfPostLinks([]RedditPost{single})
+	if posts[0].Data.URL != "https://example.com/article" || posts[0].Data.OutboundURL != "" {
+		t.Errorf("expected the linked page as the item link, got %+v", posts[0].Data)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// selftextAllowedElements are the elements kept in rendered self-post bodies, with
+// the attributes kept on them. Reddit's markdown only produces these.
+var selftextAllowedElements = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.A:  {"href", "title"},
+	atom.Em: nil, atom.Strong: nil, atom.I: nil, atom.B: nil, atom.Del: nil, atom.Sup: nil, atom.Sub: nil,
+	atom.Code: nil, atom.Pre: nil, atom.Blockquote: nil,
+	atom.Ul: nil, atom.Ol: nil, atom.Li: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tr: nil, atom.Th: nil, atom.Td: nil,
+}
+
+// selftextDroppedElements are removed with their content
+var selftextDroppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+}
+
+// selftextHTML returns the sanitized HTML body of a self post, or "" if it has none.
+// Reddit sends the rendered markdown HTML-escaped in selftext_html.
+func selftextHTML(post RedditPost) string {
+	if post.Data.SelftextHTML == "" {
+		return ""
+	}
+	return sanitizeHTML(html.UnescapeString(post.Data.SelftextHTML))
+}
+
+// sanitizeHTML keeps only the allowed elements and attributes of an HTML fragment.
+// Other elements are replaced by their content, and links only keep http(s) and
+// mailto URLs, with Reddit-relative links made absolute.
+func sanitizeHTML(fragment string) string {
+	context := &nethtml.Node{Type: nethtml.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := nethtml.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var buf bytes.Buffer
+	var render func(*nethtml.Node)
+	render = func(n *nethtml.Node) {
+		switch n.Type {
+		case nethtml.TextNode:
+			buf.WriteString(html.EscapeString(n.Data))
+			return
+		case nethtml.ElementNode:
+		default:
+			return // Comments and doctypes
+		}
+
+		if selftextDroppedElements[n.DataAtom] {
+			return
+		}
+		allowed, ok := selftextAllowedElements[n.DataAtom]
+		if ok {
+			buf.WriteString("<" + n.Data)
+			for _, a := range n.Attr {
+				value, keep := a.Val, false
+				for _, name := range allowed {
+					keep = keep || a.Key == name
+				}
+				if a.Key == "href" {
+					value, keep = safeLink(value)
+				}
+				if keep {
+					buf.WriteString(" " + a.Key + `="` + html.EscapeString(value) + `"`)
+				}
+			}
+			if n.DataAtom == atom.Br || n.DataAtom == atom.Hr {
+				buf.WriteString("/>")
+				return
+			}
+			buf.WriteString(">")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			render(c)
+		}
+		if ok {
+			buf.WriteString("</" + n.Data + ">")
+		}
+	}
+	for _, n := range nodes {
+		render(n)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// safeLink returns a link target usable in a feed, or false if it isn't
+func safeLink(href string) (string, bool) {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "mailto:"):
+		return href, true
+	case strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//"):
+		return "https://www.reddit.com" + href, true
+	}
+	return "", false
+}
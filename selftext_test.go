@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelftext(t *testing.T) {
+	post := seenPost("t3_text", 10)
+	post.Data.IsSelf = true
+	post.Data.Permalink = "/r/golang/comments/text/"
+	post.Data.Selftext = "Is **Go** & generics worth it?"
+	post.Data.SelftextHTML = `&lt;!-- SC_OFF --&gt;&lt;div class="md"&gt;&lt;p&gt;Is &lt;strong&gt;Go&lt;/strong&gt; &amp;amp; ` +
+		`&lt;a href="/r/golang/wiki"&gt;generics&lt;/a&gt; worth it?&lt;/p&gt;` +
+		`&lt;script&gt;alert(1)&lt;/script&gt;&lt;p onclick="x()"&gt;&lt;a href="javascript:x()"&gt;click&lt;/a&gt;&lt;/p&gt;&lt;/div&gt;&lt;!-- SC_ON --&gt;`
+
+	want := `<div><p>Is <strong>Go</strong> &amp; <a href="https://www.reddit.com/r/golang/wiki">generics</a> worth it?</p><p><a>click</a></p></div>`
+	if got := selftextHTML(post); got != want {
+		t.Errorf("unexpected body:\n got %s\nwant %s", got, want)
+	}
+
+	fg := NewFeedGenerator(nil)
+	if item := fg.createFeedItem(post, nil); !strings.Contains(item.Description, "Is **Go** & generics worth it?") {
+		t.Errorf("expected the body in the description:\n%s", item.Description)
+	}
+	content, err := fg.RenderFeed([]RedditPost{post}, nil, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	if !strings.Contains(string(content), "&lt;strong&gt;Go&lt;/strong&gt;") || strings.Contains(string(content), "alert(1)") {
+		t.Errorf("expected the sanitized body in the feed:\n%s", content)
+	}
+}
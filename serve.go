@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FeedServer serves generated feeds and the admin API in serve mode
+type FeedServer struct {
+	tenants    *TenantManager
+	adminToken string
+	pauseFile  string // Scheduled runs are skipped while it exists
+	startedAt  time.Time
+}
+
+// NewFeedServer creates a server for the given tenants. The admin API is
+// disabled when adminToken is empty.
+func NewFeedServer(tenants *TenantManager, adminToken string) *FeedServer {
+	return &FeedServer{
+		tenants:    tenants,
+		adminToken: adminToken,
+		pauseFile:  PausePath,
+		startedAt:  time.Now(),
+	}
+}
+
+// Handler returns the HTTP routes of the server
+func (s *FeedServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feed.xml", s.handleDefaultFeed)
+	mux.HandleFunc("GET /u/{tenant}/feed.xml", s.handleTenantFeed)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /callback", s.handleCallback)
+	mux.HandleFunc("GET /admin/tenants", s.requireAdmin(s.handleListTenants))
+	mux.HandleFunc("POST /admin/tenants", s.requireAdmin(s.handleCreateTenant))
+	mux.HandleFunc("POST /admin/tenants/{tenant}/auth", s.requireAdmin(s.handleBeginAuth))
+	mux.HandleFunc("POST /admin/tenants/{tenant}/refresh", s.requireAdmin(s.handleRefresh))
+	mux.HandleFunc("POST /admin/pause", s.requireAdmin(s.handlePause))
+	mux.HandleFunc("DELETE /admin/pause", s.requireAdmin(s.handleResume))
+	return mux
+}
+
+// handleDefaultFeed serves the feed generated from the local config
+func (s *FeedServer) handleDefaultFeed(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, s.tenants.Get(DefaultTenant))
+}
+
+// handleTenantFeed serves a tenant's feed
+func (s *FeedServer) handleTenantFeed(w http.ResponseWriter, r *http.Request) {
+	s.serveFeed(w, s.tenants.Get(r.PathValue("tenant")))
+}
+
+// serveFeed writes the tenant's last generated feed
+func (s *FeedServer) serveFeed(w http.ResponseWriter, t *Tenant) {
+	if t == nil {
+		http.NotFound(w, nil)
+		return
+	}
+
+	content, contentType, ok := t.Feed()
+	if !ok {
+		http.Error(w, "feed not generated yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(content)
+}
+
+// handleStatus reports daemon and tenant state
+func (s *FeedServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := struct {
+		Version   string         `json:"version"`
+		Build     BuildInfo      `json:"build"`
+		StartedAt time.Time      `json:"started_at"`
+		Pause     PauseState     `json:"pause"`
+		Tenants   []TenantStatus `json:"tenants"`
+	}{
+		Version:   Version,
+		Build:     CurrentBuild(),
+		StartedAt: s.startedAt,
+		Pause:     ReadPause(s.pauseFile),
+	}
+	for _, t := range s.tenants.List() {
+		status.Tenants = append(status.Tenants, t.Status())
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleCallback completes a tenant's OAuth flow
+func (s *FeedServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errorParam := query.Get("error"); errorParam != "" {
+		hint := describeCallbackError(errorParam)
+		slog.Error("OAuth2 callback error", "error", errorParam, "hint", hint)
+		http.Error(w, fmt.Sprintf("Authentication failed: %s. Hint: %s", errorParam, hint), http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.tenants.CompleteAuth(query.Get("state"), query.Get("code"))
+	if err != nil {
+		slog.Error("Tenant authentication failed", "error", err)
+		http.Error(w, "Authentication failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Fprintf(w, "Authentication successful for %s! You can close this browser tab.", t.Name)
+
+	// Generate the first feed right away instead of waiting for the next interval
+	s.tenants.Go(func(ctx context.Context) {
+		if err := t.Generate(ctx); err != nil {
+			slog.Warn("Initial tenant feed generation failed", "tenant", t.Name, "error", err)
+		}
+	})
+}
+
+// requireAdmin rejects requests without the admin bearer token
+func (s *FeedServer) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.Error(w, "admin API disabled", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleListTenants lists all tenants
+func (s *FeedServer) handleListTenants(w http.ResponseWriter, r *http.Request) {
+	var statuses []TenantStatus
+	for _, t := range s.tenants.List() {
+		statuses = append(statuses, t.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// handleCreateTenant creates a tenant from a JSON body: {"name": ..., "config": {...}}
+func (s *FeedServer) handleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Decode the config like a config file so mistyped keys are rejected instead of dropped
+	config := DefaultConfig()
+	if len(request.Config) > 0 {
+		if err := decodeConfig(request.Config, &config); err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	t, err := s.tenants.Create(request.Name, config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, t.Status())
+}
+
+// handleBeginAuth returns the authorization URL the tenant must open
+func (s *FeedServer) handleBeginAuth(w http.ResponseWriter, r *http.Request) {
+	authURL, err := s.tenants.BeginAuth(r.PathValue("tenant"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"auth_url": authURL})
+}
+
+// handleRefresh kicks off feed generation for a tenant
+func (s *FeedServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	t := s.tenants.Get(r.PathValue("tenant"))
+	if t == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.tenants.Go(func(ctx context.Context) {
+		if err := t.Generate(ctx); err != nil && !errors.Is(err, ErrGenerationRunning) {
+			slog.Warn("Tenant feed generation failed", "tenant", t.Name, "error", err)
+		}
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		slog.Warn("Failed to write JSON response", "error", err)
+	}
+}
+
+// runServe implements the serve subcommand
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8081", "address to listen on")
+	tenantsDir := fs.String("tenants", "", "directory with one subdirectory per tenant (enables multi-tenant mode)")
+	interval := fs.Duration("interval", 30*time.Minute, "how often feeds are regenerated")
+	maxInterval := fs.Duration("max-interval", 4*time.Hour, "longest interval feeds back off to after consecutive failures")
+	jitter := fs.Duration("jitter", 0, "maximum random delay added to every scheduled run, e.g. 2m")
+	pauseFile := fs.String("pause-file", PausePath, "scheduled runs are skipped while this file exists")
+	splay := fs.Duration("splay", 0, "window the first runs after startup are spread over, e.g. 5m")
+	publicURL := fs.String("public-url", "", "externally reachable base URL, used for tenant OAuth redirects (default http://localhost<addr>)")
+	adminToken := fs.String("admin-token", os.Getenv("RED_RSS_ADMIN_TOKEN"), "bearer token for the admin API (disabled when empty)")
+	requestsPerMinute := fs.Int("reddit-rpm", DefaultRedditRequestsPerMinute, "Reddit API requests per minute shared fairly by all tenants")
+	debug := fs.Bool("debug", false, "enable debug logging")
+	fs.Parse(args)
+
+	SetRedditRate(*requestsPerMinute, DefaultRedditBurst)
+
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+
+	if *publicURL == "" {
+		*publicURL = "http://localhost" + *addr
+		if !strings.HasPrefix(*addr, ":") {
+			*publicURL = "http://" + *addr
+		}
+	}
+	*publicURL = strings.TrimSuffix(*publicURL, "/")
+
+	manager := NewTenantManager(*tenantsDir, *publicURL)
+	defer manager.Close()
+
+	// The local config, when present, is served as the default tenant
+	if _, err := os.Stat(DefaultConfigPath); err == nil {
+		if err := manager.AddDefault(DefaultConfigPath, CacheDBPath, "."); err != nil {
+			return err
+		}
+	}
+
+	if *tenantsDir != "" {
+		if err := os.MkdirAll(*tenantsDir, 0700); err != nil {
+			return fmt.Errorf("failed to create tenants directory: %w", err)
+		}
+		if err := manager.LoadAll(); err != nil {
+			return err
+		}
+	}
+
+	if *tenantsDir == "" && manager.Get(DefaultTenant) == nil {
+		return fmt.Errorf("nothing to serve: create %s or pass -tenants", DefaultConfigPath)
+	}
+
+	// Generate all feeds on startup, then each on its schedule or every interval
+	schedule := ScheduleOptions{Interval: *interval, MaxInterval: *maxInterval, Jitter: *jitter, Splay: *splay}
+	tick := min(*interval, time.Minute)
+	var lastTick atomic.Int64
+	ctx, stop := shutdownContext()
+	defer stop()
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		wasPaused := false
+		for now := time.Now(); ctx.Err() == nil; now = <-ticker.C {
+			lastTick.Store(now.UnixNano())
+			// Paused feeds are still served, and overdue ones run once resumed
+			paused := ReadPause(*pauseFile)
+			if paused.Paused != wasPaused && paused.Paused {
+				slog.Warn("Skipping scheduled runs while paused", "file", *pauseFile, "reason", paused.Reason)
+			}
+			wasPaused = paused.Paused
+			if !paused.Paused {
+				manager.GenerateDue(now, schedule)
+			}
+		}
+	}()
+
+	// Under systemd, the listening socket can be passed by socket activation
+	listener, err := systemdListener()
+	if err != nil {
+		return err
+	}
+	if listener == nil {
+		if listener, err = net.Listen("tcp", *addr); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", *addr, err)
+		}
+	}
+
+	server := NewFeedServer(manager, *adminToken)
+	server.pauseFile = *pauseFile
+	slog.Info("Serving feeds", "addr", listener.Addr().String(), "tenants", len(manager.List()), "interval", *interval)
+	fmt.Printf("Serving feeds on %s (public URL %s)\n", listener.Addr(), *publicURL)
+
+	// The daemon is healthy while its scheduler keeps ticking
+	notifySystemd("READY=1\nSTATUS=Serving feeds on " + listener.Addr().String())
+	startWatchdog(func() bool { return time.Since(time.Unix(0, lastTick.Load())) < 2*tick })
+
+	// On SIGINT or SIGTERM, finish the requests being served, then stop the running
+	// feed generations and close the databases
+	httpServer := &http.Server{Handler: server.Handler()}
+	served := make(chan error, 1)
+	go func() { served <- httpServer.Serve(listener) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	notifySystemd("STOPPING=1")
+	slog.Info("Stopping the server", "reason", context.Cause(ctx))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop the server: %w", err)
+	}
+	return nil
+}
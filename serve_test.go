@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedServerAdminAPI(t *testing.T) {
+	manager := NewTenantManager(t.TempDir(), "https://feeds.example")
+	defer manager.Close()
+	server := httptest.NewServer(NewFeedServer(manager, "secret").Handler())
+	defer server.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+
+	if resp := do("GET", "/admin/tenants", "wrong", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", resp.StatusCode)
+	}
+
+	resp := do("POST", "/admin/tenants", "secret", `{"name": "alice", "config": {"client_id": "abcDEF123_-xyz"}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 creating tenant, got %d", resp.StatusCode)
+	}
+
+	resp = do("POST", "/admin/tenants", "secret", `{"name": "bob", "config": {"client_id": "abcDEF123_-xyz", "scorefilter": 100}}`)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), `did you mean "score_filter"?`) {
+		t.Errorf("expected 400 naming the mistyped config key, got %d: %s", resp.StatusCode, body)
+	}
+	if manager.Get("bob") != nil {
+		t.Error("expected no tenant created from a config with unknown keys")
+	}
+
+	if resp := do("POST", "/admin/tenants", "secret", `{"name": "../evil", "config": {"client_id": "abcDEF123_-xyz"}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid tenant name, got %d", resp.StatusCode)
+	}
+
+	resp = do("POST", "/admin/tenants/alice/auth", "secret", "")
+	var auth struct {
+		AuthURL string `json:"auth_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		t.Fatalf("failed to decode auth response: %v", err)
+	}
+	authURL, err := url.Parse(auth.AuthURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL %q: %v", auth.AuthURL, err)
+	}
+	if got := authURL.Query().Get("redirect_uri"); got != "https://feeds.example/callback" {
+		t.Errorf("expected tenant redirect URI, got %q", got)
+	}
+	if authURL.Query().Get("state") == "" {
+		t.Errorf("expected state in auth URL")
+	}
+
+	// No feed until the tenant has been authorized and generated
+	if resp := do("GET", "/u/alice/feed.xml", "", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before first generation, got %d", resp.StatusCode)
+	}
+	if resp := do("GET", "/u/bob/feed.xml", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown tenant, got %d", resp.StatusCode)
+	}
+}
+
+func TestFeedServedDuringRegeneration(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, ConfigFileName)
+	if err := os.WriteFile(configPath, []byte(`{"client_id": "id", "feed_type": "rss", "output_path": "reddit.xml"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewTenantManager(dir, "")
+	defer manager.Close()
+	if err := manager.AddDefault(configPath, filepath.Join(dir, "cache.db"), dir); err != nil {
+		t.Fatal(err)
+	}
+	tenant := manager.Get(DefaultTenant)
+	server := httptest.NewServer(NewFeedServer(manager, "secret").Handler())
+	defer server.Close()
+
+	// Large enough that a torn read or write would show
+	feeds := [][]byte{
+		[]byte("<rss>" + strings.Repeat("a", 256*1024) + "</rss>"),
+		[]byte("<feed>" + strings.Repeat("b", 512*1024) + "</feed>"),
+	}
+	types := []string{FeedContentType("rss"), FeedContentType("atom")}
+	tenant.publish(feeds[0], types[0], time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 50; i++ {
+			tenant.publish(feeds[i%2], types[i%2], time.Now())
+		}
+	}()
+
+	outputPath := filepath.Join(dir, "reddit.xml")
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		resp, err := http.Get(server.URL + "/feed.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		i := slices.IndexFunc(feeds, func(f []byte) bool { return bytes.Equal(f, body) })
+		if i < 0 {
+			t.Fatalf("served a partial feed of %d bytes", len(body))
+		}
+		if got := resp.Header.Get("Content-Type"); got != types[i] {
+			t.Fatalf("served feed %d with content type %q", i, got)
+		}
+
+		content, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.ContainsFunc(feeds, func(f []byte) bool { return bytes.Equal(f, content) }) {
+			t.Fatalf("read a partial feed file of %d bytes", len(content))
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestFeedServerPause(t *testing.T) {
+	manager := NewTenantManager(t.TempDir(), "https://feeds.example")
+	defer manager.Close()
+	feedServer := NewFeedServer(manager, "secret")
+	feedServer.pauseFile = filepath.Join(t.TempDir(), PauseFile)
+	server := httptest.NewServer(feedServer.Handler())
+	defer server.Close()
+
+	do := func(method, body string) PauseState {
+		req, _ := http.NewRequest(method, server.URL+"/admin/pause", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s /admin/pause failed: %v", method, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 from %s /admin/pause, got %d", method, resp.StatusCode)
+		}
+		var state PauseState
+		if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+			t.Fatalf("failed to decode pause state: %v", err)
+		}
+		return state
+	}
+
+	if state := do("POST", `{"reason": "Reddit API incident"}`); !state.Paused || state.Reason != "Reddit API incident" {
+		t.Errorf("expected paused with reason, got %+v", state)
+	}
+	if state := ReadPause(feedServer.pauseFile); !state.Paused {
+		t.Errorf("expected pause file to exist")
+	}
+
+	// Pausing without a reason works too, and resuming twice is fine
+	if state := do("POST", ""); !state.Paused {
+		t.Errorf("expected paused, got %+v", state)
+	}
+	do("DELETE", "")
+	if state := do("DELETE", ""); state.Paused {
+		t.Errorf("expected resumed, got %+v", state)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sftpCommand is the OpenSSH SFTP client used for uploads, replaced in tests
+var sftpCommand = "sftp"
+
+// saveFeed writes a generated feed to its output path and, when sftp_host is set,
+// uploads the written file to the remote host
+func (config *Config) saveFeed(path string, content []byte, contentType string) error {
+	if err := writeOutput(path, content, contentType); err != nil {
+		return err
+	}
+	if config.SFTPHost == "" || outputURL(path) != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outputTimeout)
+	defer cancel()
+	if err := config.uploadSFTP(ctx, path); err != nil {
+		return fmt.Errorf("failed to upload feed to %s: %w", config.SFTPHost, err)
+	}
+	slog.Debug("Uploaded feed over SFTP", "host", config.SFTPHost, "file", filepath.Base(path))
+	return nil
+}
+
+// uploadSFTP uploads a local file into sftp_path on sftp_host with the OpenSSH client.
+// The file is uploaded under a temporary name and renamed, so readers never see a
+// partial feed. Host keys are checked against known_hosts and nothing is prompted for.
+func (config *Config) uploadSFTP(ctx context.Context, local string) error {
+	args, script := config.sftpArgs(local)
+	cmd := exec.CommandContext(ctx, sftpCommand, args...)
+	cmd.Stdin = strings.NewReader(script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// sftpArgs returns the arguments of the sftp client and the batch script that uploads
+// a local file
+func (config *Config) sftpArgs(local string) ([]string, string) {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	host := config.SFTPHost
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		host = h
+		args = append(args, "-P", port)
+	}
+	if config.SFTPKeyFile != "" {
+		args = append(args, "-i", config.SFTPKeyFile)
+	}
+	args = append(args, host)
+
+	remote := path.Join(config.SFTPPath, filepath.Base(local))
+	temp := remote + ".tmp"
+	script := fmt.Sprintf("put %s %s\nrename %s %s\n",
+		sftpQuote(local), sftpQuote(temp), sftpQuote(temp), sftpQuote(remote))
+	return args, script
+}
+
+// sftpQuote quotes an argument of an sftp batch command
+func sftpQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSFTPArgs(t *testing.T) {
+	config := &Config{SFTPHost: "feeds@example.com:2222", SFTPPath: "public_html/rss", SFTPKeyFile: "/keys/id_ed25519"}
+	args, script := config.sftpArgs("/tmp/out/my \"feed\".xml")
+
+	wantArgs := []string{"-b", "-", "-o", "BatchMode=yes", "-P", "2222", "-i", "/keys/id_ed25519", "feeds@example.com"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %q, want %q", args, wantArgs)
+	}
+	wantScript := `put "/tmp/out/my \"feed\".xml" "public_html/rss/my \"feed\".xml.tmp"` + "\n" +
+		`rename "public_html/rss/my \"feed\".xml.tmp" "public_html/rss/my \"feed\".xml"` + "\n"
+	if script != wantScript {
+		t.Errorf("script = %q, want %q", script, wantScript)
+	}
+
+	args, _ = (&Config{SFTPHost: "example.com"}).sftpArgs("feed.xml")
+	if args[len(args)-1] != "example.com" || strings.Contains(strings.Join(args, " "), "-P") {
+		t.Errorf("args without port = %q", args)
+	}
+}
+
+func TestSaveFeedUploads(t *testing.T) {
+	dir := t.TempDir()
+	// A stand-in sftp client that records its arguments and batch script
+	fake := filepath.Join(dir, "sftp")
+	script := "#!/bin/sh\necho \"$@\" > \"$0.args\"\ncat > \"$0.batch\"\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	orig := sftpCommand
+	sftpCommand = fake
+	defer func() { sftpCommand = orig }()
+
+	config := &Config{SFTPHost: "example.com", SFTPPath: "rss"}
+	path := filepath.Join(dir, "reddit.xml")
+	if err := config.saveFeed(path, []byte("<feed/>"), "application/atom+xml"); err != nil {
+		t.Fatalf("saveFeed: %v", err)
+	}
+
+	if content, err := os.ReadFile(path); err != nil || string(content) != "<feed/>" {
+		t.Errorf("local feed = %q, %v", content, err)
+	}
+	batch, err := os.ReadFile(fake + ".batch")
+	if err != nil {
+		t.Fatalf("sftp wasn't run: %v", err)
+	}
+	if !strings.Contains(string(batch), `rename "rss/reddit.xml.tmp" "rss/reddit.xml"`) {
+		t.Errorf("batch = %q", batch)
+	}
+
+	if err := validateConfig(&Config{ClientID: "id", FeedType: "atom", OutputPath: "s3://bucket/feed.xml", SFTPHost: "example.com"}); err == nil || !strings.Contains(err.Error(), "sftp_host") {
+		t.Error("expected sftp_host with an S3 output path to be rejected")
+	}
+}
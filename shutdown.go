@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout bounds how long serve mode waits for the requests being served after
+// SIGINT or SIGTERM
+const ShutdownTimeout = 30 * time.Second
+
+// shutdownContext returns a context canceled by SIGINT or SIGTERM. Canceling it stops
+// Reddit and OpenGraph requests, and runs skip writing their feeds rather than write
+// incomplete ones, while databases are closed as usual. A second signal exits at once.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// Restore the default handling, so a second signal kills the process
+		stop()
+		if cause := context.Cause(ctx); cause != context.Canceled {
+			slog.Warn("Shutting down, interrupt again to quit immediately", "reason", cause)
+		}
+	}()
+	return ctx, stop
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestShutdownContext(t *testing.T) {
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("can't interrupt the test process: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected SIGINT to cancel the context")
+	}
+}
+
+func TestInterruptedBuild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &Pipeline{config: &Config{FeedType: "rss"}}
+	opts := DefaultRunOptions()
+	opts.Offline = true
+	if _, err := p.build(ctx, []RedditPost{historyPost("t3_a", 100)}, opts, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected an interrupted run to render no feed, got %v", err)
+	}
+}
+
+func TestTenantManagerCloseWaits(t *testing.T) {
+	m := NewTenantManager("", "http://localhost:8081")
+	started, stopped := make(chan struct{}), make(chan struct{})
+	m.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // Finishing a database write
+		close(stopped)
+	})
+	<-started
+
+	m.Close()
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("expected Close to wait for the running generation")
+	}
+
+	m.Go(func(ctx context.Context) { t.Error("expected no generations to start after Close") })
+	time.Sleep(10 * time.Millisecond)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Origins of posts that don't come from Reddit
+const (
+	OriginLemmy      = "lemmy"
+	OriginKbin       = "kbin"
+	OriginHackerNews = "hackernews"
+)
+
+// Source fetch limits
+const (
+	maxSourceFetches  = 4       // Sources of a feed fetched at once, Reddit requests are paced further by the shared rate limiter
+	sourceMaxResponse = 8 << 20 // Bytes of a listing of another site read at most
+)
+
+// sourceClient fetches listings of other sites than Reddit, it never carries Reddit
+// credentials
+var sourceClient = &http.Client{Timeout: 15 * time.Second}
+
+// Source is a listing the pipeline fetches posts from. Posts from other sites than
+// Reddit are converted to RedditPost with their Origin set, so the filters,
+// enrichment and rendering handle every source the same way.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]RedditPost, error)
+}
+
+// homepageSource is the user's homepage, fetched in pages or differentially
+type homepageSource struct {
+	p          *Pipeline
+	checkpoint *Checkpoint
+}
+
+func (s homepageSource) Name() string { return HomepageFeed }
+
+func (s homepageSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	return s.p.fetchHomepage(ctx, s.checkpoint)
+}
+
+// listingSource is a subreddit listing, including r/popular and r/all
+type listingSource struct {
+	api       *RedditAPI
+	name      string // Source as configured
+	subreddit string
+	sort      string
+}
+
+func (s listingSource) Name() string { return s.name }
+
+func (s listingSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	return s.api.FetchSubredditContext(ctx, s.subreddit, s.sort)
+}
+
+// multiredditSource is the merged listing of several subreddits
+type multiredditSource struct {
+	p     *Pipeline
+	names []string
+}
+
+func (s multiredditSource) Name() string { return "r/" + strings.Join(s.names, "+") }
+
+func (s multiredditSource) Fetch(ctx context.Context) ([]RedditPost, error) {
+	return s.p.fetchMultireddit(ctx, s.names)
+}
+
+// fetchSources fetches the sources of a feed concurrently. A source that fails is
+// logged and left out with a nil listing, the fetch only fails when all of them fail.
+func fetchSources(ctx context.Context, sources []Source) ([][]RedditPost, error) {
+	listings := make([][]RedditPost, len(sources))
+	errs := make([]error, len(sources))
+	tasks := make([]Task, len(sources))
+	for i, source := range sources {
+		tasks[i] = Task{
+			Run: func(ctx context.Context) {
+				if listings[i], errs[i] = source.Fetch(ctx); errs[i] != nil {
+					slog.Warn("Failed to fetch source, leaving it out", "source", source.Name(), "error", errs[i])
+				}
+			},
+			Skipped: func() { errs[i] = ctx.Err() },
+		}
+	}
+	NewWorkScheduler(maxSourceFetches, 0).RunBatch(ctx, tasks)
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 && failed == len(sources) {
+		return nil, errors.Join(errs...)
+	}
+	return listings, nil
+}
+
+// validateSource checks a configured source: "homepage", "popular", "all", a
+// subreddit such as "r/golang", a fediverse community such as
+// "lemmy:technology@lemmy.world" or "kbin:tech@kbin.social", or a Hacker News listing
+// such as "hn:front"
+func validateSource(spec string) error {
+	switch {
+	case spec == HomepageFeed || spec == BlendPopular || spec == BlendAll:
+		return nil
+	case strings.HasPrefix(spec, "r/"):
+		if !validSubreddit.MatchString(subredditName(spec)) {
+			return fmt.Errorf("invalid subreddit source %q", spec)
+		}
+		return nil
+	case strings.HasPrefix(spec, hackerNewsPrefix+":"):
+		if _, ok := parseHackerNewsSource(spec); !ok {
+			return fmt.Errorf(`Hacker News source must be "hn:front", "hn:new", "hn:show" or "hn:ask", got %q`, spec)
+		}
+		return nil
+	}
+	if _, _, _, err := parseFediverseSource(spec); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newSource returns the Source of a configured source, which validateSource accepted
+func (p *Pipeline) newSource(spec string, checkpoint *Checkpoint) Source {
+	switch {
+	case spec == HomepageFeed:
+		return homepageSource{p: p, checkpoint: checkpoint}
+	case spec == BlendPopular || spec == BlendAll:
+		return listingSource{api: p.api, name: spec, subreddit: spec, sort: SortHot}
+	case strings.HasPrefix(spec, "r/"):
+		return listingSource{api: p.api, name: spec, subreddit: subredditName(spec), sort: p.config.SubredditSort}
+	}
+	if listing, ok := parseHackerNewsSource(spec); ok {
+		return &HackerNewsSource{Listing: listing}
+	}
+	origin, community, instance, _ := parseFediverseSource(spec)
+	if origin == OriginKbin {
+		return &KbinSource{Instance: instance, Magazine: community}
+	}
+	return &LemmySource{Instance: instance, Community: community}
+}
+
+// onReddit reports whether the post comes from Reddit, where its comments, removal
+// state and poll results can be looked up
+func (d PostData) onReddit() bool {
+	return d.Origin == ""
+}
+
+// discussionURL returns the page of the post with its comments
+func (d PostData) discussionURL() string {
+	if d.onReddit() {
+		return "https://www.reddit.com" + d.Permalink
+	}
+	return d.Permalink
+}
+
+// community names the subreddit of the post, or its community on another site,
+// e.g. "r/golang" or "!technology@lemmy.world"
+func (d PostData) community() string {
+	switch d.Origin {
+	case "":
+		return "r/" + d.Subreddit
+	case OriginHackerNews:
+		return "Hacker News"
+	}
+	return "!" + d.Subreddit
+}
+
+// communityURL returns the page of the subreddit or community of the post
+func (d PostData) communityURL() string {
+	name, instance, _ := strings.Cut(d.Subreddit, "@")
+	switch d.Origin {
+	case "":
+		return "https://www.reddit.com/r/" + d.Subreddit
+	case OriginKbin:
+		return "https://" + instance + "/m/" + url.PathEscape(name)
+	case OriginHackerNews:
+		return "https://news.ycombinator.com/"
+	default:
+		return "https://" + instance + "/c/" + url.PathEscape(name)
+	}
+}
+
+// authorURL returns the profile page of the author, or "" when it isn't known
+func (d PostData) authorURL() string {
+	if d.onReddit() {
+		return "https://www.reddit.com/user/" + d.Author
+	}
+	return d.AuthorURL
+}
+
+// discussionLabel names the link to the discussion of the post
+func (d PostData) discussionLabel() string {
+	switch d.Origin {
+	case OriginLemmy:
+		return "Lemmy Discussion"
+	case OriginKbin:
+		return "kbin Discussion"
+	case OriginHackerNews:
+		return "HN Discussion"
+	}
+	return "Reddit Discussion"
+}
+
+// fetchSourceJSON fetches and decodes a JSON response of the API of another site than Reddit
+func fetchSourceJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "red-rss/"+Version)
+
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, sourceMaxResponse)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+// setSourceLink sets the URL and domain of a post of another site than Reddit, which
+// links to its own discussion when it is a text post, like Reddit self posts do
+func setSourceLink(data *PostData, community string) {
+	if data.URL == "" {
+		data.IsSelf = true
+		data.URL = data.Permalink
+		data.Domain = "self." + community
+		return
+	}
+	data.Domain = strings.TrimPrefix(hostOf(data.URL), "www.")
+}
+
+// linkKey identifies the page a link post links to, ignoring the scheme, "www.",
+// trailing slashes and fragments, and AMP and mobile versions of the page. Self posts
+// have no key.
+func linkKey(post RedditPost) string {
+	if post.Data.IsSelf || post.Data.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(canonicalURL(post.Data.URL))
+	if err != nil || u.Host == "" {
+		return post.Data.URL
+	}
+	key := strings.TrimPrefix(strings.ToLower(u.Host), "www.") + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
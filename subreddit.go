@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// validSubreddit matches subreddit names as Reddit allows them
+var validSubreddit = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+
+// FetchSubreddit fetches the posts of a subreddit sorted by "hot", "new", "top" or "rising"
+func (api *RedditAPI) FetchSubreddit(name, sort string) ([]RedditPost, error) {
+	return api.FetchSubredditContext(context.Background(), name, sort)
+}
+
+// FetchSubredditContext fetches the posts of a subreddit, tracing requests as children of ctx
+func (api *RedditAPI) FetchSubredditContext(ctx context.Context, name, sort string) ([]RedditPost, error) {
+	if sort == "" {
+		sort = SortHot
+	}
+	posts, err := api.FetchListing(ctx, "/r/"+name+"/"+sort, url.Values{"limit": {"100"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch r/%s: %w", name, err)
+	}
+
+	slog.Info("Successfully fetched subreddit posts", "subreddit", name, "sort", sort, "count", len(posts))
+	return posts, nil
+}
+
+// subredditName normalizes a configured subreddit, accepting "r/golang" for "golang"
+func subredditName(name string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(name), "/"), "r/")
+	return strings.TrimSuffix(name, "/")
+}
+
+// subredditSource names a subreddit listing in the item store, e.g. "r/golang"
+func subredditSource(name string) string {
+	return "r/" + name
+}
+
+// subredditOutputPath returns where the feed of a subreddit is written. Templates
+// without {feed} get the subreddit name appended to the file name, so that each
+// subreddit has its own file next to the homepage feed.
+func subredditOutputPath(template, dir, name, feedType string, t time.Time) string {
+	if !strings.Contains(template, "{feed}") {
+		ext := ""
+		if i := strings.LastIndex(template, "."); i > strings.LastIndex(template, "/") {
+			ext = template[i:]
+		}
+		template = strings.TrimSuffix(template, ext) + "-{feed}" + ext
+	}
+	return resolveOutputPath(template, dir, name, feedType, t)
+}
+
+// GenerateSubreddits generates the feed of each configured subreddit and writes it
+// next to the homepage feed. A failing subreddit doesn't stop the others.
+func (p *Pipeline) GenerateSubreddits(ctx context.Context, opts RunOptions, dir string) error {
+	names := make([]string, 0, len(p.config.Subreddits))
+	for _, name := range p.config.Subreddits {
+		names = append(names, subredditName(name))
+	}
+	_, err := p.generateSubredditFeeds(ctx, opts, dir, names)
+	return err
+}
+
+// generateSubredditFeeds generates and writes the feed of each subreddit, returning
+// the paths written by subreddit
+func (p *Pipeline) generateSubredditFeeds(ctx context.Context, opts RunOptions, dir string, names []string) (map[string]string, error) {
+	failed := 0
+	written := make(map[string]string)
+	for _, name := range names {
+		opts.Subreddit = name
+		result, err := p.Generate(ctx, opts)
+		if err == nil {
+			path := subredditOutputPath(p.config.OutputPath, dir, name, p.config.FeedType, time.Now())
+			if err = p.config.saveFeed(path, result.Content, result.ContentType); err == nil {
+				written[name] = path
+			}
+			slog.Debug("Generated subreddit feed", "subreddit", name, "path", path, "items", result.Items)
+		}
+		if err != nil {
+			slog.Error("Failed to generate subreddit feed", "subreddit", name, "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return written, fmt.Errorf("%d of %d subreddit feeds failed", failed, len(names))
+	}
+	return written, nil
+}
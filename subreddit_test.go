@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateSubreddits(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/r/golang/new" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		body := `{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"name": "t3_go", "title": "Go 2 released", "subreddit": "golang", "permalink": "/r/golang/comments/go/"}}
+		]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	dir := t.TempDir()
+	config := &Config{FeedType: "atom", EnhancedAtom: true, OutputPath: "reddit.xml", Subreddits: []string{"r/golang"}, SubredditSort: SortNew}
+	db := newTestDB(t)
+	p := NewPipeline(config, client, db)
+	if err := p.GenerateSubreddits(context.Background(), DefaultRunOptions(), dir); err != nil {
+		t.Fatalf("GenerateSubreddits failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "reddit-golang.xml"))
+	if err != nil {
+		t.Fatalf("expected a feed file for r/golang: %v", err)
+	}
+	for _, want := range []string{"<title>r/golang Feed</title>", "Go 2 released", `<link href="https://www.reddit.com/r/golang/"/>`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %s in the feed:\n%s", want, content)
+		}
+	}
+
+	run, err := db.GetFetchRunAsOf("r/golang", time.Now())
+	if err != nil || run == nil || len(run.Posts) != 1 {
+		t.Errorf("expected the fetch to be recorded under r/golang, got %+v, %v", run, err)
+	}
+}
+
+func TestSubredditOutputPath(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	for _, tc := range []struct{ template, want string }{
+		{"reddit.xml", "reddit-golang.xml"},
+		{"feeds/{feed}.{type}", "feeds/golang.atom"},
+		{"out.d/feed", "out.d/feed-golang"},
+	} {
+		if got := subredditOutputPath(tc.template, "", "golang", "atom", now); got != tc.want {
+			t.Errorf("subredditOutputPath(%q) = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+
+	if err := validateConfig(&Config{ClientID: "x", FeedType: "rss", OutputPath: "a.xml", Subreddits: []string{"no such"}}); err == nil {
+		t.Errorf("expected invalid subreddit names to be rejected")
+	}
+}
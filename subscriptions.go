@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SubscriptionsFeed names the merged feed of the subscribed subreddits
+const SubscriptionsFeed = "subscriptions"
+
+// Feeds generated from the subscribed subreddits
+const (
+	SubscriptionsMerged       = "merged"        // One feed of all subscriptions
+	SubscriptionsPerSubreddit = "per_subreddit" // A feed per subscription
+)
+
+// DefaultMaxSubscriptions bounds the subreddits taken from the subscriptions
+const DefaultMaxSubscriptions = 50
+
+// multiredditSize is the number of subreddits fetched together as one multireddit
+const multiredditSize = 50
+
+// maxSubscriptions returns how many subscribed subreddits get feeds
+func (c *Config) maxSubscriptions() int {
+	if c.MaxSubscriptions > 0 {
+		return c.MaxSubscriptions
+	}
+	return DefaultMaxSubscriptions
+}
+
+// FetchSubscriptions returns the names of the subreddits the user is subscribed to.
+// It needs the mysubreddits scope.
+func (api *RedditAPI) FetchSubscriptions(ctx context.Context) ([]string, error) {
+	var names []string
+	after := ""
+	for page := 0; page < MaxListingPages; page++ {
+		params := url.Values{"limit": {"100"}}
+		if after != "" {
+			params.Set("after", after)
+		}
+		listing, err := api.fetchSubredditListing(ctx, "https://oauth.reddit.com/subreddits/mine/subscriber?"+params.Encode())
+		if errors.Is(err, ErrRedditForbidden) {
+			// Tokens from before subscriptions was enabled lack the scope
+			return nil, fmt.Errorf("failed to fetch subscriptions, the token lacks the mysubreddits scope, run `red-rss auth -force`: %w", err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch subscriptions: %w", err)
+		}
+
+		// Listings of subreddits hold t5 things, which decodeListing skips
+		for _, child := range listing.Data.Children {
+			var thing struct {
+				Kind string `json:"kind"`
+				Data struct {
+					DisplayName string `json:"display_name"`
+				} `json:"data"`
+			}
+			if json.Unmarshal(child, &thing) == nil && thing.Kind == KindSubreddit && thing.Data.DisplayName != "" {
+				names = append(names, thing.Data.DisplayName)
+			}
+		}
+		if after = listing.Data.After; after == "" {
+			break
+		}
+	}
+	return names, nil
+}
+
+// fetchSubredditListing fetches a page of a listing without decoding its children
+func (api *RedditAPI) fetchSubredditListing(ctx context.Context, apiURL string) (*rawListing, error) {
+	resp, err := api.get(ctx, apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseRedditError(resp)
+	}
+
+	var listing rawListing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("failed to decode Reddit API response: %w", err)
+	}
+	return &listing, nil
+}
+
+// subscriptions returns the subscribed subreddits, in alphabetical order and bounded
+// by max_subscriptions
+func (p *Pipeline) subscriptions(ctx context.Context) ([]string, error) {
+	names, err := p.api.FetchSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(names, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	if limit := p.config.maxSubscriptions(); len(names) > limit {
+		slog.Warn("Ignoring subscriptions beyond max_subscriptions", "subscriptions", len(names), "max", limit)
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+// fetchSubscriptionPosts fetches the merged listing of the subreddits of the run,
+// as multireddits of up to multiredditSize subreddits fetched concurrently and blended
+// evenly. Subreddits that can't be fetched are left out.
+func (p *Pipeline) fetchSubscriptionPosts(ctx context.Context, names []string) ([]RedditPost, error) {
+	var sources []Source
+	for start := 0; start < len(names); start += multiredditSize {
+		sources = append(sources, multiredditSource{p: p, names: names[start:min(start+multiredditSize, len(names))]})
+	}
+	listings, err := fetchSources(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+	return blendPosts(make([]BlendSource, len(listings)), listings), nil
+}
+
+// fetchMultireddit fetches the merged listing of subreddits. When Reddit refuses it
+// because one of them is banned or private, each half is fetched on its own, so only
+// the subreddits that fail are left out.
+func (p *Pipeline) fetchMultireddit(ctx context.Context, names []string) ([]RedditPost, error) {
+	posts, err := p.api.FetchSubredditContext(ctx, strings.Join(names, "+"), p.config.SubredditSort)
+	if err == nil || len(names) == 1 || !(errors.Is(err, ErrRedditForbidden) || errors.Is(err, ErrRedditSubredditBanned)) {
+		return posts, err
+	}
+
+	half := len(names) / 2
+	first, firstErr := p.fetchMultireddit(ctx, names[:half])
+	second, secondErr := p.fetchMultireddit(ctx, names[half:])
+	switch {
+	case firstErr != nil && secondErr != nil:
+		return nil, errors.Join(firstErr, secondErr)
+	case firstErr != nil:
+		slog.Warn("Leaving out subreddits that failed to load", "subreddits", strings.Join(names[:half], "+"), "error", firstErr)
+	case secondErr != nil:
+		slog.Warn("Leaving out subreddits that failed to load", "subreddits", strings.Join(names[half:], "+"), "error", secondErr)
+	}
+	return blendPosts(make([]BlendSource, 2), [][]RedditPost{first, second}), nil
+}
+
+// GenerateSubscriptions generates the feeds of the subscribed subreddits, as fetched
+// on every run, and writes them next to the homepage feed: one merged feed, or a feed
+// per subreddit not already in subreddits. Per-subreddit feeds of subreddits no longer
+// subscribed to, or of all of them with merged, are removed.
+func (p *Pipeline) GenerateSubscriptions(ctx context.Context, opts RunOptions, dir string) error {
+	names, err := p.subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		slog.Warn("No subscribed subreddits, skipping subscription feeds")
+		p.removeStaleSubscriptionFeeds(nil, nil)
+		return nil
+	}
+
+	if p.config.Subscriptions == SubscriptionsPerSubreddit {
+		// The feeds are scheduled together, and live while any has live match threads
+		opts.Schedule = SubscriptionsFeed
+		liveMatches.clear(opts.Consumer, SubscriptionsFeed)
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return slices.ContainsFunc(p.config.Subreddits, func(configured string) bool {
+				return strings.EqualFold(subredditName(configured), name)
+			})
+		})
+		written, err := p.generateSubredditFeeds(ctx, opts, dir, names)
+		if ctx.Err() == nil {
+			p.removeStaleSubscriptionFeeds(names, written)
+		}
+		return err
+	}
+
+	opts.Subscriptions = names
+	result, err := p.Generate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// Per-subreddit feeds of an earlier setting are stale too
+	p.removeStaleSubscriptionFeeds(nil, nil)
+	path := subredditOutputPath(p.config.OutputPath, dir, SubscriptionsFeed, p.config.FeedType, time.Now())
+	slog.Debug("Generated subscriptions feed", "subreddits", len(names), "path", path, "items", result.Items)
+	return p.config.saveFeed(path, result.Content, result.ContentType)
+}
+
+// removeStaleSubscriptionFeeds records the per-subreddit feeds written by the run and
+// removes the files of earlier ones whose subreddits are no longer among the
+// subscriptions. Feeds written to URLs can't be removed and are only forgotten.
+func (p *Pipeline) removeStaleSubscriptionFeeds(names []string, written map[string]string) {
+	if p.db == nil {
+		return
+	}
+	for name, path := range written {
+		if err := p.db.SaveSubscriptionFeed(name, path); err != nil {
+			slog.Warn("Failed to record subscription feed", "subreddit", name, "error", err)
+		}
+	}
+
+	feeds, err := p.db.SubscriptionFeeds()
+	if err != nil {
+		slog.Warn("Failed to load subscription feeds", "error", err)
+		return
+	}
+	for name, path := range feeds {
+		if slices.ContainsFunc(names, func(subscribed string) bool { return strings.EqualFold(subscribed, name) }) {
+			continue
+		}
+		// Subreddits moved to subreddits keep their file, now written by that feed
+		configured := slices.ContainsFunc(p.config.Subreddits, func(configured string) bool {
+			return strings.EqualFold(subredditName(configured), name)
+		})
+		if !configured && outputURL(path) == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("Failed to remove feed of unsubscribed subreddit", "subreddit", name, "path", path, "error", err)
+				continue
+			}
+			slog.Info("Removed feed of unsubscribed subreddit", "subreddit", name, "path", path)
+		}
+		if err := p.db.DeleteSubscriptionFeed(name); err != nil {
+			slog.Warn("Failed to forget subscription feed", "subreddit", name, "error", err)
+		}
+	}
+}
+
+// SubscriptionFeeds returns the paths of the per-subreddit subscription feeds written,
+// by subreddit
+func (ogDB *OpenGraphDB) SubscriptionFeeds() (map[string]string, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`SELECT subreddit, path FROM subscription_feeds`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscription feeds: %w", err)
+	}
+	defer rows.Close()
+
+	feeds := make(map[string]string)
+	for rows.Next() {
+		var name, path string
+		if err := rows.Scan(&name, &path); err != nil {
+			return nil, fmt.Errorf("failed to load subscription feeds: %w", err)
+		}
+		feeds[name] = path
+	}
+	return feeds, rows.Err()
+}
+
+// SaveSubscriptionFeed records where the feed of a subscribed subreddit was written
+func (ogDB *OpenGraphDB) SaveSubscriptionFeed(subreddit, path string) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO subscription_feeds (subreddit, path, written_at) VALUES (?, ?, ?)`,
+		subreddit, path, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save subscription feed: %w", err)
+	}
+	return nil
+}
+
+// DeleteSubscriptionFeed forgets the feed of a subreddit no longer subscribed to
+func (ogDB *OpenGraphDB) DeleteSubscriptionFeed(subreddit string) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	if _, err := ogDB.db.Exec(`DELETE FROM subscription_feeds WHERE subreddit = ?`, subreddit); err != nil {
+		return fmt.Errorf("failed to delete subscription feed: %w", err)
+	}
+	return nil
+}
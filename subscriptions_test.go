@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// subscriptionsAPI serves two pages of subscriptions and the hot listing of any
+// subreddits, recording the subreddit listings requested
+func subscriptionsAPI(t *testing.T, listings *[]string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case req.URL.Path == "/subreddits/mine/subscriber" && req.URL.Query().Get("after") == "":
+			body = `{"kind": "Listing", "data": {"after": "t5_2", "children": [
+				{"kind": "t5", "data": {"display_name": "rust"}},
+				{"kind": "t5", "data": {"display_name": "Golang"}}]}}`
+		case req.URL.Path == "/subreddits/mine/subscriber":
+			body = `{"kind": "Listing", "data": {"children": [{"kind": "t5", "data": {"display_name": "linux"}}]}}`
+		case strings.HasPrefix(req.URL.Path, "/r/"):
+			*listings = append(*listings, req.URL.Path)
+			body = `{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {"name": "t3_` + strings.Split(req.URL.Path, "/")[2] + `", "title": "Post", "score": 10, "permalink": "/r/x/comments/1/post/", "url": "https://www.reddit.com/r/x/comments/1/post/", "is_self": true}}]}}`
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+}
+
+func TestGenerateSubscriptions(t *testing.T) {
+	dir := t.TempDir()
+
+	var listings []string
+	config := &Config{FeedType: "rss", OutputPath: "reddit.xml", Subscriptions: SubscriptionsMerged, MaxSubscriptions: 2}
+	p := NewPipeline(config, subscriptionsAPI(t, &listings), newTestDB(t))
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	// The first two subscriptions in alphabetical order as one multireddit
+	if err := p.GenerateSubscriptions(context.Background(), DefaultRunOptions(), dir); err != nil {
+		t.Fatalf("GenerateSubscriptions failed: %v", err)
+	}
+	if len(listings) != 1 || listings[0] != "/r/Golang+linux/hot" {
+		t.Errorf("expected one multireddit listing, got %v", listings)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "reddit-subscriptions.xml"))
+	if err != nil {
+		t.Fatalf("expected merged feed: %v", err)
+	}
+	if !strings.Contains(string(content), "My Subscribed Subreddits Feed") {
+		t.Errorf("expected subscriptions feed title in:\n%s", content)
+	}
+
+	// A feed per subscription, except those already in subreddits
+	listings = nil
+	config.Subscriptions = SubscriptionsPerSubreddit
+	config.MaxSubscriptions = 0
+	config.Subreddits = []string{"r/golang"}
+	if err := p.GenerateSubscriptions(context.Background(), DefaultRunOptions(), dir); err != nil {
+		t.Fatalf("GenerateSubscriptions failed: %v", err)
+	}
+	if strings.Join(listings, " ") != "/r/linux/hot /r/rust/hot" {
+		t.Errorf("expected feeds of linux and rust, got %v", listings)
+	}
+	for _, name := range []string{"reddit-linux.xml", "reddit-rust.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+
+	// Feeds of subreddits no longer among the subscriptions are removed
+	config.MaxSubscriptions = 2
+	if err := p.GenerateSubscriptions(context.Background(), DefaultRunOptions(), dir); err != nil {
+		t.Fatalf("GenerateSubscriptions failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reddit-rust.xml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the feed of rust to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reddit-linux.xml")); err != nil {
+		t.Errorf("expected the feed of linux to stay: %v", err)
+	}
+	config.Subscriptions = SubscriptionsMerged
+	if err := p.GenerateSubscriptions(context.Background(), DefaultRunOptions(), dir); err != nil {
+		t.Fatalf("GenerateSubscriptions failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reddit-linux.xml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the per-subreddit feeds to be removed with merged, got %v", err)
+	}
+}
+
+func TestFetchSubscriptionsNeedsScope(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(`{"message": "Forbidden", "error": 403}`))}, nil
+	})}
+	p := NewPipeline(&Config{Subscriptions: SubscriptionsMerged}, client, nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	_, err := p.api.FetchSubscriptions(context.Background())
+	if !errors.Is(err, ErrRedditForbidden) || !strings.Contains(err.Error(), "red-rss auth -force") {
+		t.Errorf("expected a forbidden listing to ask for authorizing again, got %v", err)
+	}
+}
+
+func TestFetchSubscriptionPostsIsolatesFailures(t *testing.T) {
+	var mu sync.Mutex
+	var listings []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		listings = append(listings, req.URL.Path)
+		mu.Unlock()
+		multi := strings.Split(req.URL.Path, "/")[2]
+		if slices.Contains(strings.Split(multi, "+"), "banned") {
+			body := `{"reason": "banned", "message": "Not Found", "error": 404}`
+			return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}
+		var children []string
+		for _, name := range strings.Split(multi, "+") {
+			children = append(children, `{"kind": "t3", "data": {"name": "t3_`+name+`"}}`)
+		}
+		body := `{"kind": "Listing", "data": {"children": [` + strings.Join(children, ",") + `]}}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	p := NewPipeline(&Config{}, client, nil)
+	p.api.rateLimiter = NewRateLimiter(0)
+	p.api.limiter = NewFairLimiter(6000, 10)
+
+	// The banned subreddit is narrowed down by halves and left out
+	posts, err := p.fetchSubscriptionPosts(context.Background(), []string{"a", "b", "banned", "c"})
+	if err != nil {
+		t.Fatalf("fetchSubscriptionPosts failed: %v", err)
+	}
+	var names []string
+	for _, post := range posts {
+		names = append(names, strings.TrimPrefix(post.Data.Name, "t3_"))
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"a", "b", "c"}) {
+		t.Errorf("expected the posts of a, b and c, got %v", names)
+	}
+	if len(listings) != 5 {
+		t.Errorf("expected the multireddit, its halves and the banned half's halves, got %v", listings)
+	}
+
+	// Only a feed whose every subreddit fails fails
+	if _, err := p.fetchSubscriptionPosts(context.Background(), []string{"banned"}); !errors.Is(err, ErrRedditSubredditBanned) {
+		t.Errorf("expected banned error, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdListenFDsStart is the first file descriptor passed by systemd socket activation,
+// see sd_listen_fds(3)
+const sdListenFDsStart = 3
+
+// sdNotify sends a state such as "READY=1" to the service manager. It returns false
+// without error when the process isn't run by systemd with Type=notify.
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Abstract socket names start with @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to the notify socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// notifySystemd sends a state to the service manager, logging failures
+func notifySystemd(state string) {
+	if _, err := sdNotify(state); err != nil {
+		slog.Warn("Failed to notify systemd", "state", state, "error", err)
+	}
+}
+
+// sdWatchdogInterval returns the watchdog timeout of the service, 0 when WatchdogSec
+// isn't set for this process
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// startWatchdog pings the systemd watchdog at half its timeout while alive reports
+// the daemon healthy. A daemon whose alive check keeps failing is restarted by systemd.
+func startWatchdog(alive func() bool) {
+	timeout := sdWatchdogInterval()
+	if timeout <= 0 {
+		return
+	}
+	slog.Debug("Pinging the systemd watchdog", "timeout", timeout)
+	go func() {
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if alive() {
+				notifySystemd("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+// systemdListener returns the first socket passed by systemd socket activation, or nil
+// when the process wasn't socket-activated. The activation variables are cleared so
+// that child processes don't take the socket too.
+func systemdListener() (net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	if fds > 1 {
+		slog.Warn("Using only the first of the sockets passed by systemd", "sockets", fds)
+	}
+
+	file := os.NewFile(sdListenFDsStart, "systemd-socket")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket passed by systemd: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := sdNotify("READY=1"); sent || err != nil {
+		t.Errorf("expected nothing sent without systemd, got %v, %v", sent, err)
+	}
+
+	// Socket paths are limited to about 100 bytes, which test directories can exceed
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	if sent, err := sdNotify("READY=1\nSTATUS=Serving"); !sent || err != nil {
+		t.Fatalf("expected the state sent, got %v, %v", sent, err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1\nSTATUS=Serving" {
+		t.Errorf("expected the state on the socket, got %q, %v", buf[:n], err)
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := sdWatchdogInterval(); got != 30*time.Second {
+		t.Errorf("sdWatchdogInterval() = %v, want 30s", got)
+	}
+
+	// The watchdog of another process, e.g. a parent shell
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := sdWatchdogInterval(); got != 0 {
+		t.Errorf("expected no watchdog for another process, got %v", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := sdWatchdogInterval(); got != 0 {
+		t.Errorf("expected no watchdog without WatchdogSec, got %v", got)
+	}
+}
+
+func TestSystemdListenerNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listener, err := systemdListener()
+	if listener != nil || err != nil {
+		t.Errorf("expected no listener for sockets passed to another process, got %v, %v", listener, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("expected the activation variables to be cleared")
+	}
+}
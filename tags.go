@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// HomepageFeed names the homepage feed in the feeds of tag rules
+const HomepageFeed = "homepage"
+
+// TagRule attaches a tag to posts whose title, flair or text match its keywords or pattern
+type TagRule struct {
+	Tag         string   `json:"tag"`          // e.g. "CW: spoilers"
+	Keywords    []string `json:"keywords"`     // Whole words, case-insensitive
+	Pattern     string   `json:"pattern"`      // Regular expression
+	TitlePrefix bool     `json:"title_prefix"` // Prefix titles with "[tag]"
+	Feeds       []string `json:"feeds"`        // Feeds the rule applies to: "homepage" or subreddit names, empty for all
+}
+
+// tagMatcher is a compiled tag rule
+type tagMatcher struct {
+	rule TagRule
+	re   *regexp.Regexp
+}
+
+// compileTagRules compiles the keywords and pattern of each rule into a regular expression
+func compileTagRules(rules []TagRule) ([]tagMatcher, error) {
+	matchers := make([]tagMatcher, 0, len(rules))
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Tag) == "" {
+			return nil, fmt.Errorf("tag_rules[%d] has no tag", i)
+		}
+		re, err := keywordRegexp(rule.Keywords, rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("tag_rules[%d] (%s) has an invalid pattern: %w", i, rule.Tag, err)
+		}
+		if re == nil {
+			return nil, fmt.Errorf("tag_rules[%d] (%s) needs keywords or a pattern", i, rule.Tag)
+		}
+		matchers = append(matchers, tagMatcher{rule: rule, re: re})
+	}
+	return matchers, nil
+}
+
+// keywordRegexp compiles keywords, matched as whole words regardless of case, and a
+// regular expression into one expression matching either. It returns nil without
+// keywords and pattern.
+func keywordRegexp(keywords []string, pattern string) (*regexp.Regexp, error) {
+	var alternatives []string
+	if len(keywords) > 0 {
+		words := make([]string, 0, len(keywords))
+		for _, keyword := range keywords {
+			words = append(words, regexp.QuoteMeta(strings.TrimSpace(keyword)))
+		}
+		alternatives = append(alternatives, `(?i)\b(?:`+strings.Join(words, "|")+`)\b`)
+	}
+	if pattern != "" {
+		alternatives = append(alternatives, pattern)
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(`(?:` + strings.Join(alternatives, `)|(?:`) + `)`)
+}
+
+// appliesTo reports whether the rule is used for the feed of a run
+func (m tagMatcher) appliesTo(opts RunOptions) bool {
+	if len(m.rule.Feeds) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(m.rule.Feeds, func(name string) bool {
+		return strings.EqualFold(subredditName(name), opts.feed())
+	})
+}
+
+// tagPosts attaches the tags of matching tag_rules to the posts and prefixes the
+// titles of rules with title_prefix
+func (p *Pipeline) tagPosts(posts []RedditPost, opts RunOptions) []RedditPost {
+	if len(p.config.TagRules) == 0 {
+		return posts
+	}
+	matchers, err := compileTagRules(p.config.TagRules)
+	if err != nil {
+		slog.Warn("Ignoring invalid tag rules", "error", err)
+		return posts
+	}
+
+	for i := range posts {
+		post := &posts[i].Data
+		text := ruleText(*post)
+		var prefixes []string
+		for _, m := range matchers {
+			if !m.appliesTo(opts) || slices.Contains(post.Tags, m.rule.Tag) || !m.re.MatchString(text) {
+				continue
+			}
+			post.Tags = append(post.Tags, m.rule.Tag)
+			if m.rule.TitlePrefix {
+				prefixes = append(prefixes, "["+m.rule.Tag+"] ")
+			}
+		}
+		post.Title = strings.Join(prefixes, "") + post.Title
+	}
+	return posts
+}
+
+// ruleText returns the text tag and filter rules match: the title, flair and body
+func ruleText(post PostData) string {
+	return strings.Join([]string{post.Title, post.LinkFlairText, post.Selftext}, "\n")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagPosts(t *testing.T) {
+	spoiler := seenPost("t3_spoiler", 10)
+	spoiler.Data.Title = "Ending of the finale explained"
+	spoiler.Data.LinkFlairText = "Spoilers"
+	gore := seenPost("t3_gore", 10)
+	gore.Data.Title = "NSFL: accident footage"
+	plain := seenPost("t3_plain", 10)
+	plain.Data.Title = "Spoiled milk recipes"
+
+	config := &Config{TagRules: []TagRule{
+		{Tag: "CW: spoilers", Keywords: []string{"spoiler", "spoilers"}, TitlePrefix: true},
+		{Tag: "gore", Pattern: `(?i)\bnsfl\b`, Feeds: []string{"homepage"}},
+	}}
+	if err := validateConfig(&Config{ClientID: "x", FeedType: "rss", OutputPath: "a.xml", TagRules: config.TagRules}); err != nil {
+		t.Fatalf("expected valid tag rules: %v", err)
+	}
+
+	p := NewPipeline(config, nil, nil)
+	posts := p.tagPosts([]RedditPost{spoiler, gore, plain}, DefaultRunOptions())
+	if got := posts[0].Data.Title; got != "[CW: spoilers] Ending of the finale explained" {
+		t.Errorf("unexpected title %q", got)
+	}
+	if tags := posts[1].Data.Tags; len(tags) != 1 || tags[0] != "gore" || posts[1].Data.Title != gore.Data.Title {
+		t.Errorf("expected gore to be tagged without a prefix, got %v %q", tags, posts[1].Data.Title)
+	}
+	if len(posts[2].Data.Tags) != 0 {
+		t.Errorf("expected keywords to match whole words only, got %v", posts[2].Data.Tags)
+	}
+
+	// Rules limited to other feeds don't apply
+	gore.Data.Tags = nil
+	if tagged := p.tagPosts([]RedditPost{gore}, RunOptions{Subreddit: "videos"}); len(tagged[0].Data.Tags) != 0 {
+		t.Errorf("expected the homepage rule not to apply to r/videos, got %v", tagged[0].Data.Tags)
+	}
+
+	fg := NewFeedGenerator(nil)
+	if item := fg.createFeedItem(posts[0], nil); !strings.Contains(item.Description, "Tags: CW: spoilers") {
+		t.Errorf("expected tags in the description:\n%s", item.Description)
+	}
+	content, err := fg.RenderFeed(posts[:1], nil, "atom", true)
+	if err != nil {
+		t.Fatalf("RenderFeed failed: %v", err)
+	}
+	if !strings.Contains(string(content), `<category term="CW: spoilers" label="CW: spoilers"/>`) {
+		t.Errorf("expected the tag as a category:\n%s", content)
+	}
+
+	for _, rules := range [][]TagRule{{{Tag: "x"}}, {{Tag: "x", Pattern: "("}}, {{Keywords: []string{"a"}}}} {
+		if _, err := compileTagRules(rules); err == nil {
+			t.Errorf("expected %+v to be rejected", rules)
+		}
+	}
+}
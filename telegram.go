@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// telegramAPI is the Bot API endpoint, replaced in tests
+var telegramAPI = "https://api.telegram.org"
+
+// Telegram limits
+const (
+	telegramCaptionLength = 1024             // Characters of photo captions
+	telegramMaxRetryAfter = 30 * time.Second // Longest flood wait honored before giving up on an item
+)
+
+// TelegramNotifier posts new items to a Telegram chat or channel through a bot, with
+// the preview image of the item attached when it has one
+type TelegramNotifier struct {
+	Token  string
+	ChatID string // Numeric chat ID or @channelname
+}
+
+// telegramResponse is the envelope of Bot API responses
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"` // Seconds to wait after hitting the flood limits
+	} `json:"parameters"`
+}
+
+// Name identifies the chat in the record of delivered items
+func (t *TelegramNotifier) Name() string {
+	return "telegram:" + t.ChatID
+}
+
+// NotifyItem posts an item as a photo with a caption, or as a text message when it
+// has no image or Telegram can't fetch the image
+func (t *TelegramNotifier) NotifyItem(ctx context.Context, item NewItem) error {
+	text := telegramText(item)
+	if item.Image != "" {
+		err := t.call(ctx, "sendPhoto", map[string]any{
+			"chat_id": t.ChatID, "photo": item.Image, "caption": text, "parse_mode": "HTML",
+		})
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return t.call(ctx, "sendMessage", map[string]any{
+		"chat_id": t.ChatID, "text": text, "parse_mode": "HTML",
+	})
+}
+
+// telegramText formats an item as a Telegram HTML message short enough for a caption
+func telegramText(item NewItem) string {
+	post := item.Post.Data
+	link := ""
+	if !post.IsSelf && post.URL != "" {
+		link = "\n" + post.URL
+	}
+	stats := fmt.Sprintf("\n%s · %d points · ", post.community(), post.Score)
+	comments := fmt.Sprintf("%d comments", post.NumComments)
+
+	// Markup doesn't count towards the caption limit, so only the title is shortened
+	title := post.Title
+	if room := telegramCaptionLength - textLength(link+stats+comments); textLength(title) > room {
+		title = truncateText(title, max(room, 1))
+	}
+	return "<b>" + html.EscapeString(title) + "</b>" + html.EscapeString(link+stats) +
+		"<a href=\"" + html.EscapeString(post.discussionURL()) + "\">" + comments + "</a>"
+}
+
+// call invokes a Bot API method, waiting once for the flood limits when Telegram asks to
+func (t *TelegramNotifier) call(ctx context.Context, method string, params map[string]any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, telegramAPI+"/bot"+t.Token+"/"+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := notifyClient.Do(req)
+		if err != nil {
+			// The URL contains the token, keep it out of logs
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("telegram %s failed: %w", method, err)
+		}
+		var result telegramResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("telegram %s returned %s", method, resp.Status)
+		}
+		if result.OK {
+			return nil
+		}
+
+		wait := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if resp.StatusCode != http.StatusTooManyRequests || attempt > 0 || wait > telegramMaxRetryAfter {
+			return fmt.Errorf("telegram %s failed: %s", method, result.Description)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTelegramNotifier(t *testing.T) {
+	type call struct {
+		Method string
+		Params map[string]string
+	}
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]string
+		json.NewDecoder(r.Body).Decode(&params)
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if !strings.HasPrefix(r.URL.Path, "/bottoken/") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		calls = append(calls, call{method, params})
+		if params["photo"] == "https://example.com/broken.png" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"description":"Bad Request: wrong file identifier"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	orig := telegramAPI
+	telegramAPI = server.URL
+	defer func() { telegramAPI = orig }()
+
+	post := func(name string, created float64, image string) RedditPost {
+		p := seenPost(name, 10)
+		p.Data.CreatedUTC = created
+		p.Data.Permalink = "/r/test/comments/" + name + "/"
+		p.Data.URL = "https://example.com/" + name
+		p.Data.Subreddit = "test"
+		if image != "" {
+			p.Data.Preview = &PostPreview{Images: []PreviewImage{{Source: ImageSource{URL: image}}}}
+		}
+		return p
+	}
+
+	p := NewPipeline(&Config{TelegramBotToken: "token", TelegramChatID: "@chan"}, nil, newTestDB(t))
+	opts := DefaultRunOptions()
+	run := func(posts ...RedditPost) {
+		p.notifyNewPosts(context.Background(), posts, nil, opts)
+		p.recordEmittedPosts(posts, opts)
+	}
+
+	run(post("t3_a", 1, ""))
+	if len(calls) != 0 {
+		t.Fatalf("expected nothing for the first generation, got %v", calls)
+	}
+
+	run(post("t3_c", 3, "https://example.com/broken.png"), post("t3_b", 2, "https://example.com/b.png"), post("t3_a", 1, ""))
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls, got %v", calls)
+	}
+	// Oldest first, falling back to text when the image can't be sent
+	if calls[0].Method != "sendPhoto" || calls[0].Params["photo"] != "https://example.com/b.png" || calls[0].Params["chat_id"] != "@chan" {
+		t.Errorf("unexpected first call %v", calls[0])
+	}
+	if calls[1].Method != "sendPhoto" || calls[2].Method != "sendMessage" || !strings.Contains(calls[2].Params["text"], "<b>Post t3_c</b>") {
+		t.Errorf("expected a photo falling back to a message, got %v", calls[1:])
+	}
+
+	// Items already sent aren't sent again
+	run(post("t3_c", 3, ""), post("t3_b", 2, ""))
+	if len(calls) != 3 {
+		t.Errorf("expected no reposts, got %v", calls[3:])
+	}
+}
+
+func TestTelegramText(t *testing.T) {
+	item := NewItem{Post: seenPost("t3_a", 42)}
+	item.Post.Data.Title = "Fish & <chips>"
+	item.Post.Data.Subreddit = "food"
+	item.Post.Data.Permalink = "/r/food/comments/a/"
+	item.Post.Data.URL = "https://example.com/?a=1&b=2"
+	item.Post.Data.NumComments = 7
+
+	want := "<b>Fish &amp; &lt;chips&gt;</b>\nhttps://example.com/?a=1&amp;b=2\nr/food · 42 points · " +
+		`<a href="https://www.reddit.com/r/food/comments/a/">7 comments</a>`
+	if got := telegramText(item); got != want {
+		t.Errorf("telegramText = %q, want %q", got, want)
+	}
+
+	item.Post.Data.Title = strings.Repeat("long ", 400)
+	if got := telegramText(item); textLength(captionText(got)) > telegramCaptionLength {
+		t.Errorf("caption of %d characters is too long", textLength(captionText(got)))
+	}
+}
+
+// captionText returns the text of an HTML fragment as Telegram counts it
+func captionText(fragment string) string {
+	var text strings.Builder
+	inTag := false
+	for _, r := range fragment {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			text.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(text.String())
+}
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Tenant layout and naming
+const (
+	DefaultTenant    = "default"     // Name of the tenant backed by the local config file
+	TenantConfigFile = "config.json" // Config file inside a tenant directory
+	TenantDBFile     = "cache.db"    // Cache database inside a tenant directory
+)
+
+// tenantNamePattern restricts tenant names to safe path and URL segments
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ErrGenerationRunning is returned when a tenant's feed is already being generated
+var ErrGenerationRunning = errors.New("feed generation already running")
+
+// Tenant is an isolated user of the serve-mode daemon with its own config,
+// tokens, cache database and feed output
+type Tenant struct {
+	Name       string
+	configPath string
+	outputDir  string
+	db         *OpenGraphDB
+
+	feed atomic.Pointer[feedSnapshot] // The last generated feed, replaced as a whole
+
+	mu          sync.Mutex // Guards the fields below
+	config      Config
+	authState   string
+	lastRun     time.Time
+	lastError   string
+	needsReauth bool // Reddit rejected the tenant's token, the user has to authorize again
+	items       int
+	rejected    FilterStats
+	discovery   []SubredditEngagement
+	runs        map[string]*feedRun // Scheduling of each feed by name
+
+	composing sync.Mutex // Held while composite feeds are written
+}
+
+// feedRun is the scheduling state of one feed of a tenant
+type feedRun struct {
+	running sync.Mutex    // Held while the feed is being generated
+	next    time.Time     // When the feed is due next, zero until it is first scheduled
+	backoff time.Duration // Lengthened interval after failures, zero while healthy
+}
+
+// feedSnapshot is a generated feed. Snapshots are never modified, so requests served
+// during a generation get either the previous feed or the new one, never a mix.
+type feedSnapshot struct {
+	content     []byte
+	contentType string
+}
+
+// TenantStatus is the public view of a tenant's state
+type TenantStatus struct {
+	Name       string      `json:"name"`
+	Authorized bool        `json:"authorized"`
+	LastRun    time.Time   `json:"last_run,omitzero"`
+	Items      int         `json:"items"`
+	Rejected   FilterStats `json:"rejected,omitempty"` // Posts rejected by each filter rule in the last run
+	Error      string      `json:"error,omitempty"`
+
+	// Subreddits often filtered out of the feed, refreshed after each generation
+	Discovery []SubredditEngagement `json:"discovery,omitempty"`
+}
+
+// openTenant loads a tenant's config and opens its cache database
+func openTenant(name, configPath, dbPath, outputDir string) (*Tenant, error) {
+	// Environment variables only override the config of the default tenant
+	var environ []string
+	if name == DefaultTenant {
+		environ = os.Environ()
+	}
+	config := DefaultConfig()
+	if err := readConfigFiles(configPath, &config, environ); err != nil {
+		return nil, err
+	}
+
+	db, err := OpenCacheDB(&config, dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tenant{
+		Name:       name,
+		configPath: configPath,
+		outputDir:  outputDir,
+		db:         db,
+		config:     config,
+	}
+
+	recoverTempFeeds(filepath.Dir(t.outputPath(time.Now())))
+
+	// Serve the previous run's output until the first generation completes
+	if content, err := os.ReadFile(t.outputPath(time.Now())); err == nil {
+		t.feed.Store(&feedSnapshot{content: content, contentType: FeedContentType(config.FeedType)})
+	}
+
+	return t, nil
+}
+
+// outputPath returns where the tenant's feed file generated at the given time is
+// written, always inside the tenant's output directory
+func (t *Tenant) outputPath(at time.Time) string {
+	return resolveOutputPath(t.config.OutputPath, t.outputDir, t.Name, t.config.FeedType, at)
+}
+
+// Feed returns the last generated feed and its content type. It doesn't wait for a
+// running generation.
+func (t *Tenant) Feed() ([]byte, string, bool) {
+	snapshot := t.feed.Load()
+	if snapshot == nil {
+		return nil, "", false
+	}
+	return snapshot.content, snapshot.contentType, true
+}
+
+// publish makes a generated feed the one served and written to the output file
+func (t *Tenant) publish(content []byte, contentType string, at time.Time) {
+	t.feed.Store(&feedSnapshot{content: content, contentType: contentType})
+	if err := t.config.saveFeed(t.outputPath(at), content, contentType); err != nil {
+		slog.Warn("Failed to write tenant feed file", "tenant", t.Name, "error", err)
+	}
+}
+
+// Status returns a snapshot of the tenant's state
+func (t *Tenant) Status() TenantStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TenantStatus{
+		Name:       t.Name,
+		Authorized: (t.config.RefreshToken != "" || t.config.AppType == AppTypeScript) && !t.needsReauth,
+		LastRun:    t.lastRun,
+		Items:      t.items,
+		Rejected:   t.rejected,
+		Error:      t.lastError,
+		Discovery:  t.discovery,
+	}
+}
+
+// tokenSource returns a token source that persists refreshed tokens to the tenant config
+func (t *Tenant) tokenSource(ctx context.Context, config *Config) InvalidatingTokenSource {
+	oauthConfig := newOAuth2Config(config)
+	token := &oauth2.Token{
+		AccessToken:  config.AccessToken,
+		RefreshToken: config.RefreshToken,
+		Expiry:       config.ExpiresAt,
+	}
+
+	base := NewRefreshableTokenSource(ctx, oauthConfig, config.AppType, token)
+	return &tenantTokenSource{tenant: t, base: base}
+}
+
+// tenantTokenSource saves tokens to the tenant config whenever they change
+type tenantTokenSource struct {
+	tenant *Tenant
+	base   *RefreshableTokenSource
+}
+
+// Invalidate makes the next Token call fetch a new token
+func (s *tenantTokenSource) Invalidate(rejected *oauth2.Token) {
+	s.base.Invalidate(rejected)
+}
+
+func (s *tenantTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.base.Token()
+	if err != nil {
+		auditAuth(s.kind(), s.tenant.Name, err)
+		return nil, err
+	}
+
+	s.tenant.mu.Lock()
+	defer s.tenant.mu.Unlock()
+	if token.AccessToken != s.tenant.config.AccessToken {
+		auditAuth(s.kind(), s.tenant.Name, nil)
+		s.tenant.config.AccessToken = token.AccessToken
+		if token.RefreshToken != "" {
+			s.tenant.config.RefreshToken = token.RefreshToken
+		}
+		s.tenant.config.ExpiresAt = token.Expiry
+		if err := saveConfigFile(s.tenant.configPath, &s.tenant.config); err != nil {
+			slog.Warn("Failed to save refreshed tenant token", "tenant", s.tenant.Name, "error", err)
+		}
+	}
+
+	return token, nil
+}
+
+// kind returns the audit event kind for a new token from this source
+func (s *tenantTokenSource) kind() string {
+	if s.tenant.config.AppType == AppTypeScript {
+		return AuditAuth
+	}
+	return AuditTokenRefresh
+}
+
+// Feeds returns the names of the tenant's feeds: the homepage, its subreddits, the
+// feeds of its subscriptions, which are generated together, its friends feed and the
+// entries of its feeds config
+func (t *Tenant) Feeds() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	feeds := []string{HomepageFeed}
+	for _, name := range t.config.Subreddits {
+		feeds = append(feeds, subredditName(name))
+	}
+	if t.config.Subscriptions != "" {
+		feeds = append(feeds, SubscriptionsFeed)
+	}
+	if t.config.FriendsFeed {
+		feeds = append(feeds, FriendsFeed)
+	}
+	feeds = append(feeds, t.config.collections()...)
+	for _, profile := range t.config.Feeds {
+		feeds = append(feeds, profile.Name)
+	}
+	return feeds
+}
+
+// feedRun returns the scheduling state of a feed
+func (t *Tenant) feedRun(feed string) *feedRun {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.runs == nil {
+		t.runs = make(map[string]*feedRun)
+	}
+	run, ok := t.runs[feed]
+	if !ok {
+		run = &feedRun{}
+		t.runs[feed] = run
+	}
+	return run
+}
+
+// due reports whether a feed should be generated at now and, if so, schedules its
+// next run: at the next time of its cron schedule, or an interval later, plus
+// jitter. The first run is delayed by the feed's splay.
+func (t *Tenant) due(feed string, now time.Time, opts ScheduleOptions) bool {
+	run := t.feedRun(feed)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if run.next.IsZero() {
+		run.next = now.Add(splayDelay(t.Name+"/"+feed, opts.Splay))
+	}
+	if now.Before(run.next) {
+		return false
+	}
+	run.next = t.nextRunLocked(feed, run, now, opts).Add(jitterDelay(opts.Jitter))
+	return true
+}
+
+// nextRunLocked returns when a feed runs after now: at the next time of its cron
+// schedule, or an interval later, and every match_thread_interval while it has live
+// match threads. A failing feed waits for its backoff first.
+func (t *Tenant) nextRunLocked(feed string, run *feedRun, now time.Time, opts ScheduleOptions) time.Time {
+	next := now.Add(max(opts.Interval, run.backoff))
+	if expr, ok := t.config.Schedules[feed]; ok {
+		if schedule, err := ParseCron(expr); err == nil {
+			next = schedule.Next(now.Add(run.backoff))
+		}
+	}
+	if t.config.MatchThreads && run.backoff == 0 && liveMatches.live(t.Name, feed) {
+		next = earliest(next, now.Add(t.config.matchThreadInterval()))
+	}
+	return next
+}
+
+// recordRun adapts the interval of a feed to the outcome of its run: every failure
+// doubles it up to the maximum interval, every success halves it back down to the
+// configured one. A failed run also postpones the next one scheduled before it.
+func (t *Tenant) recordRun(feed string, err error, now time.Time, opts ScheduleOptions) {
+	if opts.MaxInterval <= opts.Interval || errors.Is(err, ErrGenerationRunning) {
+		return
+	}
+	run := t.feedRun(feed)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		if run.backoff /= 2; run.backoff <= opts.Interval {
+			run.backoff = 0
+		}
+		return
+	}
+
+	run.backoff = min(max(run.backoff*2, opts.Interval*2), opts.MaxInterval)
+	if next := t.nextRunLocked(feed, run, now, opts); next.After(run.next) {
+		run.next = next
+	}
+	slog.Warn("Lengthening refresh interval after failure", "tenant", t.Name, "feed", feed, "backoff", run.backoff, "next", run.next)
+}
+
+// Generate runs the feed pipeline for the tenant's homepage feed and stores the result
+func (t *Tenant) Generate(ctx context.Context) error {
+	return t.GenerateFeed(ctx, HomepageFeed)
+}
+
+// GenerateFeed runs the feed pipeline for one of the tenant's feeds. Subreddit feeds
+// are written next to the homepage feed. A feed is only generated once at a time.
+func (t *Tenant) GenerateFeed(ctx context.Context, feed string) error {
+	run := t.feedRun(feed)
+	if !run.running.TryLock() {
+		return ErrGenerationRunning
+	}
+	defer run.running.Unlock()
+
+	t.mu.Lock()
+	config := t.config
+	t.mu.Unlock()
+
+	if err := t.generateFeed(ctx, config, feed); err != nil {
+		return err
+	}
+	t.generateCompositeFeeds(&config, feed)
+	return nil
+}
+
+// generateFeed generates one of the tenant's feeds with a snapshot of its config
+func (t *Tenant) generateFeed(ctx context.Context, config Config, feed string) error {
+	if config.RefreshToken == "" && config.AppType != AppTypeScript {
+		return fmt.Errorf("tenant %q is not authorized yet", t.Name)
+	}
+
+	client := NewRetryingClient(ctx, t.tokenSource(ctx, &config))
+
+	opts := DefaultRunOptions()
+	opts.Consumer = t.Name
+	if feed == SubscriptionsFeed {
+		slog.Info("Generating tenant subscription feeds", "tenant", t.Name)
+		return NewPipeline(&config, client, t.db).GenerateSubscriptions(ctx, opts, t.outputDir)
+	}
+	if profile, ok := config.feedProfile(feed); ok {
+		slog.Info("Generating tenant feed", "tenant", t.Name, "feed", feed)
+		return GenerateFeedProfile(ctx, &config, profile, client, t.db, opts, t.outputDir)
+	}
+	if feed == FriendsFeed {
+		slog.Info("Generating tenant friends feed", "tenant", t.Name)
+		return NewPipeline(&config, client, t.db).GenerateFriends(ctx, opts, t.outputDir)
+	}
+	if slices.Contains(config.collections(), feed) {
+		slog.Info("Generating tenant "+feed+" feed", "tenant", t.Name)
+		return NewPipeline(&config, client, t.db).GenerateCollection(ctx, opts, t.outputDir, feed)
+	}
+	if feed != HomepageFeed {
+		opts.Subreddit = feed
+		result, err := NewPipeline(&config, client, t.db).Generate(ctx, opts)
+		if err != nil {
+			return err
+		}
+		path := subredditOutputPath(config.OutputPath, t.outputDir, feed, config.FeedType, time.Now())
+		slog.Info("Generated tenant feed", "tenant", t.Name, "feed", feed, "items", result.Items)
+		return config.saveFeed(path, result.Content, result.ContentType)
+	}
+	result, err := NewPipeline(&config, client, t.db).Generate(ctx, opts)
+
+	var discovery []SubredditEngagement
+	if err == nil {
+		discovery = t.discover(&config)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastRun = time.Now()
+	t.needsReauth = NeedsReauth(err)
+	if err != nil {
+		t.lastError = err.Error()
+		return err
+	}
+
+	t.lastError = ""
+	t.items = result.Items
+	t.rejected = result.Rejected
+	t.discovery = discovery
+	t.publish(result.Content, result.ContentType, t.lastRun)
+
+	slog.Info("Generated tenant feed", "tenant", t.Name, "items", result.Items)
+	return nil
+}
+
+// generateCompositeFeeds regenerates the composite feeds merging a feed that was just
+// generated. A composite feed waits until all of its feeds were generated once.
+func (t *Tenant) generateCompositeFeeds(config *Config, feed string) {
+	t.composing.Lock()
+	defer t.composing.Unlock()
+	now := time.Now()
+	for _, composite := range config.compositesMerging(feed) {
+		err := config.generateCompositeFeed(composite, t.outputDir, t.Name, now)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			slog.Debug("Waiting for the feeds of composite feed", "tenant", t.Name, "feed", composite.Name, "error", err)
+		case err != nil:
+			slog.Error("Failed to generate composite feed", "tenant", t.Name, "feed", composite.Name, "error", err)
+		}
+	}
+}
+
+// discover returns the top subreddits of the discovery report for the status page
+func (t *Tenant) discover(config *Config) []SubredditEngagement {
+	const maxSubreddits = 10
+
+	report, err := DiscoverSubreddits(t.db, config, time.Now().Add(-DiscoveryWindow), 3)
+	if err != nil {
+		slog.Warn("Failed to build subreddit discovery report", "tenant", t.Name, "error", err)
+		return nil
+	}
+	if len(report) > maxSubreddits {
+		report = report[:maxSubreddits]
+	}
+	return report
+}
+
+// Close releases the tenant's database
+func (t *Tenant) Close() error {
+	return t.db.Close()
+}
+
+// TenantManager owns the tenants of a serve-mode daemon
+type TenantManager struct {
+	dir       string // Directory with one subdirectory per tenant, "" when multi-tenancy is off
+	publicURL string // Externally reachable base URL, used for OAuth redirects
+
+	mu        sync.RWMutex
+	tenants   map[string]*Tenant
+	serveLock *RunLock // Held while the default tenant is open
+
+	ctx     context.Context    // Context of feed generations, canceled by Close
+	cancel  context.CancelFunc // Stops running feed generations
+	running sync.WaitGroup     // Feed generations started with Go
+}
+
+// NewTenantManager creates a manager for tenants stored under dir
+func NewTenantManager(dir, publicURL string) *TenantManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TenantManager{
+		dir:       dir,
+		publicURL: publicURL,
+		tenants:   make(map[string]*Tenant),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Go runs a feed generation in the background. Close cancels its context and waits
+// for it, so that it finishes writing to the tenant databases first.
+func (m *TenantManager) Go(generate func(ctx context.Context)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx.Err() != nil {
+		return // Closing
+	}
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		generate(m.ctx)
+	}()
+}
+
+// AddDefault registers the local configuration as the default tenant. The serve lock
+// next to its database is held until Close, so runs don't move its files meanwhile.
+func (m *TenantManager) AddDefault(configPath, dbPath, outputDir string) error {
+	lock, err := AcquireRunLock(filepath.Join(filepath.Dir(dbPath), ServeLockFile))
+	if err != nil {
+		return fmt.Errorf("failed to lock default tenant: %w", err)
+	}
+	t, err := openTenant(DefaultTenant, configPath, dbPath, outputDir)
+	if err != nil {
+		lock.Release()
+		return fmt.Errorf("failed to open default tenant: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants[DefaultTenant] = t
+	m.serveLock = lock
+	return nil
+}
+
+// LoadAll opens every tenant found in the tenants directory
+func (m *TenantManager) LoadAll() error {
+	if m.dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read tenants directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !tenantNamePattern.MatchString(entry.Name()) || entry.Name() == DefaultTenant {
+			continue
+		}
+
+		dir := filepath.Join(m.dir, entry.Name())
+		t, err := openTenant(entry.Name(), filepath.Join(dir, TenantConfigFile), filepath.Join(dir, TenantDBFile), dir)
+		if err != nil {
+			slog.Warn("Skipping tenant", "tenant", entry.Name(), "error", err)
+			continue
+		}
+
+		m.mu.Lock()
+		m.tenants[t.Name] = t
+		m.mu.Unlock()
+		slog.Info("Loaded tenant", "tenant", t.Name)
+	}
+
+	return nil
+}
+
+// Create sets up a new tenant directory with the given configuration
+func (m *TenantManager) Create(name string, config Config) (*Tenant, error) {
+	if m.dir == "" {
+		return nil, fmt.Errorf("multi-tenant mode is not enabled")
+	}
+	if !tenantNamePattern.MatchString(name) || name == DefaultTenant {
+		return nil, fmt.Errorf("invalid tenant name %q", name)
+	}
+	if config.AppType == AppTypeScript {
+		// Password grant credentials come from the daemon's environment, so they can't be per-tenant
+		return nil, fmt.Errorf("app_type %q is only supported for the default tenant", AppTypeScript)
+	}
+	if config.RedirectURI == "" {
+		config.RedirectURI = m.callbackURL()
+	}
+	if err := validateConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.tenants[name]; exists {
+		return nil, fmt.Errorf("tenant %q already exists", name)
+	}
+
+	dir := filepath.Join(m.dir, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create tenant directory: %w", err)
+	}
+
+	configPath := filepath.Join(dir, TenantConfigFile)
+	if err := writeConfigFile(configPath, &config); err != nil {
+		return nil, err
+	}
+
+	t, err := openTenant(name, configPath, filepath.Join(dir, TenantDBFile), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m.tenants[name] = t
+	slog.Info("Created tenant", "tenant", name)
+	return t, nil
+}
+
+// Get returns the named tenant or nil
+func (m *TenantManager) Get(name string) *Tenant {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tenants[name]
+}
+
+// List returns all tenants sorted by name
+func (m *TenantManager) List() []*Tenant {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tenants := make([]*Tenant, 0, len(m.tenants))
+	for _, t := range m.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Name < tenants[j].Name })
+	return tenants
+}
+
+// BeginAuth starts the OAuth flow for a tenant and returns the authorization URL
+func (m *TenantManager) BeginAuth(name string) (string, error) {
+	t := m.Get(name)
+	if t == nil {
+		return "", fmt.Errorf("unknown tenant %q", name)
+	}
+
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	state := hex.EncodeToString(stateBytes)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.config.AppType == AppTypeScript {
+		return "", fmt.Errorf("script apps authenticate with the password grant and need no authorization")
+	}
+	t.authState = state
+
+	oauthConfig := newOAuth2Config(&t.config)
+	return oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("duration", "permanent")), nil
+}
+
+// CompleteAuth exchanges the authorization code for the tenant that issued state
+func (m *TenantManager) CompleteAuth(state, code string) (*Tenant, error) {
+	if state == "" {
+		return nil, fmt.Errorf("missing state")
+	}
+
+	for _, t := range m.List() {
+		t.mu.Lock()
+		if t.authState != state {
+			t.mu.Unlock()
+			continue
+		}
+		t.authState = ""
+		oauthConfig := newOAuth2Config(&t.config)
+		t.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		token, err := oauthConfig.Exchange(ctx, code)
+		auditAuth(AuditAuth, t.Name, err)
+		if err != nil {
+			return t, fmt.Errorf("failed to exchange authorization code: %w", explainOAuthError(err))
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.config.AccessToken = token.AccessToken
+		t.config.RefreshToken = token.RefreshToken
+		t.config.ExpiresAt = token.Expiry
+		t.needsReauth = false
+		if err := saveConfigFile(t.configPath, &t.config); err != nil {
+			return t, err
+		}
+
+		slog.Info("Tenant authorized", "tenant", t.Name)
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("unknown or expired state")
+}
+
+// GenerateAll runs the pipeline for every tenant, logging failures
+func (m *TenantManager) GenerateAll() {
+	for _, t := range m.List() {
+		if err := t.Generate(m.ctx); err != nil {
+			slog.Warn("Tenant feed generation failed", "tenant", t.Name, "error", err)
+		}
+	}
+}
+
+// GenerateDue starts the generation of every feed that is due at now: feeds with a
+// cron expression in schedules at its times, others every interval. Feeds run
+// concurrently, and a feed whose previous run hasn't finished is skipped.
+func (m *TenantManager) GenerateDue(now time.Time, opts ScheduleOptions) {
+	for _, t := range m.List() {
+		for _, feed := range t.Feeds() {
+			if !t.due(feed, now, opts) {
+				continue
+			}
+			m.Go(func(ctx context.Context) {
+				err := t.GenerateFeed(ctx, feed)
+				t.recordRun(feed, err, time.Now(), opts)
+				if errors.Is(err, ErrGenerationRunning) {
+					slog.Warn("Skipping feed run, the previous one is still running", "tenant", t.Name, "feed", feed)
+				} else if err != nil {
+					slog.Warn("Tenant feed generation failed", "tenant", t.Name, "feed", feed, "error", err)
+				}
+			})
+		}
+	}
+}
+
+// Close stops running feed generations, waits for them and releases all tenant
+// resources
+func (m *TenantManager) Close() {
+	m.mu.Lock()
+	m.cancel()
+	m.mu.Unlock()
+	m.running.Wait()
+	for _, t := range m.List() {
+		if err := t.Close(); err != nil {
+			slog.Warn("Failed to close tenant", "tenant", t.Name, "error", err)
+		}
+	}
+	if m.serveLock != nil {
+		m.serveLock.Release()
+		m.serveLock = nil
+	}
+}
+
+// callbackURL returns the OAuth redirect URI served by the daemon
+func (m *TenantManager) callbackURL() string {
+	return m.publicURL + "/callback"
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultThresholds are the score thresholds analyzed when none are given
+var DefaultThresholds = []int{0, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// ThresholdRow is the number of items per day each threshold would have produced
+type ThresholdRow struct {
+	Subreddit string    // Empty for all subreddits combined
+	Posts     int       // Distinct posts fetched
+	PerDay    []float64 // Items per day, one per threshold
+}
+
+// ThresholdReport is the outcome of replaying stored history against score thresholds
+type ThresholdReport struct {
+	Thresholds []int
+	Days       float64
+	Total      ThresholdRow
+	Subreddits []ThresholdRow // Busiest subreddits first
+}
+
+// peakEngagement is the highest score and comment count recorded for a post
+type peakEngagement struct {
+	Score       int
+	NumComments int
+}
+
+// getPeakEngagementSince returns the highest recorded score and comment count of
+// every post seen since the given time
+func (ogDB *OpenGraphDB) getPeakEngagementSince(since time.Time) (map[string]peakEngagement, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	rows, err := ogDB.db.Query(`SELECT fullname, MAX(score), MAX(num_comments) FROM post_snapshots
+		WHERE seen_at >= ? GROUP BY fullname`, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load score snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	peaks := make(map[string]peakEngagement)
+	for rows.Next() {
+		var name string
+		var peak peakEngagement
+		if err := rows.Scan(&name, &peak.Score, &peak.NumComments); err != nil {
+			return nil, fmt.Errorf("failed to scan score snapshot: %w", err)
+		}
+		peaks[name] = peak
+	}
+	return peaks, rows.Err()
+}
+
+// AnalyzeThresholds replays the posts fetched since the given time against score
+// thresholds. A post counts for a threshold when its highest recorded score reached it,
+// as it would then have made it into the feed on some run. The comment filter of
+// the config applies to every threshold.
+func AnalyzeThresholds(db *OpenGraphDB, config *Config, since time.Time, thresholds []int) (*ThresholdReport, error) {
+	peaks, err := db.getPeakEngagementSince(since)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := db.GetFetchRunsSince(HomepageSource, since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ThresholdReport{
+		Thresholds: thresholds,
+		Total:      ThresholdRow{PerDay: make([]float64, len(thresholds))},
+		Days:       1,
+	}
+	if len(runs) == 0 {
+		return report, nil
+	}
+	if span := runs[len(runs)-1].FetchedAt.Sub(runs[0].FetchedAt).Hours() / 24; span > 1 {
+		report.Days = span
+	}
+
+	names := make([]string, 0, len(peaks))
+	for name := range peaks {
+		names = append(names, name)
+	}
+	posts, err := db.GetSeenPosts(names)
+	if err != nil {
+		return nil, err
+	}
+
+	bySubreddit := make(map[string]*ThresholdRow)
+	for name, peak := range peaks {
+		subreddit := posts[name].Data.Subreddit
+		if subreddit == "" {
+			continue
+		}
+		row := bySubreddit[subreddit]
+		if row == nil {
+			row = &ThresholdRow{Subreddit: subreddit, PerDay: make([]float64, len(thresholds))}
+			bySubreddit[subreddit] = row
+		}
+		row.Posts++
+		report.Total.Posts++
+
+		if peak.NumComments < config.CommentFilter {
+			continue
+		}
+		for i, threshold := range thresholds {
+			if peak.Score >= threshold {
+				row.PerDay[i]++
+				report.Total.PerDay[i]++
+			}
+		}
+	}
+
+	for i := range thresholds {
+		report.Total.PerDay[i] /= report.Days
+	}
+	for _, row := range bySubreddit {
+		for i := range thresholds {
+			row.PerDay[i] /= report.Days
+		}
+		report.Subreddits = append(report.Subreddits, *row)
+	}
+	sort.Slice(report.Subreddits, func(i, j int) bool {
+		if report.Subreddits[i].Posts != report.Subreddits[j].Posts {
+			return report.Subreddits[i].Posts > report.Subreddits[j].Posts
+		}
+		return report.Subreddits[i].Subreddit < report.Subreddits[j].Subreddit
+	})
+
+	return report, nil
+}
+
+// parseThresholds parses a comma separated list of score thresholds
+func parseThresholds(value string) ([]int, error) {
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		threshold, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid threshold %q", part)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
+
+// runThresholds implements the thresholds subcommand
+func runThresholds(args []string) error {
+	fs := flag.NewFlagSet("thresholds", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	window := fs.Duration("window", 30*24*time.Hour, "how far back to look")
+	list := fs.String("thresholds", "", "comma separated score thresholds (default: 0,50,100,250,500,1000,2500,5000)")
+	limit := fs.Int("n", 15, "maximum number of subreddits to show")
+	fs.Parse(args)
+
+	thresholds := DefaultThresholds
+	if *list != "" {
+		var err error
+		if thresholds, err = parseThresholds(*list); err != nil {
+			return err
+		}
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil {
+		return err
+	}
+
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := AnalyzeThresholds(db, &config, time.Now().Add(-*window), thresholds)
+	if err != nil {
+		return err
+	}
+	if report.Total.Posts == 0 {
+		fmt.Println("No stored history in the window yet")
+		return nil
+	}
+
+	fmt.Printf("Items per day by minimum score over %.1f days (min comments %d, current min score %d):\n\n",
+		report.Days, config.CommentFilter, config.ScoreFilter)
+
+	fmt.Printf("%-24s %6s", "SUBREDDIT", "POSTS")
+	for _, threshold := range thresholds {
+		fmt.Printf(" %7s", ">="+strconv.Itoa(threshold))
+	}
+	fmt.Println()
+
+	printRow := func(name string, row ThresholdRow) {
+		fmt.Printf("%-24s %6d", name, row.Posts)
+		for _, perDay := range row.PerDay {
+			fmt.Printf(" %7.1f", perDay)
+		}
+		fmt.Println()
+	}
+	for i, row := range report.Subreddits {
+		if i == *limit {
+			break
+		}
+		printRow("r/"+row.Subreddit, row)
+	}
+	printRow("ALL", report.Total)
+
+	// Bar graph of the feed volume for each threshold
+	fmt.Println()
+	const width = 50
+	peak := report.Total.PerDay[0]
+	for i, threshold := range thresholds {
+		bar := 0
+		if peak > 0 {
+			bar = int(report.Total.PerDay[i] / peak * width)
+		}
+		fmt.Printf("%7s | %-*s %.1f/day\n", ">="+strconv.Itoa(threshold), width, strings.Repeat("#", bar), report.Total.PerDay[i])
+	}
+	return nil
+}
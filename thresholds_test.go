@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyzeThresholds(t *testing.T) {
+	db := newTestDB(t)
+
+	post := func(name, subreddit string, score int) RedditPost {
+		p := seenPost(name, score)
+		p.Data.Subreddit = subreddit
+		return p
+	}
+
+	start := time.Now().Add(-48 * time.Hour)
+	runs := [][]RedditPost{
+		{post("t3_a", "golang", 40), post("t3_b", "pics", 900)},
+		// t3_a peaks later, t3_c never gets far
+		{post("t3_a", "golang", 300), post("t3_c", "golang", 20)},
+	}
+	for i, run := range runs {
+		if err := db.SaveSeenPosts(run); err != nil {
+			t.Fatalf("SaveSeenPosts failed: %v", err)
+		}
+		if err := db.RecordFetchRun(HomepageSource, run, start.Add(time.Duration(i)*48*time.Hour)); err != nil {
+			t.Fatalf("RecordFetchRun failed: %v", err)
+		}
+	}
+
+	report, err := AnalyzeThresholds(db, &Config{}, start.Add(-time.Minute), []int{0, 100, 1000})
+	if err != nil {
+		t.Fatalf("AnalyzeThresholds failed: %v", err)
+	}
+
+	if report.Days != 2 {
+		t.Errorf("expected the history to span 2 days, got %v", report.Days)
+	}
+	if report.Total.Posts != 3 {
+		t.Errorf("expected 3 distinct posts, got %d", report.Total.Posts)
+	}
+	want := []float64{1.5, 1, 0}
+	for i := range want {
+		if report.Total.PerDay[i] != want[i] {
+			t.Errorf("expected %v items per day in total, got %v", want, report.Total.PerDay)
+			break
+		}
+	}
+
+	if len(report.Subreddits) != 2 || report.Subreddits[0].Subreddit != "golang" {
+		t.Fatalf("expected golang to be the busiest subreddit, got %+v", report.Subreddits)
+	}
+	if got := report.Subreddits[0].PerDay[1]; got != 0.5 {
+		t.Errorf("expected golang to produce 0.5 items per day at 100 points, got %v", got)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := parseThresholds("500, 10,100")
+	if err != nil {
+		t.Fatalf("parseThresholds failed: %v", err)
+	}
+	if len(thresholds) != 3 || thresholds[0] != 10 || thresholds[2] != 500 {
+		t.Errorf("expected sorted thresholds, got %v", thresholds)
+	}
+	if _, err := parseThresholds("10,-5"); err == nil {
+		t.Error("expected negative threshold to be rejected")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Handling of emoji in titles
+const (
+	TitleEmojiKeep  = "keep"  // Leave emoji in titles
+	TitleEmojiStrip = "strip" // Remove emoji, for readers that can't show them
+)
+
+// emojiRanges are the code points removed with title_emoji "strip": pictographs,
+// dingbats, flags, and the joiners, variation selectors and tags that combine them
+var emojiRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1}, // Zero width joiner
+		{Lo: 0x20e3, Hi: 0x20e3, Stride: 1}, // Combining keycap
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1}, // Miscellaneous symbols and dingbats
+		{Lo: 0x2b50, Hi: 0x2b55, Stride: 1},
+		{Lo: 0xfe0e, Hi: 0xfe0f, Stride: 1}, // Text and emoji variation selectors
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1}, // Pictographs, emoticons, flags and skin tones
+		{Lo: 0xe0020, Hi: 0xe007f, Stride: 1}, // Tags of subdivision flags
+	},
+}
+
+// stripEmoji removes emoji from s
+func stripEmoji(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(emojiRanges, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// normalizeTitle cleans up a post title for feeds according to the config. HTML
+// entities are already decoded when listings are read.
+func normalizeTitle(title string, config *Config) string {
+	if config.TitleEmoji == TitleEmojiStrip {
+		title = strings.Join(strings.Fields(stripEmoji(title)), " ")
+	}
+	if config.NormalizeTitles {
+		title = strings.Join(strings.Fields(norm.NFC.String(title)), " ")
+	}
+	return title
+}
+
+// normalizeTitles applies normalizeTitle to the posts of a feed
+func (p *Pipeline) normalizeTitles(posts []RedditPost) []RedditPost {
+	for i := range posts {
+		posts[i].Data.Title = normalizeTitle(posts[i].Data.Title, p.config)
+	}
+	return posts
+}
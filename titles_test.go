@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title  string
+		config Config
+		want   string
+	}{
+		{"🔥 Hot  take 👍🏽 on 🇫🇮 rust ❤️", Config{}, "🔥 Hot  take 👍🏽 on 🇫🇮 rust ❤️"},
+		{"🔥 Hot  take 👍🏽 on 🇫🇮 rust ❤️", Config{TitleEmoji: TitleEmojiStrip}, "Hot take on rust"},
+		{"👨‍👩‍👧 Family ©2024 → 50% off™", Config{TitleEmoji: TitleEmojiStrip}, "Family ©2024 → 50% off™"},
+		{"Cafe\u0301 \t opens\n today", Config{NormalizeTitles: true}, "Caf\u00e9 opens today"},
+		{"日本語のタイトル", Config{TitleEmoji: TitleEmojiStrip, NormalizeTitles: true}, "日本語のタイトル"},
+	}
+	for _, tt := range tests {
+		if got := normalizeTitle(tt.title, &tt.config); got != tt.want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// InvalidatingTokenSource is a token source that can be told its token was rejected
+type InvalidatingTokenSource interface {
+	oauth2.TokenSource
+	Invalidate(rejected *oauth2.Token)
+}
+
+// RefreshableTokenSource reuses a token until it expires like oauth2.ReuseTokenSource,
+// but also fetches a new one after the API rejected the current token
+type RefreshableTokenSource struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+	fetch oauth2.TokenSource // Obtains a new token on every call
+
+	// OnRefresh, if set, is called with every newly fetched token or error
+	OnRefresh func(token *oauth2.Token, err error)
+}
+
+// NewRefreshableTokenSource creates a token source starting with token. Script apps
+// get new tokens with the password grant, all others with the refresh token.
+func NewRefreshableTokenSource(ctx context.Context, config *oauth2.Config, appType string, token *oauth2.Token) *RefreshableTokenSource {
+	var fetch oauth2.TokenSource
+	if appType == AppTypeScript {
+		fetch = &scriptTokenSource{ctx: ctx, config: config}
+	} else {
+		fetch = &refreshTokenSource{ctx: ctx, config: config, refreshToken: token.RefreshToken}
+	}
+	return &RefreshableTokenSource{token: token, fetch: fetch}
+}
+
+// Token returns the current token, fetching a new one if it expired or was rejected
+func (s *RefreshableTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	token, err := s.fetch.Token()
+	if s.OnRefresh != nil {
+		s.OnRefresh(token, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate drops the current token if it is the rejected one. Requests that were
+// rejected concurrently with the same token thus cause a single refresh.
+func (s *RefreshableTokenSource) Invalidate(rejected *oauth2.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && rejected != nil && s.token.AccessToken == rejected.AccessToken {
+		s.token = nil
+	}
+}
+
+// refreshTokenSource redeems the refresh token on every call, keeping track of
+// refresh tokens rotated by Reddit
+type refreshTokenSource struct {
+	ctx          context.Context
+	config       *oauth2.Config
+	refreshToken string
+}
+
+func (s *refreshTokenSource) Token() (*oauth2.Token, error) {
+	// Without an access token the config's token source always refreshes
+	token, err := s.config.TokenSource(s.ctx, &oauth2.Token{RefreshToken: s.refreshToken}).Token()
+	if err != nil {
+		return nil, explainOAuthError(err)
+	}
+	if token.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken
+	}
+	return token, nil
+}
+
+// NewRetryingClient returns an HTTP client authenticating with tokens from source.
+// When a request is rejected with 401, e.g. because the token was revoked or expired
+// between check and use, the token is refreshed once and the request retried.
+func NewRetryingClient(ctx context.Context, source InvalidatingTokenSource) *http.Client {
+	base := http.DefaultTransport
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c.Transport != nil {
+		base = c.Transport
+	}
+	return &http.Client{Transport: &retryUnauthorizedTransport{source: source, base: base}}
+}
+
+// retryUnauthorizedTransport authorizes requests and retries them once after a 401
+type retryUnauthorizedTransport struct {
+	source InvalidatingTokenSource
+	base   http.RoundTripper
+}
+
+func (t *retryUnauthorizedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Only requests without a body can be sent again as is
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	slog.Warn("Reddit rejected the access token, refreshing and retrying", "url", req.URL.Path)
+	t.source.Invalidate(token)
+	fresh, refreshErr := t.source.Token()
+	if refreshErr != nil {
+		// Let the caller see the 401, so it asks the user to authenticate again
+		slog.Warn("Token refresh after 401 failed", "error", refreshErr)
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry := authorize(req, fresh)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// authorize returns a copy of req carrying the token
+func authorize(req *http.Request, token *oauth2.Token) *http.Request {
+	r := req.Clone(req.Context())
+	token.SetAuthHeader(r)
+	return r
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newRefreshTestServer serves an API accepting only the "fresh" token and a token
+// endpoint that hands it out, or fails when refreshOK is false
+func newRefreshTestServer(t *testing.T, refreshOK bool, refreshes *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if !refreshOK {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "fresh", "token_type": "bearer", "expires_in": 3600}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRefreshTestClient(server *httptest.Server) (*http.Client, *RefreshableTokenSource) {
+	config := &oauth2.Config{
+		ClientID: "test",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token", AuthStyle: oauth2.AuthStyleInHeader},
+	}
+	// The revoked token still looks valid locally
+	token := &oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	source := NewRefreshableTokenSource(context.Background(), config, AppTypeInstalled, token)
+	return NewRetryingClient(context.Background(), source), source
+}
+
+func TestRetryingClientRefreshesOn401(t *testing.T) {
+	var refreshes atomic.Int32
+	server := newRefreshTestServer(t, true, &refreshes)
+	client, _ := newRefreshTestClient(server)
+
+	for range 2 {
+		resp, err := client.Get(server.URL + "/api")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the retried request to succeed, got %d", resp.StatusCode)
+		}
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("expected exactly one refresh, got %d", n)
+	}
+}
+
+func TestRetryingClientFailsWhenRefreshFails(t *testing.T) {
+	var refreshes atomic.Int32
+	server := newRefreshTestServer(t, false, &refreshes)
+	client, _ := newRefreshTestClient(server)
+
+	resp, err := client.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the original 401 when the refresh fails, got %d", resp.StatusCode)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("expected one refresh attempt, got %d", n)
+	}
+}
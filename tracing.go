@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracing export settings
+const (
+	traceBatchSize       = 256             // Spans buffered before an export is forced
+	traceFlushInterval   = 5 * time.Second // Export interval for long-running processes
+	traceShutdownTimeout = 5 * time.Second // Time allowed for the final export on exit
+)
+
+// OTLP span kinds and status codes
+const (
+	SpanKindInternal = 1
+	SpanKindClient   = 3
+	spanStatusError  = 2
+)
+
+// Tracer collects finished spans and exports them to an OTLP/HTTP endpoint using
+// the JSON encoding, so traces can be sent to Tempo, Jaeger or an OpenTelemetry
+// collector without extra dependencies
+type Tracer struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// DefaultTracer is nil unless tracing is configured through the environment
+var DefaultTracer *Tracer
+
+// Span is a timed operation within a trace
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  map[string]any
+	errMsg string
+}
+
+type spanContextKey struct{}
+
+// InitTracing enables tracing when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT is set, following the OpenTelemetry conventions
+func InitTracing() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		slog.Warn("Only the http/json OTLP protocol is supported, ignoring OTEL_EXPORTER_OTLP_PROTOCOL", "protocol", protocol)
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "red-rss"
+	}
+
+	DefaultTracer = NewTracer(endpoint, serviceName, parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")))
+	go DefaultTracer.flushPeriodically()
+	slog.Debug("Tracing enabled", "endpoint", endpoint, "service", serviceName)
+}
+
+// NewTracer creates a tracer exporting to the given OTLP/HTTP traces endpoint
+func NewTracer(endpoint, serviceName string, headers map[string]string) *Tracer {
+	return &Tracer{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// parseOTLPHeaders parses the "key1=value1,key2=value2" header format
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+// StartSpan starts a span as a child of the span in ctx. When tracing is disabled
+// it returns a nil span whose methods are no-ops.
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if DefaultTracer == nil {
+		return ctx, nil
+	}
+	return DefaultTracer.Start(ctx, name, kind)
+}
+
+// Start starts a span as a child of the span in ctx
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  make(map[string]any),
+	}
+
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttributes sets attributes from alternating key/value pairs, like slog
+func (s *Span) SetAttributes(keyValues ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if key, ok := keyValues[i].(string); ok {
+			s.attrs[key] = keyValues[i+1]
+		}
+	}
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	full := len(s.tracer.spans) >= traceBatchSize
+	s.tracer.mu.Unlock()
+
+	if full {
+		go s.tracer.Flush(context.Background())
+	}
+}
+
+// flushPeriodically exports buffered spans for long-running processes
+func (t *Tracer) flushPeriodically() {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.Flush(context.Background())
+	}
+}
+
+// Flush exports all buffered spans
+func (t *Tracer) Flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		slog.Warn("Failed to encode trace spans", "error", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("Failed to create trace export request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		slog.Warn("Failed to export trace spans", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("Trace export rejected", "status", resp.Status)
+	}
+}
+
+// ShutdownTracing exports any spans still buffered before the process exits
+func ShutdownTracing() {
+	if DefaultTracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+	defer cancel()
+	DefaultTracer.Flush(ctx)
+}
+
+// encode converts spans to the OTLP JSON ExportTraceServiceRequest format
+func (t *Tracer) encode(spans []*Span) map[string]any {
+	encoded := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := map[string]any{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        encodeAttributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.errMsg != "" {
+			span["status"] = map[string]any{"code": spanStatusError, "message": s.errMsg}
+		}
+		s.mu.Unlock()
+		encoded = append(encoded, span)
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": encodeAttributes(map[string]any{
+					"service.name":    t.serviceName,
+					"service.version": Version,
+				}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "red-rss"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// encodeAttributes converts attributes to OTLP KeyValue JSON
+func encodeAttributes(attrs map[string]any) []map[string]any {
+	encoded := make([]map[string]any, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]any
+		switch value := value.(type) {
+		case string:
+			v = map[string]any{"stringValue": value}
+		case bool:
+			v = map[string]any{"boolValue": value}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			v = map[string]any{"doubleValue": value}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, map[string]any{"key": key, "value": v})
+	}
+	return encoded
+}
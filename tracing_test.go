@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracerExportsSpans(t *testing.T) {
+	var received struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       *struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var authHeader string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid export payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(server.URL+"/v1/traces", "red-rss-test", parseOTLPHeaders("Authorization=Basic abc"))
+
+	ctx, root := tracer.Start(context.Background(), "generate", SpanKindInternal)
+	_, child := tracer.Start(ctx, "reddit GET", SpanKindClient)
+	child.SetAttributes("http.response.status_code", 500)
+	child.RecordError(errors.New("HTTP 500"))
+	child.End()
+	root.End()
+	tracer.Flush(context.Background())
+
+	if authHeader != "Basic abc" {
+		t.Errorf("expected configured headers to be sent, got %q", authHeader)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload structure: %+v", received)
+	}
+
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	exportedChild, exportedRoot := spans[0], spans[1]
+	if exportedChild.TraceID != exportedRoot.TraceID {
+		t.Errorf("child and root should share a trace ID")
+	}
+	if exportedChild.ParentSpanID != exportedRoot.SpanID || exportedRoot.ParentSpanID != "" {
+		t.Errorf("unexpected parent linkage: child parent %q, root %q", exportedChild.ParentSpanID, exportedRoot.SpanID)
+	}
+	if exportedChild.Status == nil || exportedChild.Status.Code != spanStatusError {
+		t.Errorf("expected error status on child span")
+	}
+}
+
+func TestStartSpanWithoutTracer(t *testing.T) {
+	ctx := context.Background()
+	got, span := StartSpan(ctx, "noop", SpanKindInternal)
+	if span != nil || got != ctx {
+		t.Fatalf("expected no-op span when tracing is disabled")
+	}
+
+	// Methods on the nil span must be safe to call
+	span.SetAttributes("key", "value")
+	span.RecordError(errors.New("ignored"))
+	span.End()
+}
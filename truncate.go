@@ -0,0 +1,179 @@
+package main
+
+import (
+	"html"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// ellipsis marks truncated text
+const ellipsis = "…"
+
+// Text is truncated by user-perceived characters, so a cut never splits a rune, an
+// emoji sequence or a letter from its accents.
+
+// graphemes splits text into user-perceived characters: a character with the combining
+// marks, variation selectors and emoji modifiers that follow it, emoji joined by zero
+// width joiners, and flags made of two regional indicators. It approximates the Unicode
+// grapheme cluster rules closely enough for truncation.
+func graphemes(text string) []string {
+	var clusters []string
+	start := 0
+	var prev rune
+	regional := 0
+	for i, r := range text {
+		joined := i > start && (extendsGrapheme(r) || prev == zeroWidthJoiner ||
+			(isRegionalIndicator(r) && regional%2 == 1))
+		if !joined && i > start {
+			clusters = append(clusters, text[start:i])
+			start = i
+			regional = 0
+		}
+		if isRegionalIndicator(r) {
+			regional++
+		}
+		prev = r
+	}
+	if start < len(text) {
+		clusters = append(clusters, text[start:])
+	}
+	return clusters
+}
+
+// zeroWidthJoiner joins emoji into a single one, e.g. in family emoji
+const zeroWidthJoiner = '\u200d'
+
+// extendsGrapheme reports whether r belongs to the character before it
+func extendsGrapheme(r rune) bool {
+	return r == zeroWidthJoiner ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // Emoji skin tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F) // Tags of subdivision flags
+}
+
+// isRegionalIndicator reports whether r is one of the letters flags are made of
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// textLength returns the number of user-perceived characters of text
+func textLength(text string) int {
+	return len(graphemes(text))
+}
+
+// truncateText shortens text to at most limit characters, ellipsis included. It cuts
+// after the last complete sentence that keeps at least half of the limit, otherwise
+// at the last word boundary in the same half, and cuts inside a word only when there
+// is none, e.g. in a long URL. A limit of 0 or less means no limit.
+func truncateText(text string, limit int) string {
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+	chars := graphemes(text)
+	if len(chars) <= limit {
+		return text
+	}
+
+	cut := chars[:limit-1]
+	if end := sentenceEnd(cut); end >= limit/2 {
+		return strings.TrimRightFunc(strings.Join(cut[:end], ""), unicode.IsSpace) + ellipsis
+	}
+	if isSpace(chars[len(cut)]) {
+		return strings.TrimRightFunc(strings.Join(cut, ""), unicode.IsSpace) + ellipsis
+	}
+	for i := len(cut) - 1; i >= limit/2; i-- {
+		if isSpace(cut[i]) {
+			return strings.TrimRightFunc(strings.Join(cut[:i], ""), unicode.IsSpace) + ellipsis
+		}
+	}
+	return strings.Join(cut, "") + ellipsis
+}
+
+// isSpace reports whether a character is white space
+func isSpace(char string) bool {
+	r, _ := utf8.DecodeRuneInString(char)
+	return unicode.IsSpace(r)
+}
+
+// sentenceEnd returns the number of characters up to the end of the last complete
+// sentence, or 0. A sentence ends at a line break, at ., ! or ? followed by a space,
+// or at a full-width stop as in Chinese and Japanese.
+func sentenceEnd(chars []string) int {
+	for i := len(chars) - 1; i >= 0; i-- {
+		switch chars[i] {
+		case "\n", "。", "！", "？":
+			return i + 1
+		case ".", "!", "?":
+			if i+1 < len(chars) && isSpace(chars[i+1]) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// truncateHTML shortens an HTML fragment to at most limit characters of text, ellipsis
+// included, as truncateText does, and closes the elements left open by the cut. Markup
+// doesn't count towards the limit. A limit of 0 or less means no limit.
+func truncateHTML(fragment string, limit int) string {
+	if limit <= 0 || textLength(fragment) <= limit {
+		return fragment
+	}
+
+	var out strings.Builder
+	var open []string
+	remaining := limit
+	z := nethtml.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			if z.Err() != io.EOF {
+				return truncateText(fragment, limit)
+			}
+			return out.String()
+		}
+
+		switch tt {
+		case nethtml.TextToken:
+			text := html.UnescapeString(string(z.Raw()))
+			length := textLength(text)
+			if length <= remaining {
+				out.Write(z.Raw())
+				remaining -= length
+				continue
+			}
+			// truncateText takes a limit of 0 as none, when the earlier text used the whole limit
+			if remaining > 0 {
+				out.WriteString(html.EscapeString(truncateText(text, remaining)))
+			}
+			for i := len(open) - 1; i >= 0; i-- {
+				out.WriteString("</" + open[i] + ">")
+			}
+			return out.String()
+		case nethtml.StartTagToken:
+			name, _ := z.TagName()
+			if !voidElements[string(name)] {
+				open = append(open, string(name))
+			}
+		case nethtml.EndTagToken:
+			name, _ := z.TagName()
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		}
+		out.Write(z.Raw())
+	}
+}
+
+// voidElements are the HTML elements without an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name, text string
+		limit      int
+		want       string
+	}{
+		{"short", "Short text.", 20, "Short text."},
+		{"no limit", "Short text.", 0, "Short text."},
+		{"sentence", "First sentence here. Second one is longer.", 30, "First sentence here.…"},
+		{"word", "A rather long sentence without any stop", 20, "A rather long…"},
+		{"no spaces", "https://example.com/a/very/long/path", 20, "https://example.com…"},
+		{"multibyte", "äöüäöüäöüäöü", 5, "äöüä…"},
+		{"cjk", "今日は晴れです。明日は雨が降るでしょう", 12, "今日は晴れです。…"},
+		{"cjk without stops", "東京都渋谷区神南一丁目", 6, "東京都渋谷…"},
+		{"accents", "Cafe\u0301 cre\u0300me bru\u0302le\u0301e", 8, "Cafe\u0301…"},
+		{"zwj emoji", "👨\u200d👩\u200d👧\u200d👦👨\u200d👩\u200d👧\u200d👦👨\u200d👩\u200d👧\u200d👦", 2, "👨\u200d👩\u200d👧\u200d👦…"},
+		{"skin tones", "👍🏽👍🏽👍🏽👍🏽", 3, "👍🏽👍🏽…"},
+		{"flags", "🇫🇮🇸🇪🇳🇴🇩🇰", 3, "🇫🇮🇸🇪…"},
+	}
+	for _, tt := range tests {
+		got := truncateText(tt.text, tt.limit)
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: %q is not valid UTF-8", tt.name, got)
+		}
+		if tt.limit > 0 && textLength(got) > tt.limit {
+			t.Errorf("%s: %q is longer than %d characters", tt.name, got, tt.limit)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	for text, want := range map[string]int{
+		"":              0,
+		"abc":           3,
+		"e\u0301":       1,
+		"❤\ufe0f":       1,
+		"👩\u200d💻 code": 6,
+		"🇫🇮🇸":           2,
+		"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f": 1,
+	} {
+		if got := textLength(text); got != want {
+			t.Errorf("expected %d characters in %q, got %d (%q)", want, text, got, graphemes(text))
+		}
+	}
+}
+
+func TestCleanupOpenGraphData(t *testing.T) {
+	og := &OpenGraphData{
+		Title:       strings.Repeat("日本語", 100),
+		Description: strings.Repeat("🙂 ", 400),
+	}
+	og = NewOpenGraphFetcher(nil).cleanupOpenGraphData(og)
+	if !utf8.ValidString(og.Title) || textLength(og.Title) > maxOpenGraphTitle {
+		t.Errorf("expected a valid title of at most %d characters, got %d", maxOpenGraphTitle, textLength(og.Title))
+	}
+	if !utf8.ValidString(og.Description) || textLength(og.Description) > maxOpenGraphDescription {
+		t.Errorf("expected a valid description of at most %d characters, got %d", maxOpenGraphDescription, textLength(og.Description))
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	fragment := `<div class="selftext"><p>First <em>paragraph</em> &amp; more.</p><p>Second <strong>paragraph that goes on</strong></p></div>`
+
+	got := truncateHTML(fragment, 41)
+	want := `<div class="selftext"><p>First <em>paragraph</em> &amp; more.</p><p>Second <strong>paragraph…</strong></p></div>`
+	if got != want {
+		t.Errorf("expected text cut and elements closed:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := truncateHTML(fragment, 1000); got != fragment {
+		t.Errorf("expected short fragments unchanged, got %s", got)
+	}
+	if got := truncateHTML(`<p>a<br>b <img src="x.png">c d e f g h</p>`, 6); strings.Contains(got, "</br>") || !strings.HasSuffix(got, "</p>") {
+		t.Errorf("expected void elements left unclosed, got %s", got)
+	}
+	// The earlier text uses the whole limit
+	if got := truncateHTML(`<p>First</p><p>Second</p>`, 5); got != `<p>First</p><p></p>` {
+		t.Errorf("expected nothing after the limit is used up, got %s", got)
+	}
+}
@@ -11,6 +11,7 @@ import (
 type Config struct {
 	ClientID      string    `json:"client_id"`
 	ClientSecret  string    `json:"client_secret"` // This will be empty for "installed app" type
+	AppType       string    `json:"app_type"`      // "installed" (default), "web" or "script"
 	RedirectURI   string    `json:"redirect_uri"`
 	AccessToken   string    `json:"access_token"`
 	RefreshToken  string    `json:"refresh_token"`
@@ -20,27 +21,262 @@ type Config struct {
 	FeedType      string    `json:"feed_type"`     // "rss" or "atom"
 	EnhancedAtom  bool      `json:"enhanced_atom"` // Use enhanced Atom features
 	OutputPath    string    `json:"output_path"`
+	UserAgent     string    `json:"user_agent"`    // Overrides the User-Agent sent to the Reddit API
+	FeedUpdated   string    `json:"feed_updated"`  // Feed updated time: "newest_item" (default), "content_change" or "now"
+	RemovedPosts  string    `json:"removed_posts"` // Posts removed on Reddit: "keep" (default), "annotate", "drop" or "tombstone"
+	ShowFlair     bool      `json:"show_flair"`    // Show author flair and [MOD]/[ADMIN] labels in items
+
+	DistinguishedPosts   string  `json:"distinguished_posts"`    // Mod/admin posts: "include" (default), "exclude" or "only"
+	PostTypes            string  `json:"post_types"`             // Posts included: "all" (default), "links" or "discussions"
+	ShowAwards           bool    `json:"show_awards"`            // Show awards and gildings in items
+	ImageEnclosures      bool    `json:"image_enclosures"`       // Attach i.redd.it images to their items
+	PreviewEnclosures    bool    `json:"preview_enclosures"`     // Attach preview images, thumbnails or og:image to items
+	ReadingTime          bool    `json:"reading_time"`           // Show reading time estimates of linked articles
+	MinAwards            int     `json:"min_awards"`             // Only include posts with at least this many awards
+	MinUpvoteRatio       float64 `json:"min_upvote_ratio"`       // Only include posts with at least this share of upvotes, e.g. 0.85
+	CanonicalURLs        bool    `json:"canonical_urls"`         // Rewrite AMP and mobile links to the canonical desktop URL
+	SelfPostLinks        string  `json:"self_post_links"`        // Self posts linking to a single page: "ignore" (default), "preview" or "link"
+	TitleEmoji           string  `json:"title_emoji"`            // Emoji in titles: "keep" (default) or "strip"
+	NormalizeTitles      bool    `json:"normalize_titles"`       // NFC-normalize titles and collapse their whitespace
+	MaxDescriptionLength int     `json:"max_description_length"` // Characters of item descriptions, 0 for no limit
+	EventTimezone        string  `json:"event_timezone"`         // Time zone event start times are shown in, e.g. "Europe/Helsinki" (default UTC)
+	EventLeadTime        string  `json:"event_lead_time"`        // Hold back event posts until this long before they start, e.g. "2h"
+	MatchThreads         bool    `json:"match_threads"`          // Show the scores of match threads and refresh feeds with live ones every match_thread_interval
+	MatchThreadInterval  string  `json:"match_thread_interval"`  // Refresh interval of feeds with live match threads (default "2m")
+	ChannelImage         bool    `json:"channel_image"`          // Use the og:image of the top post as the feed image
+	ShowProvenance       bool    `json:"show_provenance"`        // Show why each item is in the feed: its source, filters, matched rules, score and run
+
+	TopComments       int `json:"top_comments"`        // Top comments shown in each item, 0 for none
+	CommentDepth      int `json:"comment_depth"`       // Levels of replies shown, 1 (default) for top-level comments only
+	CommentsPerMinute int `json:"comments_per_minute"` // Comment fetches per minute (default 60)
+
+	BotPosts   string   `json:"bot_posts"`   // Posts by bots: "include" (default), "tag" or "exclude"
+	BotAuthors []string `json:"bot_authors"` // Additional bot accounts
+	NotBots    []string `json:"not_bots"`    // Accounts the bot heuristics got wrong
+
+	TagRules []TagRule `json:"tag_rules"` // Keyword and pattern rules that tag items
+
+	FilterPresets []string     `json:"filter_presets"` // Shipped filter rule bundles, e.g. "no-politics"
+	FilterRules   []FilterRule `json:"filter_rules"`   // Keyword, pattern, subreddit and domain rules that drop posts
+
+	RedditDomains  []string `json:"reddit_domains"`  // Domains of Reddit's own links, replacing the defaults when set
+	BlockedDomains []string `json:"blocked_domains"` // Domains whose pages aren't fetched, replacing the defaults when set
+
+	ArchiveDir string `json:"archive_dir"` // Directory where copies of linked pages are saved, empty to not archive
+	ArchiveURL string `json:"archive_url"` // URL archive_dir is served at, file URLs are linked when empty
+
+	OnlyNewPosts      bool   `json:"only_new_posts"`      // Only include posts that weren't in an earlier feed
+	MaxPages          int    `json:"max_pages"`           // Homepage pages of 100 posts fetched per run (default 1, at most 10)
+	DifferentialFetch bool   `json:"differential_fetch"`  // Only fetch posts newer than the last run
+	FullFetchInterval string `json:"full_fetch_interval"` // How often differential mode does a full fetch, e.g. "6h"
+	HostDelay         string `json:"host_delay"`          // Time between OpenGraph fetches from the same host, e.g. "1s"; "0" disables
+
+	HistoryMaxAge    string `json:"history_max_age"`     // How long fetch history is kept, e.g. "720h" (default 90 days, "0" keeps it forever)
+	HistoryMaxPosts  int    `json:"history_max_posts"`   // Maximum number of stored posts
+	HistoryMaxSizeMB int    `json:"history_max_size_mb"` // Maximum size of the history tables in megabytes
+
+	Schedule       string `json:"schedule"`        // Keep running and generate the feeds every interval, e.g. "15m", or on a cron expression
+	ScheduleJitter string `json:"schedule_jitter"` // Maximum random delay added to scheduled runs, e.g. "2m"
+
+	Blend []BlendSource `json:"blend"` // Listings blended into the homepage feed, e.g. r/popular
+
+	CompositeFeeds []CompositeFeed `json:"composite_feeds"` // Feeds merged from other feeds after every run
+
+	Subreddits    []string          `json:"subreddits"`     // Subreddits to generate feeds for besides the homepage
+	SubredditSort string            `json:"subreddit_sort"` // Sort of subreddit feeds: "hot" (default), "new", "top" or "rising"
+	Schedules     map[string]string `json:"schedules"`      // Cron expressions of feeds in serve mode, by "homepage" or subreddit name
+
+	Subscriptions    string `json:"subscriptions"`     // Feeds of the subscribed subreddits: "merged" or "per_subreddit", empty for none
+	MaxSubscriptions int    `json:"max_subscriptions"` // Maximum number of subscribed subreddits used (default 50)
+	FriendsFeed      bool   `json:"friends_feed"`      // Generate a feed of posts by friends and followed users
+	SavedFeed        bool   `json:"saved_feed"`        // Generate a feed of the posts the user saved
+	UpvotedFeed      bool   `json:"upvoted_feed"`      // Generate a feed of the posts the user upvoted
+	RepliesFeed      bool   `json:"replies_feed"`      // Generate a feed of replies to the user's posts and comments
+
+	Feeds []FeedProfile `json:"feeds"` // Additional feeds with their own source and config keys
+
+	RateLimitLedger  string `json:"rate_limit_ledger"`  // SQLite file to share the Reddit API budget with other red-rss processes
+	MetricsFile      string `json:"metrics_file"`       // node_exporter textfile collector file written after one-shot runs, e.g. "/var/lib/node_exporter/red_rss.prom"
+	NotifyWebhook    string `json:"notify_webhook"`     // URL that gets a JSON summary of the new items of each feed after a run
+	TelegramBotToken string `json:"telegram_bot_token"` // Token of the Telegram bot that posts new items, empty for none
+	TelegramChatID   string `json:"telegram_chat_id"`   // Chat or channel new items are posted to, e.g. "@mychannel"
+	DiscordWebhook   string `json:"discord_webhook"`    // Discord webhook URL new items are posted to as embeds, empty for none
+	SFTPHost         string `json:"sftp_host"`          // Host feeds are uploaded to over SFTP after generation, e.g. "user@example.com:22"
+	SFTPPath         string `json:"sftp_path"`          // Remote directory of uploaded feeds
+	SFTPKeyFile      string `json:"sftp_key_file"`      // SSH private key used for uploads, the SSH defaults when empty
+	TokenStorage     string `json:"token_storage"`      // Where tokens are stored: "file" (default) or "keyring"
+	EncryptCache     bool   `json:"encrypt_cache"`      // Encrypt cached pages and stored posts with a key from the OS keyring or RED_RSS_CACHE_KEY
+
+	tokensInKeyring bool // The tokens were loaded from or saved to the OS keyring, so they stay out of the file
+	envOverridden   bool // Environment variables override keys, so only the tokens are saved
 }
 
-// RedditPost represents a simplified Reddit post structure for our needs
+// RedditPost represents a Reddit thing as it appears in listings
 type RedditPost struct {
-	Data struct {
-		Title       string  `json:"title"`
-		URL         string  `json:"url"`
-		Permalink   string  `json:"permalink"`
-		CreatedUTC  float64 `json:"created_utc"`
-		Score       int     `json:"score"`
-		NumComments int     `json:"num_comments"`
-		Author      string  `json:"author"`
-		Subreddit   string  `json:"subreddit"`
-	} `json:"data"`
+	Kind string   `json:"kind"` // Thing kind, "t3" for posts
+	Data PostData `json:"data"`
+}
+
+// PostData holds the fields of a Reddit post (t3) that we use
+type PostData struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"` // Fullname, e.g. t3_abc123
+	Title           string       `json:"title"`
+	URL             string       `json:"url"`
+	Permalink       string       `json:"permalink"`
+	CreatedUTC      float64      `json:"created_utc"`
+	Score           int          `json:"score"`
+	NumComments     int          `json:"num_comments"`
+	Author          string       `json:"author"`
+	Subreddit       string       `json:"subreddit"`
+	Over18          bool         `json:"over_18"`
+	Stickied        bool         `json:"stickied"`
+	LinkFlairText   string       `json:"link_flair_text"`
+	UpvoteRatio     float64      `json:"upvote_ratio"`
+	Domain          string       `json:"domain"`
+	IsSelf          bool         `json:"is_self"`
+	Selftext        string       `json:"selftext"`
+	SelftextHTML    string       `json:"selftext_html,omitempty"` // Rendered body of self posts, HTML-escaped
+	Preview         *PostPreview `json:"preview,omitempty"`
+	Thumbnail       string       `json:"thumbnail,omitempty"` // Thumbnail URL, or a placeholder such as "self" or "default"
+	ThumbnailWidth  int          `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int          `json:"thumbnail_height,omitempty"`
+	Media           *PostMedia   `json:"media,omitempty"`
+	CrosspostParent string       `json:"crosspost_parent,omitempty"` // Fullname of the original post
+	Origin          string       `json:"origin,omitempty"`           // "lemmy", "kbin" or "hackernews" for posts from other sites, empty for Reddit
+	AuthorURL       string       `json:"author_url,omitempty"`       // Profile of authors on other sites
+
+	RemovedByCategory string    `json:"removed_by_category,omitempty"` // Why the post was removed, e.g. "moderator" or "deleted"
+	AuthorFlairText   string    `json:"author_flair_text,omitempty"`
+	Distinguished     string    `json:"distinguished,omitempty"` // "moderator", "admin" or "special" for official posts
+	PollData          *PollData `json:"poll_data,omitempty"`
+
+	EventStart  float64 `json:"event_start,omitempty"`   // Start of scheduled posts such as AMAs or match threads, seconds since the epoch
+	EventEnd    float64 `json:"event_end,omitempty"`     // End of the event, seconds since the epoch
+	EventIsLive bool    `json:"event_is_live,omitempty"` // The event is taking place
+
+	TotalAwardsReceived int            `json:"total_awards_received"`
+	Gildings            map[string]int `json:"gildings,omitempty"` // Gilding counts by kind, e.g. "gid_2" for gold
+
+	IsGallery     bool                     `json:"is_gallery,omitempty"`
+	GalleryData   *GalleryData             `json:"gallery_data,omitempty"`
+	MediaMetadata map[string]MediaMetadata `json:"media_metadata,omitempty"` // Gallery and inline media by media ID
+
+	Tags       []string  `json:"-"` // Tags attached by tag_rules
+	ArchiveURL string    `json:"-"` // Archived copy of the linked page, when archive_dir is set
+	Tombstone  string    `json:"-"` // Why the post was removed after it was in a feed, shown instead of its content
+	Comments   []Comment `json:"-"` // Top comments, when top_comments is set
+
+	OutboundURL string    `json:"-"` // The page a self post links to, previewed with self_post_links "preview"
+	ChangedAt   time.Time `json:"-"` // When the item last changed after it was posted, e.g. the score of a match thread
+	Matched     []string  `json:"-"` // Rules that selected the post, e.g. keep rules and pins, shown with show_provenance
+	Duplicates  int       `json:"-"` // Copies of the post dropped while fetching, e.g. a link also on Hacker News
+}
+
+// PollData holds the options and results of a poll post
+type PollData struct {
+	Options            []PollOption `json:"options"`
+	TotalVoteCount     int          `json:"total_vote_count"`
+	VotingEndTimestamp float64      `json:"voting_end_timestamp"` // Milliseconds since the epoch
+}
+
+// PollOption is a poll choice. Reddit only reports vote counts once the poll closed
+// or the user voted.
+type PollOption struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	VoteCount *int   `json:"vote_count,omitempty"`
+}
+
+// VotingEnds returns when the poll closes
+func (p *PollData) VotingEnds() time.Time {
+	return time.UnixMilli(int64(p.VotingEndTimestamp))
+}
+
+// Open reports whether the poll still accepts votes
+func (p *PollData) Open() bool {
+	return time.Now().Before(p.VotingEnds())
+}
+
+// PostPreview holds the preview images Reddit generates for link posts
+type PostPreview struct {
+	Images  []PreviewImage `json:"images"`
+	Enabled bool           `json:"enabled"`
+}
+
+// PreviewImage is a preview image with its downscaled variants
+type PreviewImage struct {
+	Source      ImageSource   `json:"source"`
+	Resolutions []ImageSource `json:"resolutions"`
+}
+
+// ImageSource is a single image URL with its dimensions. Reddit HTML-escapes the URL
+// unless the request asks for raw_json.
+type ImageSource struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// GalleryData is the order of the images of a gallery post
+type GalleryData struct {
+	Items []GalleryItem `json:"items"`
+}
+
+// GalleryItem is an image of a gallery with its optional caption and link
+type GalleryItem struct {
+	MediaID     string `json:"media_id"` // Key in PostData.MediaMetadata
+	Caption     string `json:"caption,omitempty"`
+	OutboundURL string `json:"outbound_url,omitempty"`
+}
+
+// MediaMetadata describes an image uploaded to Reddit
+type MediaMetadata struct {
+	Status string      `json:"status"` // "valid" once processed
+	Kind   string      `json:"e"`      // "Image" or "AnimatedImage"
+	MIME   string      `json:"m"`
+	Source MediaSource `json:"s"`
+}
+
+// MediaSource is the full-size version of an uploaded image. Reddit HTML-escapes the
+// URLs unless the request asks for raw_json.
+type MediaSource struct {
+	URL    string `json:"u,omitempty"`
+	GIF    string `json:"gif,omitempty"`
+	MP4    string `json:"mp4,omitempty"`
+	Width  int    `json:"x"`
+	Height int    `json:"y"`
+}
+
+// PostMedia describes embedded media such as Reddit-hosted videos or oEmbeds
+type PostMedia struct {
+	Type        string       `json:"type,omitempty"` // Embed provider domain, e.g. "youtube.com"
+	RedditVideo *RedditVideo `json:"reddit_video,omitempty"`
+	OEmbed      *MediaOEmbed `json:"oembed,omitempty"`
+}
+
+// RedditVideo is a video hosted on v.redd.it
+type RedditVideo struct {
+	FallbackURL string `json:"fallback_url"`
+	HLSURL      string `json:"hls_url"`
+	Duration    int    `json:"duration"`
+	IsGIF       bool   `json:"is_gif"`
+}
+
+// MediaOEmbed is the oEmbed data of an embedded third-party media link
+type MediaOEmbed struct {
+	ProviderName string `json:"provider_name"`
+	Title        string `json:"title"`
+	ThumbnailURL string `json:"thumbnail_url"`
 }
 
 // RedditListing represents the structure of the Reddit API response for listings
 type RedditListing struct {
+	Kind string `json:"kind"`
 	Data struct {
 		Children []RedditPost `json:"children"`
 		After    string       `json:"after"`
+		Before   string       `json:"before"`
 	} `json:"data"`
 }
 
@@ -51,10 +287,63 @@ type OpenGraphData struct {
 	Description string    `json:"description"`
 	Image       string    `json:"image"`
 	SiteName    string    `json:"site_name"`
+	WordCount   int       `json:"word_count,omitempty"` // Words of the article text, for reading time estimates
+	Canonical   string    `json:"canonical,omitempty"`  // The rel=canonical link of the page
 	FetchedAt   time.Time `json:"fetched_at"`
 	ExpiresAt   time.Time `json:"expires_at"`
 }
 
+// Reddit OAuth app types
+const (
+	AppTypeInstalled = "installed" // Browser flow without a client secret
+	AppTypeWeb       = "web"       // Browser flow with a client secret
+	AppTypeScript    = "script"    // Password grant with a client secret, for trusted servers
+)
+
+// Handling of posts that were removed or deleted on Reddit
+const (
+	RemovedKeep      = "keep"      // Leave them as they are
+	RemovedAnnotate  = "annotate"  // Prefix the title with [removed] or [deleted]
+	RemovedDrop      = "drop"      // Leave them out of the feed
+	RemovedTombstone = "tombstone" // Drop new ones, replace the content of ones already in a feed
+)
+
+// Filtering of link and self posts
+const (
+	PostTypesAll         = "all"         // Every post
+	PostTypesLinks       = "links"       // Link posts only, e.g. for a feed of articles
+	PostTypesDiscussions = "discussions" // Self posts only, e.g. for a feed of discussions
+)
+
+// Filtering of posts distinguished by moderators or admins
+const (
+	DistinguishedInclude = "include"
+	DistinguishedExclude = "exclude"
+	DistinguishedOnly    = "only"
+)
+
+// Handling of posts by bots
+const (
+	BotInclude = "include" // Treat them like any other post
+	BotTag     = "tag"     // Prefix the title with [BOT]
+	BotExclude = "exclude" // Leave them out of the feed
+)
+
+// Subreddit listing sorts
+const (
+	SortHot    = "hot"
+	SortNew    = "new"
+	SortTop    = "top"
+	SortRising = "rising"
+)
+
+// Feed updated time semantics
+const (
+	UpdatedNewestItem    = "newest_item"    // Creation time of the newest item
+	UpdatedContentChange = "content_change" // Last run that changed the set of items
+	UpdatedNow           = "now"            // Time of every run
+)
+
 // Global constants
 const (
 	ConfigFileName      = "reddit_feed_config.json"
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// postTime returns when a post was created, in UTC
+func postTime(post RedditPost) time.Time {
+	return time.Unix(int64(post.Data.CreatedUTC), 0).UTC()
+}
+
+// itemUpdated returns when the item of a post last changed: when it was posted, or
+// later, e.g. when the score of its match thread changed
+func itemUpdated(post RedditPost) time.Time {
+	if created := postTime(post); !post.Data.ChangedAt.After(created) {
+		return created
+	}
+	return post.Data.ChangedAt.UTC()
+}
+
+// newestPostTime returns the creation time of the newest post, or zero without posts
+func newestPostTime(posts []RedditPost) time.Time {
+	var newest time.Time
+	for _, post := range posts {
+		if t := postTime(post); t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// feedDigest identifies the set of items in a feed, ignoring scores that change every run
+func feedDigest(posts []RedditPost) string {
+	h := sha256.New()
+	for _, post := range posts {
+		h.Write([]byte(post.Data.Name))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// feedUpdated returns the updated time of a feed with the given items, so that feeds
+// without new items keep their updated time and readers can tell nothing changed.
+// A zero time means the time of rendering.
+func (p *Pipeline) feedUpdated(posts []RedditPost, opts RunOptions) time.Time {
+	mode := opts.Updated
+	if mode == "" {
+		mode = p.config.FeedUpdated
+	}
+
+	switch mode {
+	case UpdatedNow:
+		return time.Time{}
+	case UpdatedContentChange:
+		return p.lastContentChange(posts, opts)
+	default:
+		return newestPostTime(posts)
+	}
+}
+
+// lastContentChange returns when the set of items in the feed last changed
+func (p *Pipeline) lastContentChange(posts []RedditPost, opts RunOptions) time.Time {
+	now := p.now()
+	if p.db == nil {
+		return now
+	}
+
+	key := runKey(opts)
+	digest := feedDigest(posts)
+	state, err := p.db.GetFeedState(key)
+	if err != nil {
+		slog.Warn("Failed to load feed state", "error", err)
+		return now
+	}
+	if state != nil && state.Digest == digest {
+		return state.ChangedAt
+	}
+
+	if err := p.db.SaveFeedState(FeedState{Key: key, Digest: digest, ChangedAt: now}); err != nil {
+		slog.Warn("Failed to save feed state", "error", err)
+	}
+	return now
+}
+
+// FeedState records when the items of a feed last changed
+type FeedState struct {
+	Key       string
+	Digest    string // feedDigest of the items
+	ChangedAt time.Time
+}
+
+// GetFeedState returns the state of a feed, or nil if it wasn't generated before
+func (ogDB *OpenGraphDB) GetFeedState(key string) (*FeedState, error) {
+	ogDB.mu.RLock()
+	defer ogDB.mu.RUnlock()
+
+	state := &FeedState{Key: key}
+	var changedAt int64
+	err := ogDB.db.QueryRow(`SELECT digest, changed_at FROM feed_state WHERE key = ?`, key).Scan(&state.Digest, &changedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feed state: %w", err)
+	}
+	state.ChangedAt = time.Unix(changedAt, 0)
+	return state, nil
+}
+
+// SaveFeedState stores the state of a feed
+func (ogDB *OpenGraphDB) SaveFeedState(state FeedState) error {
+	ogDB.mu.Lock()
+	defer ogDB.mu.Unlock()
+
+	_, err := ogDB.db.Exec(`INSERT OR REPLACE INTO feed_state (key, digest, changed_at) VALUES (?, ?, ?)`,
+		state.Key, state.Digest, state.ChangedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save feed state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedUpdatedNewestItem(t *testing.T) {
+	p := &Pipeline{config: &Config{}}
+
+	older := seenPost("t3_a", 10)
+	older.Data.CreatedUTC = 1000
+	newer := seenPost("t3_b", 10)
+	newer.Data.CreatedUTC = 2000
+
+	got := p.feedUpdated([]RedditPost{newer, older}, DefaultRunOptions())
+	if !got.Equal(time.Unix(2000, 0)) || got.Location() != time.UTC {
+		t.Errorf("expected the newest item's time in UTC, got %v", got)
+	}
+
+	p.config.FeedUpdated = UpdatedNow
+	if got := p.feedUpdated([]RedditPost{newer}, DefaultRunOptions()); !got.IsZero() {
+		t.Errorf("expected the time of rendering, got %v", got)
+	}
+}
+
+func TestFeedUpdatedContentChange(t *testing.T) {
+	p := &Pipeline{config: &Config{FeedUpdated: UpdatedContentChange}, db: newTestDB(t)}
+	opts := DefaultRunOptions()
+
+	posts := []RedditPost{seenPost("t3_a", 10), seenPost("t3_b", 20)}
+	first := p.feedUpdated(posts, opts)
+
+	// Pretend the first run happened a while ago
+	if err := p.db.SaveFeedState(FeedState{Key: runKey(opts), Digest: feedDigest(posts), ChangedAt: first.Add(-time.Hour)}); err != nil {
+		t.Fatalf("SaveFeedState failed: %v", err)
+	}
+
+	// Score changes alone don't count
+	rescored := []RedditPost{seenPost("t3_a", 500), seenPost("t3_b", 900)}
+	if got := p.feedUpdated(rescored, opts); !got.Equal(first.Add(-time.Hour).Truncate(time.Second)) {
+		t.Errorf("expected the updated time to stay, got %v", got)
+	}
+
+	changed := append(rescored, seenPost("t3_c", 5))
+	if got := p.feedUpdated(changed, opts); time.Since(got) > time.Minute {
+		t.Errorf("expected a new item to move the updated time to now, got %v", got)
+	}
+}
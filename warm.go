@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// WarmResult counts what warming the OpenGraph cache did with each URL
+type WarmResult struct {
+	Cached  int // Already in the cache
+	Fetched int // Fetched and cached
+	Skipped int // Reddit or blocked links, which aren't fetched
+	Failed  int // No OpenGraph data could be fetched
+}
+
+// runCacheWarm implements `cache warm`, pre-fetching OpenGraph data of the URLs listed
+// in a file, or standard input for "-"
+func runCacheWarm(args []string) error {
+	fs := flag.NewFlagSet("cache warm", flag.ExitOnError)
+	configPath := fs.String("config-file", DefaultConfigPath, "path to the configuration file")
+	concurrency := fs.Int("concurrency", DefaultMaxConcurrent, "maximum number of fetches at once")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *concurrency < 1 {
+		return fmt.Errorf("usage: red-rss cache warm [-concurrency n] <urls.txt | ->")
+	}
+
+	config := DefaultConfig()
+	if err := readConfigFile(*configPath, &config); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	in := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	urls, err := readURLList(in)
+	if err != nil {
+		return fmt.Errorf("error reading URL list: %w", err)
+	}
+
+	db, err := OpenCacheDB(&config, CacheDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ogf := NewOpenGraphFetcher(db)
+	ogf.SetDomains(config.redditDomains(), config.blockedDomains())
+	ogf.SetHostDelay(config.hostDelay())
+	ogf.scheduler = NewWorkScheduler(*concurrency, DefaultMaxPerHost)
+
+	result := warmCache(context.Background(), ogf, urls)
+	fmt.Printf("%d URLs: %d fetched, %d already cached, %d skipped, %d failed\n",
+		len(urls), result.Fetched, result.Cached, result.Skipped, result.Failed)
+	return nil
+}
+
+// readURLList reads one URL per line, skipping blank lines, # comments and duplicates
+func readURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// warmCache fetches and caches the OpenGraph data of the URLs that aren't cached yet,
+// within the limits of the fetcher's scheduler
+func warmCache(ctx context.Context, ogf *OpenGraphFetcher, urls []string) WarmResult {
+	var result WarmResult
+	var pending []string
+	for _, u := range urls {
+		switch {
+		case ogf.reddit.Matches(u) || ogf.blocked.Matches(u):
+			result.Skipped++
+		case ogf.db != nil && cachedOpenGraph(ogf.db, u):
+			result.Cached++
+		default:
+			pending = append(pending, u)
+		}
+	}
+
+	var mu sync.Mutex
+	tasks := ogf.OpenGraphTasks(pending, func(string, *OpenGraphData) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Fetched++
+	})
+	ogf.scheduler.RunBatch(ctx, tasks)
+	result.Failed = len(pending) - result.Fetched
+	return result
+}
+
+// cachedOpenGraph reports whether the cache has valid OpenGraph data of a URL
+func cachedOpenGraph(db *OpenGraphDB, url string) bool {
+	cached, _ := db.GetCachedOpenGraph(url)
+	return cached != nil
+}
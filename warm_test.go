@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadURLList(t *testing.T) {
+	urls, err := readURLList(strings.NewReader("# feeds\nhttps://a.example/1\n\n  https://b.example/2  \nhttps://a.example/1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://a.example/1", "https://b.example/2"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("urls = %q, want %q", urls, want)
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><meta property="og:title" content="Page %s"></head></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	db := newTestDB(t)
+	ogf := NewOpenGraphFetcher(db)
+	ogf.SetDomains(DefaultRedditDomains, DefaultBlockedDomains)
+	ogf.scheduler = NewWorkScheduler(2, 2)
+
+	urls := []string{server.URL + "/a", server.URL + "/b", server.URL + "/missing", "https://x.com/post"}
+	got := warmCache(context.Background(), ogf, urls)
+	if want := (WarmResult{Fetched: 2, Skipped: 1, Failed: 1}); got != want {
+		t.Errorf("first warm = %+v, want %+v", got, want)
+	}
+	if cached, _ := db.GetCachedOpenGraph(server.URL + "/a"); cached == nil || cached.Title != "Page /a" {
+		t.Errorf("cached data = %+v", cached)
+	}
+
+	got = warmCache(context.Background(), ogf, urls)
+	if want := (WarmResult{Cached: 2, Skipped: 1, Failed: 1}); got != want {
+		t.Errorf("second warm = %+v, want %+v", got, want)
+	}
+}